@@ -0,0 +1,82 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterPgHostCredentialSpec defines the desired state of
+// ClusterPgHostCredential
+type ClusterPgHostCredentialSpec struct {
+	PgHostCredentialSpec `json:",inline"`
+
+	// Namespace is the namespace the secrets and config maps referenced by
+	// Host, User and Password are read from. Tenants of the allowed
+	// namespaces need no access to it.
+	Namespace string `json:"namespace"`
+
+	// AllowedNamespaces selects the namespaces whose custom resources may use
+	// the credential.
+	AllowedNamespaces AllowedNamespaces `json:"allowedNamespaces"`
+}
+
+// AllowedNamespaces selects namespaces by name or by label. A namespace is
+// allowed if it is listed in Names or matched by Selector.
+type AllowedNamespaces struct {
+	// Names lists the allowed namespaces.
+	// +optional
+	// +listType=set
+	Names []string `json:"names,omitempty"`
+	// Selector selects the allowed namespaces by label.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Version",type="integer",JSONPath=".status.host.serverVersionNum"
+// +kubebuilder:printcolumn:name="Superuser",type="boolean",JSONPath=".status.host.superuser"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="PhaseUpdated",type="string",JSONPath=".status.phaseUpdated"
+// +kubebuilder:printcolumn:name="Error",type="string",JSONPath=".status.error"
+
+// ClusterPgHostCredential is the Schema for the clusterpghostcredentials
+// API. Custom resources in the allowed namespaces reference it by name like a
+// PgHostCredential. A PgHostCredential with the same name in their namespace
+// takes precedence.
+type ClusterPgHostCredential struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterPgHostCredentialSpec `json:"spec,omitempty"`
+	Status PgHostCredentialStatus      `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterPgHostCredentialList contains a list of ClusterPgHostCredential
+type ClusterPgHostCredentialList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterPgHostCredential `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterPgHostCredential{}, &ClusterPgHostCredentialList{})
+}
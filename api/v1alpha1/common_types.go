@@ -46,8 +46,39 @@ type Status struct {
 	PhaseUpdated metav1.Time `json:"phaseUpdated"`
 	Phase        Phase       `json:"phase"`
 	Error        string      `json:"error,omitempty"`
+
+	// Plan lists the statements the last reconciliation would have executed
+	// if it had not been a dry run. Passwords are redacted.
+	// +optional
+	// +listType=atomic
+	Plan []string `json:"plan,omitempty"`
 }
 
+// NameClaim records that a custom resource owns a database or role on a
+// host. Custom resources cannot claim names owned by others.
+type NameClaim struct {
+	// HostCredential is the name of the host credential.
+	HostCredential string `json:"hostCredential"`
+	// Host is the address of the host.
+	Host string `json:"host"`
+	// Name is the name of the database or role on the host.
+	Name string `json:"name"`
+}
+
+// DriftPolicy tells what the operator does when the live state of a managed
+// object differs from its specification.
+// +kubebuilder:validation:Enum=Repair;Report
+type DriftPolicy string
+
+const (
+	// DriftPolicyRepair applies the specification again on every resync.
+	DriftPolicyRepair DriftPolicy = "Repair"
+	// DriftPolicyReport only compares the live state with the specification
+	// on resyncs and lists the differences in the Drifted condition.
+	// Changes of the specification are still applied.
+	DriftPolicyReport DriftPolicy = "Report"
+)
+
 // Phase represents the current phase of the object.
 type Phase string
 
@@ -65,3 +96,42 @@ const (
 	// reconcile the object and will retry.
 	PhaseFailed Phase = "Failed"
 )
+
+const (
+	// AnnotationRequestedBy records the user who last changed the
+	// specification of a PgUser. It is set by the admission webhook, so that
+	// the user cannot approve their own access specifications.
+	AnnotationRequestedBy = "postgres.jeewangue.com/requested-by"
+
+	// AnnotationDryRun set to "true" makes the operator record the
+	// statements the reconciliation of an object would execute in its
+	// status instead of executing them.
+	AnnotationDryRun = "postgres.jeewangue.com/dry-run"
+
+	// LabelBackupSchedule is set on the PgBackups created by a
+	// PgBackupSchedule to its name.
+	LabelBackupSchedule = "postgres.jeewangue.com/backup-schedule"
+
+	// ConditionPending tells whether part of the specification of an object
+	// waits for something before it is reconciled, e.g. the access
+	// specifications of a PgUser awaiting approval.
+	ConditionPending = "Pending"
+
+	// ConditionQuotaExceeded tells whether the usage of a PgQuota exceeds one
+	// of its limits, e.g. because the limit was lowered or the databases
+	// grew.
+	ConditionQuotaExceeded = "QuotaExceeded"
+
+	// ConditionConflict tells whether the database or role of a custom
+	// resource is owned by another custom resource or was not created by the
+	// operator.
+	ConditionConflict = "Conflict"
+
+	// ConditionDrifted tells whether the live state of an object with the
+	// Report drift policy differs from its specification.
+	ConditionDrifted = "Drifted"
+
+	// ConditionRestored tells whether the source of a PgDatabase was applied
+	// when the database was created.
+	ConditionRestored = "Restored"
+)
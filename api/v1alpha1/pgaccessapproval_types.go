@@ -0,0 +1,79 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PgAccessApprovalSpec defines the desired state of PgAccessApproval
+type PgAccessApprovalSpec struct {
+	// User is the name of the PgUser in the same namespace whose access
+	// specification is approved.
+	User string `json:"user"`
+	// HostCredential, Database, Permission and Reason identify the approved
+	// access specification of the user.
+	HostCredential string `json:"hostCredential"`
+	Database       string `json:"database"`
+	Permission     Perm   `json:"permission"`
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// AccessHash is the hash of the whole approved access specification,
+	// including its schema, privileges and expiry. It is set by the
+	// admission webhook and cannot be changed, so that a changed access
+	// specification needs a new approval.
+	// +optional
+	AccessHash string `json:"accessHash,omitempty"`
+
+	// ApprovedBy is the user who created the approval. It is set by the
+	// admission webhook and cannot be changed.
+	// +optional
+	ApprovedBy string `json:"approvedBy,omitempty"`
+	// ApprovedAt is the time the approval was created. It is set by the
+	// admission webhook and cannot be changed.
+	// +optional
+	ApprovedAt *metav1.Time `json:"approvedAt,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="User",type="string",JSONPath=".spec.user"
+// +kubebuilder:printcolumn:name="Permission",type="string",JSONPath=".spec.permission"
+// +kubebuilder:printcolumn:name="ApprovedBy",type="string",JSONPath=".spec.approvedBy"
+// +kubebuilder:printcolumn:name="ApprovedAt",type="string",JSONPath=".spec.approvedAt"
+
+// PgAccessApproval is the Schema for the pgaccessapprovals API. It signs off
+// an access specification of a PgUser on a host credential which requires
+// approval for its permission.
+type PgAccessApproval struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PgAccessApprovalSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PgAccessApprovalList contains a list of PgAccessApproval
+type PgAccessApprovalList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PgAccessApproval `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PgAccessApproval{}, &PgAccessApprovalList{})
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PgBackupSpec defines the desired state of PgBackup
+type PgBackupSpec struct {
+	// Database is the name of the PgDatabase to back up.
+	Database string `json:"database"`
+
+	// Storage is where the backup is written to.
+	Storage BackupStorage `json:"storage"`
+
+	// DeletionPolicy tells whether the backup file is deleted together with
+	// the PgBackup. Defaults to Delete.
+	// +optional
+	// +kubebuilder:default=Delete
+	DeletionPolicy BackupDeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// BackupStorage is where backups are written to. Exactly one of its fields
+// must be set.
+type BackupStorage struct {
+	// PersistentVolumeClaim writes the backup to a volume.
+	// +optional
+	PersistentVolumeClaim *PVCBackupStorage `json:"persistentVolumeClaim,omitempty"`
+	// S3 uploads the backup to a bucket of an S3-compatible object storage.
+	// +optional
+	S3 *S3BackupStorage `json:"s3,omitempty"`
+}
+
+// PVCBackupStorage writes backups to a PersistentVolumeClaim.
+type PVCBackupStorage struct {
+	// ClaimName is the name of the PersistentVolumeClaim in the namespace.
+	ClaimName string `json:"claimName"`
+	// Path is the directory in the volume the backups are written to.
+	// Defaults to the root of the volume.
+	// +optional
+	Path string `json:"path,omitempty"`
+}
+
+// S3BackupStorage uploads backups to an S3-compatible object storage.
+type S3BackupStorage struct {
+	// Bucket is the name of the bucket.
+	Bucket string `json:"bucket"`
+	// Prefix is prepended to the keys of the backups, e.g. `backups/`.
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+	// Endpoint is the URL of an S3-compatible service, e.g.
+	// `http://minio.storage:9000`. Defaults to AWS S3.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+	// Region is the region of the bucket.
+	// +optional
+	Region string `json:"region,omitempty"`
+	// CredentialsSecret is the name of a Secret in the namespace with the
+	// keys AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+	CredentialsSecret string `json:"credentialsSecret"`
+}
+
+// BackupDeletionPolicy tells what happens to the file of a backup once its
+// PgBackup is deleted.
+// +kubebuilder:validation:Enum=Delete;Retain
+type BackupDeletionPolicy string
+
+const (
+	// BackupDeletionPolicyDelete deletes the file of the backup.
+	BackupDeletionPolicyDelete BackupDeletionPolicy = "Delete"
+	// BackupDeletionPolicyRetain keeps the file of the backup.
+	BackupDeletionPolicyRetain BackupDeletionPolicy = "Retain"
+)
+
+// PgBackupStatus defines the observed state of PgBackup
+type PgBackupStatus struct {
+	Status `json:",inline"`
+
+	// JobName is the name of the Job running pg_dump.
+	// +optional
+	JobName string `json:"jobName,omitempty"`
+	// Location is the path of the backup file in the volume, or its
+	// `s3://` URL.
+	// +optional
+	Location string `json:"location,omitempty"`
+	// StartTime is the time the backup started.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// CompletionTime is the time the backup completed.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Duration is the time the backup took.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// Size is the size of the backup file.
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+	// Checksum is the SHA-256 checksum of the backup file, formatted as
+	// `sha256:<hex>`.
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Database",type="string",JSONPath=".spec.database"
+// +kubebuilder:printcolumn:name="Size",type="string",JSONPath=".status.size"
+// +kubebuilder:printcolumn:name="Completed",type="date",JSONPath=".status.completionTime"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="PhaseUpdated",type="string",JSONPath=".status.phaseUpdated"
+// +kubebuilder:printcolumn:name="Error",type="string",JSONPath=".status.error"
+
+// PgBackup is the Schema for the pgbackups API. It is a logical backup of a
+// PgDatabase taken with pg_dump in the custom format by a Job. The phase of
+// a PgBackup is Pending while the Job runs and Available once the backup
+// completed.
+type PgBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PgBackupSpec   `json:"spec,omitempty"`
+	Status PgBackupStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PgBackupList contains a list of PgBackup
+type PgBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PgBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PgBackup{}, &PgBackupList{})
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PgBackupScheduleSpec defines the desired state of PgBackupSchedule
+type PgBackupScheduleSpec struct {
+	// Schedule is the cron expression of the times backups are taken, e.g.
+	// `0 3 * * *`, in the time zone of the operator.
+	Schedule string `json:"schedule"`
+
+	// Suspend stops taking backups. Retention still applies.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// PgBackupSpec is the specification of the PgBackups created.
+	PgBackupSpec `json:",inline"`
+
+	// Retention tells which backups are deleted.
+	// +optional
+	Retention BackupRetention `json:"retention,omitempty"`
+}
+
+// BackupRetention tells which backups of a PgBackupSchedule are kept.
+type BackupRetention struct {
+	// KeepLast is the number of completed backups kept. Older backups are
+	// deleted, as are failed backups older than the latest completed one.
+	// +optional
+	// +kubebuilder:default=7
+	// +kubebuilder:validation:Minimum=1
+	KeepLast int32 `json:"keepLast,omitempty"`
+}
+
+// PgBackupScheduleStatus defines the observed state of PgBackupSchedule
+type PgBackupScheduleStatus struct {
+	Status `json:",inline"`
+
+	// LastScheduleTime is the last time a backup was scheduled.
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// NextScheduleTime is the next time a backup will be scheduled.
+	// +optional
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+	// LastBackup is the name of the PgBackup created last.
+	// +optional
+	LastBackup string `json:"lastBackup,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Database",type="string",JSONPath=".spec.database"
+// +kubebuilder:printcolumn:name="Schedule",type="string",JSONPath=".spec.schedule"
+// +kubebuilder:printcolumn:name="LastBackup",type="string",JSONPath=".status.lastBackup"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="PhaseUpdated",type="string",JSONPath=".status.phaseUpdated"
+// +kubebuilder:printcolumn:name="Error",type="string",JSONPath=".status.error"
+
+// PgBackupSchedule is the Schema for the pgbackupschedules API. It creates
+// PgBackups of a PgDatabase on a schedule and deletes the old ones.
+type PgBackupSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PgBackupScheduleSpec   `json:"spec,omitempty"`
+	Status PgBackupScheduleStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PgBackupScheduleList contains a list of PgBackupSchedule
+type PgBackupScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PgBackupSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PgBackupSchedule{}, &PgBackupScheduleList{})
+}
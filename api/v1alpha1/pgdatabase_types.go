@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -30,10 +31,105 @@ type PgDatabaseSpec struct {
 
 	HostCredential string `json:"hostCredential"`
 	Name           string `json:"name"`
+	// Adopt tells the operator to manage the database if it exists but was
+	// not created by the operator.
+	// +optional
+	Adopt bool `json:"adopt,omitempty"`
+	// DriftPolicy tells what the operator does when the resync finds that
+	// the live state of the database and its access roles differs from the specification.
+	// +optional
+	// +kubebuilder:default=Repair
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+	// Source fills the database when the operator creates it. It is not
+	// applied to a database that already exists, and changing it later has
+	// no effect. The Restored condition reports the outcome.
+	// +optional
+	Source *DatabaseSource `json:"source,omitempty"`
+	// InitSQL selects keys of config maps or secrets with SQL scripts run
+	// once in the database after the operator created it and applied its
+	// source. The scripts run in the listed order as the owner role of the
+	// database, in a single transaction. They are not run again when they
+	// change, nor for a database that existed before.
+	// +optional
+	InitSQL []ResourceVarSource `json:"initSQL,omitempty"`
+}
+
+// DatabaseSource is the initial content of a database. Exactly one of its
+// fields must be set.
+type DatabaseSource struct {
+	// Database is the name of a PgDatabase on the same host credential the
+	// database is created as a copy of. Sessions connected to the source
+	// database are terminated, since PostgreSQL cannot copy a database in
+	// use.
+	// +optional
+	Database string `json:"database,omitempty"`
+	// Backup is the name of a completed PgBackup restored into the database
+	// with pg_restore by a Job.
+	// +optional
+	Backup string `json:"backup,omitempty"`
+	// SQL selects a key of a config map with a script run in the database as
+	// its owner role in a single transaction.
+	// +optional
+	SQL *KeySelector `json:"sql,omitempty"`
+}
+
+// PgDatabaseStatus defines the observed state of PgDatabase
+type PgDatabaseStatus struct {
+	Status `json:",inline"`
+
+	// Claim records the database owned on the host.
+	// +optional
+	Claim *NameClaim `json:"claim,omitempty"`
+
+	// ObservedGeneration is the generation of the specification last
+	// reconciled successfully.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// InitSQL records the run of the init SQL scripts. It is set when the
+	// operator creates a database with init SQL scripts.
+	// +optional
+	InitSQL *InitSQLStatus `json:"initSQL,omitempty"`
+
+	// Stats is the size and the activity of the database, measured at every
+	// reconciliation.
+	// +optional
+	Stats *DatabaseStats `json:"stats,omitempty"`
+}
+
+// DatabaseStats is the size and the activity of a database.
+type DatabaseStats struct {
+	// Size is the disk space used by the database.
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+	// Connections is the number of sessions connected to the database.
+	Connections int32 `json:"connections"`
+	// XactCommit is the number of transactions committed in the database
+	// since the statistics were last reset.
+	XactCommit int64 `json:"xactCommit"`
+	// XactRollback is the number of transactions rolled back in the
+	// database since the statistics were last reset.
+	XactRollback int64 `json:"xactRollback"`
+	// CollectionTime is when the stats were measured.
+	// +optional
+	CollectionTime *metav1.Time `json:"collectionTime,omitempty"`
+}
+
+// InitSQLStatus records the run of the init SQL scripts of a database.
+type InitSQLStatus struct {
+	// Hash is the SHA-256 hash of the scripts that ran.
+	// +optional
+	Hash string `json:"hash,omitempty"`
+	// CompletionTime is when the scripts ran. They are still to be run if
+	// it is not set.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Size",type="string",JSONPath=".status.stats.size"
+// +kubebuilder:printcolumn:name="Connections",type="integer",JSONPath=".status.stats.connections"
 // +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
 // +kubebuilder:printcolumn:name="PhaseUpdated",type="string",JSONPath=".status.phaseUpdated"
 // +kubebuilder:printcolumn:name="Error",type="string",JSONPath=".status.error"
@@ -43,8 +139,8 @@ type PgDatabase struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec   PgDatabaseSpec `json:"spec,omitempty"`
-	Status Status         `json:"status,omitempty"`
+	Spec   PgDatabaseSpec   `json:"spec,omitempty"`
+	Status PgDatabaseStatus `json:"status,omitempty"`
 }
 
 //+kubebuilder:object:root=true
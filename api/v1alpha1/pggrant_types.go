@@ -0,0 +1,134 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PgGrantSpec defines the desired state of PgGrant
+type PgGrantSpec struct {
+	// Database is the name of the PgDatabase containing the objects.
+	Database string `json:"database"`
+
+	// Grantee is the PgUser or PgRole receiving the privileges.
+	Grantee GranteeRef `json:"grantee"`
+
+	// ObjectType is the type of the objects the privileges are granted on.
+	ObjectType GrantObjectType `json:"objectType"`
+
+	// Schema is the schema containing the objects. If ObjectType is
+	// `schema`, it is the schema the privileges are granted on.
+	// +optional
+	// +kubebuilder:default=public
+	Schema string `json:"schema,omitempty"`
+
+	// Objects is the list of table or sequence names, or function signatures
+	// (e.g., `refresh_stats(integer)`), in the schema. It is ignored if
+	// ObjectType is `schema`.
+	// +optional
+	// +listType=set
+	Objects []string `json:"objects,omitempty"`
+
+	// Columns restricts the privileges on tables to the listed columns.
+	// Only SELECT, INSERT, UPDATE and REFERENCES can be granted on columns.
+	// +optional
+	// +listType=set
+	Columns []string `json:"columns,omitempty"`
+
+	// Privileges is the list of privileges to grant.
+	// +kubebuilder:validation:MinItems=1
+	// +listType=set
+	Privileges []Privilege `json:"privileges"`
+}
+
+// GranteeRef references the PgUser or PgRole receiving privileges.
+type GranteeRef struct {
+	// Kind is either PgUser or PgRole.
+	// +kubebuilder:validation:Enum=PgUser;PgRole
+	Kind string `json:"kind"`
+	// Name is the name of the PgUser or PgRole in the same namespace.
+	Name string `json:"name"`
+}
+
+// GrantObjectType is the type of object privileges are granted on.
+// +kubebuilder:validation:Enum=table;sequence;function;schema
+type GrantObjectType string
+
+const (
+	GrantObjectTable    GrantObjectType = "table"
+	GrantObjectSequence GrantObjectType = "sequence"
+	GrantObjectFunction GrantObjectType = "function"
+	GrantObjectSchema   GrantObjectType = "schema"
+)
+
+// Privilege is a Postgres object privilege.
+// +kubebuilder:validation:Enum=SELECT;INSERT;UPDATE;DELETE;TRUNCATE;REFERENCES;TRIGGER;USAGE;EXECUTE
+type Privilege string
+
+// PgGrantStatus defines the observed state of PgGrant
+type PgGrantStatus struct {
+	Status `json:",inline"`
+
+	// Applied lists the privileges granted by the last successful
+	// reconciliation. Entries that are no longer desired are revoked.
+	// +optional
+	// +listType=atomic
+	Applied []AppliedGrant `json:"applied,omitempty"`
+}
+
+// AppliedGrant records privileges granted on a single object or column.
+type AppliedGrant struct {
+	HostCredential string          `json:"hostCredential"`
+	Database       string          `json:"database"`
+	Role           string          `json:"role"`
+	ObjectType     GrantObjectType `json:"objectType"`
+	Schema         string          `json:"schema"`
+	// +optional
+	Object string `json:"object,omitempty"`
+	// +optional
+	Column     string      `json:"column,omitempty"`
+	Privileges []Privilege `json:"privileges"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="PhaseUpdated",type="string",JSONPath=".status.phaseUpdated"
+// +kubebuilder:printcolumn:name="Error",type="string",JSONPath=".status.error"
+
+// PgGrant is the Schema for the pggrants API
+type PgGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PgGrantSpec   `json:"spec,omitempty"`
+	Status PgGrantStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PgGrantList contains a list of PgGrant
+type PgGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PgGrant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PgGrant{}, &PgGrantList{})
+}
@@ -39,10 +39,92 @@ type PgHostCredentialSpec struct {
 	// Params is the space-separated list of parameters (e.g.,
 	// `"sslmode=require"`)
 	Params string `json:"params,omitempty"`
+	// ApprovalRequired lists the permissions which access specifications of
+	// PgUsers on this host are only granted with once a PgAccessApproval
+	// signs them off.
+	// +optional
+	// +listType=set
+	ApprovalRequired []Perm `json:"approvalRequired,omitempty"`
+	// NamingPolicy maps the names of databases and roles requested by custom
+	// resources to their names on the host. Names are used as they are if
+	// omitted.
+	// +optional
+	NamingPolicy *NamingPolicy `json:"namingPolicy,omitempty"`
+}
+
+// NamingPolicy defines how the names of databases and roles on a host are
+// derived from the names requested by custom resources.
+type NamingPolicy struct {
+	// Template is a Go template for the names with the fields .Namespace and
+	// .Name (e.g., `{{ .Namespace }}_{{ .Name }}`). Characters which are not
+	// allowed in unquoted identifiers are replaced with underscores.
+	// +optional
+	// +kubebuilder:default="{{ .Name }}"
+	Template string `json:"template,omitempty"`
+	// Reserved lists names which cannot be used in addition to `postgres`,
+	// `template0`, `template1`, `public`, the admin user and names starting
+	// with `pg_`.
+	// +optional
+	// +listType=set
+	Reserved []string `json:"reserved,omitempty"`
+	// MaxLength is the maximum length of role names. Longer names are
+	// shortened and suffixed with a hash. Database names are limited to
+	// MaxLength minus the length of the `_readwrite` suffix of their access
+	// roles.
+	// +optional
+	// +kubebuilder:default=63
+	// +kubebuilder:validation:Minimum=20
+	// +kubebuilder:validation:Maximum=63
+	MaxLength int32 `json:"maxLength,omitempty"`
+}
+
+// PgHostCredentialStatus defines the observed state of PgHostCredential
+type PgHostCredentialStatus struct {
+	Status `json:",inline"`
+
+	// Host records the capabilities of the host and of the admin user,
+	// checked at every reconciliation.
+	// +optional
+	Host *HostStatus `json:"host,omitempty"`
+}
+
+// HostStatus describes the capabilities of a host and of its admin user.
+type HostStatus struct {
+	// ServerVersionNum is the version of the server (e.g., `150004` for
+	// 15.4).
+	ServerVersionNum int32 `json:"serverVersionNum"`
+	// Superuser tells whether the admin user is a superuser.
+	Superuser bool `json:"superuser"`
+	// Replication tells whether the admin user has the REPLICATION
+	// attribute.
+	// +optional
+	Replication bool `json:"replication,omitempty"`
+	// BypassRLS tells whether the admin user has the BYPASSRLS attribute.
+	// +optional
+	BypassRLS bool `json:"bypassRLS,omitempty"`
+	// ManagedAdminRole is the role of a managed service the admin user is a
+	// member of instead of being a superuser, i.e. `rds_superuser` on Amazon
+	// RDS or `cloudsqlsuperuser` on Cloud SQL.
+	// +optional
+	ManagedAdminRole string `json:"managedAdminRole,omitempty"`
+	// MaxConnections is the maximum number of sessions of the server.
+	MaxConnections int32 `json:"maxConnections"`
+	// SCRAM tells whether passwords are encrypted with SCRAM-SHA-256 rather
+	// than MD5.
+	SCRAM bool `json:"scram"`
+	// Extensions lists the extensions available for installation.
+	// +optional
+	// +listType=set
+	Extensions []string `json:"extensions,omitempty"`
+	// CheckTime is when the capabilities were checked.
+	// +optional
+	CheckTime *metav1.Time `json:"checkTime,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Version",type="integer",JSONPath=".status.host.serverVersionNum"
+// +kubebuilder:printcolumn:name="Superuser",type="boolean",JSONPath=".status.host.superuser"
 // +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
 // +kubebuilder:printcolumn:name="PhaseUpdated",type="string",JSONPath=".status.phaseUpdated"
 // +kubebuilder:printcolumn:name="Error",type="string",JSONPath=".status.error"
@@ -52,8 +134,8 @@ type PgHostCredential struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec   PgHostCredentialSpec `json:"spec,omitempty"`
-	Status Status               `json:"status,omitempty"`
+	Spec   PgHostCredentialSpec   `json:"spec,omitempty"`
+	Status PgHostCredentialStatus `json:"status,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -0,0 +1,153 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PgMaintenanceSpec defines the desired state of PgMaintenance
+type PgMaintenanceSpec struct {
+	// Database is the name of the PgDatabase the tasks run in.
+	Database string `json:"database"`
+
+	// Schedule is the cron expression of the times the tasks run, e.g.
+	// `0 4 * * 0`, in the time zone of the operator.
+	Schedule string `json:"schedule"`
+
+	// Suspend stops running the tasks.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// Tasks are run one after the other in the listed order. A failed task
+	// does not stop the following ones.
+	// +kubebuilder:validation:MinItems=1
+	Tasks []MaintenanceTask `json:"tasks"`
+}
+
+// MaintenanceTask is a maintenance statement run in a database.
+type MaintenanceTask struct {
+	// Name identifies the task in the status and the metrics.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// Type is the statement run by the task.
+	Type MaintenanceTaskType `json:"type"`
+
+	// Target is the relation the statement applies to, qualified with its
+	// schema or in the schema public: a table for Vacuum and Analyze, an
+	// index or a table whose indexes are rebuilt for Reindex, and a
+	// materialized view for RefreshMaterializedView. Vacuum, Analyze and
+	// Reindex apply to the whole database if it is empty.
+	// +optional
+	Target string `json:"target,omitempty"`
+
+	// Concurrently rebuilds indexes and refreshes materialized views without
+	// locking out writes, respectively reads.
+	// +optional
+	Concurrently bool `json:"concurrently,omitempty"`
+
+	// Timeout cancels the statement if it runs longer. Defaults to one hour.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// MaintenanceTaskType is the statement run by a maintenance task.
+// +kubebuilder:validation:Enum=Vacuum;Analyze;Reindex;RefreshMaterializedView
+type MaintenanceTaskType string
+
+const (
+	MaintenanceVacuum                  MaintenanceTaskType = "Vacuum"
+	MaintenanceAnalyze                 MaintenanceTaskType = "Analyze"
+	MaintenanceReindex                 MaintenanceTaskType = "Reindex"
+	MaintenanceRefreshMaterializedView MaintenanceTaskType = "RefreshMaterializedView"
+)
+
+// MaintenanceResult is the outcome of the last run of a maintenance task.
+type MaintenanceResult string
+
+const (
+	MaintenanceSucceeded MaintenanceResult = "Succeeded"
+	MaintenanceFailed    MaintenanceResult = "Failed"
+	MaintenanceTimedOut  MaintenanceResult = "TimedOut"
+)
+
+// MaintenanceTaskStatus is the last run of a maintenance task.
+type MaintenanceTaskStatus struct {
+	// Name is the name of the task.
+	Name string `json:"name"`
+	// LastRunTime is when the task last started.
+	// +optional
+	LastRunTime *metav1.Time `json:"lastRunTime,omitempty"`
+	// Duration is how long the last run took.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// Result is the outcome of the last run.
+	// +optional
+	Result MaintenanceResult `json:"result,omitempty"`
+	// Message is the error of the last run if it did not succeed.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// PgMaintenanceStatus defines the observed state of PgMaintenance
+type PgMaintenanceStatus struct {
+	Status `json:",inline"`
+
+	// LastScheduleTime is the last time the tasks were scheduled.
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// NextScheduleTime is the next time the tasks will be scheduled.
+	// +optional
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+	// Tasks are the last runs of the tasks.
+	// +optional
+	Tasks []MaintenanceTaskStatus `json:"tasks,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Database",type="string",JSONPath=".spec.database"
+// +kubebuilder:printcolumn:name="Schedule",type="string",JSONPath=".spec.schedule"
+// +kubebuilder:printcolumn:name="LastSchedule",type="date",JSONPath=".status.lastScheduleTime"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="PhaseUpdated",type="string",JSONPath=".status.phaseUpdated"
+// +kubebuilder:printcolumn:name="Error",type="string",JSONPath=".status.error"
+
+// PgMaintenance is the Schema for the pgmaintenances API. It runs
+// maintenance statements like VACUUM or REINDEX in a PgDatabase on a
+// schedule.
+type PgMaintenance struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PgMaintenanceSpec   `json:"spec,omitempty"`
+	Status PgMaintenanceStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PgMaintenanceList contains a list of PgMaintenance
+type PgMaintenanceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PgMaintenance `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PgMaintenance{}, &PgMaintenanceList{})
+}
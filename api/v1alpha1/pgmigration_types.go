@@ -0,0 +1,88 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PgMigrationSpec defines the desired state of PgMigration
+type PgMigrationSpec struct {
+	// Database is the name of the PgDatabase the migrations are applied to.
+	Database string `json:"database"`
+
+	// ConfigMaps are the names of the config maps in the namespace holding
+	// the migration scripts. Every key ending in .sql is a migration whose
+	// version is the key without the suffix, e.g. 0001_create_items.sql.
+	// Migrations are applied in the lexical order of their versions across
+	// all config maps.
+	// +kubebuilder:validation:MinItems=1
+	ConfigMaps []string `json:"configMaps"`
+}
+
+// PgMigrationStatus defines the observed state of PgMigration
+type PgMigrationStatus struct {
+	Status `json:",inline"`
+
+	// Version is the version of the last applied migration.
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Applied is the number of migrations applied to the database.
+	// +optional
+	Applied int32 `json:"applied,omitempty"`
+
+	// Pending lists the versions of the migrations not applied yet.
+	// +optional
+	Pending []string `json:"pending,omitempty"`
+
+	// LastAppliedTime is when the operator last applied a migration.
+	// +optional
+	LastAppliedTime *metav1.Time `json:"lastAppliedTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Database",type="string",JSONPath=".spec.database"
+// +kubebuilder:printcolumn:name="Version",type="string",JSONPath=".status.version"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="PhaseUpdated",type="string",JSONPath=".status.phaseUpdated"
+// +kubebuilder:printcolumn:name="Error",type="string",JSONPath=".status.error"
+
+// PgMigration is the Schema for the pgmigrations API. It applies versioned
+// SQL scripts to a PgDatabase, each in a transaction as the owner role of the
+// database, and records them in a version table of the database.
+type PgMigration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PgMigrationSpec   `json:"spec,omitempty"`
+	Status PgMigrationStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PgMigrationList contains a list of PgMigration
+type PgMigrationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PgMigration `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PgMigration{}, &PgMigrationList{})
+}
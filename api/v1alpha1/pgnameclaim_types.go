@@ -0,0 +1,86 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// PgNameClaimSpec defines the name of a database or role on a host taken by
+// a custom resource
+type PgNameClaimSpec struct {
+	// Type is the type of the object the name is claimed for.
+	Type NameType `json:"type"`
+	// Host is the address of the host.
+	Host string `json:"host"`
+	// Name is the name of the database or role on the host.
+	Name string `json:"name"`
+	// Owner is the custom resource which claimed the name.
+	Owner ClaimOwner `json:"owner"`
+}
+
+// NameType is the type of the object a name is claimed for.
+// +kubebuilder:validation:Enum=Database;Role
+type NameType string
+
+const (
+	NameTypeDatabase NameType = "Database"
+	NameTypeRole     NameType = "Role"
+)
+
+// ClaimOwner identifies the custom resource which claimed a name.
+type ClaimOwner struct {
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	UID       types.UID `json:"uid"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Type",type="string",JSONPath=".spec.type"
+// +kubebuilder:printcolumn:name="Host",type="string",JSONPath=".spec.host"
+// +kubebuilder:printcolumn:name="Claimed",type="string",JSONPath=".spec.name"
+// +kubebuilder:printcolumn:name="OwnerKind",type="string",JSONPath=".spec.owner.kind"
+// +kubebuilder:printcolumn:name="OwnerNamespace",type="string",JSONPath=".spec.owner.namespace"
+// +kubebuilder:printcolumn:name="OwnerName",type="string",JSONPath=".spec.owner.name"
+
+// PgNameClaim records that a database or role name on a host is taken by a
+// PgDatabase, PgRole or PgUser. The operator creates one per name and host,
+// named after both, so that the API server refuses a second claim of the
+// same name even if two custom resources are reconciled together. It is
+// deleted when the name is no longer used by its owner.
+type PgNameClaim struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PgNameClaimSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PgNameClaimList contains a list of PgNameClaim
+type PgNameClaimList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PgNameClaim `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PgNameClaim{}, &PgNameClaimList{})
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PgPublicationSpec defines the desired state of PgPublication
+type PgPublicationSpec struct {
+	// Database is the name of the PgDatabase the publication is created in.
+	Database string `json:"database"`
+
+	// Name is the name of the publication in the database.
+	// +kubebuilder:validation:MaxLength=63
+	Name string `json:"name"`
+
+	// AllTables publishes all tables of the database, including the ones
+	// created in the future. Tables must be empty if it is set. Creating a
+	// publication for all tables requires a superuser.
+	// +optional
+	AllTables bool `json:"allTables,omitempty"`
+
+	// Tables is the list of published tables. Table names can be qualified
+	// with their schema and default to the schema `public`.
+	// +optional
+	// +listType=set
+	Tables []string `json:"tables,omitempty"`
+
+	// Publish is the list of operations replicated to the subscribers. All
+	// operations are published if it is empty.
+	// +optional
+	// +listType=set
+	Publish []PublishOperation `json:"publish,omitempty"`
+}
+
+// PublishOperation is a data modification replicated by a publication.
+// +kubebuilder:validation:Enum=insert;update;delete;truncate
+type PublishOperation string
+
+const (
+	PublishInsert   PublishOperation = "insert"
+	PublishUpdate   PublishOperation = "update"
+	PublishDelete   PublishOperation = "delete"
+	PublishTruncate PublishOperation = "truncate"
+)
+
+// PgPublicationStatus defines the observed state of PgPublication
+type PgPublicationStatus struct {
+	Status `json:",inline"`
+
+	// HostCredential, Database and Name identify the publication created by the
+	// last successful reconciliation, so that it can be dropped once it is
+	// renamed or moved, or once the PgPublication is deleted.
+	// +optional
+	HostCredential string `json:"hostCredential,omitempty"`
+	// +optional
+	Database string `json:"database,omitempty"`
+	// +optional
+	Name string `json:"name,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Database",type="string",JSONPath=".spec.database"
+// +kubebuilder:printcolumn:name="Publication",type="string",JSONPath=".spec.name"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="PhaseUpdated",type="string",JSONPath=".status.phaseUpdated"
+// +kubebuilder:printcolumn:name="Error",type="string",JSONPath=".status.error"
+
+// PgPublication is the Schema for the pgpublications API. It publishes the
+// changes of tables of a PgDatabase for logical replication.
+type PgPublication struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PgPublicationSpec   `json:"spec,omitempty"`
+	Status PgPublicationStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PgPublicationList contains a list of PgPublication
+type PgPublicationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PgPublication `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PgPublication{}, &PgPublicationList{})
+}
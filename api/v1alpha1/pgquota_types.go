@@ -0,0 +1,99 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PgQuotaSpec defines the desired state of PgQuota
+type PgQuotaSpec struct {
+	// HostCredential is the name of the PgHostCredential or
+	// ClusterPgHostCredential the limits apply to.
+	HostCredential string `json:"hostCredential"`
+
+	// MaxDatabases is the number of PgDatabases the namespace may have on
+	// the host.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxDatabases *int32 `json:"maxDatabases,omitempty"`
+	// MaxUsers is the number of PgUsers the namespace may have on the host.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxUsers *int32 `json:"maxUsers,omitempty"`
+	// MaxConnections is the total connection limit of the PgUsers of the
+	// namespace on the host. If set, the PgUsers must set a connection
+	// limit.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxConnections *int32 `json:"maxConnections,omitempty"`
+	// MaxSize is the total size of the PgDatabases of the namespace on the
+	// host. Once exceeded, no more PgDatabases can be created.
+	// +optional
+	MaxSize *resource.Quantity `json:"maxSize,omitempty"`
+}
+
+// PgQuotaUsage is the usage of a namespace on a host.
+type PgQuotaUsage struct {
+	Databases   int32 `json:"databases"`
+	Users       int32 `json:"users"`
+	Connections int32 `json:"connections"`
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+}
+
+// PgQuotaStatus defines the observed state of PgQuota
+type PgQuotaStatus struct {
+	Status `json:",inline"`
+
+	// Used is the current usage of the namespace on the host.
+	// +optional
+	Used PgQuotaUsage `json:"used,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="HostCredential",type="string",JSONPath=".spec.hostCredential"
+// +kubebuilder:printcolumn:name="Databases",type="integer",JSONPath=".status.used.databases"
+// +kubebuilder:printcolumn:name="Users",type="integer",JSONPath=".status.used.users"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="PhaseUpdated",type="string",JSONPath=".status.phaseUpdated"
+// +kubebuilder:printcolumn:name="Error",type="string",JSONPath=".status.error"
+
+// PgQuota is the Schema for the pgquotas API. It limits the usage of a host
+// by the custom resources in its namespace.
+type PgQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PgQuotaSpec   `json:"spec,omitempty"`
+	Status PgQuotaStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PgQuotaList contains a list of PgQuota
+type PgQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PgQuota `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PgQuota{}, &PgQuotaList{})
+}
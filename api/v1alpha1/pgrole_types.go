@@ -0,0 +1,119 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PgRoleSpec defines the desired state of PgRole
+type PgRoleSpec struct {
+	// HostCredential is the name of the PgHostCredential
+	HostCredential string `json:"hostCredential"`
+
+	// Name is the name of the NOLOGIN role on the Postgres instance.
+	Name string `json:"name"`
+
+	// Adopt tells the operator to manage the role if it exists but was not
+	// created by the operator.
+	// +optional
+	Adopt bool `json:"adopt,omitempty"`
+
+	// Privileges defines the access rights of the role to databases on the
+	// host.
+	// +optional
+	// +listType=atomic
+	Privileges []RolePrivilege `json:"privileges,omitempty"`
+
+	// MemberOf is the list of PgRole names in the same namespace which this
+	// role is a member of. The referenced roles must live on the same host.
+	// +optional
+	// +listType=set
+	MemberOf []string `json:"memberOf,omitempty"`
+}
+
+// RolePrivilege defines an access right of a role to a database.
+type RolePrivilege struct {
+	// Database is the name of the PgDatabase
+	Database string `json:"database"`
+	// Permission defines the access right to the database
+	Permission Perm `json:"permission"`
+	// Privileges is the list of table privileges granted if Permission is
+	// `custom`.
+	// +optional
+	// +listType=set
+	Privileges []Privilege `json:"privileges,omitempty"`
+}
+
+// PgRoleStatus defines the observed state of PgRole
+type PgRoleStatus struct {
+	Status `json:",inline"`
+
+	// Claim records the role owned on the host.
+	// +optional
+	Claim *NameClaim `json:"claim,omitempty"`
+
+	// MemberOf records the roles on the host the role was made a member of,
+	// so that memberships removed from the specification are revoked.
+	// +optional
+	// +listType=set
+	MemberOf []string `json:"memberOf,omitempty"`
+
+	// Privileges records the privileges granted to the role, so that
+	// privileges removed from the specification are revoked.
+	// +optional
+	// +listType=atomic
+	Privileges []AppliedRolePrivilege `json:"privileges,omitempty"`
+}
+
+// AppliedRolePrivilege records an access right granted to a role on a
+// database.
+type AppliedRolePrivilege struct {
+	// Database is the name of the database on the host.
+	Database   string `json:"database"`
+	Permission Perm   `json:"permission"`
+	// +optional
+	Privileges []Privilege `json:"privileges,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="PhaseUpdated",type="string",JSONPath=".status.phaseUpdated"
+// +kubebuilder:printcolumn:name="Error",type="string",JSONPath=".status.error"
+
+// PgRole is the Schema for the pgroles API
+type PgRole struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PgRoleSpec   `json:"spec,omitempty"`
+	Status PgRoleStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PgRoleList contains a list of PgRole
+type PgRoleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PgRole `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PgRole{}, &PgRoleList{})
+}
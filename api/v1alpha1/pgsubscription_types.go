@@ -0,0 +1,173 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PgSubscriptionSpec defines the desired state of PgSubscription
+type PgSubscriptionSpec struct {
+	// Database is the name of the PgDatabase the subscription is created
+	// in, i.e., the database receiving the changes.
+	Database string `json:"database"`
+
+	// Name is the name of the subscription in the database.
+	// +kubebuilder:validation:MaxLength=63
+	Name string `json:"name"`
+
+	// Source is the database publishing the changes.
+	Source SubscriptionSource `json:"source"`
+
+	// Publications is the list of the names of the publications in the
+	// source database to subscribe to.
+	// +kubebuilder:validation:MinItems=1
+	// +listType=set
+	Publications []string `json:"publications"`
+
+	// SlotName is the name of the replication slot created on the source
+	// host. Defaults to the name of the subscription. The slot of an
+	// existing subscription is only changed while it is disabled.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[a-z_][a-z0-9_]*$`
+	// +kubebuilder:validation:MaxLength=63
+	SlotName string `json:"slotName,omitempty"`
+
+	// Enabled tells whether the subscription replicates changes. Defaults
+	// to true.
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// SubscriptionSource references the database publishing changes.
+type SubscriptionSource struct {
+	// HostCredential is the name of the PgHostCredential or
+	// ClusterPgHostCredential of the source host. Its user creates a login
+	// role with the REPLICATION attribute, which may only read the tables of
+	// the publications, and the subscription connects with that role.
+	HostCredential string `json:"hostCredential"`
+	// Database is the name of the database on the source host.
+	Database string `json:"database"`
+}
+
+// PgSubscriptionStatus defines the observed state of PgSubscription
+type PgSubscriptionStatus struct {
+	Status `json:",inline"`
+
+	// HostCredential, Database and Name identify the subscription created by the
+	// last successful reconciliation, so that it can be dropped once it is
+	// renamed or moved, or once the PgSubscription is deleted.
+	// +optional
+	HostCredential string `json:"hostCredential,omitempty"`
+	// +optional
+	Database string `json:"database,omitempty"`
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Source identifies the login role the subscription connects to the
+	// source database with, so that it can be dropped once the source is
+	// changed, or once the PgSubscription is deleted.
+	// +optional
+	Source *SubscriptionSourceStatus `json:"source,omitempty"`
+
+	// Replication is the state of the subscription as of the last
+	// reconciliation.
+	// +optional
+	Replication *ReplicationStatus `json:"replication,omitempty"`
+}
+
+// SubscriptionSourceStatus is the login role of a subscription on the
+// source host.
+type SubscriptionSourceStatus struct {
+	HostCredential string `json:"hostCredential"`
+	Database       string `json:"database"`
+	// User is the name of the login role.
+	User string `json:"user"`
+	// Tables are the schema-qualified tables the role was granted SELECT
+	// on.
+	// +optional
+	// +listType=set
+	Tables []string `json:"tables,omitempty"`
+}
+
+// ReplicationStatus is the state of a subscription, from
+// pg_stat_subscription on the subscriber and pg_replication_slots on the
+// source host.
+type ReplicationStatus struct {
+	// WorkerRunning tells whether the apply worker of the subscription is
+	// running.
+	WorkerRunning bool `json:"workerRunning"`
+	// ReceivedLSN is the last write-ahead log location received.
+	// +optional
+	ReceivedLSN string `json:"receivedLSN,omitempty"`
+	// LatestEndLSN is the last write-ahead log location reported to the
+	// source.
+	// +optional
+	LatestEndLSN string `json:"latestEndLSN,omitempty"`
+	// LastMessageReceiptTime is the time the last message was received
+	// from the source.
+	// +optional
+	LastMessageReceiptTime *metav1.Time `json:"lastMessageReceiptTime,omitempty"`
+	// LatestEndTime is the time of the last write-ahead log location
+	// reported to the source.
+	// +optional
+	LatestEndTime *metav1.Time `json:"latestEndTime,omitempty"`
+	// SlotActive tells whether the replication slot on the source host is
+	// in use.
+	SlotActive bool `json:"slotActive"`
+	// ConfirmedFlushLSN is the location up to which the subscriber
+	// confirmed the changes of the replication slot.
+	// +optional
+	ConfirmedFlushLSN string `json:"confirmedFlushLSN,omitempty"`
+	// LagBytes is the amount of write-ahead log on the source host that was
+	// not yet confirmed by the subscriber.
+	// +optional
+	LagBytes *int64 `json:"lagBytes,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Database",type="string",JSONPath=".spec.database"
+// +kubebuilder:printcolumn:name="Subscription",type="string",JSONPath=".spec.name"
+// +kubebuilder:printcolumn:name="Running",type="boolean",JSONPath=".status.replication.workerRunning"
+// +kubebuilder:printcolumn:name="Lag",type="integer",JSONPath=".status.replication.lagBytes"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="PhaseUpdated",type="string",JSONPath=".status.phaseUpdated"
+// +kubebuilder:printcolumn:name="Error",type="string",JSONPath=".status.error"
+
+// PgSubscription is the Schema for the pgsubscriptions API. It replicates
+// the changes published by another database into a PgDatabase.
+type PgSubscription struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PgSubscriptionSpec   `json:"spec,omitempty"`
+	Status PgSubscriptionStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PgSubscriptionList contains a list of PgSubscription
+type PgSubscriptionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PgSubscription `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PgSubscription{}, &PgSubscriptionList{})
+}
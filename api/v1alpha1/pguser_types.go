@@ -34,13 +34,73 @@ type PgUserSpec struct {
 	// +optional
 	// +listType=atomic
 	AccessSpecs *[]AccessSpec `json:"accessSpecs,omitempty"`
+	// Roles is the list of PgRole names in the same namespace which the user
+	// is granted. The user is created on the host of each role.
+	// +optional
+	// +listType=set
+	Roles []string `json:"roles,omitempty"`
+	// Attributes of the login role. Omitted attributes take the defaults of
+	// CREATE ROLE.
+	// +optional
+	Attributes RoleAttributes `json:"attributes,omitempty"`
+	// Parameters are the session defaults of the user on every host (e.g.,
+	// `statement_timeout: 30s`). Parameters removed from the map are reset.
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// Adopt tells the operator to manage the login role if it exists but was
+	// not created by the operator.
+	// +optional
+	Adopt bool `json:"adopt,omitempty"`
+	// DriftPolicy tells what the operator does when the resync finds that
+	// the live state of the login role, its memberships and privileges differs from the specification.
+	// +optional
+	// +kubebuilder:default=Repair
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
 }
 
+// RoleAttributes defines the attributes of a login role. The privileged
+// attributes CreateDB, Replication and BypassRLS can only be enabled if the
+// operator allows them.
+type RoleAttributes struct {
+	// ConnectionLimit is the number of concurrent connections the role can
+	// make. -1 means no limit.
+	// +optional
+	// +kubebuilder:validation:Minimum=-1
+	ConnectionLimit *int32 `json:"connectionLimit,omitempty"`
+	// ValidUntil is the time after which the password of the role is no
+	// longer valid. The password never expires if omitted.
+	// +optional
+	ValidUntil *metav1.Time `json:"validUntil,omitempty"`
+	// Inherit tells whether the role inherits the privileges of the roles it
+	// is a member of. Defaults to true.
+	// +optional
+	Inherit *bool `json:"inherit,omitempty"`
+	// CreateDB tells whether the role can create databases.
+	// +optional
+	CreateDB bool `json:"createdb,omitempty"`
+	// Replication tells whether the role can initiate streaming replication.
+	// +optional
+	Replication bool `json:"replication,omitempty"`
+	// BypassRLS tells whether the role bypasses every row-level security
+	// policy.
+	// +optional
+	BypassRLS bool `json:"bypassrls,omitempty"`
+}
+
+// Perm is the access right to a database.
+// +kubebuilder:validation:Enum=readonly;readwrite;owner;custom
 type Perm string
 
 const (
 	PermReadOnly  Perm = "readonly"
 	PermReadWrite Perm = "readwrite"
+	// PermOwner grants the `<db>_owner` role which owns the public schema and
+	// may run DDL. Sessions of the user in the database assume the owner
+	// role, so that objects they create are owned by it.
+	PermOwner Perm = "owner"
+	// PermCustom grants the privileges listed in the access specification on
+	// all tables in the schema.
+	PermCustom Perm = "custom"
 )
 
 // AccessSpecs defines a access request specification.
@@ -55,6 +115,86 @@ type AccessSpec struct {
 	Reason string `json:"reason"`
 	// Permission defines the access right to the database or schema
 	Permission Perm `json:"permission"`
+	// ExpiresAt is the time after which the access is revoked.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+	// Duration is the time after the first grant after which the access is
+	// revoked. It is ignored if ExpiresAt is set.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+	// Privileges is the list of table privileges granted if Permission is
+	// `custom`.
+	// +optional
+	// +listType=set
+	Privileges []Privilege `json:"privileges,omitempty"`
+	// Parameters are the session defaults of the user in the database. They
+	// take precedence over the parameters of the user.
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// PgUserStatus defines the observed state of PgUser
+type PgUserStatus struct {
+	Status `json:",inline"`
+
+	// AccessGrants records when access specifications were granted and
+	// revoked. Access specifications are identified by their host
+	// credential, database, permission and reason, so that a new request
+	// for expired access needs a new reason.
+	// +optional
+	// +listType=atomic
+	AccessGrants []AccessGrantStatus `json:"accessGrants,omitempty"`
+
+	// Claims records the login roles owned on the hosts.
+	// +optional
+	// +listType=atomic
+	Claims []NameClaim `json:"claims,omitempty"`
+
+	// Roles records the roles on the hosts the login roles were made
+	// members of, so that memberships removed from the specification are
+	// revoked.
+	// +optional
+	// +listType=atomic
+	Roles []RoleMembership `json:"roles,omitempty"`
+
+	// ObservedGeneration is the generation of the specification last
+	// reconciled successfully.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// RoleMembership records the membership of a login role in a role on a
+// host.
+type RoleMembership struct {
+	// HostCredential is the name of the host credential.
+	HostCredential string `json:"hostCredential"`
+	// Role is the name of the role on the host.
+	Role string `json:"role"`
+}
+
+// AccessGrantStatus records the lifecycle of an access specification.
+type AccessGrantStatus struct {
+	HostCredential string `json:"hostCredential"`
+	Database       string `json:"database"`
+	Permission     Perm   `json:"permission"`
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Schema and Privileges are recorded for custom permissions, so that
+	// they can be revoked once the access specification is removed.
+	// +optional
+	Schema string `json:"schema,omitempty"`
+	// +optional
+	Privileges []Privilege `json:"privileges,omitempty"`
+	// GrantedAt is the time the access was first granted.
+	// +optional
+	GrantedAt *metav1.Time `json:"grantedAt,omitempty"`
+	// ExpiresAt is the time the access expires.
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+	// RevokedAt is the time the access was revoked because it expired or
+	// because the access specification was removed.
+	// +optional
+	RevokedAt *metav1.Time `json:"revokedAt,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -68,8 +208,8 @@ type PgUser struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec   PgUserSpec `json:"spec,omitempty"`
-	Status Status     `json:"status,omitempty"`
+	Spec   PgUserSpec   `json:"spec,omitempty"`
+	Status PgUserStatus `json:"status,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -0,0 +1,59 @@
+package util
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/strings/slices"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func ClusterPgHostCredentialByName(c client.Client, name string) (*v1alpha1.ClusterPgHostCredential, error) {
+	cred := &v1alpha1.ClusterPgHostCredential{}
+	err := c.Get(context.TODO(), types.NamespacedName{Name: name}, cred)
+	if err != nil {
+		return nil, fmt.Errorf("get a cluster host credential by name (%s): %w", name, err)
+	}
+	return cred, nil
+}
+
+// NamespaceAllowed returns whether custom resources in namespace may use
+// cred.
+func NamespaceAllowed(c client.Client, cred *v1alpha1.ClusterPgHostCredential, namespace string) (bool, error) {
+	allowed := cred.Spec.AllowedNamespaces
+	if slices.Contains(allowed.Names, namespace) {
+		return true, nil
+	}
+	if allowed.Selector == nil {
+		return false, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(allowed.Selector)
+	if err != nil {
+		return false, fmt.Errorf("allowed namespaces of cluster host credential %s: %w", cred.Name, err)
+	}
+	ns := &corev1.Namespace{}
+	if err := c.Get(context.TODO(), types.NamespacedName{Name: namespace}, ns); err != nil {
+		return false, fmt.Errorf("get namespace %s: %w", namespace, err)
+	}
+	return selector.Matches(labels.Set(ns.Labels)), nil
+}
+
+// AsPgHostCredential returns cred as a PgHostCredential in the namespace its
+// secrets and config maps are read from, so that it can be used wherever a
+// PgHostCredential is.
+func AsPgHostCredential(cred *v1alpha1.ClusterPgHostCredential) *v1alpha1.PgHostCredential {
+	return &v1alpha1.PgHostCredential{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cred.Name,
+			Namespace: cred.Spec.Namespace,
+		},
+		Spec:   cred.Spec.PgHostCredentialSpec,
+		Status: cred.Status,
+	}
+}
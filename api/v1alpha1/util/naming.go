@@ -0,0 +1,90 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	ctlerrors "github.com/jeewangue/postgres-indb-operator/internal/errors"
+	"k8s.io/utils/strings/slices"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// maxIdentifierLength is NAMEDATALEN - 1 of Postgres.
+	maxIdentifierLength = 63
+	// accessRoleSuffix is the longest suffix of the access roles of a
+	// database.
+	accessRoleSuffix = "_readwrite"
+)
+
+var (
+	reservedNames     = []string{"postgres", "template0", "template1", "public"}
+	invalidIdentifier = regexp.MustCompile(`[^a-z0-9_]`)
+)
+
+// DatabaseName returns the name on the host of hostCred of the database
+// requested as name by a custom resource in namespace.
+func DatabaseName(c client.Client, hostCred *v1alpha1.PgHostCredential, namespace, name string) (string, error) {
+	return applyNamingPolicy(c, hostCred, namespace, name, len(accessRoleSuffix))
+}
+
+// RoleName returns the name on the host of hostCred of the role requested as
+// name by a custom resource in namespace.
+func RoleName(c client.Client, hostCred *v1alpha1.PgHostCredential, namespace, name string) (string, error) {
+	return applyNamingPolicy(c, hostCred, namespace, name, 0)
+}
+
+func applyNamingPolicy(c client.Client, hostCred *v1alpha1.PgHostCredential, namespace, name string, reserve int) (string, error) {
+	policy := hostCred.Spec.NamingPolicy
+	if policy == nil {
+		return name, nil
+	}
+
+	tmpl := policy.Template
+	if tmpl == "" {
+		tmpl = "{{ .Name }}"
+	}
+	t, err := template.New("name").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", ctlerrors.NewInvalid(fmt.Errorf("naming policy of host credential %s: %w", hostCred.Name, err))
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, struct{ Namespace, Name string }{namespace, name}); err != nil {
+		return "", ctlerrors.NewInvalid(fmt.Errorf("naming policy of host credential %s: %w", hostCred.Name, err))
+	}
+	result := invalidIdentifier.ReplaceAllString(strings.ToLower(b.String()), "_")
+
+	maxLength := int(policy.MaxLength)
+	if maxLength <= 0 || maxLength > maxIdentifierLength {
+		maxLength = maxIdentifierLength
+	}
+	result = shorten(result, maxLength-reserve)
+
+	admin, err := ResourceValue(c, hostCred.Spec.User, hostCred.Namespace)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(result, "pg_") ||
+		slices.Contains(reservedNames, result) ||
+		slices.Contains(policy.Reserved, result) ||
+		result == admin {
+		return "", ctlerrors.NewInvalid(fmt.Errorf("name %s is reserved on host credential %s", result, hostCred.Name))
+	}
+	return result, nil
+}
+
+// shorten cuts name to maxLength bytes, replacing the end with a hash of
+// the whole name so that shortened names stay distinct.
+func shorten(name string, maxLength int) string {
+	if len(name) <= maxLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(sum[:])[:8]
+	return name[:maxLength-len(hash)-1] + "_" + hash
+}
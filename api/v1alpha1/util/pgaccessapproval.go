@@ -0,0 +1,58 @@
+package util
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func PgAccessApprovals(c client.Client, namespace string) ([]v1alpha1.PgAccessApproval, error) {
+	var approvals v1alpha1.PgAccessApprovalList
+	err := c.List(context.TODO(), &approvals, client.InNamespace(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("get approvals in namespace: %w", err)
+	}
+	return approvals.Items, nil
+}
+
+func PgAccessApprovalByName(c client.Client, namespace string, name string) (*v1alpha1.PgAccessApproval, error) {
+	approval := &v1alpha1.PgAccessApproval{}
+	err := c.Get(context.TODO(), types.NamespacedName{
+		Namespace: namespace,
+		Name:      name,
+	}, approval)
+	if err != nil {
+		return nil, fmt.Errorf("get an approval by name (%s) in namespace (%s): %w", name, namespace, err)
+	}
+	return approval, nil
+}
+
+// AccessSpecHash returns the hash of accessSpec PgAccessApprovals are bound
+// to. The order of the privileges does not matter.
+func AccessSpecHash(accessSpec v1alpha1.AccessSpec) string {
+	privileges := append([]v1alpha1.Privilege(nil), accessSpec.Privileges...)
+	sort.Slice(privileges, func(i, j int) bool { return privileges[i] < privileges[j] })
+	accessSpec.Privileges = privileges
+
+	// marshaling the fields of an AccessSpec cannot fail
+	data, _ := json.Marshal(accessSpec)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// MatchesAccessSpec returns whether approval identifies accessSpec of the
+// PgUser user by its host credential, database, permission and reason.
+func MatchesAccessSpec(approval *v1alpha1.PgAccessApproval, user string, accessSpec v1alpha1.AccessSpec) bool {
+	return approval.Spec.User == user &&
+		approval.Spec.HostCredential == accessSpec.HostCredential &&
+		approval.Spec.Database == accessSpec.Database &&
+		approval.Spec.Permission == accessSpec.Permission &&
+		approval.Spec.Reason == accessSpec.Reason
+}
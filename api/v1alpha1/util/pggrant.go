@@ -0,0 +1,31 @@
+package util
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func PgGrants(c client.Client, namespace string) ([]v1alpha1.PgGrant, error) {
+	var grants v1alpha1.PgGrantList
+	err := c.List(context.TODO(), &grants, client.InNamespace(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("get grants in namespace: %w", err)
+	}
+	return grants.Items, nil
+}
+
+func PgGrantByName(c client.Client, namespace string, name string) (*v1alpha1.PgGrant, error) {
+	grant := &v1alpha1.PgGrant{}
+	err := c.Get(context.TODO(), types.NamespacedName{
+		Namespace: namespace,
+		Name:      name,
+	}, grant)
+	if err != nil {
+		return nil, fmt.Errorf("get a grant by name (%s) in namespace (%s): %w", name, namespace, err)
+	}
+	return grant, nil
+}
@@ -6,6 +6,8 @@ import (
 	"net/url"
 
 	"github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	ctlerrors "github.com/jeewangue/postgres-indb-operator/internal/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -36,6 +38,11 @@ func GetConnectionStringWithDatabase(h *v1alpha1.PgHostCredential, c client.Clie
 	return connStr, nil
 }
 
+// HostAddress returns the address of the host of h.
+func HostAddress(h *v1alpha1.PgHostCredential, c client.Client) (string, error) {
+	return ResourceValue(c, h.Spec.Host, h.Namespace)
+}
+
 func GetConnectionString(h *v1alpha1.PgHostCredential, c client.Client) (string, error) {
 	return GetConnectionStringWithDatabase(h, c, "template1")
 }
@@ -49,12 +56,33 @@ func PgHostCredentials(c client.Client, namespace string) ([]v1alpha1.PgHostCred
 	return creds.Items, nil
 }
 
+// PgHostCredentialByName returns the PgHostCredential name in namespace or,
+// if there is none, the ClusterPgHostCredential name if namespace is allowed
+// to use it.
 func PgHostCredentialByName(c client.Client, namespace string, name string) (*v1alpha1.PgHostCredential, error) {
 	cred := &v1alpha1.PgHostCredential{}
 	err := c.Get(context.TODO(), types.NamespacedName{
 		Namespace: namespace,
 		Name:      name,
 	}, cred)
+	if apierrors.IsNotFound(err) {
+		clusterCred, clusterErr := ClusterPgHostCredentialByName(c, name)
+		if apierrors.IsNotFound(clusterErr) {
+			return nil, fmt.Errorf("get a host credential by name (%s) in namespace (%s): %w", name, namespace, err)
+		}
+		if clusterErr != nil {
+			return nil, clusterErr
+		}
+
+		allowed, err := NamespaceAllowed(c, clusterCred, namespace)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, ctlerrors.NewInvalid(fmt.Errorf("%w: cluster host credential %s in namespace %s", ErrNamespaceNotAllowed, name, namespace))
+		}
+		return AsPgHostCredential(clusterCred), nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("get a host credential by name (%s) in namespace (%s): %w", name, namespace, err)
 	}
@@ -0,0 +1,18 @@
+package util
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func PgMigrations(c client.Client, namespace string) ([]v1alpha1.PgMigration, error) {
+	var migrations v1alpha1.PgMigrationList
+	err := c.List(context.TODO(), &migrations, client.InNamespace(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("get migrations in namespace: %w", err)
+	}
+	return migrations.Items, nil
+}
@@ -0,0 +1,100 @@
+package util
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/strings/slices"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func PgQuotas(c client.Client, namespace string) ([]v1alpha1.PgQuota, error) {
+	var quotas v1alpha1.PgQuotaList
+	err := c.List(context.TODO(), &quotas, client.InNamespace(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("get quotas in namespace: %w", err)
+	}
+	return quotas.Items, nil
+}
+
+func PgQuotaByName(c client.Client, namespace string, name string) (*v1alpha1.PgQuota, error) {
+	quota := &v1alpha1.PgQuota{}
+	err := c.Get(context.TODO(), types.NamespacedName{
+		Namespace: namespace,
+		Name:      name,
+	}, quota)
+	if err != nil {
+		return nil, fmt.Errorf("get a quota by name (%s) in namespace (%s): %w", name, namespace, err)
+	}
+	return quota, nil
+}
+
+// UserHostCredentials returns the names of the host credentials user is
+// created on, by its access specifications and by its roles in roles.
+func UserHostCredentials(user *v1alpha1.PgUser, roles []v1alpha1.PgRole) []string {
+	var hostCreds []string
+	if user.Spec.AccessSpecs != nil {
+		for _, accessSpec := range *user.Spec.AccessSpecs {
+			if !slices.Contains(hostCreds, accessSpec.HostCredential) {
+				hostCreds = append(hostCreds, accessSpec.HostCredential)
+			}
+		}
+	}
+	for _, role := range roles {
+		if slices.Contains(user.Spec.Roles, role.Name) && !slices.Contains(hostCreds, role.Spec.HostCredential) {
+			hostCreds = append(hostCreds, role.Spec.HostCredential)
+		}
+	}
+	return hostCreds
+}
+
+// QuotaUsage returns the usage of the host of hostCred by databases and
+// users, leaving the size empty. It also returns the names of the users
+// without a connection limit.
+func QuotaUsage(hostCred string, databases []v1alpha1.PgDatabase, users []v1alpha1.PgUser, roles []v1alpha1.PgRole) (v1alpha1.PgQuotaUsage, []string) {
+	var usage v1alpha1.PgQuotaUsage
+	var unlimited []string
+	for _, database := range databases {
+		if database.Spec.HostCredential == hostCred {
+			usage.Databases++
+		}
+	}
+	for i := range users {
+		user := &users[i]
+		if !slices.Contains(UserHostCredentials(user, roles), hostCred) {
+			continue
+		}
+		usage.Users++
+		if limit := user.Spec.Attributes.ConnectionLimit; limit != nil && *limit >= 0 {
+			usage.Connections += *limit
+		} else {
+			unlimited = append(unlimited, user.Name)
+		}
+	}
+	return usage, unlimited
+}
+
+// ExceededLimits describes the limits of quota which usage exceeds.
+// unlimited are the names of the users without a connection limit.
+func ExceededLimits(quota *v1alpha1.PgQuota, usage v1alpha1.PgQuotaUsage, unlimited []string) []string {
+	var exceeded []string
+	spec := quota.Spec
+	if spec.MaxDatabases != nil && usage.Databases > *spec.MaxDatabases {
+		exceeded = append(exceeded, fmt.Sprintf("%d databases exceed the limit of %d", usage.Databases, *spec.MaxDatabases))
+	}
+	if spec.MaxUsers != nil && usage.Users > *spec.MaxUsers {
+		exceeded = append(exceeded, fmt.Sprintf("%d users exceed the limit of %d", usage.Users, *spec.MaxUsers))
+	}
+	if spec.MaxConnections != nil && usage.Connections > *spec.MaxConnections {
+		exceeded = append(exceeded, fmt.Sprintf("%d connections exceed the limit of %d", usage.Connections, *spec.MaxConnections))
+	}
+	if spec.MaxConnections != nil && len(unlimited) > 0 {
+		exceeded = append(exceeded, fmt.Sprintf("users %v have no connection limit", unlimited))
+	}
+	if spec.MaxSize != nil && usage.Size != nil && usage.Size.Cmp(*spec.MaxSize) > 0 {
+		exceeded = append(exceeded, fmt.Sprintf("size %s exceeds the limit of %s", usage.Size, spec.MaxSize))
+	}
+	return exceeded
+}
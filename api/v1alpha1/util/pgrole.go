@@ -0,0 +1,31 @@
+package util
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func PgRoles(c client.Client, namespace string) ([]v1alpha1.PgRole, error) {
+	var roles v1alpha1.PgRoleList
+	err := c.List(context.TODO(), &roles, client.InNamespace(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("get roles in namespace: %w", err)
+	}
+	return roles.Items, nil
+}
+
+func PgRoleByName(c client.Client, namespace string, name string) (*v1alpha1.PgRole, error) {
+	role := &v1alpha1.PgRole{}
+	err := c.Get(context.TODO(), types.NamespacedName{
+		Namespace: namespace,
+		Name:      name,
+	}, role)
+	if err != nil {
+		return nil, fmt.Errorf("get a role by name (%s) in namespace (%s): %w", name, namespace, err)
+	}
+	return role, nil
+}
@@ -18,6 +18,9 @@ var (
 	ErrNoValue    = ctlerrors.NewInvalid(errors.New("no value"))
 	ErrNotFound   = ctlerrors.NewTemporary(ctlerrors.NewInvalid(errors.New("not found")))
 	ErrUnknownKey = ctlerrors.NewTemporary(ctlerrors.NewInvalid(errors.New("unknown key")))
+	// ErrNamespaceNotAllowed indicates that a ClusterPgHostCredential does not
+	// allow the namespace of a custom resource to use it.
+	ErrNamespaceNotAllowed = errors.New("namespace not allowed")
 )
 
 // ResourceValue returns the value of a ResourceVar in a specific namespace.
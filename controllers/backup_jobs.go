@@ -0,0 +1,423 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	apiutil "github.com/jeewangue/postgres-indb-operator/api/v1alpha1/util"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres"
+)
+
+const (
+	// DefaultBackupImage is the image with pg_dump and pg_restore the backup
+	// Jobs run. Its major version must not be older than the one of the
+	// servers.
+	DefaultBackupImage = "postgres:15-alpine"
+	// DefaultBackupUploadImage is the image with the AWS CLI the backup Jobs
+	// upload to and delete from S3-compatible storages with.
+	DefaultBackupUploadImage = "amazon/aws-cli:2.13.0"
+
+	// backupMountPath is where the volume of a backup is mounted in the
+	// containers of its Jobs.
+	backupMountPath = "/backup"
+	// databaseURLKey is the key of the connection string in the Secret of a
+	// backup Job.
+	databaseURLKey = "DATABASE_URL"
+	// jobUserLifetime is how long the password of the login role of a dump
+	// or restore Job is valid. The Job has to connect within it.
+	jobUserLifetime = time.Hour
+)
+
+// dumpScript dumps DATABASE_URL as ROLE to BACKUP_FILE and writes its size
+// and checksum as JSON to RESULT_FILE.
+const dumpScript = `set -e
+mkdir -p "$(dirname "$BACKUP_FILE")"
+pg_dump --format=custom --no-password --role="$ROLE" --dbname="$DATABASE_URL" --file="$BACKUP_FILE"
+printf '{"size":%s,"checksum":"sha256:%s"}' "$(stat -c %s "$BACKUP_FILE")" "$(sha256sum "$BACKUP_FILE" | cut -d ' ' -f 1)" > "$RESULT_FILE"
+`
+
+// uploadScript uploads BACKUP_FILE to BACKUP_URL and reports the result of
+// the dump in the termination message.
+const uploadScript = `set -e
+aws s3 cp ${S3_ENDPOINT:+--endpoint-url "$S3_ENDPOINT"} --only-show-errors "$BACKUP_FILE" "$BACKUP_URL"
+cat "$RESULT_FILE" > /dev/termination-log
+`
+
+// restoreScript restores BACKUP_FILE into DATABASE_URL as ROLE in a single
+// transaction. The owners and privileges of the dump are skipped, since the
+// roles of the backed up database need not exist.
+const restoreScript = `set -e
+pg_restore --no-password --no-owner --no-privileges --single-transaction --exit-on-error --role="$ROLE" --dbname="$DATABASE_URL" "$BACKUP_FILE"
+`
+
+const downloadScript = `aws s3 cp ${S3_ENDPOINT:+--endpoint-url "$S3_ENDPOINT"} --only-show-errors "$BACKUP_URL" "$BACKUP_FILE"`
+
+const removeFileScript = `rm -f "$BACKUP_FILE"`
+
+const removeObjectScript = `aws s3 rm ${S3_ENDPOINT:+--endpoint-url "$S3_ENDPOINT"} --only-show-errors "$BACKUP_URL"`
+
+// BackupImages are the images of the Jobs taking, restoring and deleting
+// backups.
+type BackupImages struct {
+	// Postgres has pg_dump and pg_restore. Defaults to DefaultBackupImage.
+	Postgres string
+	// Upload has the AWS CLI. Defaults to DefaultBackupUploadImage.
+	Upload string
+}
+
+func (i BackupImages) postgres() string {
+	if i.Postgres == "" {
+		return DefaultBackupImage
+	}
+	return i.Postgres
+}
+
+func (i BackupImages) upload() string {
+	if i.Upload == "" {
+		return DefaultBackupUploadImage
+	}
+	return i.Upload
+}
+
+// backupResult is the termination message of the Job taking a backup.
+type backupResult struct {
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`
+}
+
+// validateBackupStorage returns an error unless exactly one storage is set.
+func validateBackupStorage(storage api.BackupStorage) error {
+	if (storage.PersistentVolumeClaim == nil) == (storage.S3 == nil) {
+		return fmt.Errorf("exactly one of persistentVolumeClaim and s3 must be set")
+	}
+	return nil
+}
+
+// backupKey returns the path of the file of backup relative to its storage.
+func backupKey(backup *api.PgBackup) string {
+	return path.Join(backup.Namespace, backup.Name+".dump")
+}
+
+// backupLocation returns the path of the file of backup in its volume, or
+// its s3:// URL.
+func backupLocation(backup *api.PgBackup) string {
+	storage := backup.Spec.Storage
+	if storage.S3 != nil {
+		return fmt.Sprintf("s3://%s/%s%s", storage.S3.Bucket, storage.S3.Prefix, backupKey(backup))
+	}
+	return path.Join(storage.PersistentVolumeClaim.Path, backupKey(backup))
+}
+
+// backupSecretName returns the name of the Secret with the connection
+// string the Jobs of backup connect with.
+func backupSecretName(backup *api.PgBackup) string {
+	return backup.Name + "-pgbackup"
+}
+
+// backupSecret returns the Secret with the connection string connStr for
+// the Jobs of backup.
+func backupSecret(backup *api.PgBackup, connStr string) *corev1.Secret {
+	return connectionSecret(backup.Namespace, backupSecretName(backup), connStr)
+}
+
+// restoreSecretName returns the name of the Secret with the connection
+// string the Job restoring a backup into database connects with.
+func restoreSecretName(database *api.PgDatabase) string {
+	return database.Name + "-pgrestore"
+}
+
+// restoreSecret returns the Secret with the connection string connStr for
+// the Job restoring a backup into database.
+func restoreSecret(database *api.PgDatabase, connStr string) *corev1.Secret {
+	return connectionSecret(database.Namespace, restoreSecretName(database), connStr)
+}
+
+func connectionSecret(namespace, name, connStr string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+		Data: map[string][]byte{databaseURLKey: []byte(connStr)},
+	}
+}
+
+// applySecret creates secret, or patches the data of the Secret of the same
+// name left by an earlier attempt.
+func applySecret(ctx context.Context, c client.Client, secret *corev1.Secret) error {
+	err := c.Create(ctx, secret)
+	if !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	existing := &corev1.Secret{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(secret), existing); err != nil {
+		return err
+	}
+	patch := client.MergeFrom(existing.DeepCopy())
+	existing.Data = secret.Data
+	return c.Patch(ctx, existing, patch)
+}
+
+// jobUserName returns the name of the login role the dump or restore Job of
+// the object with uid connects with.
+func jobUserName(uid types.UID) string {
+	sum := sha256.Sum256([]byte(uid))
+	return "pgjob_" + hex.EncodeToString(sum[:8])
+}
+
+// createJobUser creates the login role name of a dump or restore Job on the
+// host of db as a member of the owner role of dbname. Its random password
+// expires after jobUserLifetime, so that the Job does not need the
+// credentials of the admin. It returns the password.
+func createJobUser(db *postgres.Client, dbname, name string, owner postgres.Owner) (string, error) {
+	password, err := randomPassword()
+	if err != nil {
+		return "", err
+	}
+
+	if err := db.EnsureUser(name, password, owner, false); err != nil {
+		return "", err
+	}
+	attrs := postgres.DefaultRoleAttributes()
+	validUntil := time.Now().Add(jobUserLifetime).Truncate(time.Second)
+	attrs.ValidUntil = &validUntil
+	if err := db.EnsureRoleAttributes(name, attrs); err != nil {
+		return "", err
+	}
+	if err := db.EnsureRoleToUser(dbname+"_owner", name); err != nil {
+		return "", err
+	}
+	return password, nil
+}
+
+// randomPassword returns a random password for a login role created by the
+// operator.
+func randomPassword() (string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// loginConnectionString returns the connection string of the database dbname
+// on the host of hostCred for the login role user instead of the admin.
+func loginConnectionString(c client.Client, hostCred *api.PgHostCredential, dbname, user, password string) (string, error) {
+	connStr, err := apiutil.GetConnectionStringWithDatabase(hostCred, c, dbname)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(connStr)
+	if err != nil {
+		return "", err
+	}
+	u.User = url.UserPassword(user, password)
+	return u.String(), nil
+}
+
+// databaseURLEnv returns the variable with the connection string of the
+// Secret name.
+func databaseURLEnv(name string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: databaseURLKey,
+		ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: name},
+			Key:                  databaseURLKey,
+		}},
+	}
+}
+
+// dumpJob returns the Job running pg_dump for backup as role. Backups to a
+// volume are written by a single container. Backups to S3 are written to a
+// temporary volume by an init container and uploaded by the container.
+func dumpJob(backup *api.PgBackup, role string, images BackupImages) *batchv1.Job {
+	dump := corev1.Container{
+		Name:    "dump",
+		Image:   images.postgres(),
+		Command: []string{"/bin/sh", "-c", dumpScript},
+		Env: []corev1.EnvVar{
+			databaseURLEnv(backupSecretName(backup)),
+			{Name: "ROLE", Value: role},
+		},
+		VolumeMounts: []corev1.VolumeMount{{Name: "backup", MountPath: backupMountPath}},
+	}
+
+	s3 := backup.Spec.Storage.S3
+	if s3 == nil {
+		dump.Env = append(dump.Env,
+			corev1.EnvVar{Name: "BACKUP_FILE", Value: path.Join(backupMountPath, backupLocation(backup))},
+			corev1.EnvVar{Name: "RESULT_FILE", Value: corev1.TerminationMessagePathDefault},
+		)
+		return backupJob(backup, backup.Name+"-dump", nil, []corev1.Container{dump})
+	}
+
+	file := []corev1.EnvVar{
+		{Name: "BACKUP_FILE", Value: path.Join(backupMountPath, "backup.dump")},
+		{Name: "RESULT_FILE", Value: path.Join(backupMountPath, "result.json")},
+	}
+	dump.Env = append(dump.Env, file...)
+	upload := s3Container(backup, "upload", uploadScript, images)
+	upload.Env = append(upload.Env, file...)
+	return backupJob(backup, backup.Name+"-dump", []corev1.Container{dump}, []corev1.Container{upload})
+}
+
+// restoreJob returns the Job running pg_restore for backup into database as
+// role. Backups on S3 are downloaded to a temporary volume by an init
+// container first.
+func restoreJob(database *api.PgDatabase, backup *api.PgBackup, role string, images BackupImages) *batchv1.Job {
+	restore := corev1.Container{
+		Name:    "restore",
+		Image:   images.postgres(),
+		Command: []string{"/bin/sh", "-c", restoreScript},
+		Env: []corev1.EnvVar{
+			databaseURLEnv(restoreSecretName(database)),
+			{Name: "ROLE", Value: role},
+		},
+		VolumeMounts: []corev1.VolumeMount{{Name: "backup", MountPath: backupMountPath}},
+	}
+
+	name := database.Name + "-restore"
+	if backup.Spec.Storage.S3 == nil {
+		restore.Env = append(restore.Env, corev1.EnvVar{Name: "BACKUP_FILE", Value: path.Join(backupMountPath, backupLocation(backup))})
+		return backupJob(backup, name, nil, []corev1.Container{restore})
+	}
+
+	file := corev1.EnvVar{Name: "BACKUP_FILE", Value: path.Join(backupMountPath, "backup.dump")}
+	restore.Env = append(restore.Env, file)
+	download := s3Container(backup, "download", downloadScript, images)
+	download.Env = append(download.Env, file)
+	return backupJob(backup, name, []corev1.Container{download}, []corev1.Container{restore})
+}
+
+// cleanupJob returns the Job deleting the file of backup.
+func cleanupJob(backup *api.PgBackup, images BackupImages) *batchv1.Job {
+	if backup.Spec.Storage.S3 != nil {
+		return backupJob(backup, backup.Name+"-cleanup", nil, []corev1.Container{
+			s3Container(backup, "cleanup", removeObjectScript, images),
+		})
+	}
+	return backupJob(backup, backup.Name+"-cleanup", nil, []corev1.Container{{
+		Name:         "cleanup",
+		Image:        images.postgres(),
+		Command:      []string{"/bin/sh", "-c", removeFileScript},
+		Env:          []corev1.EnvVar{{Name: "BACKUP_FILE", Value: path.Join(backupMountPath, backupLocation(backup))}},
+		VolumeMounts: []corev1.VolumeMount{{Name: "backup", MountPath: backupMountPath}},
+	}})
+}
+
+// s3Container returns a container running script with the AWS CLI and the
+// credentials, endpoint and URL of the S3 storage of backup.
+func s3Container(backup *api.PgBackup, name, script string, images BackupImages) corev1.Container {
+	s3 := backup.Spec.Storage.S3
+	env := []corev1.EnvVar{{Name: "BACKUP_URL", Value: backupLocation(backup)}}
+	if s3.Endpoint != "" {
+		env = append(env, corev1.EnvVar{Name: "S3_ENDPOINT", Value: s3.Endpoint})
+	}
+	if s3.Region != "" {
+		env = append(env, corev1.EnvVar{Name: "AWS_DEFAULT_REGION", Value: s3.Region})
+	}
+	return corev1.Container{
+		Name:    name,
+		Image:   images.upload(),
+		Command: []string{"/bin/sh", "-c", script},
+		Env:     env,
+		EnvFrom: []corev1.EnvFromSource{{
+			SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: s3.CredentialsSecret}},
+		}},
+		VolumeMounts: []corev1.VolumeMount{{Name: "backup", MountPath: backupMountPath}},
+	}
+}
+
+// backupJob returns a Job named name running containers with the volume
+// "backup" of the storage of backup, or a temporary volume for S3.
+func backupJob(backup *api.PgBackup, name string, initContainers, containers []corev1.Container) *batchv1.Job {
+	volume := corev1.Volume{Name: "backup"}
+	if pvc := backup.Spec.Storage.PersistentVolumeClaim; pvc != nil {
+		volume.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvc.ClaimName}
+	} else {
+		volume.EmptyDir = &corev1.EmptyDirVolumeSource{}
+	}
+
+	backoffLimit := int32(2)
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: backup.Namespace,
+			Name:      name,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy:  corev1.RestartPolicyNever,
+					InitContainers: initContainers,
+					Containers:     containers,
+					Volumes:        []corev1.Volume{volume},
+				},
+			},
+		},
+	}
+}
+
+// jobFinished returns whether job completed or failed, and the message of
+// the failure.
+func jobFinished(job *batchv1.Job) (finished bool, failed bool, message string) {
+	for _, c := range job.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			return true, false, ""
+		case batchv1.JobFailed:
+			return true, true, c.Message
+		}
+	}
+	return false, false, ""
+}
+
+// jobTerminationMessage returns the termination message of the last
+// container of a succeeded pod of job.
+func jobTerminationMessage(ctx context.Context, c client.Client, job *batchv1.Job) (string, error) {
+	var pods corev1.PodList
+	err := c.List(ctx, &pods, client.InNamespace(job.Namespace), client.MatchingLabels{"job-name": job.Name})
+	if err != nil {
+		return "", err
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodSucceeded {
+			continue
+		}
+		statuses := pod.Status.ContainerStatuses
+		if len(statuses) == 0 {
+			continue
+		}
+		if terminated := statuses[len(statuses)-1].State.Terminated; terminated != nil && terminated.Message != "" {
+			return terminated.Message, nil
+		}
+	}
+	return "", fmt.Errorf("no succeeded pod of job %s reported a result", job.Name)
+}
+
+// parseBackupResult parses the termination message of a backup Job.
+func parseBackupResult(message string) (backupResult, error) {
+	var result backupResult
+	if err := json.Unmarshal([]byte(message), &result); err != nil {
+		return result, fmt.Errorf("invalid result of backup job: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	apiutil "github.com/jeewangue/postgres-indb-operator/api/v1alpha1/util"
+	ctlerrors "github.com/jeewangue/postgres-indb-operator/internal/errors"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ClusterPgHostCredentialReconciler reconciles a ClusterPgHostCredential object
+type ClusterPgHostCredentialReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// Connector opens the clients of the hosts. Defaults to
+	// postgres.NewClient.
+	Connector postgres.Connector
+
+	logger   logr.Logger
+	hostCred *api.ClusterPgHostCredential
+}
+
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=clusterpghostcredentials,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=clusterpghostcredentials/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=clusterpghostcredentials/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets;configmaps,verbs=get;list;watch
+
+// Reconcile validates the allowed namespaces of a ClusterPgHostCredential,
+// connects to its host and records the capabilities of the host in the
+// status.
+func (r *ClusterPgHostCredentialReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.logger = setupLogger(ctx)
+	r.logger.Info("Reconciling ClusterPgHostCredential")
+
+	result, err := r.handleResult(r.reconcile(ctx, req))
+	r.logger.Info("Finished reconciling ClusterPgHostCredential")
+	return result, err
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterPgHostCredentialReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&api.ClusterPgHostCredential{}).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: 1,
+			RateLimiter:             DefaultControllerRateLimiter(),
+		}).
+		Complete(r)
+}
+
+func (r *ClusterPgHostCredentialReconciler) reconcile(ctx context.Context, req reconcile.Request) error {
+	// Fetch the ClusterPgHostCredential instance
+	cred := &api.ClusterPgHostCredential{}
+	{
+		err := r.Client.Get(ctx, req.NamespacedName, cred)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				// Request object not found, could have been deleted after reconcile request.
+				// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
+				// Return and don't requeue
+				r.logger.Info("Object not found")
+				return ctlerrors.NewInvalid(err)
+			}
+			// Error reading the object - requeue the request.
+			return ctlerrors.NewTemporary(err)
+		}
+
+		r.hostCred = cred
+	}
+
+	// ClusterPgHostCredential instance created or updated
+	r.logger = r.logger.WithValues("clusterHostCredential", cred.Name)
+	r.logger.Info("Reconciling found ClusterPgHostCredential resource")
+
+	if _, err := metav1.LabelSelectorAsSelector(cred.Spec.AllowedNamespaces.Selector); err != nil {
+		return ctlerrors.NewInvalid(err)
+	}
+
+	connStr, err := apiutil.GetConnectionString(apiutil.AsPgHostCredential(cred), r.Client)
+	if err != nil {
+		return ctlerrors.NewInvalid(err)
+	}
+
+	db, err := newClient(ctx, r.logger, r.Connector, connStr)
+	if err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+	defer db.Close()
+
+	host, err := db.HostInfo()
+	if err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+	cred.Status.Host = hostStatus(host)
+
+	return nil
+}
+
+func (r *ClusterPgHostCredentialReconciler) handleResult(err error) (ctrl.Result, error) {
+	var phase api.Phase
+	var errorMessage string
+
+	switch {
+	case err == nil:
+		phase = api.PhaseAvailable
+		errorMessage = ""
+	case ctlerrors.IsTemporary(err):
+		phase = api.PhaseFailed
+		errorMessage = err.Error()
+	case ctlerrors.IsInvalid(err):
+		phase = api.PhaseInvalid
+		errorMessage = err.Error()
+	default:
+		phase = api.PhaseInvalid
+		errorMessage = err.Error()
+	}
+
+	if r.hostCred != nil {
+		r.hostCred.Status.Phase = phase
+		r.hostCred.Status.PhaseUpdated = metav1.Now()
+		r.hostCred.Status.Error = errorMessage
+	}
+
+	if err := r.Status().Update(context.Background(), r.hostCred); err != nil {
+		r.logger.Error(err, "Failed to update the status")
+	}
+
+	if phase == api.PhaseInvalid {
+		return ctrl.Result{}, err
+	} else {
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, err
+	}
+}
@@ -2,12 +2,27 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	goerrors "errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/google/uuid"
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	apiutil "github.com/jeewangue/postgres-indb-operator/api/v1alpha1/util"
+	ctlerrors "github.com/jeewangue/postgres-indb-operator/internal/errors"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres"
+	"github.com/robfig/cron/v3"
 	"golang.org/x/time/rate"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -31,3 +46,437 @@ func DefaultControllerRateLimiter() workqueue.RateLimiter {
 		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
 	)
 }
+
+// scheduledTimes returns the latest time of schedule after last which is not
+// after now, or the zero time if there is none, and the next time after now.
+// Earlier missed times are skipped.
+func scheduledTimes(schedule cron.Schedule, last, now time.Time) (missed, next time.Time) {
+	next = schedule.Next(last)
+	for !next.After(now) {
+		missed = next
+		next = schedule.Next(next)
+	}
+	return missed, next
+}
+
+// newClient opens a client for connStr with connector, or with
+// postgres.NewClient if connector is nil.
+func newClient(ctx context.Context, logger logr.Logger, connector postgres.Connector, connStr string) (*postgres.Client, error) {
+	if connector == nil {
+		connector = postgres.NewClient
+	}
+	return connector(ctx, logger, connStr)
+}
+
+// scriptUserName returns the name of the login role the SQL scripts of the
+// object with uid run as.
+func scriptUserName(uid types.UID) string {
+	sum := sha256.Sum256([]byte(uid))
+	return "pgsql_" + hex.EncodeToString(sum[:8])
+}
+
+// connectAsOwner creates the short-lived login role name on the host of db, a
+// member of the owner role of the database dbname whose sessions there
+// assume the owner role, and returns a client connected to dbname as it. db
+// is connected to dbname as the admin. SQL of tenants runs over the client,
+// so that it cannot switch back to the role of the admin. The returned
+// function closes the client and drops the role.
+func connectAsOwner(ctx context.Context, logger logr.Logger, connector postgres.Connector, c client.Client, db *postgres.Client, hostCred *api.PgHostCredential, dbname, name string, owner postgres.Owner) (*postgres.Client, func(), error) {
+	password, err := createJobUser(db, dbname, name, owner)
+	if err != nil {
+		return nil, nil, ownershipError(err)
+	}
+	drop := func() {
+		// the role expires after jobUserLifetime if it cannot be dropped
+		if err := db.DropLoginRole(name, dbname+"_owner"); err != nil {
+			logger.Error(err, "Failed to drop the login role of the owner", "role", name)
+		}
+	}
+	if err := db.EnsureOwnerRoleToUser(dbname, name); err != nil {
+		drop()
+		return nil, nil, ctlerrors.NewTemporary(err)
+	}
+
+	connStr, err := loginConnectionString(c, hostCred, dbname, name, password)
+	if err != nil {
+		drop()
+		return nil, nil, ctlerrors.NewTemporary(err)
+	}
+	ownerDB, err := newClient(ctx, logger, connector, connStr)
+	if err != nil {
+		drop()
+		logger.Error(err, "Failed to open database connection as the owner")
+		return nil, nil, ctlerrors.NewTemporary(err)
+	}
+	return ownerDB, func() {
+		ownerDB.Close()
+		drop()
+	}, nil
+}
+
+// revokeMembership revokes the membership in role from member. Roles which
+// no longer exist have no memberships to revoke.
+func revokeMembership(db *postgres.Client, role, member string) error {
+	for _, name := range []string{role, member} {
+		exists, err := db.RoleExists(name)
+		if err != nil {
+			return ctlerrors.NewTemporary(err)
+		}
+		if !exists {
+			return nil
+		}
+	}
+	if err := db.RevokeRoleFromUser(role, member); err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+	return nil
+}
+
+// ensurePermission grants perm on dbname to grantee. privileges and schema
+// are only used by the custom permission.
+func ensurePermission(db *postgres.Client, dbname, schema string, perm api.Perm, privileges []api.Privilege, grantee string) error {
+	var err error
+	switch perm {
+	case api.PermReadOnly:
+		err = db.EnsureReadonlyRoleToUser(dbname, grantee)
+	case api.PermReadWrite:
+		err = db.EnsureReadwriteRoleToUser(dbname, grantee)
+	case api.PermOwner:
+		err = db.EnsureOwnerRoleToUser(dbname, grantee)
+	case api.PermCustom:
+		if len(privileges) == 0 {
+			return ctlerrors.NewInvalid(fmt.Errorf("no privileges given for custom permission on %s", dbname))
+		}
+		list := make([]string, len(privileges))
+		for i, p := range privileges {
+			list[i] = string(p)
+		}
+		if err := postgres.ValidateTablePrivileges(list); err != nil {
+			return ctlerrors.NewInvalid(err)
+		}
+		err = db.EnsureCustomPrivilegesToUser(dbname, schema, grantee, list)
+	default:
+		return ctlerrors.NewInvalid(fmt.Errorf("unknown permission %q", perm))
+	}
+	return ctlerrors.NewTemporary(err)
+}
+
+// revokePermission revokes perm on dbname from grantee. privileges and
+// schema are only used by the custom permission.
+func revokePermission(db *postgres.Client, dbname, schema string, perm api.Perm, privileges []api.Privilege, grantee string) error {
+	var err error
+	switch perm {
+	case api.PermReadOnly:
+		err = db.RevokeReadonlyRoleFromUser(dbname, grantee)
+	case api.PermReadWrite:
+		err = db.RevokeReadwriteRoleFromUser(dbname, grantee)
+	case api.PermOwner:
+		err = db.RevokeOwnerRoleFromUser(dbname, grantee)
+	case api.PermCustom:
+		if len(privileges) == 0 {
+			return nil
+		}
+		list := make([]string, len(privileges))
+		for i, p := range privileges {
+			list[i] = string(p)
+		}
+		err = db.RevokeCustomPrivilegesFromUser(dbname, schema, grantee, list)
+	default:
+		return ctlerrors.NewInvalid(fmt.Errorf("unknown permission %q", perm))
+	}
+	return ctlerrors.NewTemporary(err)
+}
+
+// samePrivileges returns whether a and b contain the same privileges,
+// regardless of their order.
+func samePrivileges(a, b []api.Privilege) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, p := range a {
+		if !containsPrivilege(b, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsPrivilege returns whether privileges contains p.
+func containsPrivilege(privileges []api.Privilege, p api.Privilege) bool {
+	for _, other := range privileges {
+		if other == p {
+			return true
+		}
+	}
+	return false
+}
+
+// permissionDrift returns the differences between the privileges of grantee
+// on dbname and the ones ensurePermission grants for perm.
+func permissionDrift(db *postgres.Client, dbname, schema string, perm api.Perm, privileges []api.Privilege, grantee string) ([]string, error) {
+	var (
+		diffs []string
+		err   error
+	)
+	switch perm {
+	case api.PermReadOnly:
+		diffs, err = db.MembershipDrift(dbname+"_readonly", grantee)
+	case api.PermReadWrite:
+		diffs, err = db.MembershipDrift(dbname+"_readwrite", grantee)
+	case api.PermOwner:
+		diffs, err = db.MembershipDrift(dbname+"_owner", grantee)
+	case api.PermCustom:
+		list := make([]string, len(privileges))
+		for i, p := range privileges {
+			list[i] = string(p)
+		}
+		diffs, err = db.CustomPrivilegesDrift(dbname, schema, grantee, list)
+	default:
+		return nil, ctlerrors.NewInvalid(fmt.Errorf("unknown permission %q", perm))
+	}
+	if err != nil {
+		return nil, ctlerrors.NewTemporary(err)
+	}
+	return diffs, nil
+}
+
+// nameClaim returns the claim of name on the host of hostCred.
+func nameClaim(c client.Client, hostCred *api.PgHostCredential, name string) (api.NameClaim, error) {
+	host, err := apiutil.HostAddress(hostCred, c)
+	if err != nil {
+		return api.NameClaim{}, ctlerrors.NewInvalid(err)
+	}
+	return api.NameClaim{HostCredential: hostCred.Name, Host: host, Name: name}, nil
+}
+
+// sameName returns whether a and b claim the same name on the same host.
+func sameName(a, b api.NameClaim) bool {
+	return a.Host == b.Host && a.Name == b.Name
+}
+
+// claimName takes the name of claim for owner of kind by creating its
+// PgNameClaim. The API server refuses to create a PgNameClaim twice, so that
+// two custom resources reconciled together cannot both take the same name.
+// The claims of custom resources which no longer exist are taken over.
+func claimName(ctx context.Context, c client.Client, nameType api.NameType, claim api.NameClaim, kind string, owner client.Object) error {
+	obj := &api.PgNameClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: nameClaimName(nameType, claim)},
+		Spec: api.PgNameClaimSpec{
+			Type: nameType,
+			Host: claim.Host,
+			Name: claim.Name,
+			Owner: api.ClaimOwner{
+				Kind:      kind,
+				Namespace: owner.GetNamespace(),
+				Name:      owner.GetName(),
+				UID:       owner.GetUID(),
+			},
+		},
+	}
+	err := c.Create(ctx, obj)
+	if err == nil {
+		return nil
+	}
+	if !apierrors.IsAlreadyExists(err) {
+		return ctlerrors.NewTemporary(err)
+	}
+
+	existing := &api.PgNameClaim{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+	other := existing.Spec.Owner
+	if other.UID == owner.GetUID() {
+		return nil
+	}
+	exists, err := claimOwnerExists(ctx, c, other)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ctlerrors.NewInvalid(fmt.Errorf("%s %s on %s is %w: %s %s/%s", strings.ToLower(string(nameType)), claim.Name, claim.Host, postgres.ErrConflict, other.Kind, other.Namespace, other.Name))
+	}
+
+	// the owner was deleted without releasing the name
+	if err := c.Delete(ctx, existing, client.Preconditions{UID: &existing.UID}); err != nil && !apierrors.IsNotFound(err) {
+		return ctlerrors.NewTemporary(err)
+	}
+	obj.ResourceVersion = ""
+	if err := c.Create(ctx, obj); err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+	return nil
+}
+
+// releaseName deletes the PgNameClaim of claim if it is owned by the custom
+// resource with uid.
+func releaseName(ctx context.Context, c client.Client, nameType api.NameType, claim api.NameClaim, uid types.UID) error {
+	existing := &api.PgNameClaim{}
+	err := c.Get(ctx, types.NamespacedName{Name: nameClaimName(nameType, claim)}, existing)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+	if existing.Spec.Owner.UID != uid {
+		return nil
+	}
+	if err := c.Delete(ctx, existing, client.Preconditions{UID: &existing.UID}); err != nil && !apierrors.IsNotFound(err) {
+		return ctlerrors.NewTemporary(err)
+	}
+	return nil
+}
+
+// nameClaimName returns the name of the PgNameClaim of claim, derived from
+// the host and the name, since names on hosts may contain characters which
+// object names cannot.
+func nameClaimName(nameType api.NameType, claim api.NameClaim) string {
+	sum := sha256.Sum256([]byte(claim.Host + "/" + claim.Name))
+	return fmt.Sprintf("%s-%x", strings.ToLower(string(nameType)), sum[:16])
+}
+
+// claimOwnerExists returns whether the custom resource owner still exists.
+func claimOwnerExists(ctx context.Context, c client.Client, owner api.ClaimOwner) (bool, error) {
+	var obj client.Object
+	switch owner.Kind {
+	case "PgDatabase":
+		obj = &api.PgDatabase{}
+	case "PgRole":
+		obj = &api.PgRole{}
+	case "PgUser":
+		obj = &api.PgUser{}
+	default:
+		return true, nil
+	}
+	err := c.Get(ctx, types.NamespacedName{Namespace: owner.Namespace, Name: owner.Name}, obj)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, ctlerrors.NewTemporary(err)
+	}
+	return obj.GetUID() == owner.UID, nil
+}
+
+// ownerOf returns obj of kind as the owner of a database or role.
+func ownerOf(kind string, obj client.Object) postgres.Owner {
+	return postgres.Owner{
+		Kind:      kind,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		UID:       string(obj.GetUID()),
+	}
+}
+
+// ownershipError returns err as an Invalid error if a database or role is
+// owned by another custom resource or not managed by the operator, and as a
+// Temporary error otherwise.
+func ownershipError(err error) error {
+	if goerrors.Is(err, postgres.ErrConflict) || goerrors.Is(err, postgres.ErrUnmanaged) {
+		return ctlerrors.NewInvalid(err)
+	}
+	return ctlerrors.NewTemporary(err)
+}
+
+// setConflictCondition reports in conditions whether err is caused by a
+// database or role owned by another custom resource or not managed by the
+// operator. Other errors leave the condition unchanged.
+func setConflictCondition(conditions *[]metav1.Condition, generation int64, err error) {
+	condition := metav1.Condition{
+		Type:               api.ConditionConflict,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             "Owned",
+		Message:            "The databases and roles are owned by this resource",
+	}
+	switch {
+	case goerrors.Is(err, postgres.ErrConflict):
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "OwnedByOther"
+		condition.Message = err.Error()
+	case goerrors.Is(err, postgres.ErrUnmanaged):
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "Unmanaged"
+		condition.Message = err.Error()
+	case err != nil:
+		return
+	}
+	meta.SetStatusCondition(conditions, condition)
+}
+
+// planContext returns a context in which the statements of the
+// reconciliation of obj are recorded in the returned plan instead of
+// executed, if dryRun is set or obj has the dry-run annotation. Otherwise ctx
+// and a nil plan are returned.
+func planContext(ctx context.Context, dryRun bool, obj client.Object) (context.Context, *postgres.Plan) {
+	if !dryRun && obj.GetAnnotations()[api.AnnotationDryRun] != "true" {
+		return ctx, nil
+	}
+	plan := &postgres.Plan{}
+	return postgres.WithPlan(ctx, plan), plan
+}
+
+// ownerContext returns a copy of ctx in which clients take over databases and
+// roles stamped with a custom resource which no longer exists, like
+// claimName does with names.
+func ownerContext(ctx context.Context, c client.Client) context.Context {
+	return postgres.WithOwnerExists(ctx, func(owner postgres.Owner) (bool, error) {
+		return claimOwnerExists(ctx, c, api.ClaimOwner{
+			Kind:      owner.Kind,
+			Namespace: owner.Namespace,
+			Name:      owner.Name,
+			UID:       types.UID(owner.UID),
+		})
+	})
+}
+
+// hostContext returns a copy of ctx in which clients rely on the host
+// capabilities recorded in the status of hostCred. ctx is returned as is
+// while they are unknown.
+func hostContext(ctx context.Context, hostCred *api.PgHostCredential) context.Context {
+	host := hostCred.Status.Host
+	if host == nil {
+		return ctx
+	}
+	return postgres.WithHost(ctx, postgres.HostInfo{
+		ServerVersionNum: host.ServerVersionNum,
+		Superuser:        host.Superuser,
+		Replication:      host.Replication,
+		BypassRLS:        host.BypassRLS,
+		ManagedAdminRole: host.ManagedAdminRole,
+		MaxConnections:   host.MaxConnections,
+		Extensions:       host.Extensions,
+	})
+}
+
+// reportsDrift returns whether the reconciliation of an object with policy
+// should only compare the live state with the specification, i.e., whether
+// the generation of the specification was already reconciled.
+func reportsDrift(policy api.DriftPolicy, generation, observedGeneration int64) bool {
+	return policy == api.DriftPolicyReport && generation == observedGeneration
+}
+
+// setDriftedCondition reports in conditions the differences between the live
+// state and the specification of an object with policy. The condition is
+// removed from objects with the Repair drift policy.
+func setDriftedCondition(conditions *[]metav1.Condition, generation int64, policy api.DriftPolicy, diffs []string) {
+	if policy != api.DriftPolicyReport {
+		meta.RemoveStatusCondition(conditions, api.ConditionDrifted)
+		return
+	}
+
+	condition := metav1.Condition{
+		Type:               api.ConditionDrifted,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: generation,
+		Reason:             "InSync",
+		Message:            "The live state matches the specification",
+	}
+	if len(diffs) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "Drifted"
+		condition.Message = strings.Join(diffs, "; ")
+	}
+	meta.SetStatusCondition(conditions, condition)
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/rand"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+)
+
+// createNamespace creates a namespace with a random name, so that the
+// claims of the objects of a test do not collide with the ones of others.
+func createNamespace(ctx context.Context) string {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test-" + rand.String(8)}}
+	Expect(k8sClient.Create(ctx, ns)).To(Succeed())
+	return ns.Name
+}
+
+// createHostCredential creates the PgHostCredential host in namespace. Its
+// host address is unique to the namespace.
+func createHostCredential(ctx context.Context, namespace string) *api.PgHostCredential {
+	hostCred := &api.PgHostCredential{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "host"},
+		Spec: api.PgHostCredentialSpec{
+			Host:     api.ResourceVar{Value: namespace + ".postgres.local"},
+			User:     api.ResourceVar{Value: "admin"},
+			Password: api.ResourceVar{Value: "secret"},
+		},
+	}
+	Expect(k8sClient.Create(ctx, hostCred)).To(Succeed())
+	return hostCred
+}
+
+// reconcileObject runs r for obj and reads obj back. obj is left as is if
+// it was deleted. Failures are reported in the status of obj.
+func reconcileObject(ctx context.Context, r reconcile.Reconciler, obj client.Object) {
+	key := client.ObjectKeyFromObject(obj)
+	_, _ = r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+
+	if err := k8sClient.Get(ctx, key, obj); !apierrors.IsNotFound(err) {
+		Expect(err).NotTo(HaveOccurred())
+	}
+}
+
+// deleteObject deletes obj and runs r to finalize it.
+func deleteObject(ctx context.Context, r reconcile.Reconciler, obj client.Object) {
+	Expect(k8sClient.Delete(ctx, obj)).To(Succeed())
+	reconcileObject(ctx, r, obj)
+
+	err := k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+	Expect(apierrors.IsNotFound(err)).To(BeTrue(), "object still exists: %v", err)
+}
+
+// claimedNames returns the names claimed by the custom resource with uid.
+func claimedNames(ctx context.Context, uid types.UID) []string {
+	var claims api.PgNameClaimList
+	Expect(k8sClient.List(ctx, &claims)).To(Succeed())
+	var names []string
+	for _, claim := range claims.Items {
+		if claim.Spec.Owner.UID == uid {
+			names = append(names, claim.Spec.Name)
+		}
+	}
+	return names
+}
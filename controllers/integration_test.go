@@ -0,0 +1,279 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres"
+	"github.com/jeewangue/postgres-indb-operator/test"
+)
+
+// The integration specs run the reconcilers against a server started from
+// the locally installed PostgreSQL binaries. It is shared by the specs and
+// stopped after the suite. The specs are skipped if it cannot be started.
+var (
+	postgresServer *test.Postgres
+	postgresErr    error
+	postgresOnce   sync.Once
+)
+
+// integrationPostgres returns the shared server or skips the spec.
+func integrationPostgres() *test.Postgres {
+	postgresOnce.Do(func() {
+		postgresServer, postgresErr = test.StartPostgres()
+	})
+	if errors.Is(postgresErr, test.ErrNoPostgres) {
+		Skip(postgresErr.Error())
+	}
+	Expect(postgresErr).NotTo(HaveOccurred())
+	return postgresServer
+}
+
+var _ = Describe("Controllers against PostgreSQL", func() {
+	var (
+		ctx       context.Context
+		pg        *test.Postgres
+		namespace string
+		hostCred  *api.PgHostCredential
+		dbname    string
+		database  *api.PgDatabase
+	)
+
+	// execAs runs sql in the database as user with the password "secret".
+	execAs := func(user, sql string) error {
+		conn, err := pgx.Connect(ctx, pg.UserConnectionString(user, "secret", dbname))
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close(ctx)
+		_, err = conn.Exec(ctx, sql)
+		return err
+	}
+
+	expectPermissionDenied := func(err error) {
+		var pgErr *pgconn.PgError
+		Expect(errors.As(err, &pgErr)).To(BeTrue(), "unexpected error %v", err)
+		Expect(pgErr.Code).To(Equal("42501"), pgErr.Message)
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		pg = integrationPostgres()
+		namespace = createNamespace(ctx)
+
+		hostCred = &api.PgHostCredential{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "host"},
+			Spec: api.PgHostCredentialSpec{
+				Host:     api.ResourceVar{Value: pg.Host},
+				User:     api.ResourceVar{Value: test.PostgresUser},
+				Password: api.ResourceVar{Value: test.PostgresPassword},
+			},
+		}
+		Expect(k8sClient.Create(ctx, hostCred)).To(Succeed())
+
+		dbname = test.Name("db")
+		database = &api.PgDatabase{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "app"},
+			Spec:       api.PgDatabaseSpec{HostCredential: "host", Name: dbname},
+		}
+		Expect(k8sClient.Create(ctx, database)).To(Succeed())
+		reconcileObject(ctx, &PgDatabaseReconciler{Client: k8sClient, Scheme: scheme.Scheme}, database)
+		Expect(database.Status.Phase).To(Equal(api.PhaseAvailable), database.Status.Error)
+
+		db, err := postgres.NewClient(ctx, logr.Discard(), pg.ConnectionString(dbname))
+		Expect(err).NotTo(HaveOccurred())
+		defer db.Close()
+		diffs, err := db.DatabaseDrift(dbname)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diffs).To(BeEmpty())
+
+		conn, err := pgx.Connect(ctx, pg.ConnectionString(dbname))
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close(ctx)
+		_, err = conn.Exec(ctx, "CREATE TABLE accounts (id int)")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("connects with host credentials", func() {
+		reconcileObject(ctx, &PgHostCredentialReconciler{Client: k8sClient, Scheme: scheme.Scheme}, hostCred)
+		Expect(hostCred.Status.Phase).To(Equal(api.PhaseAvailable), hostCred.Status.Error)
+
+		clusterHostCred := &api.ClusterPgHostCredential{
+			ObjectMeta: metav1.ObjectMeta{Name: namespace},
+			Spec: api.ClusterPgHostCredentialSpec{
+				PgHostCredentialSpec: hostCred.Spec,
+				Namespace:            namespace,
+			},
+		}
+		Expect(k8sClient.Create(ctx, clusterHostCred)).To(Succeed())
+		defer k8sClient.Delete(ctx, clusterHostCred)
+		reconcileObject(ctx, &ClusterPgHostCredentialReconciler{Client: k8sClient, Scheme: scheme.Scheme}, clusterHostCred)
+		Expect(clusterHostCred.Status.Phase).To(Equal(api.PhaseAvailable), clusterHostCred.Status.Error)
+	})
+
+	It("grants and revokes the access of users", func() {
+		name := test.Name("user")
+		user := &api.PgUser{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "alice"},
+			Spec: api.PgUserSpec{
+				Name:     api.ResourceVar{Value: name},
+				Password: api.ResourceVar{Value: "secret"},
+				AccessSpecs: &[]api.AccessSpec{{
+					HostCredential: "host",
+					Database:       dbname,
+					Reason:         "testing",
+					Permission:     api.PermReadWrite,
+				}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, user)).To(Succeed())
+		reconciler := &PgUserReconciler{Client: k8sClient, Scheme: scheme.Scheme}
+
+		reconcileObject(ctx, reconciler, user)
+		Expect(user.Status.Phase).To(Equal(api.PhaseAvailable), user.Status.Error)
+		Expect(execAs(name, "INSERT INTO accounts VALUES (1)")).To(Succeed())
+
+		(*user.Spec.AccessSpecs)[0].Permission = api.PermReadOnly
+		Expect(k8sClient.Update(ctx, user)).To(Succeed())
+		reconcileObject(ctx, reconciler, user)
+		Expect(user.Status.Phase).To(Equal(api.PhaseAvailable), user.Status.Error)
+		Expect(execAs(name, "SELECT * FROM accounts")).To(Succeed())
+		expectPermissionDenied(execAs(name, "INSERT INTO accounts VALUES (1)"))
+
+		user.Spec.AccessSpecs = nil
+		Expect(k8sClient.Update(ctx, user)).To(Succeed())
+		reconcileObject(ctx, reconciler, user)
+		Expect(user.Status.Phase).To(Equal(api.PhaseAvailable), user.Status.Error)
+		expectPermissionDenied(execAs(name, "SELECT * FROM accounts"))
+
+		deleteObject(ctx, reconciler, user)
+	})
+
+	It("grants the privileges of roles to their members", func() {
+		roleName := test.Name("role")
+		role := &api.PgRole{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "readers"},
+			Spec: api.PgRoleSpec{
+				HostCredential: "host",
+				Name:           roleName,
+				Privileges:     []api.RolePrivilege{{Database: dbname, Permission: api.PermReadOnly}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, role)).To(Succeed())
+		roleReconciler := &PgRoleReconciler{Client: k8sClient, Scheme: scheme.Scheme}
+		reconcileObject(ctx, roleReconciler, role)
+		Expect(role.Status.Phase).To(Equal(api.PhaseAvailable), role.Status.Error)
+
+		name := test.Name("user")
+		user := &api.PgUser{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "bob"},
+			Spec: api.PgUserSpec{
+				Name:     api.ResourceVar{Value: name},
+				Password: api.ResourceVar{Value: "secret"},
+				Roles:    []string{"readers"},
+			},
+		}
+		Expect(k8sClient.Create(ctx, user)).To(Succeed())
+		reconcileObject(ctx, &PgUserReconciler{Client: k8sClient, Scheme: scheme.Scheme}, user)
+		Expect(user.Status.Phase).To(Equal(api.PhaseAvailable), user.Status.Error)
+
+		Expect(execAs(name, "SELECT * FROM accounts")).To(Succeed())
+		expectPermissionDenied(execAs(name, "INSERT INTO accounts VALUES (1)"))
+
+		deleteObject(ctx, roleReconciler, role)
+	})
+
+	It("grants and revokes privileges on single objects", func() {
+		name := test.Name("user")
+		user := &api.PgUser{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "carol"},
+			Spec: api.PgUserSpec{
+				Name:     api.ResourceVar{Value: name},
+				Password: api.ResourceVar{Value: "secret"},
+				AccessSpecs: &[]api.AccessSpec{{
+					HostCredential: "host",
+					Database:       dbname,
+					Reason:         "testing",
+					Permission:     api.PermCustom,
+					Privileges:     []api.Privilege{"SELECT"},
+				}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, user)).To(Succeed())
+		reconcileObject(ctx, &PgUserReconciler{Client: k8sClient, Scheme: scheme.Scheme}, user)
+		Expect(user.Status.Phase).To(Equal(api.PhaseAvailable), user.Status.Error)
+		expectPermissionDenied(execAs(name, "INSERT INTO accounts VALUES (1)"))
+
+		grant := &api.PgGrant{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "carol-accounts"},
+			Spec: api.PgGrantSpec{
+				Database:   "app",
+				Grantee:    api.GranteeRef{Kind: "PgUser", Name: "carol"},
+				ObjectType: api.GrantObjectTable,
+				Schema:     "public",
+				Objects:    []string{"accounts"},
+				Privileges: []api.Privilege{"INSERT"},
+			},
+		}
+		Expect(k8sClient.Create(ctx, grant)).To(Succeed())
+		reconciler := &PgGrantReconciler{Client: k8sClient, Scheme: scheme.Scheme}
+		reconcileObject(ctx, reconciler, grant)
+		Expect(grant.Status.Phase).To(Equal(api.PhaseAvailable), grant.Status.Error)
+		Expect(execAs(name, "INSERT INTO accounts VALUES (1)")).To(Succeed())
+
+		deleteObject(ctx, reconciler, grant)
+		expectPermissionDenied(execAs(name, "INSERT INTO accounts VALUES (1)"))
+	})
+
+	It("creates and drops publications", func() {
+		publication := &api.PgPublication{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "changes"},
+			Spec: api.PgPublicationSpec{
+				Database: "app",
+				Name:     "changes",
+				Tables:   []string{"accounts"},
+				Publish:  []api.PublishOperation{api.PublishInsert},
+			},
+		}
+		Expect(k8sClient.Create(ctx, publication)).To(Succeed())
+		reconciler := &PgPublicationReconciler{Client: k8sClient, Scheme: scheme.Scheme}
+		reconcileObject(ctx, reconciler, publication)
+		Expect(publication.Status.Phase).To(Equal(api.PhaseAvailable), publication.Status.Error)
+
+		conn, err := pgx.Connect(ctx, pg.ConnectionString(dbname))
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close(ctx)
+		var tables int
+		Expect(conn.QueryRow(ctx, "SELECT count(*) FROM pg_publication_tables WHERE pubname = 'changes'").Scan(&tables)).To(Succeed())
+		Expect(tables).To(Equal(1))
+
+		deleteObject(ctx, reconciler, publication)
+		Expect(conn.QueryRow(ctx, "SELECT count(*) FROM pg_publication WHERE pubname = 'changes'").Scan(&tables)).To(Succeed())
+		Expect(tables).To(BeZero())
+	})
+
+	It("measures the usage of quotas", func() {
+		maxSize := resource.MustParse("1Ti")
+		quota := &api.PgQuota{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "quota"},
+			Spec:       api.PgQuotaSpec{HostCredential: "host", MaxSize: &maxSize},
+		}
+		Expect(k8sClient.Create(ctx, quota)).To(Succeed())
+		reconcileObject(ctx, &PgQuotaReconciler{Client: k8sClient, Scheme: scheme.Scheme}, quota)
+
+		Expect(quota.Status.Phase).To(Equal(api.PhaseAvailable), quota.Status.Error)
+		Expect(quota.Status.Used.Databases).To(Equal(int32(1)))
+		Expect(quota.Status.Used.Size).NotTo(BeNil())
+		Expect(quota.Status.Used.Size.Value()).To(BeNumerically(">", 0))
+	})
+})
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres"
+)
+
+// databaseLabels are the labels of the metrics of PgDatabases.
+var databaseLabels = []string{"namespace", "database"}
+
+var (
+	databaseSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_indb_database_size_bytes",
+		Help: "Disk space used by the database of a PgDatabase.",
+	}, databaseLabels)
+	databaseConnections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_indb_database_connections",
+		Help: "Number of sessions connected to the database of a PgDatabase.",
+	}, databaseLabels)
+	databaseXactCommit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_indb_database_xact_commit",
+		Help: "Number of transactions committed in the database of a PgDatabase since the statistics were reset.",
+	}, databaseLabels)
+	databaseXactRollback = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_indb_database_xact_rollback",
+		Help: "Number of transactions rolled back in the database of a PgDatabase since the statistics were reset.",
+	}, databaseLabels)
+)
+
+// maintenanceLabels are the labels of the metrics of maintenance tasks.
+var maintenanceLabels = []string{"namespace", "maintenance", "task"}
+
+var (
+	maintenanceTaskLastRun = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_indb_maintenance_task_last_run_timestamp_seconds",
+		Help: "Time the last run of a maintenance task started, in seconds since the epoch.",
+	}, maintenanceLabels)
+	maintenanceTaskDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_indb_maintenance_task_duration_seconds",
+		Help: "Duration of the last run of a maintenance task.",
+	}, maintenanceLabels)
+	maintenanceTaskSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "postgres_indb_maintenance_task_success",
+		Help: "Whether the last run of a maintenance task succeeded.",
+	}, maintenanceLabels)
+	maintenanceTaskRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "postgres_indb_maintenance_task_runs_total",
+		Help: "Number of runs of a maintenance task by result.",
+	}, append(maintenanceLabels, "result"))
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		databaseSize,
+		databaseConnections,
+		databaseXactCommit,
+		databaseXactRollback,
+		maintenanceTaskLastRun,
+		maintenanceTaskDuration,
+		maintenanceTaskSuccess,
+		maintenanceTaskRuns,
+	)
+}
+
+// exportDatabaseStats sets the gauges of the PgDatabase namespace/name to
+// stats.
+func exportDatabaseStats(namespace, name string, stats postgres.DatabaseStats) {
+	databaseSize.WithLabelValues(namespace, name).Set(float64(stats.Size))
+	databaseConnections.WithLabelValues(namespace, name).Set(float64(stats.Backends))
+	databaseXactCommit.WithLabelValues(namespace, name).Set(float64(stats.XactCommit))
+	databaseXactRollback.WithLabelValues(namespace, name).Set(float64(stats.XactRollback))
+}
+
+// deleteDatabaseStats deletes the series of the PgDatabase namespace/name.
+func deleteDatabaseStats(namespace, name string) {
+	databaseSize.DeleteLabelValues(namespace, name)
+	databaseConnections.DeleteLabelValues(namespace, name)
+	databaseXactCommit.DeleteLabelValues(namespace, name)
+	databaseXactRollback.DeleteLabelValues(namespace, name)
+}
+
+// exportMaintenanceTask sets the gauges of the last run of a task of the
+// PgMaintenance namespace/name.
+func exportMaintenanceTask(namespace, name string, task api.MaintenanceTaskStatus) {
+	if task.LastRunTime == nil {
+		return
+	}
+	success := 0.0
+	if task.Result == api.MaintenanceSucceeded {
+		success = 1
+	}
+	maintenanceTaskLastRun.WithLabelValues(namespace, name, task.Name).Set(float64(task.LastRunTime.Unix()))
+	if task.Duration != nil {
+		maintenanceTaskDuration.WithLabelValues(namespace, name, task.Name).Set(task.Duration.Seconds())
+	}
+	maintenanceTaskSuccess.WithLabelValues(namespace, name, task.Name).Set(success)
+}
+
+// deleteMaintenanceTask deletes the series of a task of the PgMaintenance
+// namespace/name.
+func deleteMaintenanceTask(namespace, name, task string) {
+	maintenanceTaskLastRun.DeleteLabelValues(namespace, name, task)
+	maintenanceTaskDuration.DeleteLabelValues(namespace, name, task)
+	maintenanceTaskSuccess.DeleteLabelValues(namespace, name, task)
+	for _, result := range []api.MaintenanceResult{api.MaintenanceSucceeded, api.MaintenanceFailed, api.MaintenanceTimedOut} {
+		maintenanceTaskRuns.DeleteLabelValues(namespace, name, task, string(result))
+	}
+}
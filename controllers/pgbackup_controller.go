@@ -0,0 +1,400 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/strings/slices"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/go-logr/logr"
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	apiutil "github.com/jeewangue/postgres-indb-operator/api/v1alpha1/util"
+	ctlerrors "github.com/jeewangue/postgres-indb-operator/internal/errors"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres"
+)
+
+// errJobFailed is returned if the Job of a PgBackup failed. Failed Jobs are
+// not retried.
+var errJobFailed = goerrors.New("job failed")
+
+// PgBackupReconciler reconciles a PgBackup object
+type PgBackupReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Images are the images of the backup Jobs.
+	Images BackupImages
+
+	// Connector opens the clients of the hosts. Defaults to
+	// postgres.NewClient.
+	Connector postgres.Connector
+
+	logger logr.Logger
+	backup *api.PgBackup
+	// running is set if the reconciliation waits for a Job
+	running bool
+}
+
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgbackups,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgbackups/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgbackups/finalizers,verbs=update
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=create;update;patch;delete
+
+// Reconcile takes the backup described by a PgBackup with a Job running
+// pg_dump and records its size, duration and checksum once the Job
+// completed. The backup file is deleted by another Job once the PgBackup is
+// deleted, unless its deletion policy is Retain.
+func (r *PgBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.logger = setupLogger(ctx)
+	r.logger.Info("Reconciling PgBackup")
+	r.backup = nil
+	r.running = false
+
+	result, err := r.handleResult(r.reconcile(ctx, req))
+	r.logger.Info("Finished reconciling PgBackup")
+	return result, err
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PgBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&api.PgBackup{}).
+		Owns(&batchv1.Job{}).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: 1,
+			RateLimiter:             DefaultControllerRateLimiter(),
+		}).
+		Complete(r)
+}
+
+func (r *PgBackupReconciler) reconcile(ctx context.Context, req reconcile.Request) error {
+	// Fetch the PgBackup instance
+	backup := &api.PgBackup{}
+	{
+		err := r.Client.Get(ctx, req.NamespacedName, backup)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				r.logger.Info("Object not found")
+				return nil
+			}
+			// Error reading the object - requeue the request.
+			return ctlerrors.NewTemporary(err)
+		}
+
+		r.backup = backup
+	}
+
+	r.logger = r.logger.WithValues("backup", backup.Name)
+	r.logger.Info("Reconciling found PgBackup resource")
+
+	if backup.GetDeletionTimestamp() != nil {
+		if !slices.Contains(backup.Finalizers, operatorFinalizer) {
+			return nil
+		}
+		if err := r.finalize(ctx, backup); err != nil || r.running {
+			return err
+		}
+
+		controllerutil.RemoveFinalizer(backup, operatorFinalizer)
+		if err := r.Update(ctx, backup); err != nil {
+			return ctlerrors.NewTemporary(err)
+		}
+
+		return nil
+	}
+
+	// Add finalizer for this CR
+	if !slices.Contains(backup.Finalizers, operatorFinalizer) {
+		controllerutil.AddFinalizer(backup, operatorFinalizer)
+
+		if err := r.Update(ctx, backup); err != nil {
+			return ctlerrors.NewTemporary(err)
+		}
+	}
+
+	if backup.Status.CompletionTime != nil {
+		// backups are taken once
+		return nil
+	}
+
+	if err := validateBackupStorage(backup.Spec.Storage); err != nil {
+		return ctlerrors.NewInvalid(err)
+	}
+
+	job := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: backup.Namespace, Name: backup.Name + "-dump"}, job)
+	switch {
+	case errors.IsNotFound(err) && backup.Status.JobName != "":
+		r.revokeCredentials(ctx, backup)
+		return fmt.Errorf("%w: job %s was deleted before it completed", errJobFailed, backup.Status.JobName)
+	case errors.IsNotFound(err):
+		job, err = r.startDump(ctx, backup)
+		if err != nil {
+			return err
+		}
+	case err != nil:
+		return ctlerrors.NewTemporary(err)
+	}
+
+	backup.Status.JobName = job.Name
+	backup.Status.Location = backupLocation(backup)
+	backup.Status.StartTime = job.Status.StartTime
+
+	finished, failed, message := jobFinished(job)
+	switch {
+	case !finished:
+		r.running = true
+		return nil
+	case failed:
+		r.revokeCredentials(ctx, backup)
+		return fmt.Errorf("%w: job %s: %s", errJobFailed, job.Name, message)
+	}
+
+	terminationMessage, err := jobTerminationMessage(ctx, r.Client, job)
+	if err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+	result, err := parseBackupResult(terminationMessage)
+	if err != nil {
+		return ctlerrors.NewInvalid(err)
+	}
+
+	backup.Status.Size = resource.NewQuantity(result.Size, resource.BinarySI)
+	backup.Status.Checksum = result.Checksum
+	backup.Status.CompletionTime = job.Status.CompletionTime
+	if backup.Status.CompletionTime == nil {
+		now := metav1.Now()
+		backup.Status.CompletionTime = &now
+	}
+	if backup.Status.StartTime != nil {
+		backup.Status.Duration = &metav1.Duration{Duration: backup.Status.CompletionTime.Sub(backup.Status.StartTime.Time)}
+	}
+	r.revokeCredentials(ctx, backup)
+
+	r.logger.Info("Successfully took backup", "location", backup.Status.Location, "size", result.Size)
+	return nil
+}
+
+// startDump creates the login role of the Job dumping the database of
+// backup, the Secret with its connection string and the Job.
+func (r *PgBackupReconciler) startDump(ctx context.Context, backup *api.PgBackup) (*batchv1.Job, error) {
+	hostCred, dbname, err := r.backupDatabase(backup)
+	if err != nil {
+		return nil, err
+	}
+
+	connStr, err := apiutil.GetConnectionString(hostCred, r.Client)
+	if err != nil {
+		r.logger.Error(err, "Failed to get connection string from the database. Skipping '"+hostCred.Name+"'")
+		return nil, ctlerrors.NewTemporary(err)
+	}
+	db, err := newClient(ctx, r.logger, r.Connector, connStr)
+	if err != nil {
+		r.logger.Error(err, "Failed to open database connection")
+		return nil, ctlerrors.NewTemporary(err)
+	}
+	defer db.Close()
+
+	user := jobUserName(backup.UID)
+	password, err := createJobUser(db, dbname, user, ownerOf("PgBackup", backup))
+	if err != nil {
+		return nil, ownershipError(err)
+	}
+	jobConnStr, err := loginConnectionString(r.Client, hostCred, dbname, user, password)
+	if err != nil {
+		return nil, ctlerrors.NewTemporary(err)
+	}
+
+	secret := backupSecret(backup, jobConnStr)
+	if err := controllerutil.SetControllerReference(backup, secret, r.Scheme); err != nil {
+		return nil, ctlerrors.NewTemporary(err)
+	}
+	if err := applySecret(ctx, r.Client, secret); err != nil {
+		return nil, ctlerrors.NewTemporary(err)
+	}
+
+	job := dumpJob(backup, dbname+"_owner", r.Images)
+	if err := controllerutil.SetControllerReference(backup, job, r.Scheme); err != nil {
+		return nil, ctlerrors.NewTemporary(err)
+	}
+	if err := r.Create(ctx, job); err != nil {
+		return nil, ctlerrors.NewTemporary(err)
+	}
+
+	r.logger.Info("Started backup job", "job", job.Name)
+	return job, nil
+}
+
+// backupDatabase returns the host credential and the name of the database
+// of backup.
+func (r *PgBackupReconciler) backupDatabase(backup *api.PgBackup) (*api.PgHostCredential, string, error) {
+	database, err := apiutil.PgDatabaseByName(r.Client, backup.Namespace, backup.Spec.Database)
+	if err != nil {
+		r.logger.Error(err, "Failed to get database from the backup. Skipping '"+backup.Spec.Database+"'")
+		return nil, "", ctlerrors.NewTemporary(err)
+	}
+
+	hostCred, err := apiutil.PgHostCredentialByName(r.Client, backup.Namespace, database.Spec.HostCredential)
+	if err != nil {
+		r.logger.Error(err, "Failed to get host credential from the database. Skipping '"+database.Spec.HostCredential+"'")
+		return nil, "", ctlerrors.NewTemporary(err)
+	}
+
+	dbname, err := apiutil.DatabaseName(r.Client, hostCred, database.Namespace, database.Spec.Name)
+	if err != nil {
+		return nil, "", err
+	}
+	return hostCred, dbname, nil
+}
+
+// revokeCredentials deletes the Secret and drops the login role of the Job
+// of backup once it is done. A role which cannot be dropped expires after
+// jobUserLifetime.
+func (r *PgBackupReconciler) revokeCredentials(ctx context.Context, backup *api.PgBackup) {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: backup.Namespace, Name: backupSecretName(backup)}}
+	if err := r.Delete(ctx, secret); err != nil && !errors.IsNotFound(err) {
+		r.logger.Error(err, "Failed to delete the connection secret of the backup job")
+	}
+
+	hostCred, _, err := r.backupDatabase(backup)
+	if err != nil {
+		return
+	}
+	connStr, err := apiutil.GetConnectionString(hostCred, r.Client)
+	if err != nil {
+		r.logger.Error(err, "Failed to get connection string from the database. Skipping '"+hostCred.Name+"'")
+		return
+	}
+	db, err := newClient(ctx, r.logger, r.Connector, connStr)
+	if err != nil {
+		r.logger.Error(err, "Failed to open database connection")
+		return
+	}
+	defer db.Close()
+	if err := db.DropUser(jobUserName(backup.UID)); err != nil {
+		r.logger.Error(err, "Failed to drop the login role of the backup job")
+	}
+}
+
+// finalize revokes the credentials of an unfinished dump and deletes the
+// file of backup with a Job. It sets r.running while the Job runs.
+func (r *PgBackupReconciler) finalize(ctx context.Context, backup *api.PgBackup) error {
+	if backup.Status.JobName != "" && backup.Status.CompletionTime == nil {
+		r.revokeCredentials(ctx, backup)
+	}
+	if backup.Spec.DeletionPolicy == api.BackupDeletionPolicyRetain || backup.Status.JobName == "" {
+		r.logger.Info("Successfully finalized PgBackup")
+		return nil
+	}
+
+	// stop a running dump before deleting its file
+	dump := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Namespace: backup.Namespace, Name: backup.Status.JobName}}
+	if err := r.Delete(ctx, dump, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+		return ctlerrors.NewTemporary(err)
+	}
+
+	job := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: backup.Namespace, Name: backup.Name + "-cleanup"}, job)
+	switch {
+	case errors.IsNotFound(err):
+		job = cleanupJob(backup, r.Images)
+		if err := controllerutil.SetControllerReference(backup, job, r.Scheme); err != nil {
+			return ctlerrors.NewTemporary(err)
+		}
+		if err := r.Create(ctx, job); err != nil {
+			return ctlerrors.NewTemporary(err)
+		}
+		r.logger.Info("Started cleanup job", "job", job.Name)
+		r.running = true
+		return nil
+	case err != nil:
+		return ctlerrors.NewTemporary(err)
+	}
+
+	finished, failed, message := jobFinished(job)
+	switch {
+	case !finished:
+		r.running = true
+		return nil
+	case failed:
+		return fmt.Errorf("%w: job %s: %s; set the deletion policy to Retain to keep the file", errJobFailed, job.Name, message)
+	}
+
+	r.logger.Info("Successfully finalized PgBackup")
+	return nil
+}
+
+func (r *PgBackupReconciler) handleResult(err error) (ctrl.Result, error) {
+	var phase api.Phase
+	var errorMessage string
+
+	switch {
+	case err == nil && r.running:
+		phase = api.PhasePending
+		errorMessage = ""
+	case err == nil:
+		phase = api.PhaseAvailable
+		errorMessage = ""
+	case goerrors.Is(err, errJobFailed):
+		// the Job is not retried
+		phase = api.PhaseFailed
+		errorMessage = err.Error()
+		err = nil
+	case ctlerrors.IsTemporary(err):
+		phase = api.PhaseFailed
+		errorMessage = err.Error()
+	case ctlerrors.IsInvalid(err):
+		phase = api.PhaseInvalid
+		errorMessage = err.Error()
+	default:
+		phase = api.PhaseInvalid
+		errorMessage = err.Error()
+	}
+
+	if r.backup == nil {
+		return ctrl.Result{}, err
+	}
+
+	r.backup.Status.Phase = phase
+	r.backup.Status.PhaseUpdated = metav1.Now()
+	r.backup.Status.Error = errorMessage
+
+	if err := r.Status().Update(context.Background(), r.backup); err != nil {
+		r.logger.Error(err, "Failed to update the status")
+	}
+
+	isRequeue := (phase == api.PhaseFailed && err != nil)
+
+	return ctrl.Result{Requeue: isRequeue}, err
+}
@@ -0,0 +1,370 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres/fake"
+)
+
+// finishJob marks the Job name as completed or failed, as the Job
+// controller would. A completed Job gets a succeeded pod terminated with
+// message.
+func finishJob(ctx context.Context, namespace, name string, failed bool, message string) {
+	job := &batchv1.Job{}
+	Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, job)).To(Succeed())
+
+	start := metav1.NewTime(time.Now().Add(-90 * time.Second).Truncate(time.Second))
+	end := metav1.NewTime(start.Add(time.Minute))
+	job.Status.StartTime = &start
+	condition := batchv1.JobCondition{Type: batchv1.JobComplete, Status: corev1.ConditionTrue, LastTransitionTime: end}
+	if failed {
+		condition = batchv1.JobCondition{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, LastTransitionTime: end, Message: message}
+		job.Status.Failed = 1
+	} else {
+		job.Status.CompletionTime = &end
+		job.Status.Succeeded = 1
+	}
+	job.Status.Conditions = append(job.Status.Conditions, condition)
+	Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
+	if failed {
+		return
+	}
+
+	containers := job.Spec.Template.Spec.Containers
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name + "-pod",
+			Labels:    map[string]string{"job-name": name},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Containers:    []corev1.Container{{Name: containers[len(containers)-1].Name, Image: containers[len(containers)-1].Image}},
+		},
+	}
+	Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+	pod.Status.Phase = corev1.PodSucceeded
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:  pod.Spec.Containers[0].Name,
+		Image: pod.Spec.Containers[0].Image,
+		State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 0, Message: message}},
+	}}
+	Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+}
+
+// envValue returns the value of the variable name in container.
+func envValue(container corev1.Container, name string) string {
+	for _, env := range container.Env {
+		if env.Name == name {
+			return env.Value
+		}
+	}
+	return ""
+}
+
+var _ = Describe("PgBackup controller", func() {
+	var (
+		ctx        context.Context
+		server     *fake.Server
+		reconciler *PgBackupReconciler
+		namespace  string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		server = fake.NewServer("admin")
+		reconciler = &PgBackupReconciler{Client: k8sClient, Scheme: scheme.Scheme, Connector: server.Connector()}
+		namespace = createNamespace(ctx)
+		createHostCredential(ctx, namespace)
+
+		database := &api.PgDatabase{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "app"},
+			Spec:       api.PgDatabaseSpec{HostCredential: "host", Name: "app"},
+		}
+		Expect(k8sClient.Create(ctx, database)).To(Succeed())
+		databaseReconciler := &PgDatabaseReconciler{Client: k8sClient, Scheme: scheme.Scheme, Connector: server.Connector()}
+		reconcileObject(ctx, databaseReconciler, database)
+		Expect(database.Status.Phase).To(Equal(api.PhaseAvailable), database.Status.Error)
+	})
+
+	It("dumps to a volume, records the result and deletes the file", func() {
+		backup := &api.PgBackup{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "nightly"},
+			Spec: api.PgBackupSpec{
+				Database: "app",
+				Storage: api.BackupStorage{
+					PersistentVolumeClaim: &api.PVCBackupStorage{ClaimName: "backups", Path: "app"},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, backup)).To(Succeed())
+
+		By("starting the dump")
+		reconcileObject(ctx, reconciler, backup)
+		Expect(backup.Status.Phase).To(Equal(api.PhasePending), backup.Status.Error)
+		Expect(backup.Status.JobName).To(Equal("nightly-dump"))
+		Expect(backup.Status.Location).To(Equal("app/" + namespace + "/nightly.dump"))
+
+		job := &batchv1.Job{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "nightly-dump"}, job)).To(Succeed())
+		Expect(job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("backups"))
+		dump := job.Spec.Template.Spec.Containers[0]
+		Expect(dump.Image).To(Equal(DefaultBackupImage))
+		Expect(envValue(dump, "BACKUP_FILE")).To(Equal("/backup/app/" + namespace + "/nightly.dump"))
+		Expect(envValue(dump, "ROLE")).To(Equal("app_owner"))
+
+		By("connecting with a short-lived login role instead of the admin")
+		user := jobUserName(backup.UID)
+		Expect(server.CanLogin(user)).To(BeTrue())
+		Expect(server.IsMember("app_owner", user)).To(BeTrue())
+		Expect(*server.Attributes(user).ValidUntil).To(BeTemporally("~", time.Now().Add(jobUserLifetime), time.Minute))
+		secret := &corev1.Secret{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "nightly-pgbackup"}, secret)).To(Succeed())
+		Expect(string(secret.Data[databaseURLKey])).To(HavePrefix("postgresql://" + user + ":" + server.Password(user) + "@" + namespace + ".postgres.local/app"))
+
+		By("recording the result of the completed job")
+		finishJob(ctx, namespace, "nightly-dump", false, `{"size":2048,"checksum":"sha256:abc"}`)
+		reconcileObject(ctx, reconciler, backup)
+		Expect(backup.Status.Phase).To(Equal(api.PhaseAvailable), backup.Status.Error)
+		Expect(backup.Status.Size.Value()).To(Equal(int64(2048)))
+		Expect(backup.Status.Checksum).To(Equal("sha256:abc"))
+		Expect(backup.Status.Duration.Duration).To(Equal(time.Minute))
+		err := k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "nightly-pgbackup"}, secret)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue(), "connection secret still exists: %v", err)
+		Expect(server.RoleExists(user)).To(BeFalse())
+
+		By("deleting the file with a job")
+		Expect(k8sClient.Delete(ctx, backup)).To(Succeed())
+		reconcileObject(ctx, reconciler, backup)
+		Expect(backup.Status.Phase).To(Equal(api.PhasePending), backup.Status.Error)
+		cleanup := &batchv1.Job{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "nightly-cleanup"}, cleanup)).To(Succeed())
+		Expect(envValue(cleanup.Spec.Template.Spec.Containers[0], "BACKUP_FILE")).To(Equal("/backup/app/" + namespace + "/nightly.dump"))
+
+		finishJob(ctx, namespace, "nightly-cleanup", false, "")
+		reconcileObject(ctx, reconciler, backup)
+		err = k8sClient.Get(ctx, client.ObjectKeyFromObject(backup), backup)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue(), "object still exists: %v", err)
+	})
+
+	It("uploads to S3-compatible storages", func() {
+		backup := &api.PgBackup{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "nightly"},
+			Spec: api.PgBackupSpec{
+				Database: "app",
+				Storage: api.BackupStorage{
+					S3: &api.S3BackupStorage{
+						Endpoint:          "http://minio.storage:9000",
+						Bucket:            "backups",
+						Prefix:            "postgres/",
+						CredentialsSecret: "minio",
+					},
+				},
+				DeletionPolicy: api.BackupDeletionPolicyRetain,
+			},
+		}
+		Expect(k8sClient.Create(ctx, backup)).To(Succeed())
+
+		reconcileObject(ctx, reconciler, backup)
+		Expect(backup.Status.Phase).To(Equal(api.PhasePending), backup.Status.Error)
+		Expect(backup.Status.Location).To(Equal("s3://backups/postgres/" + namespace + "/nightly.dump"))
+
+		job := &batchv1.Job{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "nightly-dump"}, job)).To(Succeed())
+		pod := job.Spec.Template.Spec
+		Expect(pod.Volumes[0].EmptyDir).NotTo(BeNil())
+		Expect(pod.InitContainers).To(HaveLen(1))
+		upload := pod.Containers[0]
+		Expect(upload.Image).To(Equal(DefaultBackupUploadImage))
+		Expect(envValue(upload, "S3_ENDPOINT")).To(Equal("http://minio.storage:9000"))
+		Expect(envValue(upload, "BACKUP_URL")).To(Equal(backup.Status.Location))
+		Expect(upload.EnvFrom[0].SecretRef.Name).To(Equal("minio"))
+
+		By("keeping the object of retained backups")
+		finishJob(ctx, namespace, "nightly-dump", false, `{"size":1,"checksum":"sha256:abc"}`)
+		reconcileObject(ctx, reconciler, backup)
+		Expect(backup.Status.Phase).To(Equal(api.PhaseAvailable), backup.Status.Error)
+		deleteObject(ctx, reconciler, backup)
+		err := k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "nightly-cleanup"}, job)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue(), "cleanup job exists: %v", err)
+	})
+
+	It("reports failed jobs without retrying them", func() {
+		backup := &api.PgBackup{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "nightly"},
+			Spec: api.PgBackupSpec{
+				Database: "app",
+				Storage: api.BackupStorage{
+					PersistentVolumeClaim: &api.PVCBackupStorage{ClaimName: "backups"},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, backup)).To(Succeed())
+		reconcileObject(ctx, reconciler, backup)
+
+		finishJob(ctx, namespace, "nightly-dump", true, "BackoffLimitExceeded")
+		result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(backup)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Requeue).To(BeFalse())
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(backup), backup)).To(Succeed())
+		Expect(backup.Status.Phase).To(Equal(api.PhaseFailed))
+		Expect(backup.Status.Error).To(ContainSubstring("BackoffLimitExceeded"))
+		Expect(server.RoleExists(jobUserName(backup.UID))).To(BeFalse())
+	})
+
+	It("patches the connection secret left by an earlier attempt", func() {
+		backup := &api.PgBackup{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "nightly"},
+			Spec: api.PgBackupSpec{
+				Database: "app",
+				Storage: api.BackupStorage{
+					PersistentVolumeClaim: &api.PVCBackupStorage{ClaimName: "backups"},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, backup)).To(Succeed())
+		stale := connectionSecret(namespace, "nightly-pgbackup", "postgresql://stale")
+		Expect(k8sClient.Create(ctx, stale)).To(Succeed())
+
+		reconcileObject(ctx, reconciler, backup)
+		Expect(backup.Status.Phase).To(Equal(api.PhasePending), backup.Status.Error)
+		secret := &corev1.Secret{}
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(stale), secret)).To(Succeed())
+		Expect(string(secret.Data[databaseURLKey])).To(HavePrefix("postgresql://" + jobUserName(backup.UID) + ":"))
+	})
+
+	It("rejects backups without storage", func() {
+		backup := &api.PgBackup{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "nightly"},
+			Spec:       api.PgBackupSpec{Database: "app"},
+		}
+		Expect(k8sClient.Create(ctx, backup)).To(Succeed())
+		reconcileObject(ctx, reconciler, backup)
+		Expect(backup.Status.Phase).To(Equal(api.PhaseInvalid))
+	})
+})
+
+var _ = Describe("PgBackupSchedule controller", func() {
+	var (
+		ctx        context.Context
+		now        time.Time
+		reconciler *PgBackupScheduleReconciler
+		namespace  string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		reconciler = &PgBackupScheduleReconciler{
+			Client: k8sClient,
+			Scheme: scheme.Scheme,
+			now:    func() time.Time { return now },
+		}
+		namespace = createNamespace(ctx)
+	})
+
+	It("creates backups on schedule and deletes the ones not retained", func() {
+		schedule := &api.PgBackupSchedule{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "hourly"},
+			Spec: api.PgBackupScheduleSpec{
+				Schedule: "0 * * * *",
+				PgBackupSpec: api.PgBackupSpec{
+					Database: "app",
+					Storage: api.BackupStorage{
+						PersistentVolumeClaim: &api.PVCBackupStorage{ClaimName: "backups"},
+					},
+				},
+				Retention: api.BackupRetention{KeepLast: 2},
+			},
+		}
+		Expect(k8sClient.Create(ctx, schedule)).To(Succeed())
+		created := schedule.CreationTimestamp.Time
+		hour := created.Truncate(time.Hour)
+
+		By("waiting for the first scheduled time")
+		now = created
+		reconcileObject(ctx, reconciler, schedule)
+		Expect(schedule.Status.Phase).To(Equal(api.PhaseAvailable), schedule.Status.Error)
+		Expect(schedule.Status.LastBackup).To(BeEmpty())
+		Expect(schedule.Status.NextScheduleTime.Time).To(BeTemporally("==", hour.Add(time.Hour)))
+
+		backups := func() []api.PgBackup {
+			var list api.PgBackupList
+			Expect(k8sClient.List(ctx, &list, client.InNamespace(namespace))).To(Succeed())
+			return list.Items
+		}
+		complete := func(name string) {
+			backup := &api.PgBackup{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, backup)).To(Succeed())
+			completion := metav1.Now()
+			backup.Status.CompletionTime = &completion
+			backup.Status.Phase = api.PhaseAvailable
+			backup.Status.PhaseUpdated = completion
+			Expect(k8sClient.Status().Update(ctx, backup)).To(Succeed())
+		}
+
+		By("creating a backup per scheduled time")
+		var names []string
+		for i := 1; i <= 3; i++ {
+			now = hour.Add(time.Duration(i)*time.Hour + time.Minute)
+			reconcileObject(ctx, reconciler, schedule)
+			Expect(schedule.Status.Phase).To(Equal(api.PhaseAvailable), schedule.Status.Error)
+			Expect(schedule.Status.LastScheduleTime.Time).To(BeTemporally("==", hour.Add(time.Duration(i)*time.Hour)))
+			Expect(schedule.Status.LastBackup).NotTo(BeEmpty())
+			names = append(names, schedule.Status.LastBackup)
+			complete(schedule.Status.LastBackup)
+			// creation timestamps have a resolution of seconds
+			time.Sleep(time.Second)
+		}
+		Expect(backups()).To(HaveLen(3))
+		Expect(backups()[0].Labels).To(HaveKeyWithValue(api.LabelBackupSchedule, "hourly"))
+		Expect(backups()[0].Spec.Storage.PersistentVolumeClaim.ClaimName).To(Equal("backups"))
+
+		By("deleting the oldest backup")
+		reconcileObject(ctx, reconciler, schedule)
+		var remaining []string
+		for _, backup := range backups() {
+			if backup.GetDeletionTimestamp() == nil {
+				remaining = append(remaining, backup.Name)
+			}
+		}
+		Expect(remaining).To(ConsistOf(names[1], names[2]))
+
+		By("catching up on the latest missed time only")
+		now = hour.Add(10*time.Hour + time.Minute)
+		reconcileObject(ctx, reconciler, schedule)
+		Expect(schedule.Status.LastScheduleTime.Time).To(BeTemporally("==", hour.Add(10*time.Hour)))
+		Expect(backups()).To(HaveLen(3))
+	})
+
+	It("rejects invalid schedules", func() {
+		schedule := &api.PgBackupSchedule{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "broken"},
+			Spec: api.PgBackupScheduleSpec{
+				Schedule: "every day",
+				PgBackupSpec: api.PgBackupSpec{
+					Database: "app",
+					Storage: api.BackupStorage{
+						PersistentVolumeClaim: &api.PVCBackupStorage{ClaimName: "backups"},
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, schedule)).To(Succeed())
+		reconcileObject(ctx, reconciler, schedule)
+		Expect(schedule.Status.Phase).To(Equal(api.PhaseInvalid))
+	})
+})
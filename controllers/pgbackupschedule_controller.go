@@ -0,0 +1,260 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/go-logr/logr"
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	ctlerrors "github.com/jeewangue/postgres-indb-operator/internal/errors"
+)
+
+// PgBackupScheduleReconciler reconciles a PgBackupSchedule object
+type PgBackupScheduleReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// now returns the current time. Defaults to time.Now.
+	now func() time.Time
+
+	logger   logr.Logger
+	schedule *api.PgBackupSchedule
+	// requeueAfter is the time until the next backup is scheduled
+	requeueAfter time.Duration
+}
+
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgbackupschedules,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgbackupschedules/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgbackupschedules/finalizers,verbs=update
+
+// Reconcile creates a PgBackup if a scheduled time of a PgBackupSchedule
+// passed since the last backup and deletes the backups no longer retained.
+// Only the latest of several missed times is caught up on.
+func (r *PgBackupScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.logger = setupLogger(ctx)
+	r.logger.Info("Reconciling PgBackupSchedule")
+	r.schedule = nil
+	r.requeueAfter = 0
+
+	result, err := r.handleResult(r.reconcile(ctx, req))
+	r.logger.Info("Finished reconciling PgBackupSchedule")
+	return result, err
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PgBackupScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&api.PgBackupSchedule{}).
+		Watches(&source.Kind{Type: &api.PgBackup{}}, handler.EnqueueRequestsFromMapFunc(backupSchedule)).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: 1,
+			RateLimiter:             DefaultControllerRateLimiter(),
+		}).
+		Complete(r)
+}
+
+// backupSchedule maps a PgBackup to the PgBackupSchedule which created it,
+// so that retention applies once it completes.
+func backupSchedule(obj client.Object) []reconcile.Request {
+	name, ok := obj.GetLabels()[api.LabelBackupSchedule]
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: name},
+	}}
+}
+
+func (r *PgBackupScheduleReconciler) reconcile(ctx context.Context, req reconcile.Request) error {
+	// Fetch the PgBackupSchedule instance
+	schedule := &api.PgBackupSchedule{}
+	{
+		err := r.Client.Get(ctx, req.NamespacedName, schedule)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				r.logger.Info("Object not found")
+				return nil
+			}
+			// Error reading the object - requeue the request.
+			return ctlerrors.NewTemporary(err)
+		}
+
+		r.schedule = schedule
+	}
+
+	r.logger = r.logger.WithValues("schedule", schedule.Name)
+	r.logger.Info("Reconciling found PgBackupSchedule resource")
+
+	if schedule.GetDeletionTimestamp() != nil {
+		return nil
+	}
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	if err != nil {
+		return ctlerrors.NewInvalid(fmt.Errorf("invalid schedule %q: %w", schedule.Spec.Schedule, err))
+	}
+	if err := validateBackupStorage(schedule.Spec.Storage); err != nil {
+		return ctlerrors.NewInvalid(err)
+	}
+
+	now := time.Now()
+	if r.now != nil {
+		now = r.now()
+	}
+
+	last := schedule.CreationTimestamp.Time
+	if schedule.Status.LastScheduleTime != nil {
+		last = schedule.Status.LastScheduleTime.Time
+	}
+	missed, next := scheduledTimes(cronSchedule, last, now)
+
+	if !missed.IsZero() && !schedule.Spec.Suspend {
+		backup, err := r.createBackup(ctx, schedule, missed)
+		if err != nil {
+			return err
+		}
+		schedule.Status.LastBackup = backup.Name
+	}
+	if !missed.IsZero() {
+		schedule.Status.LastScheduleTime = &metav1.Time{Time: missed}
+	}
+	schedule.Status.NextScheduleTime = &metav1.Time{Time: next}
+	r.requeueAfter = next.Sub(now)
+
+	return r.applyRetention(ctx, schedule)
+}
+
+// createBackup creates the PgBackup of schedule for the scheduled time t.
+func (r *PgBackupScheduleReconciler) createBackup(ctx context.Context, schedule *api.PgBackupSchedule, t time.Time) (*api.PgBackup, error) {
+	backup := &api.PgBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: schedule.Namespace,
+			// unique per scheduled minute, so that a time is not backed up twice
+			Name:   fmt.Sprintf("%s-%d", schedule.Name, t.Unix()/60),
+			Labels: map[string]string{api.LabelBackupSchedule: schedule.Name},
+		},
+		Spec: schedule.Spec.PgBackupSpec,
+	}
+	if err := r.Create(ctx, backup); err != nil && !errors.IsAlreadyExists(err) {
+		return nil, ctlerrors.NewTemporary(err)
+	}
+
+	r.logger.Info("Created scheduled backup", "backup", backup.Name, "scheduled", t)
+	return backup, nil
+}
+
+// applyRetention deletes the completed backups of schedule beyond the
+// number kept, and the failed backups older than the latest completed one.
+func (r *PgBackupScheduleReconciler) applyRetention(ctx context.Context, schedule *api.PgBackupSchedule) error {
+	var backups api.PgBackupList
+	err := r.List(ctx, &backups, client.InNamespace(schedule.Namespace), client.MatchingLabels{api.LabelBackupSchedule: schedule.Name})
+	if err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+
+	// newest first
+	items := backups.Items
+	sort.Slice(items, func(i, j int) bool {
+		return items[j].CreationTimestamp.Before(&items[i].CreationTimestamp)
+	})
+
+	keepLast := int(schedule.Spec.Retention.KeepLast)
+	if keepLast < 1 {
+		keepLast = 7
+	}
+	completed := 0
+	for i := range items {
+		backup := &items[i]
+		if backup.GetDeletionTimestamp() != nil {
+			continue
+		}
+
+		expired := false
+		switch {
+		case backup.Status.CompletionTime != nil:
+			completed++
+			expired = completed > keepLast
+		case backup.Status.Phase == api.PhaseFailed:
+			expired = completed > 0
+		}
+		if !expired {
+			continue
+		}
+
+		if err := r.Delete(ctx, backup); err != nil && !errors.IsNotFound(err) {
+			return ctlerrors.NewTemporary(err)
+		}
+		r.logger.Info("Deleted backup no longer retained", "backup", backup.Name)
+	}
+
+	return nil
+}
+
+func (r *PgBackupScheduleReconciler) handleResult(err error) (ctrl.Result, error) {
+	var phase api.Phase
+	var errorMessage string
+
+	switch {
+	case err == nil:
+		phase = api.PhaseAvailable
+		errorMessage = ""
+	case ctlerrors.IsTemporary(err):
+		phase = api.PhaseFailed
+		errorMessage = err.Error()
+	case ctlerrors.IsInvalid(err):
+		phase = api.PhaseInvalid
+		errorMessage = err.Error()
+	default:
+		phase = api.PhaseInvalid
+		errorMessage = err.Error()
+	}
+
+	if r.schedule == nil {
+		return ctrl.Result{}, err
+	}
+
+	r.schedule.Status.Phase = phase
+	r.schedule.Status.PhaseUpdated = metav1.Now()
+	r.schedule.Status.Error = errorMessage
+
+	if err := r.Status().Update(context.Background(), r.schedule); err != nil {
+		r.logger.Error(err, "Failed to update the status")
+	}
+
+	isRequeue := (phase == api.PhaseFailed)
+	if phase == api.PhaseAvailable && r.requeueAfter > 0 {
+		return ctrl.Result{RequeueAfter: r.requeueAfter}, err
+	}
+
+	return ctrl.Result{Requeue: isRequeue}, err
+}
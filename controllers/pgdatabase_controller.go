@@ -18,15 +18,26 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"time"
 
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/utils/strings/slices"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/go-logr/logr"
 	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
@@ -41,13 +52,38 @@ type PgDatabaseReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
 
+	// ResyncPeriod is the interval at which available databases are
+	// reconciled again, repairing or reporting drift of the live state,
+	// granting the access roles privileges on objects created outside of the
+	// default privileges and measuring their size and activity. Zero
+	// disables resyncing.
+	ResyncPeriod time.Duration
+
+	// DryRun records the statements of every reconciliation in the status
+	// instead of executing them.
+	DryRun bool
+
+	// Connector opens the clients of the hosts. Defaults to
+	// postgres.NewClient.
+	Connector postgres.Connector
+
+	// Images are the images of the Jobs restoring backups.
+	Images BackupImages
+
 	logger   logr.Logger
+	plan     *postgres.Plan
 	database *api.PgDatabase
+	// restoring is set while the source of the database is being restored
+	restoring bool
 }
 
 //+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgdatabases,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgdatabases/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgdatabases/finalizers,verbs=update
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgnameclaims,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgbackups,verbs=get;list;watch
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -61,6 +97,8 @@ type PgDatabaseReconciler struct {
 func (r *PgDatabaseReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	r.logger = setupLogger(ctx)
 	r.logger.Info("Reconciling PgDatabase")
+	r.plan = nil
+	r.restoring = false
 
 	result, err := r.handleResult(r.reconcile(ctx, req))
 	r.logger.Info("Finished reconciling PgDatabase")
@@ -71,6 +109,8 @@ func (r *PgDatabaseReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 func (r *PgDatabaseReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&api.PgDatabase{}).
+		Owns(&batchv1.Job{}).
+		Watches(&source.Kind{Type: &api.PgBackup{}}, handler.EnqueueRequestsFromMapFunc(r.restoringDatabases)).
 		WithOptions(controller.Options{
 			MaxConcurrentReconciles: 1,
 			RateLimiter:             DefaultControllerRateLimiter(),
@@ -89,6 +129,7 @@ func (r *PgDatabaseReconciler) reconcile(ctx context.Context, req reconcile.Requ
 				// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 				// Return and don't requeue
 				r.logger.Info("Object not found")
+				deleteDatabaseStats(req.Namespace, req.Name)
 				return nil
 			}
 			// Error reading the object - requeue the request.
@@ -97,6 +138,8 @@ func (r *PgDatabaseReconciler) reconcile(ctx context.Context, req reconcile.Requ
 
 		r.database = database
 	}
+	ctx, r.plan = planContext(ctx, r.DryRun, database)
+	ctx = ownerContext(ctx, r.Client)
 
 	// PgDatabase instance created or updated
 	r.logger = r.logger.WithValues("database", database.Name)
@@ -109,7 +152,7 @@ func (r *PgDatabaseReconciler) reconcile(ctx context.Context, req reconcile.Requ
 		// Run finalization logic. If the
 		// finalization logic fails, don't remove the finalizer so
 		// that we can retry during the next reconciliation.
-		if err := r.finalize(database); err != nil {
+		if err := r.finalize(ctx, database); err != nil {
 			return err
 		}
 
@@ -139,48 +182,463 @@ func (r *PgDatabaseReconciler) reconcile(ctx context.Context, req reconcile.Requ
 		return ctlerrors.NewTemporary(err)
 	}
 
+	dbname, err := apiutil.DatabaseName(r.Client, hostCred, database.Namespace, database.Spec.Name)
+	if err != nil {
+		return err
+	}
+	claim, err := nameClaim(r.Client, hostCred, dbname)
+	if err != nil {
+		return err
+	}
+	if err := claimName(ctx, r.Client, api.NameTypeDatabase, claim, "PgDatabase", database); err != nil {
+		return err
+	}
+	if previous := database.Status.Claim; previous != nil && !sameName(*previous, claim) {
+		if err := releaseName(ctx, r.Client, api.NameTypeDatabase, *previous, database.UID); err != nil {
+			return err
+		}
+	}
+	database.Status.Claim = &claim
+
+	if err := validateDatabaseSource(database.Spec.Source); err != nil {
+		return ctlerrors.NewInvalid(err)
+	}
+
+	if reportsDrift(database.Spec.DriftPolicy, database.Generation, database.Status.ObservedGeneration) {
+		return r.reportDrift(ctx, hostCred, dbname)
+	}
+
 	{
-		connStr, err := apiutil.GetConnectionString(hostCred, r.Client)
+		db, err := r.connect(ctx, hostCred, "")
 		if err != nil {
-			r.logger.Error(err, "Failed to get connection string from the access spec. Skipping '"+database.Spec.HostCredential+"'")
-			return ctlerrors.NewTemporary(err)
+			return err
 		}
+		defer db.Close()
 
-		db, err := postgres.NewClient(ctx, r.logger, connStr)
+		exists, err := db.DatabaseExists(dbname)
 		if err != nil {
-			r.logger.Error(err, "Failed to open database connection")
 			return ctlerrors.NewTemporary(err)
 		}
+		template, err := r.prepareSource(hostCred, exists)
+		if err != nil {
+			return err
+		}
+		if err := db.EnsureDatabaseFromTemplate(dbname, template, ownerOf("PgDatabase", database), database.Spec.Adopt); err != nil {
+			return ownershipError(err)
+		}
+		if !exists && r.plan == nil && len(database.Spec.InitSQL) > 0 && database.Status.InitSQL == nil {
+			// the scripts run once the database is set up
+			database.Status.InitSQL = &api.InitSQLStatus{}
+		}
+	}
+
+	{
+		db, err := r.connect(ctx, hostCred, dbname)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
 
-		if err := db.EnsureDatabase(database.Spec.Name); err != nil {
+		if err := db.EnsureDatabaseAccessRoles(dbname); err != nil {
 			return ctlerrors.NewTemporary(err)
 		}
 
+		if err := r.restore(ctx, db, hostCred, dbname); err != nil {
+			return err
+		}
+
+		if err := r.runInitSQL(ctx, db, hostCred, dbname); err != nil {
+			return err
+		}
+
+		if err := r.collectStats(db, dbname); err != nil {
+			return err
+		}
 	}
+	setDriftedCondition(&database.Status.Conditions, database.Generation, database.Spec.DriftPolicy, nil)
 
-	{
-		connStr, err := apiutil.GetConnectionStringWithDatabase(hostCred, r.Client, database.Spec.Name)
+	return nil
+}
+
+// runInitSQL runs the init SQL scripts of the database dbname if the
+// operator created it and its source was applied. db is connected to dbname.
+func (r *PgDatabaseReconciler) runInitSQL(ctx context.Context, db *postgres.Client, hostCred *api.PgHostCredential, dbname string) error {
+	status := r.database.Status.InitSQL
+	if status == nil || status.CompletionTime != nil || r.plan != nil || r.restoring {
+		return nil
+	}
+
+	scripts := make([]string, len(r.database.Spec.InitSQL))
+	for i := range r.database.Spec.InitSQL {
+		script, err := apiutil.ResourceValue(r.Client, api.ResourceVar{ValueFrom: &r.database.Spec.InitSQL[i]}, r.database.Namespace)
 		if err != nil {
-			r.logger.Error(err, "Failed to get connection string from the access spec. Skipping '"+database.Spec.HostCredential+"'")
-			return ctlerrors.NewTemporary(err)
+			return ctlerrors.NewInvalid(fmt.Errorf("init SQL: %w", err))
 		}
+		scripts[i] = script
+	}
+	// a newline ends trailing comments of the scripts
+	script := strings.Join(scripts, "\n;\n")
+	ownerDB, closeOwner, err := connectAsOwner(ctx, r.logger, r.Connector, r.Client, db, hostCred, dbname, scriptUserName(r.database.UID), ownerOf("PgDatabase", r.database))
+	if err != nil {
+		return err
+	}
+	defer closeOwner()
+	if err := ownerDB.RunScript(script); err != nil {
+		return ctlerrors.NewTemporary(fmt.Errorf("init SQL: %w", err))
+	}
+
+	now := metav1.Now()
+	status.Hash = fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(script)))
+	status.CompletionTime = &now
+	r.logger.Info("Successfully ran the init SQL scripts", "hash", status.Hash)
+	return nil
+}
+
+// collectStats measures the size and the activity of the database dbname,
+// records them in the status and exports them as metrics.
+func (r *PgDatabaseReconciler) collectStats(db *postgres.Client, dbname string) error {
+	stats, err := db.DatabaseStats(dbname)
+	if err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+
+	now := metav1.Now()
+	r.database.Status.Stats = &api.DatabaseStats{
+		Size:           roundedSize(stats.Size),
+		Connections:    stats.Backends,
+		XactCommit:     stats.XactCommit,
+		XactRollback:   stats.XactRollback,
+		CollectionTime: &now,
+	}
+	exportDatabaseStats(r.database.Namespace, r.database.Name, stats)
+	return nil
+}
+
+// roundedSize returns size in bytes rounded down to the largest binary unit
+// keeping at least four digits, e.g. 1945Mi instead of 2040109465, so that
+// it reads well in the printer column.
+func roundedSize(size int64) *resource.Quantity {
+	unit := int64(1)
+	for size/(unit<<10) >= 1000 {
+		unit <<= 10
+	}
+	return resource.NewQuantity(size/unit*unit, resource.BinarySI)
+}
+
+// validateDatabaseSource returns an error unless exactly one field of
+// source is set.
+func validateDatabaseSource(source *api.DatabaseSource) error {
+	if source == nil {
+		return nil
+	}
+	set := 0
+	for _, ok := range []bool{source.Database != "", source.Backup != "", source.SQL != nil} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return fmt.Errorf("exactly one of database, backup and sql must be set in the source")
+	}
+	return nil
+}
+
+// prepareSource decides whether the source of the database is applied and
+// returns the database to create it from, if any. The source is only applied
+// if the database does not exist yet when it is first seen, which the
+// Restored condition records.
+func (r *PgDatabaseReconciler) prepareSource(hostCred *api.PgHostCredential, exists bool) (string, error) {
+	source := r.database.Spec.Source
+	if source == nil {
+		meta.RemoveStatusCondition(&r.database.Status.Conditions, api.ConditionRestored)
+		return "", nil
+	}
+	if r.plan != nil && meta.FindStatusCondition(r.database.Status.Conditions, api.ConditionRestored) == nil {
+		// a dry run plans the copy, but leaves the decision to the first
+		// real reconciliation
+		return r.templateName(hostCred)
+	}
+	if !r.applyingSource() {
+		return "", nil
+	}
+
+	condition := meta.FindStatusCondition(r.database.Status.Conditions, api.ConditionRestored)
+	if condition == nil {
+		if exists {
+			r.setRestoredCondition(metav1.ConditionFalse, "DatabaseExisted", "The source is only applied to a database created by the operator")
+			return "", nil
+		}
+		r.setRestoredCondition(metav1.ConditionFalse, "Restoring", "Restoring the source")
+	}
 
-		db, err := postgres.NewClient(ctx, r.logger, connStr)
+	if source.Database == "" {
+		return "", nil
+	}
+	return r.templateName(hostCred)
+}
+
+// applyingSource returns whether the source of the database is still to be
+// applied.
+func (r *PgDatabaseReconciler) applyingSource() bool {
+	condition := meta.FindStatusCondition(r.database.Status.Conditions, api.ConditionRestored)
+	return condition == nil || condition.Reason == "Restoring"
+}
+
+// templateName returns the name of the database of the PgDatabase the
+// database is copied from, which must be on the same host credential.
+func (r *PgDatabaseReconciler) templateName(hostCred *api.PgHostCredential) (string, error) {
+	name := r.database.Spec.Source.Database
+	if name == "" {
+		return "", nil
+	}
+	if name == r.database.Name {
+		return "", ctlerrors.NewInvalid(fmt.Errorf("the database cannot be copied from itself"))
+	}
+	source, err := apiutil.PgDatabaseByName(r.Client, r.database.Namespace, name)
+	if err != nil {
+		r.logger.Error(err, "Failed to get the source database. Skipping '"+name+"'")
+		return "", ctlerrors.NewTemporary(err)
+	}
+	if source.Spec.HostCredential != r.database.Spec.HostCredential {
+		return "", ctlerrors.NewInvalid(fmt.Errorf("source database %s is on host credential %s instead of %s",
+			name, source.Spec.HostCredential, r.database.Spec.HostCredential))
+	}
+	return apiutil.DatabaseName(r.Client, hostCred, source.Namespace, source.Spec.Name)
+}
+
+// restore applies the source of the database dbname it was created with.
+// db is connected to dbname.
+func (r *PgDatabaseReconciler) restore(ctx context.Context, db *postgres.Client, hostCred *api.PgHostCredential, dbname string) error {
+	source := r.database.Spec.Source
+	if source == nil || r.plan != nil || !r.applyingSource() {
+		return nil
+	}
+
+	switch {
+	case source.Database != "":
+		r.setRestoredCondition(metav1.ConditionTrue, "Restored", fmt.Sprintf("Copied from PgDatabase %s", source.Database))
+	case source.SQL != nil:
+		script, err := apiutil.ConfigMapValue(r.Client, types.NamespacedName{Namespace: r.database.Namespace, Name: source.SQL.Name}, source.SQL.Key)
 		if err != nil {
-			r.logger.Error(err, "Failed to open database connection")
+			return ctlerrors.NewTemporary(fmt.Errorf("config map %s key %s: %w", source.SQL.Name, source.SQL.Key, err))
+		}
+		ownerDB, closeOwner, err := connectAsOwner(ctx, r.logger, r.Connector, r.Client, db, hostCred, dbname, scriptUserName(r.database.UID), ownerOf("PgDatabase", r.database))
+		if err != nil {
+			return err
+		}
+		defer closeOwner()
+		if err := ownerDB.RunScript(script); err != nil {
 			return ctlerrors.NewTemporary(err)
 		}
+		r.setRestoredCondition(metav1.ConditionTrue, "Restored", fmt.Sprintf("Ran key %s of config map %s", source.SQL.Key, source.SQL.Name))
+	case source.Backup != "":
+		return r.restoreBackup(ctx, db, hostCred, dbname)
+	}
 
-		if err := db.EnsureDatabaseAccessRoles(database.Spec.Name); err != nil {
-			return ctlerrors.NewTemporary(err)
+	r.logger.Info("Successfully restored the source of the database")
+	return nil
+}
+
+// restoreBackup restores the PgBackup of the source into the database dbname
+// with a Job once the backup completed. It sets r.restoring until the Job
+// completed.
+func (r *PgDatabaseReconciler) restoreBackup(ctx context.Context, db *postgres.Client, hostCred *api.PgHostCredential, dbname string) error {
+	database := r.database
+	name := database.Spec.Source.Backup
+	backup := &api.PgBackup{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: database.Namespace, Name: name}, backup); err != nil {
+		r.logger.Error(err, "Failed to get the source backup. Skipping '"+name+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+	if backup.Status.CompletionTime == nil {
+		r.restoring = true
+		r.setRestoredCondition(metav1.ConditionFalse, "Restoring", fmt.Sprintf("Waiting for PgBackup %s to complete", name))
+		return nil
+	}
+	if err := validateBackupStorage(backup.Spec.Storage); err != nil {
+		return ctlerrors.NewInvalid(err)
+	}
+
+	job := &batchv1.Job{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: database.Namespace, Name: database.Name + "-restore"}, job)
+	switch {
+	case errors.IsNotFound(err):
+		if err := r.startRestore(ctx, db, backup, hostCred, dbname); err != nil {
+			return err
 		}
+		r.restoring = true
+		r.setRestoredCondition(metav1.ConditionFalse, "Restoring", fmt.Sprintf("Restoring PgBackup %s", name))
+		return nil
+	case err != nil:
+		return ctlerrors.NewTemporary(err)
+	}
 
+	finished, failed, message := jobFinished(job)
+	switch {
+	case !finished:
+		r.restoring = true
+		return nil
+	case failed:
+		r.revokeRestoreCredentials(ctx, db)
+		message = fmt.Sprintf("job %s restoring PgBackup %s failed: %s", job.Name, name, message)
+		r.setRestoredCondition(metav1.ConditionFalse, "RestoreFailed", message)
+		return ctlerrors.NewInvalid(fmt.Errorf("%s", message))
 	}
 
+	r.revokeRestoreCredentials(ctx, db)
+	r.setRestoredCondition(metav1.ConditionTrue, "Restored", fmt.Sprintf("Restored PgBackup %s", name))
+	r.logger.Info("Successfully restored backup", "backup", name)
 	return nil
 }
 
-func (r *PgDatabaseReconciler) finalize(database *api.PgDatabase) error {
+// startRestore creates the login role of the Job restoring backup into the
+// database dbname, the Secret with its connection string and the Job.
+func (r *PgDatabaseReconciler) startRestore(ctx context.Context, db *postgres.Client, backup *api.PgBackup, hostCred *api.PgHostCredential, dbname string) error {
+	user := jobUserName(r.database.UID)
+	password, err := createJobUser(db, dbname, user, ownerOf("PgDatabase", r.database))
+	if err != nil {
+		return ownershipError(err)
+	}
+	connStr, err := loginConnectionString(r.Client, hostCred, dbname, user, password)
+	if err != nil {
+		r.logger.Error(err, "Failed to get connection string from the access spec. Skipping '"+hostCred.Name+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+
+	secret := restoreSecret(r.database, connStr)
+	if err := controllerutil.SetControllerReference(r.database, secret, r.Scheme); err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+	if err := applySecret(ctx, r.Client, secret); err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+
+	job := restoreJob(r.database, backup, dbname+"_owner", r.Images)
+	if err := controllerutil.SetControllerReference(r.database, job, r.Scheme); err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+	if err := r.Create(ctx, job); err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+
+	r.logger.Info("Started restore job", "job", job.Name)
+	return nil
+}
+
+// revokeRestoreCredentials deletes the Secret and drops the login role of
+// the restore Job once it is done. A role which cannot be dropped expires
+// after jobUserLifetime.
+func (r *PgDatabaseReconciler) revokeRestoreCredentials(ctx context.Context, db *postgres.Client) {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: r.database.Namespace, Name: restoreSecretName(r.database)}}
+	if err := r.Delete(ctx, secret); err != nil && !errors.IsNotFound(err) {
+		r.logger.Error(err, "Failed to delete the connection secret of the restore job")
+	}
+	if err := db.DropUser(jobUserName(r.database.UID)); err != nil {
+		r.logger.Error(err, "Failed to drop the login role of the restore job")
+	}
+}
+
+func (r *PgDatabaseReconciler) setRestoredCondition(status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&r.database.Status.Conditions, metav1.Condition{
+		Type:               api.ConditionRestored,
+		Status:             status,
+		ObservedGeneration: r.database.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// restoringDatabases maps a PgBackup to the PgDatabases restoring it, so
+// that the restore starts once the backup completed.
+func (r *PgDatabaseReconciler) restoringDatabases(obj client.Object) []reconcile.Request {
+	databases, err := apiutil.PgDatabases(r.Client, obj.GetNamespace())
+	if err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, database := range databases {
+		if database.Spec.Source != nil && database.Spec.Source.Backup == obj.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: database.Namespace, Name: database.Name},
+			})
+		}
+	}
+	return requests
+}
+
+// reportDrift compares the database dbname and its access roles with the
+// state the reconciliation establishes and reports the differences in the
+// Drifted condition without changing anything.
+func (r *PgDatabaseReconciler) reportDrift(ctx context.Context, hostCred *api.PgHostCredential, dbname string) error {
+	db, err := r.connect(ctx, hostCred, "")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	exists, err := db.DatabaseExists(dbname)
+	if err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+	diffs := []string{fmt.Sprintf("database %s does not exist", dbname)}
+	if exists {
+		dbConn, err := r.connect(ctx, hostCred, dbname)
+		if err != nil {
+			return err
+		}
+		defer dbConn.Close()
+
+		if diffs, err = dbConn.DatabaseDrift(dbname); err != nil {
+			return ctlerrors.NewTemporary(err)
+		}
+		if err := r.collectStats(dbConn, dbname); err != nil {
+			return err
+		}
+	} else {
+		// a missing database has no stats to report
+		r.database.Status.Stats = nil
+		deleteDatabaseStats(r.database.Namespace, r.database.Name)
+	}
+
+	if len(diffs) > 0 {
+		r.logger.Info("Found drift", "differences", diffs)
+	}
+	setDriftedCondition(&r.database.Status.Conditions, r.database.Generation, r.database.Spec.DriftPolicy, diffs)
+	return nil
+}
+
+// connect returns a client connected to the database dbname on the host of
+// hostCred, or to the default database if dbname is empty.
+func (r *PgDatabaseReconciler) connect(ctx context.Context, hostCred *api.PgHostCredential, dbname string) (*postgres.Client, error) {
+	var (
+		connStr string
+		err     error
+	)
+	if dbname == "" {
+		connStr, err = apiutil.GetConnectionString(hostCred, r.Client)
+	} else {
+		connStr, err = apiutil.GetConnectionStringWithDatabase(hostCred, r.Client, dbname)
+	}
+	if err != nil {
+		r.logger.Error(err, "Failed to get connection string from the access spec. Skipping '"+hostCred.Name+"'")
+		return nil, ctlerrors.NewTemporary(err)
+	}
+
+	db, err := newClient(ctx, r.logger, r.Connector, connStr)
+	if err != nil {
+		r.logger.Error(err, "Failed to open database connection")
+		return nil, ctlerrors.NewTemporary(err)
+	}
+	return db, nil
+}
+
+func (r *PgDatabaseReconciler) finalize(ctx context.Context, database *api.PgDatabase) error {
+	if database.Status.Claim != nil {
+		if err := releaseName(ctx, r.Client, api.NameTypeDatabase, *database.Status.Claim, database.UID); err != nil {
+			return err
+		}
+	}
+	deleteDatabaseStats(database.Namespace, database.Name)
 	r.logger.Info("Successfully finalized PgDatabase")
 	return nil
 }
@@ -190,6 +648,9 @@ func (r *PgDatabaseReconciler) handleResult(err error) (ctrl.Result, error) {
 	var errorMessage string
 
 	switch {
+	case err == nil && r.restoring:
+		phase = api.PhasePending
+		errorMessage = ""
 	case err == nil:
 		phase = api.PhaseAvailable
 		errorMessage = ""
@@ -208,6 +669,11 @@ func (r *PgDatabaseReconciler) handleResult(err error) (ctrl.Result, error) {
 		r.database.Status.Phase = phase
 		r.database.Status.PhaseUpdated = metav1.Now()
 		r.database.Status.Error = errorMessage
+		r.database.Status.Plan = r.plan.Statements()
+		if phase == api.PhaseAvailable && r.plan == nil {
+			r.database.Status.ObservedGeneration = r.database.Generation
+		}
+		setConflictCondition(&r.database.Status.Conditions, r.database.Generation, err)
 	}
 
 	if err := r.Status().Update(context.Background(), r.database); err != nil {
@@ -215,6 +681,9 @@ func (r *PgDatabaseReconciler) handleResult(err error) (ctrl.Result, error) {
 	}
 
 	isRequeue := (phase == api.PhaseFailed)
+	if phase == api.PhaseAvailable && r.ResyncPeriod > 0 {
+		return ctrl.Result{RequeueAfter: r.ResyncPeriod}, err
+	}
 
 	return ctrl.Result{Requeue: isRequeue}, err
 }
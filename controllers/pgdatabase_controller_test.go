@@ -0,0 +1,321 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres/fake"
+)
+
+var _ = Describe("PgDatabase controller", func() {
+	var (
+		ctx        context.Context
+		server     *fake.Server
+		reconciler *PgDatabaseReconciler
+		namespace  string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		server = fake.NewServer("admin")
+		reconciler = &PgDatabaseReconciler{
+			Client:    k8sClient,
+			Scheme:    scheme.Scheme,
+			Connector: server.Connector(),
+		}
+		namespace = createNamespace(ctx)
+		createHostCredential(ctx, namespace)
+	})
+
+	newDatabase := func(policy api.DriftPolicy) *api.PgDatabase {
+		database := &api.PgDatabase{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "app"},
+			Spec: api.PgDatabaseSpec{
+				HostCredential: "host",
+				Name:           "app",
+				DriftPolicy:    policy,
+			},
+		}
+		Expect(k8sClient.Create(ctx, database)).To(Succeed())
+		return database
+	}
+
+	newDatabaseFrom := func(name string, source *api.DatabaseSource) *api.PgDatabase {
+		database := &api.PgDatabase{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec: api.PgDatabaseSpec{
+				HostCredential: "host",
+				Name:           name,
+				Source:         source,
+			},
+		}
+		Expect(k8sClient.Create(ctx, database)).To(Succeed())
+		return database
+	}
+
+	restored := func(database *api.PgDatabase) *metav1.Condition {
+		return meta.FindStatusCondition(database.Status.Conditions, api.ConditionRestored)
+	}
+
+	It("creates the database and its access roles", func() {
+		database := newDatabase(api.DriftPolicyRepair)
+		reconcileObject(ctx, reconciler, database)
+
+		Expect(database.Status.Phase).To(Equal(api.PhaseAvailable), database.Status.Error)
+		Expect(database.Finalizers).To(ContainElement(operatorFinalizer))
+		Expect(server.DatabaseExists("app")).To(BeTrue())
+		Expect(server.DatabaseComment("app")).To(ContainSubstring(string(database.UID)))
+		for _, role := range []string{"app_readonly", "app_readwrite", "app_owner"} {
+			Expect(server.HasDatabasePrivilege(role, "app", "CONNECT")).To(BeTrue(), role)
+		}
+		Expect(server.SchemaOwner("app", "public")).To(Equal("app_owner"))
+
+		deleteObject(ctx, reconciler, database)
+		// databases are kept when the custom resource is deleted
+		Expect(server.DatabaseExists("app")).To(BeTrue())
+	})
+
+	table.DescribeTable("reports the size and the activity of the database",
+		func(policy api.DriftPolicy) {
+			database := newDatabase(policy)
+			reconcileObject(ctx, reconciler, database)
+			server.SetDatabaseSize("app", 2040109465)
+			server.SetDatabaseStats("app", postgres.DatabaseStats{Backends: 3, XactCommit: 120, XactRollback: 4})
+			reconcileObject(ctx, reconciler, database)
+
+			Expect(database.Status.Phase).To(Equal(api.PhaseAvailable), database.Status.Error)
+			Expect(database.Status.Stats).NotTo(BeNil())
+			Expect(database.Status.Stats.Size.String()).To(Equal("1945Mi"))
+			Expect(database.Status.Stats.Connections).To(Equal(int32(3)))
+			Expect(database.Status.Stats.XactCommit).To(Equal(int64(120)))
+			Expect(database.Status.Stats.XactRollback).To(Equal(int64(4)))
+			Expect(database.Status.Stats.CollectionTime).NotTo(BeNil())
+
+			Expect(testutil.ToFloat64(databaseSize.WithLabelValues(namespace, "app"))).To(Equal(2040109465.0))
+			Expect(testutil.ToFloat64(databaseConnections.WithLabelValues(namespace, "app"))).To(Equal(3.0))
+
+			By("deleting the metrics with the custom resource")
+			deleteObject(ctx, reconciler, database)
+			Expect(databaseSize.DeleteLabelValues(namespace, "app")).To(BeFalse())
+		},
+		table.Entry("while repairing drift", api.DriftPolicyRepair),
+		table.Entry("while reporting drift", api.DriftPolicyReport),
+	)
+
+	It("drops the stats of a missing database while reporting drift", func() {
+		database := newDatabase(api.DriftPolicyReport)
+		reconcileObject(ctx, reconciler, database)
+		reconcileObject(ctx, reconciler, database)
+		Expect(database.Status.Stats).NotTo(BeNil())
+
+		Expect(server.Exec("postgres", "DROP DATABASE app")).To(Succeed())
+		reconcileObject(ctx, reconciler, database)
+		Expect(database.Status.Phase).To(Equal(api.PhaseAvailable), database.Status.Error)
+		Expect(meta.IsStatusConditionTrue(database.Status.Conditions, api.ConditionDrifted)).To(BeTrue())
+		Expect(database.Status.Stats).To(BeNil())
+		Expect(databaseSize.DeleteLabelValues(namespace, "app")).To(BeFalse())
+	})
+
+	It("refuses a database it does not own", func() {
+		Expect(server.Exec("postgres", "CREATE DATABASE app")).To(Succeed())
+
+		database := newDatabase(api.DriftPolicyRepair)
+		reconcileObject(ctx, reconciler, database)
+
+		Expect(database.Status.Phase).To(Equal(api.PhaseInvalid))
+		Expect(meta.IsStatusConditionTrue(database.Status.Conditions, api.ConditionConflict)).To(BeTrue())
+	})
+
+	It("copies another database on creation", func() {
+		base := newDatabaseFrom("base", nil)
+		reconcileObject(ctx, reconciler, base)
+		Expect(base.Status.Phase).To(Equal(api.PhaseAvailable), base.Status.Error)
+		Expect(server.Exec("base", "SET ROLE base_owner;\nCREATE TABLE items (id int)")).To(Succeed())
+
+		database := newDatabaseFrom("app", &api.DatabaseSource{Database: "base"})
+		reconcileObject(ctx, reconciler, database)
+
+		Expect(database.Status.Phase).To(Equal(api.PhaseAvailable), database.Status.Error)
+		Expect(meta.IsStatusConditionTrue(database.Status.Conditions, api.ConditionRestored)).To(BeTrue())
+		Expect(server.Scripts("app")).To(Equal([]string{"CREATE TABLE items (id int)"}))
+		Expect(server.SchemaOwner("app", "public")).To(Equal("app_owner"))
+	})
+
+	It("runs a script of a config map on creation only", func() {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "schema"},
+			Data:       map[string]string{"schema.sql": "CREATE TABLE items (id int);"},
+		}
+		Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+
+		database := newDatabaseFrom("app", &api.DatabaseSource{SQL: &api.KeySelector{Name: "schema", Key: "schema.sql"}})
+		reconcileObject(ctx, reconciler, database)
+
+		Expect(database.Status.Phase).To(Equal(api.PhaseAvailable), database.Status.Error)
+		Expect(meta.IsStatusConditionTrue(database.Status.Conditions, api.ConditionRestored)).To(BeTrue())
+		Expect(server.Scripts("app")).To(Equal([]string{"CREATE TABLE items (id int);"}))
+		Expect(server.RoleExists(scriptUserName(database.UID))).To(BeFalse())
+
+		configMap.Data["schema.sql"] = "CREATE TABLE other (id int);"
+		Expect(k8sClient.Update(ctx, configMap)).To(Succeed())
+		reconcileObject(ctx, reconciler, database)
+		Expect(database.Status.Phase).To(Equal(api.PhaseAvailable), database.Status.Error)
+		Expect(server.Scripts("app")).To(HaveLen(1))
+	})
+
+	It("does not apply the source to an existing database", func() {
+		Expect(server.Exec("postgres", "CREATE DATABASE app")).To(Succeed())
+
+		database := newDatabaseFrom("app", &api.DatabaseSource{SQL: &api.KeySelector{Name: "schema", Key: "schema.sql"}})
+		database.Spec.Adopt = true
+		Expect(k8sClient.Update(ctx, database)).To(Succeed())
+		reconcileObject(ctx, reconciler, database)
+
+		Expect(database.Status.Phase).To(Equal(api.PhaseAvailable), database.Status.Error)
+		Expect(restored(database).Status).To(Equal(metav1.ConditionFalse))
+		Expect(restored(database).Reason).To(Equal("DatabaseExisted"))
+		Expect(server.Scripts("app")).To(BeEmpty())
+	})
+
+	It("restores a backup with a job once it completed", func() {
+		backup := &api.PgBackup{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "nightly"},
+			Spec: api.PgBackupSpec{
+				Database: "base",
+				Storage: api.BackupStorage{
+					PersistentVolumeClaim: &api.PVCBackupStorage{ClaimName: "backups"},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, backup)).To(Succeed())
+
+		By("waiting for the backup")
+		database := newDatabaseFrom("app", &api.DatabaseSource{Backup: "nightly"})
+		reconcileObject(ctx, reconciler, database)
+		Expect(database.Status.Phase).To(Equal(api.PhasePending), database.Status.Error)
+		Expect(restored(database).Reason).To(Equal("Restoring"))
+		Expect(server.DatabaseExists("app")).To(BeTrue())
+
+		By("starting the restore")
+		now := metav1.Now()
+		backup.Status.CompletionTime = &now
+		Expect(k8sClient.Status().Update(ctx, backup)).To(Succeed())
+		reconcileObject(ctx, reconciler, database)
+		Expect(database.Status.Phase).To(Equal(api.PhasePending), database.Status.Error)
+
+		job := &batchv1.Job{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "app-restore"}, job)).To(Succeed())
+		restore := job.Spec.Template.Spec.Containers[0]
+		Expect(envValue(restore, "ROLE")).To(Equal("app_owner"))
+		Expect(envValue(restore, "BACKUP_FILE")).To(Equal("/backup/" + namespace + "/nightly.dump"))
+		secret := &corev1.Secret{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "app-pgrestore"}, secret)).To(Succeed())
+		user := jobUserName(database.UID)
+		Expect(server.IsMember("app_owner", user)).To(BeTrue())
+		Expect(server.Attributes(user).ValidUntil).NotTo(BeNil())
+		Expect(string(secret.Data[databaseURLKey])).To(HavePrefix("postgresql://" + user + ":" + server.Password(user) + "@" + namespace + ".postgres.local/app"))
+
+		By("completing the restore")
+		finishJob(ctx, namespace, "app-restore", false, "")
+		reconcileObject(ctx, reconciler, database)
+		Expect(database.Status.Phase).To(Equal(api.PhaseAvailable), database.Status.Error)
+		Expect(meta.IsStatusConditionTrue(database.Status.Conditions, api.ConditionRestored)).To(BeTrue())
+		err := k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "app-pgrestore"}, secret)
+		Expect(apierrors.IsNotFound(err)).To(BeTrue(), "connection secret still exists: %v", err)
+		Expect(server.RoleExists(user)).To(BeFalse())
+	})
+
+	It("runs the init SQL once after creating the database", func() {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "schema"},
+			Data: map[string]string{
+				"schema.sql": "CREATE TABLE items (id int);",
+				"init.sql":   "CREATE TABLE codes (code text) -- reference data",
+			},
+		}
+		Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "codes"},
+			Data:       map[string][]byte{"codes.sql": []byte("INSERT INTO codes VALUES ('secret');")},
+		}
+		Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+
+		database := newDatabaseFrom("app", &api.DatabaseSource{SQL: &api.KeySelector{Name: "schema", Key: "schema.sql"}})
+		database.Spec.InitSQL = []api.ResourceVarSource{
+			{ConfigMapKeyRef: &api.KeySelector{Name: "schema", Key: "init.sql"}},
+			{SecretKeyRef: &api.KeySelector{Name: "codes", Key: "codes.sql"}},
+		}
+		Expect(k8sClient.Update(ctx, database)).To(Succeed())
+		reconcileObject(ctx, reconciler, database)
+
+		Expect(database.Status.Phase).To(Equal(api.PhaseAvailable), database.Status.Error)
+		Expect(server.Scripts("app")).To(Equal([]string{
+			"CREATE TABLE items (id int);",
+			"CREATE TABLE codes (code text) -- reference data\n;\nINSERT INTO codes VALUES ('secret');",
+		}))
+		Expect(database.Status.InitSQL).NotTo(BeNil())
+		Expect(database.Status.InitSQL.Hash).To(HavePrefix("sha256:"))
+		Expect(database.Status.InitSQL.CompletionTime).NotTo(BeNil())
+		Expect(server.RoleExists(scriptUserName(database.UID))).To(BeFalse())
+
+		By("not running changed scripts again")
+		configMap.Data["init.sql"] = "CREATE TABLE other (id int);"
+		Expect(k8sClient.Update(ctx, configMap)).To(Succeed())
+		reconcileObject(ctx, reconciler, database)
+		Expect(database.Status.Phase).To(Equal(api.PhaseAvailable), database.Status.Error)
+		Expect(server.Scripts("app")).To(HaveLen(2))
+	})
+
+	It("does not run the init SQL for an existing database", func() {
+		Expect(server.Exec("postgres", "CREATE DATABASE app")).To(Succeed())
+
+		database := newDatabaseFrom("app", nil)
+		database.Spec.Adopt = true
+		database.Spec.InitSQL = []api.ResourceVarSource{{ConfigMapKeyRef: &api.KeySelector{Name: "schema", Key: "init.sql"}}}
+		Expect(k8sClient.Update(ctx, database)).To(Succeed())
+		reconcileObject(ctx, reconciler, database)
+
+		Expect(database.Status.Phase).To(Equal(api.PhaseAvailable), database.Status.Error)
+		Expect(database.Status.InitSQL).To(BeNil())
+		Expect(server.Scripts("app")).To(BeEmpty())
+	})
+
+	It("rejects sources with more than one field", func() {
+		database := newDatabaseFrom("app", &api.DatabaseSource{Database: "base", Backup: "nightly"})
+		reconcileObject(ctx, reconciler, database)
+
+		Expect(database.Status.Phase).To(Equal(api.PhaseInvalid))
+		Expect(server.DatabaseExists("app")).To(BeFalse())
+	})
+
+	table.DescribeTable("handles drift",
+		func(policy api.DriftPolicy, repaired bool) {
+			database := newDatabase(policy)
+			reconcileObject(ctx, reconciler, database)
+			Expect(database.Status.Phase).To(Equal(api.PhaseAvailable), database.Status.Error)
+
+			Expect(server.Exec("app", "REVOKE CONNECT ON DATABASE app FROM app_readonly")).To(Succeed())
+			reconcileObject(ctx, reconciler, database)
+
+			Expect(database.Status.Phase).To(Equal(api.PhaseAvailable), database.Status.Error)
+			Expect(server.HasDatabasePrivilege("app_readonly", "app", "CONNECT")).To(Equal(repaired))
+			Expect(meta.IsStatusConditionTrue(database.Status.Conditions, api.ConditionDrifted)).To(Equal(!repaired))
+		},
+		table.Entry("repairs it", api.DriftPolicyRepair, true),
+		table.Entry("reports it", api.DriftPolicyReport, false),
+	)
+})
@@ -0,0 +1,419 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/strings/slices"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/go-logr/logr"
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	apiutil "github.com/jeewangue/postgres-indb-operator/api/v1alpha1/util"
+	ctlerrors "github.com/jeewangue/postgres-indb-operator/internal/errors"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres"
+)
+
+// PgGrantReconciler reconciles a PgGrant object
+type PgGrantReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// DryRun records the statements of every reconciliation in the status
+	// instead of executing them.
+	DryRun bool
+
+	// Connector opens the clients of the hosts. Defaults to
+	// postgres.NewClient.
+	Connector postgres.Connector
+
+	logger logr.Logger
+	plan   *postgres.Plan
+	grant  *api.PgGrant
+}
+
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pggrants,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pggrants/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pggrants/finalizers,verbs=update
+
+// Reconcile grants the privileges described by a PgGrant and revokes the
+// privileges that were granted by a previous version of it but are no
+// longer desired.
+func (r *PgGrantReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.logger = setupLogger(ctx)
+	r.logger.Info("Reconciling PgGrant")
+	r.plan = nil
+
+	result, err := r.handleResult(r.reconcile(ctx, req))
+	r.logger.Info("Finished reconciling PgGrant")
+	return result, err
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PgGrantReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&api.PgGrant{}).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: 1,
+			RateLimiter:             DefaultControllerRateLimiter(),
+		}).
+		Complete(r)
+}
+
+func (r *PgGrantReconciler) reconcile(ctx context.Context, req reconcile.Request) error {
+	// Fetch the PgGrant instance
+	grant := &api.PgGrant{}
+	{
+		err := r.Client.Get(ctx, req.NamespacedName, grant)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				// Request object not found, could have been deleted after reconcile request.
+				// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
+				// Return and don't requeue
+				r.logger.Info("Object not found")
+				return nil
+			}
+			// Error reading the object - requeue the request.
+			return ctlerrors.NewTemporary(err)
+		}
+
+		r.grant = grant
+	}
+	ctx, r.plan = planContext(ctx, r.DryRun, grant)
+	if r.plan != nil {
+		// a dry run neither grants nor revokes privileges
+		applied := grant.Status.Applied
+		defer func() { grant.Status.Applied = applied }()
+	}
+
+	// PgGrant instance created or updated
+	r.logger = r.logger.WithValues("grant", grant.Name)
+	r.logger.Info("Reconciling found PgGrant resource")
+
+	if grant.GetDeletionTimestamp() != nil {
+		if !slices.Contains(grant.Finalizers, operatorFinalizer) {
+			return nil
+		}
+		// Run finalization logic. If the
+		// finalization logic fails, don't remove the finalizer so
+		// that we can retry during the next reconciliation.
+		if err := r.finalize(ctx, grant); err != nil {
+			return err
+		}
+		if r.plan != nil {
+			// keep the grant until the privileges are revoked for real
+			return nil
+		}
+
+		// Remove finalizer. Once all finalizers have been
+		// removed, the object will be deleted.
+		controllerutil.RemoveFinalizer(grant, operatorFinalizer)
+		if err := r.Update(ctx, grant); err != nil {
+			return ctlerrors.NewTemporary(err)
+		}
+
+		return nil
+	}
+
+	// Add finalizer for this CR
+	if !slices.Contains(grant.Finalizers, operatorFinalizer) {
+		controllerutil.AddFinalizer(grant, operatorFinalizer)
+
+		if err := r.Update(ctx, grant); err != nil {
+			return ctlerrors.NewTemporary(err)
+		}
+	}
+
+	database, err := apiutil.PgDatabaseByName(r.Client, grant.Namespace, grant.Spec.Database)
+	if err != nil {
+		r.logger.Error(err, "Failed to get database from the grant. Skipping '"+grant.Spec.Database+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+
+	hostCred, err := apiutil.PgHostCredentialByName(r.Client, grant.Namespace, database.Spec.HostCredential)
+	if err != nil {
+		r.logger.Error(err, "Failed to get host credential from the database. Skipping '"+database.Spec.HostCredential+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+
+	dbname, err := apiutil.DatabaseName(r.Client, hostCred, database.Namespace, database.Spec.Name)
+	if err != nil {
+		return err
+	}
+
+	role, err := r.granteeRole(grant, database, hostCred)
+	if err != nil {
+		return err
+	}
+
+	desired := desiredGrants(grant, database, dbname, role)
+	for _, g := range desired {
+		if err := toPostgresGrant(g).Validate(); err != nil {
+			return ctlerrors.NewInvalid(err)
+		}
+	}
+
+	// Revoke what was granted before but is no longer desired
+	{
+		var kept, stale []api.AppliedGrant
+		for _, applied := range grant.Status.Applied {
+			if containsGrant(desired, applied) {
+				kept = append(kept, applied)
+			} else {
+				stale = append(stale, applied)
+			}
+		}
+		if err := r.revoke(ctx, grant.Namespace, stale); err != nil {
+			return err
+		}
+		grant.Status.Applied = kept
+	}
+
+	connStr, err := apiutil.GetConnectionStringWithDatabase(hostCred, r.Client, dbname)
+	if err != nil {
+		r.logger.Error(err, "Failed to get connection string from the database. Skipping '"+database.Spec.HostCredential+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+
+	db, err := newClient(ctx, r.logger, r.Connector, connStr)
+	if err != nil {
+		r.logger.Error(err, "Failed to open database connection")
+		return ctlerrors.NewTemporary(err)
+	}
+	defer db.Close()
+
+	// Grants are only recorded once applied, so that a failed grant is not
+	// revoked based on privileges it never got. Only the privileges recorded
+	// before are revoked from an object, so that privileges granted by
+	// others on the same object are kept.
+	for _, g := range desired {
+		i := indexOfGrant(grant.Status.Applied, g)
+		var applied []string
+		if i >= 0 {
+			applied = toPostgresGrant(grant.Status.Applied[i]).Privileges
+		}
+		if err := db.EnsureGrant(toPostgresGrant(g), applied); err != nil {
+			return ctlerrors.NewTemporary(err)
+		}
+		if i >= 0 {
+			grant.Status.Applied[i] = g
+		} else {
+			grant.Status.Applied = append(grant.Status.Applied, g)
+		}
+	}
+
+	return nil
+}
+
+// granteeRole returns the name of the Postgres role referenced by the
+// grantee of grant on the host of hostCred.
+func (r *PgGrantReconciler) granteeRole(grant *api.PgGrant, database *api.PgDatabase, hostCred *api.PgHostCredential) (string, error) {
+	switch grant.Spec.Grantee.Kind {
+	case "PgUser":
+		user, err := apiutil.PgUserByName(r.Client, grant.Namespace, grant.Spec.Grantee.Name)
+		if err != nil {
+			return "", ctlerrors.NewTemporary(err)
+		}
+		username, err := apiutil.ResourceValue(r.Client, user.Spec.Name, user.Namespace)
+		if err != nil {
+			return "", ctlerrors.NewInvalid(err)
+		}
+		return apiutil.RoleName(r.Client, hostCred, user.Namespace, username)
+	case "PgRole":
+		role, err := apiutil.PgRoleByName(r.Client, grant.Namespace, grant.Spec.Grantee.Name)
+		if err != nil {
+			return "", ctlerrors.NewTemporary(err)
+		}
+		if role.Spec.HostCredential != database.Spec.HostCredential {
+			return "", ctlerrors.NewInvalid(fmt.Errorf("role %s is on host credential %s, not %s", role.Name, role.Spec.HostCredential, database.Spec.HostCredential))
+		}
+		return apiutil.RoleName(r.Client, hostCred, role.Namespace, role.Spec.Name)
+	default:
+		return "", ctlerrors.NewInvalid(fmt.Errorf("unknown grantee kind %q", grant.Spec.Grantee.Kind))
+	}
+}
+
+// revoke revokes applied grants, connecting to every database they were
+// granted in.
+func (r *PgGrantReconciler) revoke(ctx context.Context, namespace string, grants []api.AppliedGrant) error {
+	dbs := make(map[string]*postgres.Client)
+	defer func() {
+		for _, db := range dbs {
+			db.Close()
+		}
+	}()
+
+	for _, g := range grants {
+		key := g.HostCredential + "/" + g.Database
+		if dbs[key] == nil {
+			hostCred, err := apiutil.PgHostCredentialByName(r.Client, namespace, g.HostCredential)
+			if err != nil {
+				r.logger.Error(err, "Failed to get host credential from the applied grant. Skipping '"+g.HostCredential+"'")
+				return ctlerrors.NewTemporary(err)
+			}
+			connStr, err := apiutil.GetConnectionStringWithDatabase(hostCred, r.Client, g.Database)
+			if err != nil {
+				r.logger.Error(err, "Failed to get connection string from the applied grant. Skipping '"+g.HostCredential+"'")
+				return ctlerrors.NewTemporary(err)
+			}
+			db, err := newClient(ctx, r.logger, r.Connector, connStr)
+			if err != nil {
+				r.logger.Error(err, "Failed to open database connection")
+				return ctlerrors.NewTemporary(err)
+			}
+			dbs[key] = db
+		}
+
+		if err := dbs[key].RevokeGrant(toPostgresGrant(g)); err != nil {
+			return ctlerrors.NewTemporary(err)
+		}
+	}
+
+	return nil
+}
+
+// desiredGrants expands the spec of grant into one entry per object and
+// column. dbname is the name of database on its host.
+func desiredGrants(grant *api.PgGrant, database *api.PgDatabase, dbname, role string) []api.AppliedGrant {
+	base := api.AppliedGrant{
+		HostCredential: database.Spec.HostCredential,
+		Database:       dbname,
+		Role:           role,
+		ObjectType:     grant.Spec.ObjectType,
+		Schema:         grant.Spec.Schema,
+		Privileges:     grant.Spec.Privileges,
+	}
+	if base.Schema == "" {
+		base.Schema = "public"
+	}
+
+	if grant.Spec.ObjectType == api.GrantObjectSchema {
+		return []api.AppliedGrant{base}
+	}
+
+	var grants []api.AppliedGrant
+	for _, object := range grant.Spec.Objects {
+		g := base
+		g.Object = object
+		if len(grant.Spec.Columns) == 0 {
+			grants = append(grants, g)
+			continue
+		}
+		for _, column := range grant.Spec.Columns {
+			g.Column = column
+			grants = append(grants, g)
+		}
+	}
+	return grants
+}
+
+// containsGrant returns whether grants contains an entry on the same object
+// and for the same role as g, regardless of the privileges.
+func containsGrant(grants []api.AppliedGrant, g api.AppliedGrant) bool {
+	return indexOfGrant(grants, g) >= 0
+}
+
+// indexOfGrant returns the index of the entry of grants on the same object
+// and for the same role as g, or -1.
+func indexOfGrant(grants []api.AppliedGrant, g api.AppliedGrant) int {
+	for i, other := range grants {
+		if equalGrant(other, g) {
+			return i
+		}
+	}
+	return -1
+}
+
+func equalGrant(a, b api.AppliedGrant) bool {
+	return a.HostCredential == b.HostCredential &&
+		a.Database == b.Database &&
+		a.Role == b.Role &&
+		a.ObjectType == b.ObjectType &&
+		a.Schema == b.Schema &&
+		a.Object == b.Object &&
+		a.Column == b.Column
+}
+
+func toPostgresGrant(g api.AppliedGrant) postgres.Grant {
+	privileges := make([]string, len(g.Privileges))
+	for i, p := range g.Privileges {
+		privileges[i] = strings.ToUpper(string(p))
+	}
+	return postgres.Grant{
+		Role:       g.Role,
+		ObjectType: postgres.ObjectType(g.ObjectType),
+		Schema:     g.Schema,
+		Object:     g.Object,
+		Column:     g.Column,
+		Privileges: privileges,
+	}
+}
+
+func (r *PgGrantReconciler) finalize(ctx context.Context, grant *api.PgGrant) error {
+	if err := r.revoke(ctx, grant.Namespace, grant.Status.Applied); err != nil {
+		return err
+	}
+	grant.Status.Applied = nil
+
+	r.logger.Info("Successfully finalized PgGrant")
+	return nil
+}
+
+func (r *PgGrantReconciler) handleResult(err error) (ctrl.Result, error) {
+	var phase api.Phase
+	var errorMessage string
+
+	switch {
+	case err == nil:
+		phase = api.PhaseAvailable
+		errorMessage = ""
+	case ctlerrors.IsTemporary(err):
+		phase = api.PhaseFailed
+		errorMessage = err.Error()
+	case ctlerrors.IsInvalid(err):
+		phase = api.PhaseInvalid
+		errorMessage = err.Error()
+	default:
+		phase = api.PhaseInvalid
+		errorMessage = err.Error()
+	}
+
+	if r.grant != nil {
+		r.grant.Status.Phase = phase
+		r.grant.Status.PhaseUpdated = metav1.Now()
+		r.grant.Status.Error = errorMessage
+		r.grant.Status.Plan = r.plan.Statements()
+	}
+
+	if err := r.Status().Update(context.Background(), r.grant); err != nil {
+		r.logger.Error(err, "Failed to update the status")
+	}
+
+	isRequeue := (phase == api.PhaseFailed)
+
+	return ctrl.Result{Requeue: isRequeue}, err
+}
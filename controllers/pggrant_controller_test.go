@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres/fake"
+)
+
+var _ = Describe("PgGrant controller", func() {
+	var (
+		ctx        context.Context
+		server     *fake.Server
+		reconciler *PgGrantReconciler
+		namespace  string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		server = fake.NewServer("admin")
+		reconciler = &PgGrantReconciler{
+			Client:    k8sClient,
+			Scheme:    scheme.Scheme,
+			Connector: server.Connector(),
+		}
+		namespace = createNamespace(ctx)
+		createHostCredential(ctx, namespace)
+
+		database := &api.PgDatabase{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "app"},
+			Spec:       api.PgDatabaseSpec{HostCredential: "host", Name: "app"},
+		}
+		Expect(k8sClient.Create(ctx, database)).To(Succeed())
+		reconcileObject(ctx, &PgDatabaseReconciler{Client: k8sClient, Scheme: scheme.Scheme, Connector: server.Connector()}, database)
+		Expect(database.Status.Phase).To(Equal(api.PhaseAvailable), database.Status.Error)
+
+		role := &api.PgRole{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "reporting"},
+			Spec:       api.PgRoleSpec{HostCredential: "host", Name: "reporting"},
+		}
+		Expect(k8sClient.Create(ctx, role)).To(Succeed())
+		reconcileObject(ctx, &PgRoleReconciler{Client: k8sClient, Scheme: scheme.Scheme, Connector: server.Connector()}, role)
+		Expect(role.Status.Phase).To(Equal(api.PhaseAvailable), role.Status.Error)
+	})
+
+	It("grants, updates and revokes privileges on tables", func() {
+		grant := &api.PgGrant{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "reporting"},
+			Spec: api.PgGrantSpec{
+				Database:   "app",
+				Grantee:    api.GranteeRef{Kind: "PgRole", Name: "reporting"},
+				ObjectType: api.GrantObjectTable,
+				Schema:     "public",
+				Objects:    []string{"accounts", "orders"},
+				Privileges: []api.Privilege{"SELECT", "INSERT"},
+			},
+		}
+		Expect(k8sClient.Create(ctx, grant)).To(Succeed())
+
+		By("granting the privileges")
+		reconcileObject(ctx, reconciler, grant)
+		Expect(grant.Status.Phase).To(Equal(api.PhaseAvailable), grant.Status.Error)
+		Expect(grant.Status.Applied).To(HaveLen(2))
+		Expect(server.Privileges("app", "reporting", "public.accounts")).To(Equal([]string{"INSERT", "SELECT"}))
+		Expect(server.Privileges("app", "reporting", "public.orders")).To(Equal([]string{"INSERT", "SELECT"}))
+
+		By("revoking privileges and objects no longer listed")
+		grant.Spec.Objects = []string{"accounts"}
+		grant.Spec.Privileges = []api.Privilege{"SELECT"}
+		Expect(k8sClient.Update(ctx, grant)).To(Succeed())
+		reconcileObject(ctx, reconciler, grant)
+		Expect(grant.Status.Phase).To(Equal(api.PhaseAvailable), grant.Status.Error)
+		Expect(server.Privileges("app", "reporting", "public.accounts")).To(Equal([]string{"SELECT"}))
+		Expect(server.Privileges("app", "reporting", "public.orders")).To(BeEmpty())
+
+		By("revoking everything on deletion")
+		deleteObject(ctx, reconciler, grant)
+		Expect(server.Privileges("app", "reporting", "public.accounts")).To(BeEmpty())
+	})
+
+	It("keeps the privileges granted by another PgGrant on the same object", func() {
+		createGrant := func(name string, privileges ...api.Privilege) *api.PgGrant {
+			grant := &api.PgGrant{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+				Spec: api.PgGrantSpec{
+					Database:   "app",
+					Grantee:    api.GranteeRef{Kind: "PgRole", Name: "reporting"},
+					ObjectType: api.GrantObjectTable,
+					Schema:     "public",
+					Objects:    []string{"accounts"},
+					Privileges: privileges,
+				},
+			}
+			Expect(k8sClient.Create(ctx, grant)).To(Succeed())
+			reconcileObject(ctx, reconciler, grant)
+			Expect(grant.Status.Phase).To(Equal(api.PhaseAvailable), grant.Status.Error)
+			return grant
+		}
+		readers := createGrant("readers", "SELECT")
+		createGrant("writers", "INSERT")
+
+		reconcileObject(ctx, reconciler, readers)
+		Expect(readers.Status.Phase).To(Equal(api.PhaseAvailable), readers.Status.Error)
+		Expect(server.Privileges("app", "reporting", "public.accounts")).To(Equal([]string{"INSERT", "SELECT"}))
+
+		By("updating the privileges of one of them")
+		readers.Spec.Privileges = []api.Privilege{"REFERENCES"}
+		Expect(k8sClient.Update(ctx, readers)).To(Succeed())
+		reconcileObject(ctx, reconciler, readers)
+		Expect(readers.Status.Phase).To(Equal(api.PhaseAvailable), readers.Status.Error)
+		Expect(readers.Status.Applied[0].Privileges).To(Equal([]api.Privilege{"REFERENCES"}))
+		Expect(server.Privileges("app", "reporting", "public.accounts")).To(Equal([]string{"INSERT", "REFERENCES"}))
+	})
+
+	It("records grants only once they are applied", func() {
+		grant := &api.PgGrant{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "reporting"},
+			Spec: api.PgGrantSpec{
+				Database:   "app",
+				Grantee:    api.GranteeRef{Kind: "PgRole", Name: "reporting"},
+				ObjectType: api.GrantObjectSchema,
+				Schema:     "reports",
+				Privileges: []api.Privilege{"USAGE"},
+			},
+		}
+		Expect(k8sClient.Create(ctx, grant)).To(Succeed())
+
+		By("failing on a missing schema")
+		reconcileObject(ctx, reconciler, grant)
+		Expect(grant.Status.Phase).To(Equal(api.PhaseFailed))
+		Expect(grant.Status.Applied).To(BeEmpty())
+
+		By("granting once the schema exists")
+		server.CreateSchema("app", "reports", "admin")
+		reconcileObject(ctx, reconciler, grant)
+		Expect(grant.Status.Phase).To(Equal(api.PhaseAvailable), grant.Status.Error)
+		Expect(grant.Status.Applied).To(HaveLen(1))
+	})
+})
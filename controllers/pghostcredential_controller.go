@@ -21,10 +21,10 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
-	"github.com/jackc/pgx/v5"
 	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
 	apiutil "github.com/jeewangue/postgres-indb-operator/api/v1alpha1/util"
 	ctlerrors "github.com/jeewangue/postgres-indb-operator/internal/errors"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 
@@ -39,6 +39,9 @@ import (
 type PgHostCredentialReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+	// Connector opens the clients of the hosts. Defaults to
+	// postgres.NewClient.
+	Connector postgres.Connector
 
 	logger   logr.Logger
 	hostCred *api.PgHostCredential
@@ -106,15 +109,17 @@ func (r *PgHostCredentialReconciler) reconcile(ctx context.Context, req reconcil
 		return ctlerrors.NewInvalid(err)
 	}
 
-	conn, err := pgx.Connect(ctx, connStr)
+	db, err := newClient(ctx, r.logger, r.Connector, connStr)
 	if err != nil {
 		return ctlerrors.NewTemporary(err)
 	}
-	defer conn.Close(ctx)
+	defer db.Close()
 
-	if err := conn.Ping(ctx); err != nil {
+	host, err := db.HostInfo()
+	if err != nil {
 		return ctlerrors.NewTemporary(err)
 	}
+	cred.Status.Host = hostStatus(host)
 
 	return nil
 }
@@ -154,3 +159,19 @@ func (r *PgHostCredentialReconciler) handleResult(err error) (ctrl.Result, error
 		return ctrl.Result{RequeueAfter: 10 * time.Second}, err
 	}
 }
+
+// hostStatus returns the status recording host.
+func hostStatus(host postgres.HostInfo) *api.HostStatus {
+	now := metav1.Now()
+	return &api.HostStatus{
+		ServerVersionNum: host.ServerVersionNum,
+		Superuser:        host.Superuser,
+		Replication:      host.Replication,
+		BypassRLS:        host.BypassRLS,
+		ManagedAdminRole: host.ManagedAdminRole,
+		MaxConnections:   host.MaxConnections,
+		SCRAM:            host.SCRAM(),
+		Extensions:       host.Extensions,
+		CheckTime:        &now,
+	}
+}
@@ -0,0 +1,361 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/go-logr/logr"
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	apiutil "github.com/jeewangue/postgres-indb-operator/api/v1alpha1/util"
+	ctlerrors "github.com/jeewangue/postgres-indb-operator/internal/errors"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres"
+)
+
+// defaultMaintenanceTimeout is the timeout of maintenance tasks without one.
+const defaultMaintenanceTimeout = time.Hour
+
+// errTasksFailed is returned if maintenance tasks failed. They are not
+// retried before the next scheduled time.
+var errTasksFailed = goerrors.New("maintenance tasks failed")
+
+// PgMaintenanceReconciler reconciles a PgMaintenance object
+type PgMaintenanceReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// DryRun records the statements of every reconciliation in the status
+	// instead of executing them.
+	DryRun bool
+
+	// Connector opens the clients of the hosts. Defaults to
+	// postgres.NewClient.
+	Connector postgres.Connector
+
+	// now returns the current time. Defaults to time.Now.
+	now func() time.Time
+	// exported holds the names of the tasks whose metrics are exported by
+	// PgMaintenance
+	exported map[types.NamespacedName][]string
+
+	logger      logr.Logger
+	plan        *postgres.Plan
+	maintenance *api.PgMaintenance
+	// requeueAfter is the time until the tasks are scheduled next
+	requeueAfter time.Duration
+}
+
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgmaintenances,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgmaintenances/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgmaintenances/finalizers,verbs=update
+
+// Reconcile runs the tasks of a PgMaintenance in its database if a
+// scheduled time passed since they last ran, and records the outcome of
+// every task in the status and the metrics. Only the latest of several
+// missed times is caught up on.
+func (r *PgMaintenanceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.logger = setupLogger(ctx)
+	r.logger.Info("Reconciling PgMaintenance")
+	r.maintenance = nil
+	r.plan = nil
+	r.requeueAfter = 0
+
+	result, err := r.handleResult(r.reconcile(ctx, req))
+	r.logger.Info("Finished reconciling PgMaintenance")
+	return result, err
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PgMaintenanceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&api.PgMaintenance{}).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: 1,
+			RateLimiter:             DefaultControllerRateLimiter(),
+		}).
+		Complete(r)
+}
+
+func (r *PgMaintenanceReconciler) reconcile(ctx context.Context, req reconcile.Request) error {
+	// Fetch the PgMaintenance instance
+	maintenance := &api.PgMaintenance{}
+	{
+		err := r.Client.Get(ctx, req.NamespacedName, maintenance)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				r.logger.Info("Object not found")
+				r.exportMetrics(req.NamespacedName, nil)
+				return nil
+			}
+			// Error reading the object - requeue the request.
+			return ctlerrors.NewTemporary(err)
+		}
+
+		r.maintenance = maintenance
+	}
+	ctx, r.plan = planContext(ctx, r.DryRun, maintenance)
+
+	r.logger = r.logger.WithValues("maintenance", maintenance.Name)
+	r.logger.Info("Reconciling found PgMaintenance resource")
+
+	if maintenance.GetDeletionTimestamp() != nil {
+		r.exportMetrics(req.NamespacedName, nil)
+		return nil
+	}
+
+	cronSchedule, err := cron.ParseStandard(maintenance.Spec.Schedule)
+	if err != nil {
+		return ctlerrors.NewInvalid(fmt.Errorf("invalid schedule %q: %w", maintenance.Spec.Schedule, err))
+	}
+	if err := validateMaintenanceTasks(maintenance.Spec.Tasks); err != nil {
+		return ctlerrors.NewInvalid(err)
+	}
+
+	// forget the tasks removed from the spec
+	statuses := make(map[string]api.MaintenanceTaskStatus)
+	for _, status := range maintenance.Status.Tasks {
+		statuses[status.Name] = status
+	}
+	maintenance.Status.Tasks = nil
+	for _, task := range maintenance.Spec.Tasks {
+		if status, ok := statuses[task.Name]; ok {
+			maintenance.Status.Tasks = append(maintenance.Status.Tasks, status)
+		}
+	}
+
+	now := time.Now()
+	if r.now != nil {
+		now = r.now()
+	}
+
+	last := maintenance.CreationTimestamp.Time
+	if maintenance.Status.LastScheduleTime != nil {
+		last = maintenance.Status.LastScheduleTime.Time
+	}
+	missed, next := scheduledTimes(cronSchedule, last, now)
+
+	var runErr error
+	if !missed.IsZero() && !maintenance.Spec.Suspend {
+		r.logger.Info("Running scheduled maintenance", "scheduled", missed)
+		runErr = r.runTasks(ctx, maintenance)
+		if runErr != nil && !goerrors.Is(runErr, errTasksFailed) {
+			return runErr
+		}
+	}
+	if !missed.IsZero() && r.plan == nil {
+		maintenance.Status.LastScheduleTime = &metav1.Time{Time: missed}
+	}
+	maintenance.Status.NextScheduleTime = &metav1.Time{Time: next}
+	r.requeueAfter = next.Sub(now)
+	r.exportMetrics(req.NamespacedName, maintenance.Status.Tasks)
+
+	return runErr
+}
+
+// validateMaintenanceTasks returns an error if tasks share a name or lack a
+// required target.
+func validateMaintenanceTasks(tasks []api.MaintenanceTask) error {
+	names := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		if names[task.Name] {
+			return fmt.Errorf("task %s is listed twice", task.Name)
+		}
+		names[task.Name] = true
+		if task.Type == api.MaintenanceRefreshMaterializedView && task.Target == "" {
+			return fmt.Errorf("task %s refreshes a materialized view but has no target", task.Name)
+		}
+	}
+	return nil
+}
+
+// runTasks runs the tasks of maintenance one after the other in its
+// database and records their outcomes in its status. It returns
+// errTasksFailed if some of them failed.
+func (r *PgMaintenanceReconciler) runTasks(ctx context.Context, maintenance *api.PgMaintenance) error {
+	database, err := apiutil.PgDatabaseByName(r.Client, maintenance.Namespace, maintenance.Spec.Database)
+	if err != nil {
+		r.logger.Error(err, "Failed to get database from the maintenance. Skipping '"+maintenance.Spec.Database+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+	if database.Status.Phase != api.PhaseAvailable {
+		return ctlerrors.NewTemporary(fmt.Errorf("database %s is not available", database.Name))
+	}
+
+	hostCred, err := apiutil.PgHostCredentialByName(r.Client, maintenance.Namespace, database.Spec.HostCredential)
+	if err != nil {
+		r.logger.Error(err, "Failed to get host credential from the database. Skipping '"+database.Spec.HostCredential+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+
+	dbname, err := apiutil.DatabaseName(r.Client, hostCred, database.Namespace, database.Spec.Name)
+	if err != nil {
+		return err
+	}
+
+	connStr, err := apiutil.GetConnectionStringWithDatabase(hostCred, r.Client, dbname)
+	if err != nil {
+		r.logger.Error(err, "Failed to get connection string from the database. Skipping '"+database.Spec.HostCredential+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+
+	db, err := newClient(ctx, r.logger, r.Connector, connStr)
+	if err != nil {
+		r.logger.Error(err, "Failed to open database connection")
+		return ctlerrors.NewTemporary(err)
+	}
+	defer db.Close()
+
+	var failed []string
+	for _, task := range maintenance.Spec.Tasks {
+		timeout := defaultMaintenanceTimeout
+		if task.Timeout != nil {
+			timeout = task.Timeout.Duration
+		}
+
+		start := time.Now()
+		err := db.RunMaintenance(postgres.MaintenanceTask{
+			Operation:    postgres.MaintenanceOperation(task.Type),
+			Target:       task.Target,
+			Concurrently: task.Concurrently,
+			Timeout:      timeout,
+		})
+		if r.plan != nil {
+			continue
+		}
+
+		status := api.MaintenanceTaskStatus{
+			Name:        task.Name,
+			LastRunTime: &metav1.Time{Time: start},
+			Duration:    &metav1.Duration{Duration: time.Since(start).Round(time.Millisecond)},
+			Result:      api.MaintenanceSucceeded,
+		}
+		switch {
+		case postgres.IsStatementTimeout(err):
+			status.Result = api.MaintenanceTimedOut
+			status.Message = err.Error()
+		case err != nil:
+			status.Result = api.MaintenanceFailed
+			status.Message = err.Error()
+		}
+		if err != nil {
+			failed = append(failed, task.Name)
+		}
+		setMaintenanceTaskStatus(&maintenance.Status.Tasks, status)
+		maintenanceTaskRuns.WithLabelValues(maintenance.Namespace, maintenance.Name, task.Name, string(status.Result)).Inc()
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %v", errTasksFailed, failed)
+	}
+	return nil
+}
+
+// setMaintenanceTaskStatus replaces the status of the task of the same name
+// in statuses, or appends it.
+func setMaintenanceTaskStatus(statuses *[]api.MaintenanceTaskStatus, status api.MaintenanceTaskStatus) {
+	for i := range *statuses {
+		if (*statuses)[i].Name == status.Name {
+			(*statuses)[i] = status
+			return
+		}
+	}
+	*statuses = append(*statuses, status)
+}
+
+// exportMetrics sets the metrics of the tasks of the PgMaintenance name to
+// their statuses, and deletes the ones of the tasks which are gone.
+func (r *PgMaintenanceReconciler) exportMetrics(name types.NamespacedName, tasks []api.MaintenanceTaskStatus) {
+	if r.exported == nil {
+		r.exported = make(map[types.NamespacedName][]string)
+	}
+	current := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		current[task.Name] = true
+		exportMaintenanceTask(name.Namespace, name.Name, task)
+	}
+	for _, task := range r.exported[name] {
+		if !current[task] {
+			deleteMaintenanceTask(name.Namespace, name.Name, task)
+		}
+	}
+
+	r.exported[name] = nil
+	for _, task := range tasks {
+		r.exported[name] = append(r.exported[name], task.Name)
+	}
+	if len(tasks) == 0 {
+		delete(r.exported, name)
+	}
+}
+
+func (r *PgMaintenanceReconciler) handleResult(err error) (ctrl.Result, error) {
+	var phase api.Phase
+	var errorMessage string
+
+	switch {
+	case err == nil:
+		phase = api.PhaseAvailable
+		errorMessage = ""
+	case goerrors.Is(err, errTasksFailed):
+		// the tasks run again at the next scheduled time
+		phase = api.PhaseFailed
+		errorMessage = err.Error()
+		err = nil
+	case ctlerrors.IsTemporary(err):
+		phase = api.PhaseFailed
+		errorMessage = err.Error()
+	case ctlerrors.IsInvalid(err):
+		phase = api.PhaseInvalid
+		errorMessage = err.Error()
+	default:
+		phase = api.PhaseInvalid
+		errorMessage = err.Error()
+	}
+
+	if r.maintenance == nil {
+		return ctrl.Result{}, err
+	}
+
+	r.maintenance.Status.Phase = phase
+	r.maintenance.Status.PhaseUpdated = metav1.Now()
+	r.maintenance.Status.Error = errorMessage
+	r.maintenance.Status.Plan = r.plan.Statements()
+
+	if err := r.Status().Update(context.Background(), r.maintenance); err != nil {
+		r.logger.Error(err, "Failed to update the status")
+	}
+
+	if err == nil && r.requeueAfter > 0 {
+		return ctrl.Result{RequeueAfter: r.requeueAfter}, nil
+	}
+	isRequeue := (phase == api.PhaseFailed)
+
+	return ctrl.Result{Requeue: isRequeue}, err
+}
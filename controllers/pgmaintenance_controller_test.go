@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres/fake"
+)
+
+var _ = Describe("PgMaintenance controller", func() {
+	var (
+		ctx        context.Context
+		server     *fake.Server
+		reconciler *PgMaintenanceReconciler
+		namespace  string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		server = fake.NewServer("admin")
+		reconciler = &PgMaintenanceReconciler{
+			Client:    k8sClient,
+			Scheme:    scheme.Scheme,
+			Connector: server.Connector(),
+			// a day later, so that a daily schedule passed
+			now: func() time.Time { return time.Now().Add(25 * time.Hour) },
+		}
+		namespace = createNamespace(ctx)
+		createHostCredential(ctx, namespace)
+
+		database := &api.PgDatabase{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "app"},
+			Spec:       api.PgDatabaseSpec{HostCredential: "host", Name: "app"},
+		}
+		Expect(k8sClient.Create(ctx, database)).To(Succeed())
+		databaseReconciler := &PgDatabaseReconciler{Client: k8sClient, Scheme: scheme.Scheme, Connector: server.Connector()}
+		reconcileObject(ctx, databaseReconciler, database)
+		Expect(database.Status.Phase).To(Equal(api.PhaseAvailable), database.Status.Error)
+
+		server.CreateRelation("app", "public.totals", "m")
+		server.CreateRelation("app", "public.events", "r")
+		server.LockRelation("app", "public.events")
+	})
+
+	newMaintenance := func(tasks ...api.MaintenanceTask) *api.PgMaintenance {
+		maintenance := &api.PgMaintenance{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "nightly"},
+			Spec: api.PgMaintenanceSpec{
+				Database: "app",
+				Schedule: "0 3 * * *",
+				Tasks:    tasks,
+			},
+		}
+		Expect(k8sClient.Create(ctx, maintenance)).To(Succeed())
+		return maintenance
+	}
+
+	refresh := api.MaintenanceTask{Name: "refresh", Type: api.MaintenanceRefreshMaterializedView, Target: "totals", Concurrently: true}
+	vacuum := api.MaintenanceTask{Name: "vacuum", Type: api.MaintenanceVacuum, Target: "events", Timeout: &metav1.Duration{Duration: time.Second}}
+
+	It("runs the tasks at the scheduled time", func() {
+		maintenance := newMaintenance(refresh, vacuum)
+		reconcileObject(ctx, reconciler, maintenance)
+
+		Expect(server.Maintenance("app")).To(Equal([]string{`REFRESH MATERIALIZED VIEW CONCURRENTLY "totals"`}))
+		Expect(maintenance.Status.Phase).To(Equal(api.PhaseFailed))
+		Expect(maintenance.Status.Error).To(ContainSubstring("vacuum"))
+		Expect(maintenance.Status.LastScheduleTime).NotTo(BeNil())
+		Expect(maintenance.Status.NextScheduleTime.After(maintenance.Status.LastScheduleTime.Time)).To(BeTrue())
+
+		Expect(maintenance.Status.Tasks).To(HaveLen(2))
+		Expect(maintenance.Status.Tasks[0].Name).To(Equal("refresh"))
+		Expect(maintenance.Status.Tasks[0].Result).To(Equal(api.MaintenanceSucceeded))
+		Expect(maintenance.Status.Tasks[0].Duration).NotTo(BeNil())
+		Expect(maintenance.Status.Tasks[1].Result).To(Equal(api.MaintenanceTimedOut))
+		Expect(maintenance.Status.Tasks[1].Message).To(ContainSubstring("statement timeout"))
+
+		Expect(testutil.ToFloat64(maintenanceTaskSuccess.WithLabelValues(namespace, "nightly", "refresh"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(maintenanceTaskSuccess.WithLabelValues(namespace, "nightly", "vacuum"))).To(Equal(0.0))
+		Expect(testutil.ToFloat64(maintenanceTaskRuns.WithLabelValues(namespace, "nightly", "vacuum", "TimedOut"))).To(Equal(1.0))
+
+		By("not running the tasks again before the next scheduled time")
+		reconcileObject(ctx, reconciler, maintenance)
+		Expect(server.Maintenance("app")).To(HaveLen(1))
+		Expect(maintenance.Status.Phase).To(Equal(api.PhaseAvailable), maintenance.Status.Error)
+
+		By("forgetting the tasks removed from the spec")
+		maintenance.Spec.Tasks = []api.MaintenanceTask{refresh}
+		Expect(k8sClient.Update(ctx, maintenance)).To(Succeed())
+		reconcileObject(ctx, reconciler, maintenance)
+		Expect(maintenance.Status.Tasks).To(HaveLen(1))
+		Expect(maintenanceTaskSuccess.DeleteLabelValues(namespace, "nightly", "vacuum")).To(BeFalse())
+	})
+
+	It("waits for the scheduled time", func() {
+		reconciler.now = nil
+		maintenance := newMaintenance(refresh)
+		reconcileObject(ctx, reconciler, maintenance)
+
+		Expect(maintenance.Status.Phase).To(Equal(api.PhaseAvailable), maintenance.Status.Error)
+		Expect(maintenance.Status.LastScheduleTime).To(BeNil())
+		Expect(maintenance.Status.NextScheduleTime).NotTo(BeNil())
+		Expect(server.Maintenance("app")).To(BeEmpty())
+	})
+
+	It("skips the scheduled time if suspended", func() {
+		maintenance := newMaintenance(refresh)
+		maintenance.Spec.Suspend = true
+		Expect(k8sClient.Update(ctx, maintenance)).To(Succeed())
+		reconcileObject(ctx, reconciler, maintenance)
+
+		Expect(maintenance.Status.Phase).To(Equal(api.PhaseAvailable), maintenance.Status.Error)
+		Expect(maintenance.Status.LastScheduleTime).NotTo(BeNil())
+		Expect(maintenance.Status.Tasks).To(BeEmpty())
+		Expect(server.Maintenance("app")).To(BeEmpty())
+	})
+
+	It("plans the tasks in a dry run", func() {
+		reconciler.DryRun = true
+		maintenance := newMaintenance(refresh)
+		reconcileObject(ctx, reconciler, maintenance)
+
+		Expect(maintenance.Status.Phase).To(Equal(api.PhaseAvailable), maintenance.Status.Error)
+		Expect(maintenance.Status.Plan).To(Equal([]string{
+			"SET statement_timeout = 3600000",
+			`REFRESH MATERIALIZED VIEW CONCURRENTLY "totals"`,
+			"RESET statement_timeout",
+		}))
+		Expect(maintenance.Status.LastScheduleTime).To(BeNil())
+		Expect(maintenance.Status.Tasks).To(BeEmpty())
+		Expect(server.Maintenance("app")).To(BeEmpty())
+	})
+})
@@ -0,0 +1,299 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/strings/slices"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/go-logr/logr"
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	apiutil "github.com/jeewangue/postgres-indb-operator/api/v1alpha1/util"
+	ctlerrors "github.com/jeewangue/postgres-indb-operator/internal/errors"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres"
+)
+
+// migrationSuffix is the suffix of the keys of config maps holding
+// migrations.
+const migrationSuffix = ".sql"
+
+// PgMigrationReconciler reconciles a PgMigration object
+type PgMigrationReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// DryRun records the statements of every reconciliation in the status
+	// instead of executing them.
+	DryRun bool
+
+	// Connector opens the clients of the hosts. Defaults to
+	// postgres.NewClient.
+	Connector postgres.Connector
+
+	logger    logr.Logger
+	plan      *postgres.Plan
+	migration *api.PgMigration
+}
+
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgmigrations,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgmigrations/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgmigrations/finalizers,verbs=update
+
+// Reconcile applies the migrations of a PgMigration which are not recorded
+// in the migration table of its database yet, in the order of their
+// versions. Applied migrations are never applied again; a migration changed
+// after it was applied stops the migration.
+func (r *PgMigrationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.logger = setupLogger(ctx)
+	r.logger.Info("Reconciling PgMigration")
+	r.migration = nil
+	r.plan = nil
+
+	result, err := r.handleResult(r.reconcile(ctx, req))
+	r.logger.Info("Finished reconciling PgMigration")
+	return result, err
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PgMigrationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&api.PgMigration{}).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.referencingMigrations)).
+		Watches(&source.Kind{Type: &api.PgDatabase{}}, handler.EnqueueRequestsFromMapFunc(r.referencingMigrations)).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: 1,
+			RateLimiter:             DefaultControllerRateLimiter(),
+		}).
+		Complete(r)
+}
+
+// referencingMigrations maps a config map or a PgDatabase to the
+// PgMigrations referencing it, so that new migrations are applied as soon as
+// they are added, and once the database is available.
+func (r *PgMigrationReconciler) referencingMigrations(obj client.Object) []reconcile.Request {
+	migrations, err := apiutil.PgMigrations(r.Client, obj.GetNamespace())
+	if err != nil {
+		return nil
+	}
+	_, isDatabase := obj.(*api.PgDatabase)
+	var requests []reconcile.Request
+	for _, migration := range migrations {
+		if isDatabase && migration.Spec.Database == obj.GetName() || !isDatabase && slices.Contains(migration.Spec.ConfigMaps, obj.GetName()) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: migration.Namespace, Name: migration.Name},
+			})
+		}
+	}
+	return requests
+}
+
+func (r *PgMigrationReconciler) reconcile(ctx context.Context, req reconcile.Request) error {
+	// Fetch the PgMigration instance
+	migration := &api.PgMigration{}
+	{
+		err := r.Client.Get(ctx, req.NamespacedName, migration)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				r.logger.Info("Object not found")
+				return nil
+			}
+			// Error reading the object - requeue the request.
+			return ctlerrors.NewTemporary(err)
+		}
+
+		r.migration = migration
+	}
+	ctx, r.plan = planContext(ctx, r.DryRun, migration)
+
+	r.logger = r.logger.WithValues("migration", migration.Name)
+	r.logger.Info("Reconciling found PgMigration resource")
+
+	if migration.GetDeletionTimestamp() != nil {
+		// applied migrations are kept
+		return nil
+	}
+
+	database, err := apiutil.PgDatabaseByName(r.Client, migration.Namespace, migration.Spec.Database)
+	if err != nil {
+		r.logger.Error(err, "Failed to get database from the migration. Skipping '"+migration.Spec.Database+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+	if database.Status.Phase != api.PhaseAvailable {
+		return ctlerrors.NewTemporary(fmt.Errorf("database %s is not available", database.Name))
+	}
+
+	hostCred, err := apiutil.PgHostCredentialByName(r.Client, migration.Namespace, database.Spec.HostCredential)
+	if err != nil {
+		r.logger.Error(err, "Failed to get host credential from the database. Skipping '"+database.Spec.HostCredential+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+
+	dbname, err := apiutil.DatabaseName(r.Client, hostCred, database.Namespace, database.Spec.Name)
+	if err != nil {
+		return err
+	}
+
+	migrations, err := r.migrations(ctx, migration)
+	if err != nil {
+		return err
+	}
+
+	connStr, err := apiutil.GetConnectionStringWithDatabase(hostCred, r.Client, dbname)
+	if err != nil {
+		r.logger.Error(err, "Failed to get connection string from the database. Skipping '"+database.Spec.HostCredential+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+
+	db, err := newClient(ctx, r.logger, r.Connector, connStr)
+	if err != nil {
+		r.logger.Error(err, "Failed to open database connection")
+		return ctlerrors.NewTemporary(err)
+	}
+	defer db.Close()
+
+	applied, err := db.AppliedMigrations()
+	if err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+	status := &migration.Status
+	status.Applied = int32(len(applied))
+	status.Version = ""
+	if len(applied) > 0 {
+		status.Version = applied[len(applied)-1].Version
+	}
+
+	pending, err := postgres.PendingMigrations(migrations, applied)
+	if err != nil {
+		return ctlerrors.NewInvalid(err)
+	}
+	status.Pending = nil
+	for _, m := range pending {
+		status.Pending = append(status.Pending, m.Version)
+	}
+
+	// the scripts run as a login role assuming the owner role, so that they
+	// cannot switch to the admin. A dry run records their statements on the
+	// connection of the admin instead of creating the role.
+	owner := db
+	if len(pending) > 0 && r.plan == nil {
+		ownerDB, closeOwner, err := connectAsOwner(ctx, r.logger, r.Connector, r.Client, db, hostCred, dbname, scriptUserName(migration.UID), ownerOf("PgMigration", migration))
+		if err != nil {
+			return err
+		}
+		defer closeOwner()
+		owner = ownerDB
+	}
+	for _, m := range pending {
+		if err := owner.ApplyMigration(m); err != nil {
+			return ctlerrors.NewTemporary(fmt.Errorf("migration %s: %w", m.Version, err))
+		}
+		if r.plan != nil {
+			continue
+		}
+		now := metav1.Now()
+		status.Version = m.Version
+		status.Applied++
+		status.Pending = status.Pending[1:]
+		status.LastAppliedTime = &now
+	}
+	if len(status.Pending) == 0 {
+		status.Pending = nil
+	}
+
+	return nil
+}
+
+// migrations returns the migrations in the config maps of migration.
+func (r *PgMigrationReconciler) migrations(ctx context.Context, migration *api.PgMigration) ([]postgres.Migration, error) {
+	var (
+		migrations []postgres.Migration
+		sources    = make(map[string]string)
+	)
+	for _, name := range migration.Spec.ConfigMaps {
+		configMap := &corev1.ConfigMap{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: migration.Namespace, Name: name}, configMap); err != nil {
+			r.logger.Error(err, "Failed to get config map of the migration. Skipping '"+name+"'")
+			return nil, ctlerrors.NewTemporary(err)
+		}
+		for key, script := range configMap.Data {
+			if !strings.HasSuffix(key, migrationSuffix) {
+				continue
+			}
+			version := strings.TrimSuffix(key, migrationSuffix)
+			if other, ok := sources[version]; ok && other != name {
+				return nil, ctlerrors.NewInvalid(fmt.Errorf("migration %s is in config maps %s and %s", version, other, name))
+			}
+			sources[version] = name
+			migrations = append(migrations, postgres.Migration{Version: version, Script: script})
+		}
+	}
+	if len(migrations) == 0 {
+		return nil, ctlerrors.NewInvalid(fmt.Errorf("the config maps hold no keys ending in %s", migrationSuffix))
+	}
+	return migrations, nil
+}
+
+func (r *PgMigrationReconciler) handleResult(err error) (ctrl.Result, error) {
+	var phase api.Phase
+	var errorMessage string
+
+	switch {
+	case err == nil:
+		phase = api.PhaseAvailable
+		errorMessage = ""
+	case ctlerrors.IsTemporary(err):
+		phase = api.PhaseFailed
+		errorMessage = err.Error()
+	case ctlerrors.IsInvalid(err):
+		phase = api.PhaseInvalid
+		errorMessage = err.Error()
+	default:
+		phase = api.PhaseInvalid
+		errorMessage = err.Error()
+	}
+
+	if r.migration == nil {
+		return ctrl.Result{}, err
+	}
+
+	r.migration.Status.Phase = phase
+	r.migration.Status.PhaseUpdated = metav1.Now()
+	r.migration.Status.Error = errorMessage
+	r.migration.Status.Plan = r.plan.Statements()
+
+	if err := r.Status().Update(context.Background(), r.migration); err != nil {
+		r.logger.Error(err, "Failed to update the status")
+	}
+
+	isRequeue := (phase == api.PhaseFailed)
+
+	return ctrl.Result{Requeue: isRequeue}, err
+}
@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres/fake"
+)
+
+var _ = Describe("PgMigration controller", func() {
+	var (
+		ctx        context.Context
+		server     *fake.Server
+		reconciler *PgMigrationReconciler
+		namespace  string
+		database   *api.PgDatabase
+		configMap  *corev1.ConfigMap
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		server = fake.NewServer("admin")
+		reconciler = &PgMigrationReconciler{
+			Client:    k8sClient,
+			Scheme:    scheme.Scheme,
+			Connector: server.Connector(),
+		}
+		namespace = createNamespace(ctx)
+		createHostCredential(ctx, namespace)
+
+		database = &api.PgDatabase{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "app"},
+			Spec:       api.PgDatabaseSpec{HostCredential: "host", Name: "app"},
+		}
+		Expect(k8sClient.Create(ctx, database)).To(Succeed())
+
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "migrations"},
+			Data: map[string]string{
+				"0002_insert.sql": "INSERT INTO items VALUES (1);",
+				"0001_create.sql": "CREATE TABLE items (id int);",
+				"README.md":       "not a migration",
+			},
+		}
+		Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+	})
+
+	newMigration := func() *api.PgMigration {
+		migration := &api.PgMigration{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "app"},
+			Spec:       api.PgMigrationSpec{Database: "app", ConfigMaps: []string{"migrations"}},
+		}
+		Expect(k8sClient.Create(ctx, migration)).To(Succeed())
+		return migration
+	}
+
+	createDatabase := func() {
+		databaseReconciler := &PgDatabaseReconciler{Client: k8sClient, Scheme: scheme.Scheme, Connector: server.Connector()}
+		reconcileObject(ctx, databaseReconciler, database)
+		Expect(database.Status.Phase).To(Equal(api.PhaseAvailable), database.Status.Error)
+	}
+
+	It("applies new migrations in order", func() {
+		createDatabase()
+		migration := newMigration()
+		reconcileObject(ctx, reconciler, migration)
+
+		Expect(migration.Status.Phase).To(Equal(api.PhaseAvailable), migration.Status.Error)
+		Expect(migration.Status.Version).To(Equal("0002_insert"))
+		Expect(migration.Status.Applied).To(Equal(int32(2)))
+		Expect(migration.Status.Pending).To(BeEmpty())
+		Expect(migration.Status.LastAppliedTime).NotTo(BeNil())
+		Expect(server.Scripts("app")).To(Equal([]string{"CREATE TABLE items (id int);", "INSERT INTO items VALUES (1);"}))
+		Expect(server.Migrations("app")).To(HaveLen(2))
+		Expect(server.RoleExists(scriptUserName(migration.UID))).To(BeFalse())
+
+		By("applying migrations added later only")
+		configMap.Data["0003_alter.sql"] = "ALTER TABLE items ADD COLUMN name text;"
+		Expect(k8sClient.Update(ctx, configMap)).To(Succeed())
+		reconcileObject(ctx, reconciler, migration)
+
+		Expect(migration.Status.Phase).To(Equal(api.PhaseAvailable), migration.Status.Error)
+		Expect(migration.Status.Version).To(Equal("0003_alter"))
+		Expect(migration.Status.Applied).To(Equal(int32(3)))
+		Expect(server.Scripts("app")).To(HaveLen(3))
+		Expect(server.Migrations("app")).To(HaveLen(3))
+	})
+
+	It("stops at migrations changed after they were applied", func() {
+		createDatabase()
+		migration := newMigration()
+		reconcileObject(ctx, reconciler, migration)
+		Expect(migration.Status.Phase).To(Equal(api.PhaseAvailable), migration.Status.Error)
+
+		configMap.Data["0001_create.sql"] = "CREATE TABLE items (id bigint);"
+		configMap.Data["0003_alter.sql"] = "ALTER TABLE items ADD COLUMN name text;"
+		Expect(k8sClient.Update(ctx, configMap)).To(Succeed())
+		reconcileObject(ctx, reconciler, migration)
+
+		Expect(migration.Status.Phase).To(Equal(api.PhaseInvalid))
+		Expect(migration.Status.Error).To(ContainSubstring(postgres.ErrMigrationChanged.Error()))
+		Expect(migration.Status.Version).To(Equal("0002_insert"))
+		Expect(server.Migrations("app")).To(HaveLen(2))
+	})
+
+	It("waits for the database to be available", func() {
+		migration := newMigration()
+		reconcileObject(ctx, reconciler, migration)
+
+		Expect(migration.Status.Phase).To(Equal(api.PhaseFailed))
+		Expect(migration.Status.Error).To(ContainSubstring("not available"))
+	})
+
+	It("plans the migrations in a dry run", func() {
+		createDatabase()
+		migration := &api.PgMigration{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   namespace,
+				Name:        "app",
+				Annotations: map[string]string{api.AnnotationDryRun: "true"},
+			},
+			Spec: api.PgMigrationSpec{Database: "app", ConfigMaps: []string{"migrations"}},
+		}
+		Expect(k8sClient.Create(ctx, migration)).To(Succeed())
+		reconcileObject(ctx, reconciler, migration)
+
+		Expect(migration.Status.Phase).To(Equal(api.PhaseAvailable), migration.Status.Error)
+		Expect(migration.Status.Pending).To(Equal([]string{"0001_create", "0002_insert"}))
+		Expect(migration.Status.Plan).To(ContainElements("CREATE TABLE items (id int);", "INSERT INTO items VALUES (1);"))
+		Expect(server.Migrations("app")).To(BeEmpty())
+	})
+})
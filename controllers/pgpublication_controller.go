@@ -0,0 +1,280 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/strings/slices"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/go-logr/logr"
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	apiutil "github.com/jeewangue/postgres-indb-operator/api/v1alpha1/util"
+	ctlerrors "github.com/jeewangue/postgres-indb-operator/internal/errors"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres"
+)
+
+// PgPublicationReconciler reconciles a PgPublication object
+type PgPublicationReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// DryRun records the statements of every reconciliation in the status
+	// instead of executing them.
+	DryRun bool
+
+	// Connector opens the clients of the hosts. Defaults to
+	// postgres.NewClient.
+	Connector postgres.Connector
+
+	logger      logr.Logger
+	plan        *postgres.Plan
+	publication *api.PgPublication
+}
+
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgpublications,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgpublications/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgpublications/finalizers,verbs=update
+
+// Reconcile creates or alters the publication described by a PgPublication
+// and drops the publication created by a previous version of it if it was
+// renamed or moved to another database.
+func (r *PgPublicationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.logger = setupLogger(ctx)
+	r.logger.Info("Reconciling PgPublication")
+	r.publication = nil
+	r.plan = nil
+
+	result, err := r.handleResult(r.reconcile(ctx, req))
+	r.logger.Info("Finished reconciling PgPublication")
+	return result, err
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PgPublicationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&api.PgPublication{}).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: 1,
+			RateLimiter:             DefaultControllerRateLimiter(),
+		}).
+		Complete(r)
+}
+
+func (r *PgPublicationReconciler) reconcile(ctx context.Context, req reconcile.Request) error {
+	// Fetch the PgPublication instance
+	publication := &api.PgPublication{}
+	{
+		err := r.Client.Get(ctx, req.NamespacedName, publication)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				r.logger.Info("Object not found")
+				return nil
+			}
+			// Error reading the object - requeue the request.
+			return ctlerrors.NewTemporary(err)
+		}
+
+		r.publication = publication
+	}
+	ctx, r.plan = planContext(ctx, r.DryRun, publication)
+	if r.plan != nil {
+		// a dry run neither creates nor drops publications
+		status := publication.Status
+		defer func() {
+			publication.Status.HostCredential = status.HostCredential
+			publication.Status.Database = status.Database
+			publication.Status.Name = status.Name
+		}()
+	}
+
+	r.logger = r.logger.WithValues("publication", publication.Name)
+	r.logger.Info("Reconciling found PgPublication resource")
+
+	if publication.GetDeletionTimestamp() != nil {
+		if !slices.Contains(publication.Finalizers, operatorFinalizer) {
+			return nil
+		}
+		if err := r.finalize(ctx, publication); err != nil {
+			return err
+		}
+		if r.plan != nil {
+			// keep the publication until it is dropped for real
+			return nil
+		}
+
+		controllerutil.RemoveFinalizer(publication, operatorFinalizer)
+		if err := r.Update(ctx, publication); err != nil {
+			return ctlerrors.NewTemporary(err)
+		}
+
+		return nil
+	}
+
+	// Add finalizer for this CR
+	if !slices.Contains(publication.Finalizers, operatorFinalizer) {
+		controllerutil.AddFinalizer(publication, operatorFinalizer)
+
+		if err := r.Update(ctx, publication); err != nil {
+			return ctlerrors.NewTemporary(err)
+		}
+	}
+
+	database, err := apiutil.PgDatabaseByName(r.Client, publication.Namespace, publication.Spec.Database)
+	if err != nil {
+		r.logger.Error(err, "Failed to get database from the publication. Skipping '"+publication.Spec.Database+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+
+	hostCred, err := apiutil.PgHostCredentialByName(r.Client, publication.Namespace, database.Spec.HostCredential)
+	if err != nil {
+		r.logger.Error(err, "Failed to get host credential from the database. Skipping '"+database.Spec.HostCredential+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+
+	dbname, err := apiutil.DatabaseName(r.Client, hostCred, database.Namespace, database.Spec.Name)
+	if err != nil {
+		return err
+	}
+
+	// Drop the publication created before if it was renamed or moved
+	status := &publication.Status
+	if status.Name != "" && (status.HostCredential != database.Spec.HostCredential || status.Database != dbname || status.Name != publication.Spec.Name) {
+		if err := r.drop(ctx, publication); err != nil {
+			return err
+		}
+	}
+
+	connStr, err := apiutil.GetConnectionStringWithDatabase(hostCred, r.Client, dbname)
+	if err != nil {
+		r.logger.Error(err, "Failed to get connection string from the database. Skipping '"+database.Spec.HostCredential+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+
+	db, err := newClient(ctx, r.logger, r.Connector, connStr)
+	if err != nil {
+		r.logger.Error(err, "Failed to open database connection")
+		return ctlerrors.NewTemporary(err)
+	}
+	defer db.Close()
+
+	publish := make([]string, len(publication.Spec.Publish))
+	for i, op := range publication.Spec.Publish {
+		publish[i] = string(op)
+	}
+	err = db.EnsurePublication(postgres.Publication{
+		Name:      publication.Spec.Name,
+		AllTables: publication.Spec.AllTables,
+		Tables:    publication.Spec.Tables,
+		Publish:   publish,
+	})
+	if err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+
+	status.HostCredential = database.Spec.HostCredential
+	status.Database = dbname
+	status.Name = publication.Spec.Name
+	return nil
+}
+
+// drop drops the publication recorded in the status of publication.
+func (r *PgPublicationReconciler) drop(ctx context.Context, publication *api.PgPublication) error {
+	status := &publication.Status
+	if status.Name == "" {
+		return nil
+	}
+
+	hostCred, err := apiutil.PgHostCredentialByName(r.Client, publication.Namespace, status.HostCredential)
+	if err != nil {
+		r.logger.Error(err, "Failed to get host credential of the created publication. Skipping '"+status.HostCredential+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+	connStr, err := apiutil.GetConnectionStringWithDatabase(hostCred, r.Client, status.Database)
+	if err != nil {
+		r.logger.Error(err, "Failed to get connection string of the created publication. Skipping '"+status.HostCredential+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+	db, err := newClient(ctx, r.logger, r.Connector, connStr)
+	if err != nil {
+		r.logger.Error(err, "Failed to open database connection")
+		return ctlerrors.NewTemporary(err)
+	}
+	defer db.Close()
+
+	if err := db.DropPublication(status.Name); err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+	status.HostCredential = ""
+	status.Database = ""
+	status.Name = ""
+	return nil
+}
+
+func (r *PgPublicationReconciler) finalize(ctx context.Context, publication *api.PgPublication) error {
+	if err := r.drop(ctx, publication); err != nil {
+		return err
+	}
+
+	r.logger.Info("Successfully finalized PgPublication")
+	return nil
+}
+
+func (r *PgPublicationReconciler) handleResult(err error) (ctrl.Result, error) {
+	var phase api.Phase
+	var errorMessage string
+
+	switch {
+	case err == nil:
+		phase = api.PhaseAvailable
+		errorMessage = ""
+	case ctlerrors.IsTemporary(err):
+		phase = api.PhaseFailed
+		errorMessage = err.Error()
+	case ctlerrors.IsInvalid(err):
+		phase = api.PhaseInvalid
+		errorMessage = err.Error()
+	default:
+		phase = api.PhaseInvalid
+		errorMessage = err.Error()
+	}
+
+	if r.publication == nil {
+		return ctrl.Result{}, err
+	}
+
+	r.publication.Status.Phase = phase
+	r.publication.Status.PhaseUpdated = metav1.Now()
+	r.publication.Status.Error = errorMessage
+	r.publication.Status.Plan = r.plan.Statements()
+
+	if err := r.Status().Update(context.Background(), r.publication); err != nil {
+		r.logger.Error(err, "Failed to update the status")
+	}
+
+	isRequeue := (phase == api.PhaseFailed)
+
+	return ctrl.Result{Requeue: isRequeue}, err
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres/fake"
+)
+
+var _ = Describe("PgPublication controller", func() {
+	var (
+		ctx        context.Context
+		server     *fake.Server
+		reconciler *PgPublicationReconciler
+		namespace  string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		server = fake.NewServer("admin")
+		reconciler = &PgPublicationReconciler{
+			Client:    k8sClient,
+			Scheme:    scheme.Scheme,
+			Connector: server.Connector(),
+		}
+		namespace = createNamespace(ctx)
+		createHostCredential(ctx, namespace)
+
+		database := &api.PgDatabase{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "app"},
+			Spec:       api.PgDatabaseSpec{HostCredential: "host", Name: "app"},
+		}
+		Expect(k8sClient.Create(ctx, database)).To(Succeed())
+		databaseReconciler := &PgDatabaseReconciler{Client: k8sClient, Scheme: scheme.Scheme, Connector: server.Connector()}
+		reconcileObject(ctx, databaseReconciler, database)
+		Expect(database.Status.Phase).To(Equal(api.PhaseAvailable), database.Status.Error)
+
+		server.CreateRelation("app", "public.accounts", "r")
+		server.CreateRelation("app", "public.orders", "r")
+	})
+
+	It("creates, alters and drops the publication", func() {
+		publication := &api.PgPublication{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "changes"},
+			Spec:       api.PgPublicationSpec{Database: "app", Name: "changes", Tables: []string{"accounts", "public.orders"}},
+		}
+		Expect(k8sClient.Create(ctx, publication)).To(Succeed())
+		reconcileObject(ctx, reconciler, publication)
+		Expect(publication.Status.Phase).To(Equal(api.PhaseAvailable), publication.Status.Error)
+		Expect(publication.Status.Name).To(Equal("changes"))
+		tables, publish, ok := server.Publication("app", "changes")
+		Expect(ok).To(BeTrue())
+		Expect(tables).To(Equal([]string{"public.accounts", "public.orders"}))
+		Expect(publish).To(Equal([]string{"insert", "update", "delete", "truncate"}))
+
+		By("changing the tables and the published operations")
+		publication.Spec.Tables = []string{"orders"}
+		publication.Spec.Publish = []api.PublishOperation{api.PublishInsert, api.PublishUpdate}
+		Expect(k8sClient.Update(ctx, publication)).To(Succeed())
+		reconcileObject(ctx, reconciler, publication)
+		Expect(publication.Status.Phase).To(Equal(api.PhaseAvailable), publication.Status.Error)
+		tables, publish, _ = server.Publication("app", "changes")
+		Expect(tables).To(Equal([]string{"public.orders"}))
+		Expect(publish).To(Equal([]string{"insert", "update"}))
+
+		By("publishing all tables")
+		publication.Spec.Tables = nil
+		publication.Spec.AllTables = true
+		Expect(k8sClient.Update(ctx, publication)).To(Succeed())
+		reconcileObject(ctx, reconciler, publication)
+		Expect(publication.Status.Phase).To(Equal(api.PhaseAvailable), publication.Status.Error)
+		tables, _, _ = server.Publication("app", "changes")
+		Expect(tables).To(Equal([]string{"ALL TABLES"}))
+
+		By("renaming the publication")
+		publication.Spec.Name = "all_changes"
+		Expect(k8sClient.Update(ctx, publication)).To(Succeed())
+		reconcileObject(ctx, reconciler, publication)
+		Expect(publication.Status.Phase).To(Equal(api.PhaseAvailable), publication.Status.Error)
+		_, _, ok = server.Publication("app", "changes")
+		Expect(ok).To(BeFalse())
+		_, _, ok = server.Publication("app", "all_changes")
+		Expect(ok).To(BeTrue())
+
+		By("deleting the publication")
+		deleteObject(ctx, reconciler, publication)
+		_, _, ok = server.Publication("app", "all_changes")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("fails on a missing table", func() {
+		publication := &api.PgPublication{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "changes"},
+			Spec:       api.PgPublicationSpec{Database: "app", Name: "changes", Tables: []string{"missing"}},
+		}
+		Expect(k8sClient.Create(ctx, publication)).To(Succeed())
+		reconcileObject(ctx, reconciler, publication)
+		Expect(publication.Status.Phase).To(Equal(api.PhaseFailed))
+		Expect(publication.Status.Name).To(BeEmpty())
+		_, _, ok := server.Publication("app", "changes")
+		Expect(ok).To(BeFalse())
+	})
+})
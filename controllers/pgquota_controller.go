@@ -0,0 +1,250 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	apiutil "github.com/jeewangue/postgres-indb-operator/api/v1alpha1/util"
+	ctlerrors "github.com/jeewangue/postgres-indb-operator/internal/errors"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres"
+)
+
+// PgQuotaReconciler reconciles a PgQuota object
+type PgQuotaReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// ResyncPeriod is the interval at which the usage of available quotas is
+	// measured again, as databases grow without changes to custom
+	// resources. Zero disables resyncing.
+	ResyncPeriod time.Duration
+
+	// Connector opens the clients of the hosts. Defaults to
+	// postgres.NewClient.
+	Connector postgres.Connector
+
+	logger logr.Logger
+	quota  *api.PgQuota
+}
+
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgquotas,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgquotas/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgquotas/finalizers,verbs=update
+
+// Reconcile measures the usage of the host of a PgQuota by its namespace and
+// reports whether it exceeds the limits.
+//
+// For more details, check Reconcile and its Result here:
+// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.12.2/pkg/reconcile
+func (r *PgQuotaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.logger = setupLogger(ctx)
+	r.logger.Info("Reconciling PgQuota")
+	r.quota = nil
+
+	result, err := r.handleResult(r.reconcile(ctx, req))
+	r.logger.Info("Finished reconciling PgQuota")
+	return result, err
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PgQuotaReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&api.PgQuota{}).
+		Watches(&source.Kind{Type: &api.PgDatabase{}}, handler.EnqueueRequestsFromMapFunc(r.namespaceQuotas)).
+		Watches(&source.Kind{Type: &api.PgUser{}}, handler.EnqueueRequestsFromMapFunc(r.namespaceQuotas)).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: 1,
+			RateLimiter:             DefaultControllerRateLimiter(),
+		}).
+		Complete(r)
+}
+
+// namespaceQuotas maps an object to the PgQuotas in its namespace.
+func (r *PgQuotaReconciler) namespaceQuotas(obj client.Object) []reconcile.Request {
+	quotas, err := apiutil.PgQuotas(r.Client, obj.GetNamespace())
+	if err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, len(quotas))
+	for i, quota := range quotas {
+		requests[i] = reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: quota.Namespace, Name: quota.Name},
+		}
+	}
+	return requests
+}
+
+func (r *PgQuotaReconciler) reconcile(ctx context.Context, req reconcile.Request) error {
+	// Fetch the PgQuota instance
+	quota := &api.PgQuota{}
+	{
+		err := r.Client.Get(ctx, req.NamespacedName, quota)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				// Request object not found, could have been deleted after reconcile request.
+				// Return and don't requeue
+				r.logger.Info("Object not found")
+				return nil
+			}
+			// Error reading the object - requeue the request.
+			return ctlerrors.NewTemporary(err)
+		}
+
+		r.quota = quota
+	}
+
+	// PgQuota instance created or updated
+	r.logger = r.logger.WithValues("quota", quota.Name)
+	r.logger.Info("Reconciling found PgQuota resource")
+
+	databases, err := apiutil.PgDatabases(r.Client, quota.Namespace)
+	if err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+	users, err := apiutil.PgUsers(r.Client, quota.Namespace)
+	if err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+	roles, err := apiutil.PgRoles(r.Client, quota.Namespace)
+	if err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+
+	usage, unlimited := apiutil.QuotaUsage(quota.Spec.HostCredential, databases, users, roles)
+	if quota.Spec.MaxSize != nil {
+		size, err := r.databasesSize(ctx, quota, databases)
+		if err != nil {
+			return err
+		}
+		usage.Size = resource.NewQuantity(size, resource.BinarySI)
+	}
+	quota.Status.Used = usage
+
+	condition := metav1.Condition{
+		Type:               api.ConditionQuotaExceeded,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: quota.Generation,
+		Reason:             "WithinLimits",
+		Message:            "The usage is within the limits",
+	}
+	if exceeded := apiutil.ExceededLimits(quota, usage, unlimited); len(exceeded) > 0 {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "LimitsExceeded"
+		condition.Message = strings.Join(exceeded, ", ")
+		r.logger.Info("Quota exceeded", "exceeded", exceeded)
+	}
+	meta.SetStatusCondition(&quota.Status.Conditions, condition)
+
+	return nil
+}
+
+// databasesSize returns the total size of databases on the host of quota.
+func (r *PgQuotaReconciler) databasesSize(ctx context.Context, quota *api.PgQuota, databases []api.PgDatabase) (int64, error) {
+	var size int64
+	var db *postgres.Client
+	var hostCred *api.PgHostCredential
+	for _, database := range databases {
+		if database.Spec.HostCredential != quota.Spec.HostCredential {
+			continue
+		}
+		if db == nil {
+			var err error
+			hostCred, err = apiutil.PgHostCredentialByName(r.Client, quota.Namespace, quota.Spec.HostCredential)
+			if err != nil {
+				r.logger.Error(err, "Failed to get host credential from the quota. Skipping '"+quota.Spec.HostCredential+"'")
+				return 0, ctlerrors.NewTemporary(err)
+			}
+			connStr, err := apiutil.GetConnectionString(hostCred, r.Client)
+			if err != nil {
+				r.logger.Error(err, "Failed to get connection string from the quota. Skipping '"+quota.Spec.HostCredential+"'")
+				return 0, ctlerrors.NewTemporary(err)
+			}
+			db, err = newClient(ctx, r.logger, r.Connector, connStr)
+			if err != nil {
+				r.logger.Error(err, "Failed to open database connection")
+				return 0, ctlerrors.NewTemporary(err)
+			}
+			defer db.Close()
+		}
+
+		dbname, err := apiutil.DatabaseName(r.Client, hostCred, database.Namespace, database.Spec.Name)
+		if err != nil {
+			return 0, err
+		}
+		databaseSize, err := db.DatabaseSize(dbname)
+		if err != nil {
+			return 0, ctlerrors.NewTemporary(err)
+		}
+		size += databaseSize
+	}
+	return size, nil
+}
+
+func (r *PgQuotaReconciler) handleResult(err error) (ctrl.Result, error) {
+	var phase api.Phase
+	var errorMessage string
+
+	switch {
+	case err == nil:
+		phase = api.PhaseAvailable
+		errorMessage = ""
+	case ctlerrors.IsTemporary(err):
+		phase = api.PhaseFailed
+		errorMessage = err.Error()
+	case ctlerrors.IsInvalid(err):
+		phase = api.PhaseInvalid
+		errorMessage = err.Error()
+	default:
+		phase = api.PhaseInvalid
+		errorMessage = err.Error()
+	}
+
+	if r.quota != nil {
+		r.quota.Status.Phase = phase
+		r.quota.Status.PhaseUpdated = metav1.Now()
+		r.quota.Status.Error = errorMessage
+
+		if err := r.Status().Update(context.Background(), r.quota); err != nil {
+			r.logger.Error(err, "Failed to update the status")
+		}
+	}
+
+	isRequeue := (phase == api.PhaseFailed)
+	if phase == api.PhaseAvailable && r.ResyncPeriod > 0 {
+		return ctrl.Result{RequeueAfter: r.ResyncPeriod}, err
+	}
+
+	return ctrl.Result{Requeue: isRequeue}, err
+}
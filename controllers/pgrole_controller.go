@@ -0,0 +1,383 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/strings/slices"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/go-logr/logr"
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	apiutil "github.com/jeewangue/postgres-indb-operator/api/v1alpha1/util"
+	ctlerrors "github.com/jeewangue/postgres-indb-operator/internal/errors"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres"
+)
+
+// PgRoleReconciler reconciles a PgRole object
+type PgRoleReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// DryRun records the statements of every reconciliation in the status
+	// instead of executing them.
+	DryRun bool
+
+	// Connector opens the clients of the hosts. Defaults to
+	// postgres.NewClient.
+	Connector postgres.Connector
+
+	logger logr.Logger
+	plan   *postgres.Plan
+	role   *api.PgRole
+}
+
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgroles,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgroles/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgroles/finalizers,verbs=update
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgnameclaims,verbs=get;list;watch;create;delete
+
+// Reconcile creates the NOLOGIN role described by a PgRole, grants it the
+// requested database privileges and makes it a member of the referenced
+// PgRoles.
+func (r *PgRoleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.logger = setupLogger(ctx)
+	r.logger.Info("Reconciling PgRole")
+	r.plan = nil
+
+	result, err := r.handleResult(r.reconcile(ctx, req))
+	r.logger.Info("Finished reconciling PgRole")
+	return result, err
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PgRoleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&api.PgRole{}).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: 1,
+			RateLimiter:             DefaultControllerRateLimiter(),
+		}).
+		Complete(r)
+}
+
+func (r *PgRoleReconciler) reconcile(ctx context.Context, req reconcile.Request) error {
+	// Fetch the PgRole instance
+	role := &api.PgRole{}
+	{
+		err := r.Client.Get(ctx, req.NamespacedName, role)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				// Request object not found, could have been deleted after reconcile request.
+				// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
+				// Return and don't requeue
+				r.logger.Info("Object not found")
+				return nil
+			}
+			// Error reading the object - requeue the request.
+			return ctlerrors.NewTemporary(err)
+		}
+
+		r.role = role
+	}
+	ctx, r.plan = planContext(ctx, r.DryRun, role)
+	ctx = ownerContext(ctx, r.Client)
+
+	// PgRole instance created or updated
+	r.logger = r.logger.WithValues("role", role.Name)
+	r.logger.Info("Reconciling found PgRole resource")
+
+	if role.GetDeletionTimestamp() != nil {
+		if !slices.Contains(role.Finalizers, operatorFinalizer) {
+			return nil
+		}
+		// Run finalization logic. If the
+		// finalization logic fails, don't remove the finalizer so
+		// that we can retry during the next reconciliation.
+		if err := r.finalize(ctx, role); err != nil {
+			return err
+		}
+
+		// Remove finalizer. Once all finalizers have been
+		// removed, the object will be deleted.
+		controllerutil.RemoveFinalizer(role, operatorFinalizer)
+		if err := r.Update(ctx, role); err != nil {
+			return ctlerrors.NewTemporary(err)
+		}
+
+		return nil
+	}
+
+	// Add finalizer for this CR
+	if !slices.Contains(role.Finalizers, operatorFinalizer) {
+		controllerutil.AddFinalizer(role, operatorFinalizer)
+
+		if err := r.Update(ctx, role); err != nil {
+			return ctlerrors.NewTemporary(err)
+		}
+	}
+
+	// Connect to database
+	hostCred, err := apiutil.PgHostCredentialByName(r.Client, role.Namespace, role.Spec.HostCredential)
+	if err != nil {
+		r.logger.Error(err, "Failed to get host credential from the role. Skipping '"+role.Spec.HostCredential+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+
+	name, err := apiutil.RoleName(r.Client, hostCred, role.Namespace, role.Spec.Name)
+	if err != nil {
+		return err
+	}
+	claim, err := nameClaim(r.Client, hostCred, name)
+	if err != nil {
+		return err
+	}
+	if err := claimName(ctx, r.Client, api.NameTypeRole, claim, "PgRole", role); err != nil {
+		return err
+	}
+	if previous := role.Status.Claim; previous != nil && !sameName(*previous, claim) {
+		if err := releaseName(ctx, r.Client, api.NameTypeRole, *previous, role.UID); err != nil {
+			return err
+		}
+	}
+	role.Status.Claim = &claim
+
+	{
+		connStr, err := apiutil.GetConnectionString(hostCred, r.Client)
+		if err != nil {
+			r.logger.Error(err, "Failed to get connection string from the role. Skipping '"+role.Spec.HostCredential+"'")
+			return ctlerrors.NewTemporary(err)
+		}
+
+		db, err := newClient(ctx, r.logger, r.Connector, connStr)
+		if err != nil {
+			r.logger.Error(err, "Failed to open database connection")
+			return ctlerrors.NewTemporary(err)
+		}
+		defer db.Close()
+
+		if err := db.EnsureOwnedRole(name, ownerOf("PgRole", role), role.Spec.Adopt); err != nil {
+			return ownershipError(err)
+		}
+
+		var memberOf []string
+		for _, parentName := range role.Spec.MemberOf {
+			parent, err := apiutil.PgRoleByName(r.Client, role.Namespace, parentName)
+			if err != nil {
+				r.logger.Error(err, "Failed to get parent role. Skipping '"+parentName+"'")
+				return ctlerrors.NewTemporary(err)
+			}
+			if parent.Spec.HostCredential != role.Spec.HostCredential {
+				return ctlerrors.NewInvalid(fmt.Errorf("role %s is on host credential %s, not %s", parentName, parent.Spec.HostCredential, role.Spec.HostCredential))
+			}
+
+			parentRole, err := apiutil.RoleName(r.Client, hostCred, parent.Namespace, parent.Spec.Name)
+			if err != nil {
+				return err
+			}
+			if err := db.EnsureRoleToUser(parentRole, name); err != nil {
+				return ctlerrors.NewTemporary(err)
+			}
+			memberOf = append(memberOf, parentRole)
+		}
+
+		// Revoke the memberships removed from the specification
+		for _, parentRole := range role.Status.MemberOf {
+			if slices.Contains(memberOf, parentRole) {
+				continue
+			}
+			if err := revokeMembership(db, parentRole, name); err != nil {
+				return err
+			}
+		}
+		if r.plan == nil {
+			role.Status.MemberOf = memberOf
+		}
+	}
+
+	var desired []api.AppliedRolePrivilege
+	for _, privilege := range role.Spec.Privileges {
+		dbname, err := apiutil.DatabaseName(r.Client, hostCred, role.Namespace, privilege.Database)
+		if err != nil {
+			return err
+		}
+		desired = append(desired, api.AppliedRolePrivilege{
+			Database:   dbname,
+			Permission: privilege.Permission,
+			Privileges: privilege.Privileges,
+		})
+	}
+
+	// Revoke the privileges removed from the specification first, so that
+	// privileges also granted by another entry are restored below
+	var applied []api.AppliedRolePrivilege
+	for _, privilege := range role.Status.Privileges {
+		if containsRolePrivilege(desired, privilege) {
+			applied = append(applied, privilege)
+			continue
+		}
+		if err := r.applyPrivilege(ctx, hostCred, privilege, name, revokePermission); err != nil {
+			return err
+		}
+	}
+
+	for _, privilege := range desired {
+		if err := r.applyPrivilege(ctx, hostCred, privilege, name, ensurePermission); err != nil {
+			return err
+		}
+		if !containsRolePrivilege(applied, privilege) {
+			applied = append(applied, privilege)
+		}
+	}
+	if r.plan == nil {
+		role.Status.Privileges = applied
+	}
+
+	return nil
+}
+
+// applyPrivilege grants or revokes privilege from the role name with apply,
+// connecting to the database of privilege.
+func (r *PgRoleReconciler) applyPrivilege(ctx context.Context, hostCred *api.PgHostCredential, privilege api.AppliedRolePrivilege, name string,
+	apply func(db *postgres.Client, dbname, schema string, perm api.Perm, privileges []api.Privilege, grantee string) error) error {
+	connStr, err := apiutil.GetConnectionStringWithDatabase(hostCred, r.Client, privilege.Database)
+	if err != nil {
+		r.logger.Error(err, "Failed to get connection string from the role. Skipping '"+r.role.Spec.HostCredential+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+
+	db, err := newClient(ctx, r.logger, r.Connector, connStr)
+	if err != nil {
+		r.logger.Error(err, "Failed to open database connection")
+		return ctlerrors.NewTemporary(err)
+	}
+	defer db.Close()
+
+	return apply(db, privilege.Database, "public", privilege.Permission, privilege.Privileges, name)
+}
+
+// containsRolePrivilege returns whether privileges contains an entry with
+// the same database, permission and privileges as privilege.
+func containsRolePrivilege(privileges []api.AppliedRolePrivilege, privilege api.AppliedRolePrivilege) bool {
+	for _, other := range privileges {
+		if other.Database == privilege.Database &&
+			other.Permission == privilege.Permission &&
+			samePrivileges(other.Privileges, privilege.Privileges) {
+			return true
+		}
+	}
+	return false
+}
+
+// finalize revokes the memberships of the role and releases its name. Like
+// the login roles of PgUsers, the role and its privileges are kept on the
+// host, since objects and other roles may depend on them.
+func (r *PgRoleReconciler) finalize(ctx context.Context, role *api.PgRole) error {
+	if role.Status.Claim == nil {
+		r.logger.Info("Successfully finalized PgRole")
+		return nil
+	}
+	if err := r.revokeMemberships(ctx, role); err != nil {
+		return err
+	}
+	if err := releaseName(ctx, r.Client, api.NameTypeRole, *role.Status.Claim, role.UID); err != nil {
+		return err
+	}
+
+	r.logger.Info("Successfully finalized PgRole")
+	return nil
+}
+
+// revokeMemberships revokes the memberships of the role recorded in the
+// status.
+func (r *PgRoleReconciler) revokeMemberships(ctx context.Context, role *api.PgRole) error {
+	if len(role.Status.MemberOf) == 0 {
+		return nil
+	}
+
+	hostCred, err := apiutil.PgHostCredentialByName(r.Client, role.Namespace, role.Spec.HostCredential)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// the host is no longer managed
+			r.logger.Info("Host credential not found. Skipping the revocation of memberships")
+			return nil
+		}
+		return ctlerrors.NewTemporary(err)
+	}
+	connStr, err := apiutil.GetConnectionString(hostCred, r.Client)
+	if err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+	db, err := newClient(ctx, r.logger, r.Connector, connStr)
+	if err != nil {
+		r.logger.Error(err, "Failed to open database connection")
+		return ctlerrors.NewTemporary(err)
+	}
+	defer db.Close()
+
+	for _, parentRole := range role.Status.MemberOf {
+		if err := revokeMembership(db, parentRole, role.Status.Claim.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *PgRoleReconciler) handleResult(err error) (ctrl.Result, error) {
+	var phase api.Phase
+	var errorMessage string
+
+	switch {
+	case err == nil:
+		phase = api.PhaseAvailable
+		errorMessage = ""
+	case ctlerrors.IsTemporary(err):
+		phase = api.PhaseFailed
+		errorMessage = err.Error()
+	case ctlerrors.IsInvalid(err):
+		phase = api.PhaseInvalid
+		errorMessage = err.Error()
+	default:
+		phase = api.PhaseInvalid
+		errorMessage = err.Error()
+	}
+
+	if r.role != nil {
+		r.role.Status.Phase = phase
+		r.role.Status.PhaseUpdated = metav1.Now()
+		r.role.Status.Error = errorMessage
+		r.role.Status.Plan = r.plan.Statements()
+		setConflictCondition(&r.role.Status.Conditions, r.role.Generation, err)
+	}
+
+	if err := r.Status().Update(context.Background(), r.role); err != nil {
+		r.logger.Error(err, "Failed to update the status")
+	}
+
+	isRequeue := (phase == api.PhaseFailed)
+
+	return ctrl.Result{Requeue: isRequeue}, err
+}
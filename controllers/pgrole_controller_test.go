@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres/fake"
+)
+
+var _ = Describe("PgRole controller", func() {
+	var (
+		ctx        context.Context
+		server     *fake.Server
+		reconciler *PgRoleReconciler
+		namespace  string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		server = fake.NewServer("admin")
+		reconciler = &PgRoleReconciler{
+			Client:    k8sClient,
+			Scheme:    scheme.Scheme,
+			Connector: server.Connector(),
+		}
+		namespace = createNamespace(ctx)
+		createHostCredential(ctx, namespace)
+	})
+
+	createRole := func(name string, memberOf ...string) *api.PgRole {
+		role := &api.PgRole{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       api.PgRoleSpec{HostCredential: "host", Name: name, MemberOf: memberOf},
+		}
+		Expect(k8sClient.Create(ctx, role)).To(Succeed())
+		reconcileObject(ctx, reconciler, role)
+		Expect(role.Status.Phase).To(Equal(api.PhaseAvailable), role.Status.Error)
+		return role
+	}
+
+	It("revokes removed memberships and keeps the role when deleted", func() {
+		createRole("analysts")
+		createRole("auditors")
+		role := createRole("reporting", "analysts", "auditors")
+		Expect(server.IsMember("analysts", "reporting")).To(BeTrue())
+		Expect(server.IsMember("auditors", "reporting")).To(BeTrue())
+		Expect(role.Status.MemberOf).To(ConsistOf("analysts", "auditors"))
+
+		By("removing a parent role")
+		role.Spec.MemberOf = []string{"analysts"}
+		Expect(k8sClient.Update(ctx, role)).To(Succeed())
+		reconcileObject(ctx, reconciler, role)
+		Expect(role.Status.Phase).To(Equal(api.PhaseAvailable), role.Status.Error)
+		Expect(server.IsMember("analysts", "reporting")).To(BeTrue())
+		Expect(server.IsMember("auditors", "reporting")).To(BeFalse())
+		Expect(role.Status.MemberOf).To(ConsistOf("analysts"))
+
+		By("deleting the role")
+		deleteObject(ctx, reconciler, role)
+		Expect(server.IsMember("analysts", "reporting")).To(BeFalse())
+		Expect(server.RoleExists("reporting")).To(BeTrue())
+	})
+
+	It("revokes privileges removed from the specification", func() {
+		Expect(server.Exec("postgres", "CREATE DATABASE app")).To(Succeed())
+		Expect(server.Exec("postgres", "CREATE ROLE app_readonly")).To(Succeed())
+		Expect(server.Exec("postgres", "CREATE ROLE app_readwrite")).To(Succeed())
+
+		role := &api.PgRole{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "reporting"},
+			Spec: api.PgRoleSpec{
+				HostCredential: "host",
+				Name:           "reporting",
+				Privileges:     []api.RolePrivilege{{Database: "app", Permission: api.PermReadOnly}},
+			},
+		}
+		Expect(k8sClient.Create(ctx, role)).To(Succeed())
+		reconcileObject(ctx, reconciler, role)
+		Expect(role.Status.Phase).To(Equal(api.PhaseAvailable), role.Status.Error)
+		Expect(server.IsMember("app_readonly", "reporting")).To(BeTrue())
+		Expect(role.Status.Privileges).To(ConsistOf(api.AppliedRolePrivilege{Database: "app", Permission: api.PermReadOnly}))
+
+		By("changing the permission")
+		role.Spec.Privileges[0].Permission = api.PermReadWrite
+		Expect(k8sClient.Update(ctx, role)).To(Succeed())
+		reconcileObject(ctx, reconciler, role)
+		Expect(role.Status.Phase).To(Equal(api.PhaseAvailable), role.Status.Error)
+		Expect(server.IsMember("app_readonly", "reporting")).To(BeFalse())
+		Expect(server.IsMember("app_readwrite", "reporting")).To(BeTrue())
+
+		By("removing the privilege")
+		role.Spec.Privileges = nil
+		Expect(k8sClient.Update(ctx, role)).To(Succeed())
+		reconcileObject(ctx, reconciler, role)
+		Expect(role.Status.Phase).To(Equal(api.PhaseAvailable), role.Status.Error)
+		Expect(server.IsMember("app_readwrite", "reporting")).To(BeFalse())
+		Expect(role.Status.Privileges).To(BeEmpty())
+	})
+
+	It("takes over the role of a deleted PgRole", func() {
+		role := createRole("analysts")
+		deleteObject(ctx, reconciler, role)
+		Expect(server.RoleExists("analysts")).To(BeTrue())
+
+		By("recreating the PgRole")
+		recreated := createRole("analysts")
+		Expect(server.RoleComment("analysts")).To(ContainSubstring(string(recreated.UID)))
+
+		By("renaming the PgRole")
+		deleteObject(ctx, reconciler, recreated)
+		renamed := &api.PgRole{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "renamed"},
+			Spec:       api.PgRoleSpec{HostCredential: "host", Name: "analysts"},
+		}
+		Expect(k8sClient.Create(ctx, renamed)).To(Succeed())
+		reconcileObject(ctx, reconciler, renamed)
+		Expect(renamed.Status.Phase).To(Equal(api.PhaseAvailable), renamed.Status.Error)
+		Expect(server.RoleComment("analysts")).To(ContainSubstring(string(renamed.UID)))
+	})
+
+	It("refuses a role name claimed by another resource", func() {
+		createRole("analysts")
+
+		role := &api.PgRole{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "other"},
+			Spec:       api.PgRoleSpec{HostCredential: "host", Name: "analysts"},
+		}
+		Expect(k8sClient.Create(ctx, role)).To(Succeed())
+		reconcileObject(ctx, reconciler, role)
+		Expect(role.Status.Phase).To(Equal(api.PhaseInvalid))
+		Expect(meta.IsStatusConditionTrue(role.Status.Conditions, api.ConditionConflict)).To(BeTrue())
+		Expect(claimedNames(ctx, role.UID)).To(BeEmpty())
+	})
+})
@@ -0,0 +1,522 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/strings/slices"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/go-logr/logr"
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	apiutil "github.com/jeewangue/postgres-indb-operator/api/v1alpha1/util"
+	ctlerrors "github.com/jeewangue/postgres-indb-operator/internal/errors"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres"
+)
+
+// replicationPasswordKey is the key of the password in the Secret of the
+// login role of a subscription.
+const replicationPasswordKey = "password"
+
+// PgSubscriptionReconciler reconciles a PgSubscription object
+type PgSubscriptionReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// ResyncPeriod is the interval at which the state of the replication
+	// is measured again. Zero disables resyncing.
+	ResyncPeriod time.Duration
+
+	// DryRun records the statements of every reconciliation in the status
+	// instead of executing them.
+	DryRun bool
+
+	// Connector opens the clients of the hosts. Defaults to
+	// postgres.NewClient.
+	Connector postgres.Connector
+
+	logger       logr.Logger
+	plan         *postgres.Plan
+	subscription *api.PgSubscription
+}
+
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgsubscriptions,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgsubscriptions/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgsubscriptions/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=create;update;patch;delete
+
+// Reconcile creates or alters the subscription described by a
+// PgSubscription and reports the state of the replication from the
+// subscriber and the replication slot on the source host.
+func (r *PgSubscriptionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.logger = setupLogger(ctx)
+	r.logger.Info("Reconciling PgSubscription")
+	r.subscription = nil
+	r.plan = nil
+
+	result, err := r.handleResult(r.reconcile(ctx, req))
+	r.logger.Info("Finished reconciling PgSubscription")
+	return result, err
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *PgSubscriptionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&api.PgSubscription{}).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: 1,
+			RateLimiter:             DefaultControllerRateLimiter(),
+		}).
+		Complete(r)
+}
+
+func (r *PgSubscriptionReconciler) reconcile(ctx context.Context, req reconcile.Request) error {
+	// Fetch the PgSubscription instance
+	subscription := &api.PgSubscription{}
+	{
+		err := r.Client.Get(ctx, req.NamespacedName, subscription)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				r.logger.Info("Object not found")
+				return nil
+			}
+			// Error reading the object - requeue the request.
+			return ctlerrors.NewTemporary(err)
+		}
+
+		r.subscription = subscription
+	}
+	ctx, r.plan = planContext(ctx, r.DryRun, subscription)
+	if r.plan != nil {
+		// a dry run neither creates nor drops subscriptions
+		status := subscription.Status
+		defer func() {
+			subscription.Status.HostCredential = status.HostCredential
+			subscription.Status.Database = status.Database
+			subscription.Status.Name = status.Name
+			subscription.Status.Source = status.Source
+			if subscription.GetDeletionTimestamp() != nil {
+				subscription.Status.Replication = status.Replication
+			}
+		}()
+	}
+
+	r.logger = r.logger.WithValues("subscription", subscription.Name)
+	r.logger.Info("Reconciling found PgSubscription resource")
+
+	if subscription.GetDeletionTimestamp() != nil {
+		if !slices.Contains(subscription.Finalizers, operatorFinalizer) {
+			return nil
+		}
+		if err := r.finalize(ctx, subscription); err != nil {
+			return err
+		}
+		if r.plan != nil {
+			// keep the subscription until it is dropped for real
+			return nil
+		}
+
+		controllerutil.RemoveFinalizer(subscription, operatorFinalizer)
+		if err := r.Update(ctx, subscription); err != nil {
+			return ctlerrors.NewTemporary(err)
+		}
+
+		return nil
+	}
+
+	// Add finalizer for this CR
+	if !slices.Contains(subscription.Finalizers, operatorFinalizer) {
+		controllerutil.AddFinalizer(subscription, operatorFinalizer)
+
+		if err := r.Update(ctx, subscription); err != nil {
+			return ctlerrors.NewTemporary(err)
+		}
+	}
+
+	database, err := apiutil.PgDatabaseByName(r.Client, subscription.Namespace, subscription.Spec.Database)
+	if err != nil {
+		r.logger.Error(err, "Failed to get database from the subscription. Skipping '"+subscription.Spec.Database+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+
+	hostCred, err := apiutil.PgHostCredentialByName(r.Client, subscription.Namespace, database.Spec.HostCredential)
+	if err != nil {
+		r.logger.Error(err, "Failed to get host credential from the database. Skipping '"+database.Spec.HostCredential+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+
+	dbname, err := apiutil.DatabaseName(r.Client, hostCred, database.Namespace, database.Spec.Name)
+	if err != nil {
+		return err
+	}
+
+	source := subscription.Spec.Source
+	sourceCred, err := apiutil.PgHostCredentialByName(r.Client, subscription.Namespace, source.HostCredential)
+	if err != nil {
+		r.logger.Error(err, "Failed to get host credential of the source. Skipping '"+source.HostCredential+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+
+	sourceConnStr, err := apiutil.GetConnectionStringWithDatabase(sourceCred, r.Client, source.Database)
+	if err != nil {
+		r.logger.Error(err, "Failed to get connection string of the source. Skipping '"+source.HostCredential+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+
+	// Drop the login role created before on another source
+	status := &subscription.Status
+	if status.Source != nil && (status.Source.HostCredential != source.HostCredential || status.Source.Database != source.Database) {
+		if err := r.dropReplicationUser(ctx, subscription); err != nil {
+			return err
+		}
+	}
+
+	// Drop the subscription created before if it was renamed or moved
+	if status.Name != "" && (status.HostCredential != database.Spec.HostCredential || status.Database != dbname || status.Name != subscription.Spec.Name) {
+		if err := r.drop(ctx, subscription); err != nil {
+			return err
+		}
+	}
+
+	connStr, err := apiutil.GetConnectionStringWithDatabase(hostCred, r.Client, dbname)
+	if err != nil {
+		r.logger.Error(err, "Failed to get connection string from the database. Skipping '"+database.Spec.HostCredential+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+
+	db, err := newClient(ctx, r.logger, r.Connector, connStr)
+	if err != nil {
+		r.logger.Error(err, "Failed to open database connection")
+		return ctlerrors.NewTemporary(err)
+	}
+	defer db.Close()
+
+	sourceDB, err := newClient(ctx, r.logger, r.Connector, sourceConnStr)
+	if err != nil {
+		r.logger.Error(err, "Failed to open source database connection")
+		return ctlerrors.NewTemporary(err)
+	}
+	defer sourceDB.Close()
+
+	// The subscription connects as a login role which may only read the
+	// published tables, so that neither the credentials of the admin end up
+	// in pg_subscription nor the replication runs as the admin
+	user := replicationUserName(subscription.UID)
+	password, err := r.replicationPassword(ctx, subscription)
+	if err != nil {
+		return err
+	}
+	if err := sourceDB.EnsureUser(user, password, ownerOf("PgSubscription", subscription), false); err != nil {
+		return ownershipError(err)
+	}
+	attrs := postgres.DefaultRoleAttributes()
+	attrs.Replication = true
+	if err := sourceDB.EnsureRoleAttributes(user, attrs); err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+	tables, err := sourceDB.PublishedTables(subscription.Spec.Publications)
+	if err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+	var applied []string
+	if status.Source != nil {
+		applied = status.Source.Tables
+	}
+	if err := sourceDB.EnsureReplicationGrants(user, source.Database, tables, applied); err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+	status.Source = &api.SubscriptionSourceStatus{
+		HostCredential: source.HostCredential,
+		Database:       source.Database,
+		User:           user,
+		Tables:         tables,
+	}
+	connInfo, err := loginConnectionString(r.Client, sourceCred, source.Database, user, password)
+	if err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+
+	slotName := subscription.Spec.SlotName
+	if slotName == "" {
+		slotName = subscription.Spec.Name
+	}
+	sameHost, err := r.sameHost(hostCred, sourceCred)
+	if err != nil {
+		return err
+	}
+	if sameHost {
+		// creating the slot with the subscription waits for the transaction
+		// creating the subscription on the same host to finish
+		if err := sourceDB.EnsureReplicationSlot(slotName); err != nil {
+			return ctlerrors.NewTemporary(err)
+		}
+	}
+	err = db.EnsureSubscription(postgres.Subscription{
+		Name:         subscription.Spec.Name,
+		ConnInfo:     connInfo,
+		Publications: subscription.Spec.Publications,
+		SlotName:     slotName,
+		SlotCreated:  sameHost,
+		Enabled:      subscription.Spec.Enabled == nil || *subscription.Spec.Enabled,
+	})
+	if err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+	status.HostCredential = database.Spec.HostCredential
+	status.Database = dbname
+	status.Name = subscription.Spec.Name
+
+	replication, err := r.replicationStatus(db, sourceDB, subscription.Spec.Name, slotName)
+	if err != nil {
+		return err
+	}
+	status.Replication = replication
+	return nil
+}
+
+// sameHost returns whether the host credentials hostCred and sourceCred
+// connect to the same host.
+func (r *PgSubscriptionReconciler) sameHost(hostCred, sourceCred *api.PgHostCredential) (bool, error) {
+	host, err := apiutil.HostAddress(hostCred, r.Client)
+	if err != nil {
+		r.logger.Error(err, "Failed to get host address. Skipping '"+hostCred.Name+"'")
+		return false, ctlerrors.NewTemporary(err)
+	}
+	sourceHost, err := apiutil.HostAddress(sourceCred, r.Client)
+	if err != nil {
+		r.logger.Error(err, "Failed to get host address of the source. Skipping '"+sourceCred.Name+"'")
+		return false, ctlerrors.NewTemporary(err)
+	}
+	return host == sourceHost, nil
+}
+
+// replicationStatus measures the state of the subscription name on db and of
+// its replication slot on the source host.
+func (r *PgSubscriptionReconciler) replicationStatus(db, source *postgres.Client, name, slotName string) (*api.ReplicationStatus, error) {
+	stats, err := db.SubscriptionStats(name)
+	if err != nil {
+		return nil, ctlerrors.NewTemporary(err)
+	}
+
+	slot, err := source.ReplicationSlot(slotName)
+	if err != nil {
+		return nil, ctlerrors.NewTemporary(err)
+	}
+
+	replication := &api.ReplicationStatus{
+		WorkerRunning: stats.WorkerRunning,
+		ReceivedLSN:   stats.ReceivedLSN,
+		LatestEndLSN:  stats.LatestEndLSN,
+	}
+	if stats.LastMessageReceiptTime != nil {
+		t := metav1.NewTime(*stats.LastMessageReceiptTime)
+		replication.LastMessageReceiptTime = &t
+	}
+	if stats.LatestEndTime != nil {
+		t := metav1.NewTime(*stats.LatestEndTime)
+		replication.LatestEndTime = &t
+	}
+	if slot != nil {
+		replication.SlotActive = slot.Active
+		replication.ConfirmedFlushLSN = slot.ConfirmedFlushLSN
+		replication.LagBytes = slot.LagBytes
+	}
+	return replication, nil
+}
+
+// drop drops the subscription recorded in the status of subscription.
+func (r *PgSubscriptionReconciler) drop(ctx context.Context, subscription *api.PgSubscription) error {
+	status := &subscription.Status
+	if status.Name == "" {
+		return nil
+	}
+
+	hostCred, err := apiutil.PgHostCredentialByName(r.Client, subscription.Namespace, status.HostCredential)
+	if err != nil {
+		r.logger.Error(err, "Failed to get host credential of the created subscription. Skipping '"+status.HostCredential+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+	connStr, err := apiutil.GetConnectionStringWithDatabase(hostCred, r.Client, status.Database)
+	if err != nil {
+		r.logger.Error(err, "Failed to get connection string of the created subscription. Skipping '"+status.HostCredential+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+	db, err := newClient(ctx, r.logger, r.Connector, connStr)
+	if err != nil {
+		r.logger.Error(err, "Failed to open database connection")
+		return ctlerrors.NewTemporary(err)
+	}
+	defer db.Close()
+
+	if err := db.DropSubscription(status.Name); err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+	status.HostCredential = ""
+	status.Database = ""
+	status.Name = ""
+	status.Replication = nil
+	return nil
+}
+
+// replicationUserName returns the name of the login role the subscription
+// with uid connects to its source with.
+func replicationUserName(uid types.UID) string {
+	sum := sha256.Sum256([]byte(uid))
+	return "pgrepl_" + hex.EncodeToString(sum[:8])
+}
+
+// replicationSecretName returns the name of the Secret with the password of
+// the login role of subscription.
+func replicationSecretName(subscription *api.PgSubscription) string {
+	return subscription.Name + "-pgreplication"
+}
+
+// replicationPassword returns the password of the login role of
+// subscription. A random one is generated and kept in a Secret owned by
+// subscription the first time.
+func (r *PgSubscriptionReconciler) replicationPassword(ctx context.Context, subscription *api.PgSubscription) (string, error) {
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: subscription.Namespace, Name: replicationSecretName(subscription)}, secret)
+	switch {
+	case err == nil && len(secret.Data[replicationPasswordKey]) > 0:
+		return string(secret.Data[replicationPasswordKey]), nil
+	case err != nil && !errors.IsNotFound(err):
+		return "", ctlerrors.NewTemporary(err)
+	}
+
+	password, err := randomPassword()
+	if err != nil {
+		return "", ctlerrors.NewTemporary(err)
+	}
+	if r.plan != nil {
+		// a dry run does not keep the password it would set
+		return password, nil
+	}
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: subscription.Namespace,
+			Name:      replicationSecretName(subscription),
+		},
+		Data: map[string][]byte{replicationPasswordKey: []byte(password)},
+	}
+	if err := controllerutil.SetControllerReference(subscription, secret, r.Scheme); err != nil {
+		return "", ctlerrors.NewTemporary(err)
+	}
+	if err := applySecret(ctx, r.Client, secret); err != nil {
+		return "", ctlerrors.NewTemporary(err)
+	}
+	return password, nil
+}
+
+// dropReplicationUser drops the login role recorded in the status of
+// subscription from its source host.
+func (r *PgSubscriptionReconciler) dropReplicationUser(ctx context.Context, subscription *api.PgSubscription) error {
+	source := subscription.Status.Source
+	if source == nil {
+		return nil
+	}
+
+	sourceCred, err := apiutil.PgHostCredentialByName(r.Client, subscription.Namespace, source.HostCredential)
+	if err != nil {
+		r.logger.Error(err, "Failed to get host credential of the replication user. Skipping '"+source.HostCredential+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+	connStr, err := apiutil.GetConnectionStringWithDatabase(sourceCred, r.Client, source.Database)
+	if err != nil {
+		r.logger.Error(err, "Failed to get connection string of the replication user. Skipping '"+source.HostCredential+"'")
+		return ctlerrors.NewTemporary(err)
+	}
+	sourceDB, err := newClient(ctx, r.logger, r.Connector, connStr)
+	if err != nil {
+		r.logger.Error(err, "Failed to open source database connection")
+		return ctlerrors.NewTemporary(err)
+	}
+	defer sourceDB.Close()
+
+	if err := sourceDB.DropReplicationUser(source.User); err != nil {
+		return ctlerrors.NewTemporary(err)
+	}
+	subscription.Status.Source = nil
+	return nil
+}
+
+func (r *PgSubscriptionReconciler) finalize(ctx context.Context, subscription *api.PgSubscription) error {
+	// the subscription drops its slot on the source as the login role, so
+	// the role is dropped after it
+	if err := r.drop(ctx, subscription); err != nil {
+		return err
+	}
+	if err := r.dropReplicationUser(ctx, subscription); err != nil {
+		return err
+	}
+
+	r.logger.Info("Successfully finalized PgSubscription")
+	return nil
+}
+
+func (r *PgSubscriptionReconciler) handleResult(err error) (ctrl.Result, error) {
+	var phase api.Phase
+	var errorMessage string
+
+	switch {
+	case err == nil:
+		phase = api.PhaseAvailable
+		errorMessage = ""
+	case ctlerrors.IsTemporary(err):
+		phase = api.PhaseFailed
+		errorMessage = err.Error()
+	case ctlerrors.IsInvalid(err):
+		phase = api.PhaseInvalid
+		errorMessage = err.Error()
+	default:
+		phase = api.PhaseInvalid
+		errorMessage = err.Error()
+	}
+
+	if r.subscription == nil {
+		return ctrl.Result{}, err
+	}
+
+	r.subscription.Status.Phase = phase
+	r.subscription.Status.PhaseUpdated = metav1.Now()
+	r.subscription.Status.Error = errorMessage
+	r.subscription.Status.Plan = r.plan.Statements()
+
+	if err := r.Status().Update(context.Background(), r.subscription); err != nil {
+		r.logger.Error(err, "Failed to update the status")
+	}
+
+	isRequeue := (phase == api.PhaseFailed)
+	if phase == api.PhaseAvailable && r.ResyncPeriod > 0 && r.subscription.GetDeletionTimestamp() == nil {
+		return ctrl.Result{RequeueAfter: r.ResyncPeriod}, err
+	}
+
+	return ctrl.Result{Requeue: isRequeue}, err
+}
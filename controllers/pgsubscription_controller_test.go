@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres/fake"
+)
+
+var _ = Describe("PgSubscription controller", func() {
+	var (
+		ctx        context.Context
+		server     *fake.Server
+		reconciler *PgSubscriptionReconciler
+		namespace  string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		server = fake.NewServer("admin")
+		reconciler = &PgSubscriptionReconciler{
+			Client:    k8sClient,
+			Scheme:    scheme.Scheme,
+			Connector: server.Connector(),
+		}
+		namespace = createNamespace(ctx)
+		createHostCredential(ctx, namespace)
+
+		databaseReconciler := &PgDatabaseReconciler{Client: k8sClient, Scheme: scheme.Scheme, Connector: server.Connector()}
+		for _, name := range []string{"app", "replica"} {
+			database := &api.PgDatabase{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+				Spec:       api.PgDatabaseSpec{HostCredential: "host", Name: name},
+			}
+			Expect(k8sClient.Create(ctx, database)).To(Succeed())
+			reconcileObject(ctx, databaseReconciler, database)
+			Expect(database.Status.Phase).To(Equal(api.PhaseAvailable), database.Status.Error)
+		}
+
+		server.CreateRelation("app", "public.accounts", "r")
+		Expect(server.Exec("app", `CREATE PUBLICATION "changes" FOR TABLE "public"."accounts" WITH (publish = 'insert, update, delete, truncate')`)).To(Succeed())
+		Expect(server.Exec("app", `CREATE PUBLICATION "audit" WITH (publish = 'insert')`)).To(Succeed())
+	})
+
+	It("creates the slot on the same host and alters and drops the subscription", func() {
+		subscription := &api.PgSubscription{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "changes"},
+			Spec: api.PgSubscriptionSpec{
+				Database:     "replica",
+				Name:         "changes",
+				Source:       api.SubscriptionSource{HostCredential: "host", Database: "app"},
+				Publications: []string{"changes"},
+			},
+		}
+		Expect(k8sClient.Create(ctx, subscription)).To(Succeed())
+		reconcileObject(ctx, reconciler, subscription)
+		Expect(subscription.Status.Phase).To(Equal(api.PhaseAvailable), subscription.Status.Error)
+		Expect(server.ReplicationSlotExists("changes")).To(BeTrue())
+		created := server.Subscription("replica", "changes")
+		Expect(created).NotTo(BeNil())
+		Expect(created.Enabled).To(BeTrue())
+		Expect(created.SlotName).To(Equal("changes"))
+		Expect(created.Publications).To(Equal([]string{"changes"}))
+		Expect(subscription.Status.Source).NotTo(BeNil())
+		user := subscription.Status.Source.User
+		Expect(created.ConnInfo).To(HavePrefix("postgresql://" + user + ":"))
+		Expect(created.ConnInfo).NotTo(ContainSubstring("admin"))
+		Expect(server.CanLogin(user)).To(BeTrue())
+		Expect(server.Attributes(user).Replication).To(BeTrue())
+		Expect(server.HasDatabasePrivilege(user, "app", "CONNECT")).To(BeTrue())
+		Expect(server.Privileges("app", user, "public.accounts")).To(Equal([]string{"SELECT"}))
+		Expect(subscription.Status.Source.Tables).To(Equal([]string{"public.accounts"}))
+		Expect(subscription.Status.Replication).NotTo(BeNil())
+		Expect(subscription.Status.Replication.WorkerRunning).To(BeTrue())
+		Expect(subscription.Status.Replication.SlotActive).To(BeTrue())
+
+		By("disabling the subscription and changing its publications and slot")
+		subscription.Spec.Enabled = pointer.Bool(false)
+		subscription.Spec.Publications = []string{"changes", "audit"}
+		subscription.Spec.SlotName = "changes_v2"
+		Expect(k8sClient.Update(ctx, subscription)).To(Succeed())
+		reconcileObject(ctx, reconciler, subscription)
+		Expect(subscription.Status.Phase).To(Equal(api.PhaseAvailable), subscription.Status.Error)
+		Expect(server.ReplicationSlotExists("changes_v2")).To(BeTrue())
+		altered := server.Subscription("replica", "changes")
+		Expect(altered.Enabled).To(BeFalse())
+		Expect(altered.SlotName).To(Equal("changes_v2"))
+		Expect(altered.Publications).To(Equal([]string{"changes", "audit"}))
+		Expect(subscription.Status.Replication.WorkerRunning).To(BeFalse())
+		Expect(subscription.Status.Replication.SlotActive).To(BeFalse())
+
+		By("enabling the subscription again")
+		subscription.Spec.Enabled = nil
+		Expect(k8sClient.Update(ctx, subscription)).To(Succeed())
+		reconcileObject(ctx, reconciler, subscription)
+		Expect(subscription.Status.Phase).To(Equal(api.PhaseAvailable), subscription.Status.Error)
+		Expect(server.Subscription("replica", "changes").Enabled).To(BeTrue())
+		Expect(subscription.Status.Replication.SlotActive).To(BeTrue())
+
+		By("removing the table from the publication")
+		Expect(server.Exec("app", `ALTER PUBLICATION "changes" DROP TABLE "public"."accounts"`)).To(Succeed())
+		reconcileObject(ctx, reconciler, subscription)
+		Expect(subscription.Status.Phase).To(Equal(api.PhaseAvailable), subscription.Status.Error)
+		Expect(server.Privileges("app", user, "public.accounts")).To(BeEmpty())
+		Expect(subscription.Status.Source.Tables).To(BeEmpty())
+
+		By("deleting the subscription")
+		deleteObject(ctx, reconciler, subscription)
+		Expect(server.Subscription("replica", "changes")).To(BeNil())
+		Expect(server.ReplicationSlotExists("changes_v2")).To(BeFalse())
+		Expect(server.RoleExists(user)).To(BeFalse())
+		Expect(server.HasDatabasePrivilege(user, "app", "CONNECT")).To(BeFalse())
+	})
+})
@@ -18,16 +18,24 @@ package controllers
 
 import (
 	"context"
+	goerrors "errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/utils/strings/slices"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/go-logr/logr"
 	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
@@ -41,13 +49,36 @@ type PgUserReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
 
-	logger logr.Logger
-	user   *api.PgUser
+	// AllowedRoleAttributes lists the privileged role attributes (createdb,
+	// replication and bypassrls) users may enable.
+	AllowedRoleAttributes []string
+
+	// ResyncPeriod is the interval at which available users are reconciled
+	// again, repairing or reporting drift of the live state. Zero disables
+	// resyncing.
+	ResyncPeriod time.Duration
+
+	// DryRun records the statements of every reconciliation in the status
+	// instead of executing them.
+	DryRun bool
+
+	// Connector opens the clients of the hosts. Defaults to
+	// postgres.NewClient.
+	Connector postgres.Connector
+
+	logger       logr.Logger
+	plan         *postgres.Plan
+	user         *api.PgUser
+	requeueAfter time.Duration
+	// claims holds the login roles claimed by the reconciliation
+	claims []api.NameClaim
 }
 
 //+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgusers,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgusers/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgusers/finalizers,verbs=update
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgaccessapprovals,verbs=get;list;watch
+//+kubebuilder:rbac:groups=postgres.jeewangue.com,resources=pgnameclaims,verbs=get;list;watch;create;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -61,6 +92,9 @@ type PgUserReconciler struct {
 func (r *PgUserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	r.logger = setupLogger(ctx)
 	r.logger.Info("Reconciling PgUser")
+	r.plan = nil
+	r.requeueAfter = 0
+	r.claims = nil
 
 	result, err := r.handleResult(r.reconcile(ctx, req))
 	r.logger.Info("Finished reconciling PgUser")
@@ -71,6 +105,10 @@ func (r *PgUserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 func (r *PgUserReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&api.PgUser{}).
+		Watches(
+			&source.Kind{Type: &api.PgAccessApproval{}},
+			handler.EnqueueRequestsFromMapFunc(approvedUser),
+		).
 		WithOptions(controller.Options{
 			MaxConcurrentReconciles: 1,
 			RateLimiter:             DefaultControllerRateLimiter(),
@@ -78,6 +116,17 @@ func (r *PgUserReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		Complete(r)
 }
 
+// approvedUser maps a PgAccessApproval to the PgUser it approves.
+func approvedUser(obj client.Object) []reconcile.Request {
+	approval, ok := obj.(*api.PgAccessApproval)
+	if !ok {
+		return nil
+	}
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Namespace: approval.Namespace, Name: approval.Spec.User},
+	}}
+}
+
 func (r *PgUserReconciler) reconcile(ctx context.Context, req reconcile.Request) error {
 	// Fetch the PgUser instance
 	user := &api.PgUser{}
@@ -97,6 +146,13 @@ func (r *PgUserReconciler) reconcile(ctx context.Context, req reconcile.Request)
 
 		r.user = user
 	}
+	ctx, r.plan = planContext(ctx, r.DryRun, user)
+	ctx = ownerContext(ctx, r.Client)
+	if r.plan != nil {
+		// a dry run neither grants nor revokes access
+		accessGrants := user.Status.AccessGrants
+		defer func() { user.Status.AccessGrants = accessGrants }()
+	}
 
 	// PgUser instance created or updated
 	r.logger = r.logger.WithValues("user", user.Name)
@@ -109,7 +165,7 @@ func (r *PgUserReconciler) reconcile(ctx context.Context, req reconcile.Request)
 		// Run finalization logic. If the
 		// finalization logic fails, don't remove the finalizer so
 		// that we can retry during the next reconciliation.
-		if err := r.finalize(user); err != nil {
+		if err := r.finalize(ctx, user); err != nil {
 			return err
 		}
 
@@ -142,57 +198,764 @@ func (r *PgUserReconciler) reconcile(ctx context.Context, req reconcile.Request)
 		return ctlerrors.NewInvalid(err)
 	}
 
+	attrs, err := r.roleAttributes(user)
+	if err != nil {
+		return ctlerrors.NewInvalid(err)
+	}
+
+	if err := validateParameters(user); err != nil {
+		return ctlerrors.NewInvalid(err)
+	}
+
 	dbs := make(map[string]*postgres.Client)
+	defer func() {
+		for _, db := range dbs {
+			db.Close()
+		}
+	}()
+	// hosts holds a client for every host the user exists on and settings
+	// the desired session defaults per host and database
+	hosts := make(map[string]*postgres.Client)
+	settings := make(map[string]map[string]map[string]string)
+	// names holds the name of the login role on every host
+	names := make(map[string]string)
+
+	// With the Report drift policy, access granted before and the roles are
+	// only compared with the live state once the generation was reconciled.
+	// Expired and removed access is still revoked and new access granted.
+	report := reportsDrift(user.Spec.DriftPolicy, user.Generation, user.Status.ObservedGeneration)
+	var diffs []string
+	checked := make(map[string]bool)
+
+	grants, revokes, err := r.planAccessGrants(user, time.Now())
+	if err != nil {
+		return err
+	}
 
-	// Connect to database
-	if user.Spec.AccessSpecs != nil {
-		for _, accessSpec := range *user.Spec.AccessSpecs {
-			hostCred, err := apiutil.PgHostCredentialByName(r.Client, user.Namespace, accessSpec.HostCredential)
+	grants, pending, err := r.approveAccessGrants(user, grants)
+	if err != nil {
+		return err
+	}
+	for _, grant := range pending {
+		if grant.record.GrantedAt != nil {
+			// the approval was withdrawn
+			revokes = append(revokes, grant.record)
+		}
+	}
+	setPendingCondition(user, pending)
+
+	// Revoke expired and removed access first, so that access granted by
+	// another access specification is restored below
+	for _, record := range revokes {
+		db, dbname, err := r.client(ctx, dbs, user.Namespace, record.HostCredential, record.Database)
+		if err != nil {
+			return err
+		}
+		name, err := r.loginName(ctx, names, user, record.HostCredential, username)
+		if err != nil {
+			return err
+		}
+
+		if err := revokePermission(db, dbname, record.Schema, record.Permission, record.Privileges, name); err != nil {
+			return err
+		}
+		now := metav1.Now()
+		record.RevokedAt = &now
+		r.logger.Info("Revoked access", "hostCredential", record.HostCredential, "database", record.Database, "permission", record.Permission, "reason", record.Reason)
+
+		hosts[record.HostCredential] = db
+	}
+	for _, grant := range pending {
+		grant.record.GrantedAt = nil
+		grant.record.RevokedAt = nil
+	}
+
+	for _, grant := range grants {
+		accessSpec, record := grant.spec, grant.record
+		db, dbname, err := r.client(ctx, dbs, user.Namespace, accessSpec.HostCredential, accessSpec.Database)
+		if err != nil {
+			return err
+		}
+		name, err := r.loginName(ctx, names, user, accessSpec.HostCredential, username)
+		if err != nil {
+			return err
+		}
+
+		if report && record.GrantedAt != nil {
+			hosts[accessSpec.HostCredential] = db
+			addSettings(settings, accessSpec.HostCredential, dbname, accessSpec.Parameters)
+			if accessSpec.Permission == api.PermOwner {
+				addSettings(settings, accessSpec.HostCredential, dbname, map[string]string{"role": dbname + "_owner"})
+			}
+			exists, err := checkRole(db, checked, &diffs, accessSpec.HostCredential, name, attrs)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				continue
+			}
+			permDiffs, err := permissionDrift(db, dbname, record.Schema, accessSpec.Permission, accessSpec.Privileges, name)
+			if err != nil {
+				return err
+			}
+			diffs = append(diffs, permDiffs...)
+			continue
+		}
+
+		if err := db.EnsureUser(name, password, ownerOf("PgUser", user), user.Spec.Adopt); err != nil {
+			return ownershipError(err)
+		}
+		if err := db.EnsureRoleAttributes(name, attrs); err != nil {
+			return ctlerrors.NewTemporary(err)
+		}
+
+		if err := ensurePermission(db, dbname, record.Schema, accessSpec.Permission, accessSpec.Privileges, name); err != nil {
+			return err
+		}
+		if record.GrantedAt == nil {
+			now := metav1.Now()
+			record.GrantedAt = &now
+		}
+
+		hosts[accessSpec.HostCredential] = db
+		addSettings(settings, accessSpec.HostCredential, dbname, accessSpec.Parameters)
+		if accessSpec.Permission == api.PermOwner {
+			addSettings(settings, accessSpec.HostCredential, dbname, map[string]string{"role": dbname + "_owner"})
+		}
+	}
+
+	var memberships []api.RoleMembership
+	for _, roleName := range user.Spec.Roles {
+		role, err := apiutil.PgRoleByName(r.Client, user.Namespace, roleName)
+		if err != nil {
+			r.logger.Error(err, "Failed to get role from the user. Skipping '"+roleName+"'")
+			return ctlerrors.NewTemporary(err)
+		}
+		db, _, err := r.client(ctx, dbs, user.Namespace, role.Spec.HostCredential, "")
+		if err != nil {
+			return err
+		}
+		name, err := r.loginName(ctx, names, user, role.Spec.HostCredential, username)
+		if err != nil {
+			return err
+		}
+		roleName, err := r.roleName(role)
+		if err != nil {
+			return err
+		}
+		memberships = append(memberships, api.RoleMembership{HostCredential: role.Spec.HostCredential, Role: roleName})
+
+		if report {
+			hosts[role.Spec.HostCredential] = db
+			exists, err := checkRole(db, checked, &diffs, role.Spec.HostCredential, name, attrs)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				continue
+			}
+			memberDiffs, err := db.MembershipDrift(roleName, name)
 			if err != nil {
-				r.logger.Error(err, "Failed to get host credential from the access spec. Skipping '"+accessSpec.HostCredential+"'")
 				return ctlerrors.NewTemporary(err)
 			}
-			connStr, err := apiutil.GetConnectionStringWithDatabase(hostCred, r.Client, accessSpec.Database)
+			diffs = append(diffs, memberDiffs...)
+			continue
+		}
+
+		if err := db.EnsureUser(name, password, ownerOf("PgUser", user), user.Spec.Adopt); err != nil {
+			return ownershipError(err)
+		}
+		if err := db.EnsureRoleAttributes(name, attrs); err != nil {
+			return ctlerrors.NewTemporary(err)
+		}
+
+		if err := db.EnsureRoleToUser(roleName, name); err != nil {
+			return ctlerrors.NewTemporary(err)
+		}
+
+		hosts[role.Spec.HostCredential] = db
+	}
+
+	// Revoke the memberships in roles removed from the specification
+	for _, membership := range user.Status.Roles {
+		if containsMembership(memberships, membership) {
+			continue
+		}
+		db, _, err := r.client(ctx, dbs, user.Namespace, membership.HostCredential, "")
+		if err != nil {
+			return err
+		}
+		name, err := r.loginName(ctx, names, user, membership.HostCredential, username)
+		if err != nil {
+			return err
+		}
+		if err := revokeMembership(db, membership.Role, name); err != nil {
+			return err
+		}
+		r.logger.Info("Revoked membership", "hostCredential", membership.HostCredential, "role", membership.Role)
+	}
+	if r.plan == nil {
+		user.Status.Roles = memberships
+	}
+
+	for hostCred, db := range hosts {
+		if report {
+			if exists, ok := checked[hostCred]; ok && !exists {
+				continue
+			}
+			settingDiffs, err := settingsDrift(db, names[hostCred], user.Spec.Parameters, settings[hostCred])
 			if err != nil {
-				r.logger.Error(err, "Failed to get connection string from the access spec. Skipping '"+accessSpec.HostCredential+"'")
 				return ctlerrors.NewTemporary(err)
 			}
+			diffs = append(diffs, settingDiffs...)
+			continue
+		}
+		if err := r.ensureSettings(db, names[hostCred], user.Spec.Parameters, settings[hostCred]); err != nil {
+			return ctlerrors.NewTemporary(err)
+		}
+	}
 
-			if dbs[connStr] == nil {
-				db, err := postgres.NewClient(ctx, r.logger, connStr)
-				if err != nil {
-					r.logger.Error(err, "Failed to open database connection")
-					return ctlerrors.NewTemporary(err)
-				}
-				dbs[connStr] = db
+	if r.plan == nil {
+		if err := r.releaseClaims(ctx, user); err != nil {
+			return err
+		}
+	}
+
+	if report && len(diffs) > 0 {
+		r.logger.Info("Found drift", "differences", diffs)
+	}
+	setDriftedCondition(&user.Status.Conditions, user.Generation, user.Spec.DriftPolicy, diffs)
+
+	return nil
+}
+
+// containsMembership returns whether memberships contains membership.
+func containsMembership(memberships []api.RoleMembership, membership api.RoleMembership) bool {
+	for _, m := range memberships {
+		if m == membership {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRole compares the login role name on the host of the PgHostCredential
+// named hostCredName with attrs once per host and appends the differences
+// to diffs. It returns whether the role exists. Hosts are recorded in
+// checked.
+func checkRole(db *postgres.Client, checked map[string]bool, diffs *[]string, hostCredName, name string, attrs postgres.RoleAttributes) (bool, error) {
+	if exists, ok := checked[hostCredName]; ok {
+		return exists, nil
+	}
+
+	exists, err := db.RoleExists(name)
+	if err != nil {
+		return false, ctlerrors.NewTemporary(err)
+	}
+	checked[hostCredName] = exists
+	if !exists {
+		*diffs = append(*diffs, fmt.Sprintf("role %s does not exist", name))
+		return false, nil
+	}
+
+	roleDiffs, err := db.RoleDrift(name, attrs)
+	if err != nil {
+		return false, ctlerrors.NewTemporary(err)
+	}
+	*diffs = append(*diffs, roleDiffs...)
+	return true, nil
+}
+
+// client returns a client connected to the database requested as dbname on
+// the host of the PgHostCredential named hostCredName, and the name of the
+// database on the host. Clients are shared through dbs. If dbname is empty,
+// the client connects to the default database.
+func (r *PgUserReconciler) client(ctx context.Context, dbs map[string]*postgres.Client, namespace, hostCredName, dbname string) (*postgres.Client, string, error) {
+	hostCred, err := apiutil.PgHostCredentialByName(r.Client, namespace, hostCredName)
+	if err != nil {
+		r.logger.Error(err, "Failed to get host credential. Skipping '"+hostCredName+"'")
+		return nil, "", ctlerrors.NewTemporary(err)
+	}
+
+	var connStr string
+	if dbname == "" {
+		connStr, err = apiutil.GetConnectionString(hostCred, r.Client)
+	} else {
+		dbname, err = apiutil.DatabaseName(r.Client, hostCred, namespace, dbname)
+		if err != nil {
+			return nil, "", err
+		}
+		connStr, err = apiutil.GetConnectionStringWithDatabase(hostCred, r.Client, dbname)
+	}
+	if err != nil {
+		r.logger.Error(err, "Failed to get connection string. Skipping '"+hostCredName+"'")
+		return nil, "", ctlerrors.NewTemporary(err)
+	}
+
+	if dbs[connStr] == nil {
+		db, err := newClient(hostContext(ctx, hostCred), r.logger, r.Connector, connStr)
+		if err != nil {
+			r.logger.Error(err, "Failed to open database connection")
+			return nil, "", ctlerrors.NewTemporary(err)
+		}
+		dbs[connStr] = db
+	}
+
+	return dbs[connStr], dbname, nil
+}
+
+// loginName returns the name of the login role of user on the host of the
+// PgHostCredential named hostCredName and claims it. Names are shared
+// through names.
+func (r *PgUserReconciler) loginName(ctx context.Context, names map[string]string, user *api.PgUser, hostCredName, username string) (string, error) {
+	if name, ok := names[hostCredName]; ok {
+		return name, nil
+	}
+
+	hostCred, err := apiutil.PgHostCredentialByName(r.Client, user.Namespace, hostCredName)
+	if err != nil {
+		r.logger.Error(err, "Failed to get host credential. Skipping '"+hostCredName+"'")
+		return "", ctlerrors.NewTemporary(err)
+	}
+	name, err := apiutil.RoleName(r.Client, hostCred, user.Namespace, username)
+	if err != nil {
+		return "", err
+	}
+	claim, err := nameClaim(r.Client, hostCred, name)
+	if err != nil {
+		return "", err
+	}
+	if err := claimName(ctx, r.Client, api.NameTypeRole, claim, "PgUser", user); err != nil {
+		return "", err
+	}
+
+	names[hostCredName] = name
+	r.claims = append(r.claims, claim)
+	if !containsClaim(user.Status.Claims, claim) {
+		user.Status.Claims = append(user.Status.Claims, claim)
+	}
+	return name, nil
+}
+
+// releaseClaims releases the login roles claimed before but not by the
+// reconciliation, e.g. after a rename, a change of the naming policy or
+// once the user has no access to a host anymore.
+func (r *PgUserReconciler) releaseClaims(ctx context.Context, user *api.PgUser) error {
+	for _, claim := range user.Status.Claims {
+		if containsClaim(r.claims, claim) {
+			continue
+		}
+		if err := releaseName(ctx, r.Client, api.NameTypeRole, claim, user.UID); err != nil {
+			return err
+		}
+	}
+	user.Status.Claims = r.claims
+	return nil
+}
+
+// containsClaim returns whether claims contains a claim of the same name as
+// claim.
+func containsClaim(claims []api.NameClaim, claim api.NameClaim) bool {
+	for _, other := range claims {
+		if sameName(other, claim) {
+			return true
+		}
+	}
+	return false
+}
+
+// roleName returns the name of role on its host.
+func (r *PgUserReconciler) roleName(role *api.PgRole) (string, error) {
+	hostCred, err := apiutil.PgHostCredentialByName(r.Client, role.Namespace, role.Spec.HostCredential)
+	if err != nil {
+		r.logger.Error(err, "Failed to get host credential. Skipping '"+role.Spec.HostCredential+"'")
+		return "", ctlerrors.NewTemporary(err)
+	}
+	return apiutil.RoleName(r.Client, hostCred, role.Namespace, role.Spec.Name)
+}
+
+// accessGrant is an access specification to grant and its record in the
+// status.
+type accessGrant struct {
+	spec   api.AccessSpec
+	record *api.AccessGrantStatus
+}
+
+// planAccessGrants matches the access specifications of user with their
+// records in the status. It returns the access to grant and the records of
+// access to revoke, either because it expired, because its access
+// specification was removed or because the schema or the privileges of a
+// custom permission changed. The time until the next expiry is stored in
+// r.requeueAfter.
+func (r *PgUserReconciler) planAccessGrants(user *api.PgUser, now time.Time) ([]accessGrant, []*api.AccessGrantStatus, error) {
+	previous := user.Status.AccessGrants
+	seen := make([]bool, len(previous))
+
+	var (
+		records  []api.AccessGrantStatus
+		specs    = make(map[int]api.AccessSpec)
+		toRevoke = make(map[int]bool)
+		narrowed []*api.AccessGrantStatus
+	)
+
+	if user.Spec.AccessSpecs != nil {
+		for _, accessSpec := range *user.Spec.AccessSpecs {
+			record := api.AccessGrantStatus{
+				HostCredential: accessSpec.HostCredential,
+				Database:       accessSpec.Database,
+				Permission:     accessSpec.Permission,
+				Reason:         accessSpec.Reason,
+			}
+			if indexOfAccessGrant(records, record) >= 0 {
+				// duplicate access specification
+				continue
+			}
+			if i := indexOfAccessGrant(previous, record); i >= 0 {
+				record = previous[i]
+				seen[i] = true
 			}
 
-			db := dbs[connStr]
+			if accessSpec.Permission == api.PermCustom {
+				schema, err := apiutil.ResourceValue(r.Client, accessSpec.Schema, user.Namespace)
+				if goerrors.Is(err, apiutil.ErrNoValue) {
+					schema = "public"
+				} else if err != nil {
+					return nil, nil, ctlerrors.NewInvalid(err)
+				}
+				if removed := removedCustomPrivileges(record, schema, accessSpec.Privileges); removed != nil {
+					narrowed = append(narrowed, removed)
+				}
+				record.Schema = schema
+				record.Privileges = accessSpec.Privileges
+			}
 
-			if err := db.EnsureUser(username, password); err != nil {
-				return ctlerrors.NewTemporary(err)
+			record.ExpiresAt = nil
+			switch {
+			case accessSpec.ExpiresAt != nil:
+				record.ExpiresAt = accessSpec.ExpiresAt
+			case accessSpec.Duration != nil && record.GrantedAt != nil:
+				record.ExpiresAt = &metav1.Time{Time: record.GrantedAt.Add(accessSpec.Duration.Duration)}
+			case accessSpec.Duration != nil:
+				record.ExpiresAt = &metav1.Time{Time: now.Add(accessSpec.Duration.Duration)}
 			}
 
-			switch accessSpec.Permission {
-			case api.PermReadOnly:
-				if err := db.EnsureReadonlyRoleToUser(accessSpec.Database, username); err != nil {
-					return ctlerrors.NewTemporary(err)
+			expired := record.ExpiresAt != nil && !now.Before(record.ExpiresAt.Time)
+			switch {
+			case expired && record.RevokedAt == nil && record.GrantedAt != nil:
+				toRevoke[len(records)] = true
+			case expired && record.RevokedAt == nil:
+				// expired before it was ever granted
+				revokedAt := metav1.NewTime(now)
+				record.RevokedAt = &revokedAt
+			case expired:
+			default:
+				if record.RevokedAt != nil {
+					// requested again with a later expiry
+					record.GrantedAt = nil
+					record.RevokedAt = nil
 				}
-			case api.PermReadWrite:
-				if err := db.EnsureReadwriteRoleToUser(accessSpec.Database, username); err != nil {
-					return ctlerrors.NewTemporary(err)
+				specs[len(records)] = accessSpec
+
+				if record.ExpiresAt != nil {
+					if d := record.ExpiresAt.Sub(now); r.requeueAfter == 0 || d < r.requeueAfter {
+						r.requeueAfter = d
+					}
 				}
 			}
 
-			// database := accessSpec.Database
-			// accessSpec.Permission
+			records = append(records, record)
+		}
+	}
+
+	// Keep records of revoked access as an audit trail, revoke granted access
+	// whose access specification was removed and drop the others
+	for i, record := range previous {
+		if seen[i] {
+			continue
+		}
+		switch {
+		case record.RevokedAt != nil:
+			records = append(records, record)
+		case record.GrantedAt != nil:
+			toRevoke[len(records)] = true
+			records = append(records, record)
+		}
+	}
+	user.Status.AccessGrants = records
+
+	var grants []accessGrant
+	revokes := narrowed
+	for i := range records {
+		if spec, ok := specs[i]; ok {
+			grants = append(grants, accessGrant{spec: spec, record: &records[i]})
+		}
+		if toRevoke[i] {
+			revokes = append(revokes, &records[i])
+		}
+	}
+
+	return grants, revokes, nil
+}
+
+// removedCustomPrivileges returns a copy of the record of a granted custom
+// permission holding the privileges to revoke once it is changed to schema
+// and privileges: all of them if the schema changed, or the ones no longer
+// listed. It returns nil if nothing was granted or nothing is removed.
+func removedCustomPrivileges(record api.AccessGrantStatus, schema string, privileges []api.Privilege) *api.AccessGrantStatus {
+	if record.GrantedAt == nil || record.RevokedAt != nil || len(record.Privileges) == 0 {
+		return nil
+	}
+	removed := record
+	removed.Privileges = nil
+	for _, p := range record.Privileges {
+		if record.Schema != schema || !containsPrivilege(privileges, p) {
+			removed.Privileges = append(removed.Privileges, p)
+		}
+	}
+	if len(removed.Privileges) == 0 {
+		return nil
+	}
+	return &removed
+}
+
+// approveAccessGrants splits grants into the ones to grant and the ones
+// awaiting a PgAccessApproval because their host credential requires
+// approval for their permission.
+func (r *PgUserReconciler) approveAccessGrants(user *api.PgUser, grants []accessGrant) ([]accessGrant, []accessGrant, error) {
+	var (
+		approvals []api.PgAccessApproval
+		approved  []accessGrant
+		pending   []accessGrant
+	)
+	for _, grant := range grants {
+		hostCred, err := apiutil.PgHostCredentialByName(r.Client, user.Namespace, grant.spec.HostCredential)
+		if err != nil {
+			r.logger.Error(err, "Failed to get host credential. Skipping '"+grant.spec.HostCredential+"'")
+			return nil, nil, ctlerrors.NewTemporary(err)
+		}
+		if !requiresApproval(hostCred, grant.spec.Permission) {
+			approved = append(approved, grant)
+			continue
+		}
+
+		if approvals == nil {
+			approvals, err = apiutil.PgAccessApprovals(r.Client, user.Namespace)
+			if err != nil {
+				return nil, nil, ctlerrors.NewTemporary(err)
+			}
+		}
+		if isApproved(approvals, user, grant.spec) {
+			approved = append(approved, grant)
+			continue
+		}
+
+		r.logger.Info("Access awaits approval", "hostCredential", grant.spec.HostCredential, "database", grant.spec.Database, "permission", grant.spec.Permission, "reason", grant.spec.Reason)
+		if grant.spec.ExpiresAt == nil {
+			// the duration starts once the access is approved
+			grant.record.ExpiresAt = nil
 		}
+		pending = append(pending, grant)
 	}
 
+	return approved, pending, nil
+}
+
+// requiresApproval returns whether access with perm on the host of hostCred
+// requires approval.
+func requiresApproval(hostCred *api.PgHostCredential, perm api.Perm) bool {
+	for _, p := range hostCred.Spec.ApprovalRequired {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// isApproved returns whether one of approvals signs off accessSpec of user
+// as it is now. Approvals by the user who requested the access do not count.
+func isApproved(approvals []api.PgAccessApproval, user *api.PgUser, accessSpec api.AccessSpec) bool {
+	requestedBy := user.Annotations[api.AnnotationRequestedBy]
+	hash := apiutil.AccessSpecHash(accessSpec)
+	for i := range approvals {
+		approval := &approvals[i]
+		if apiutil.MatchesAccessSpec(approval, user.Name, accessSpec) &&
+			approval.Spec.AccessHash == hash &&
+			approval.Spec.ApprovedBy != "" &&
+			approval.Spec.ApprovedBy != requestedBy {
+			return true
+		}
+	}
+	return false
+}
+
+// setPendingCondition reports the access specifications of user awaiting
+// approval in its Pending condition.
+func setPendingCondition(user *api.PgUser, pending []accessGrant) {
+	condition := metav1.Condition{
+		Type:               api.ConditionPending,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: user.Generation,
+		Reason:             "Approved",
+		Message:            "No access awaits approval",
+	}
+	if len(pending) > 0 {
+		specs := make([]string, len(pending))
+		for i, grant := range pending {
+			specs[i] = fmt.Sprintf("%s on %s/%s (%s)", grant.spec.Permission, grant.spec.HostCredential, grant.spec.Database, grant.spec.Reason)
+		}
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "AwaitingApproval"
+		condition.Message = "Access awaits approval: " + strings.Join(specs, ", ")
+	}
+	meta.SetStatusCondition(&user.Status.Conditions, condition)
+}
+
+// indexOfAccessGrant returns the index of the record in records for the same
+// access specification as record, or -1.
+func indexOfAccessGrant(records []api.AccessGrantStatus, record api.AccessGrantStatus) int {
+	for i, other := range records {
+		if other.HostCredential == record.HostCredential &&
+			other.Database == record.Database &&
+			other.Permission == record.Permission &&
+			other.Reason == record.Reason {
+			return i
+		}
+	}
+	return -1
+}
+
+// ensureSettings sets the database independent session defaults of username
+// to parameters and the ones in each database to databases. Session defaults
+// in databases not in databases are reset.
+func (r *PgUserReconciler) ensureSettings(db *postgres.Client, username string, parameters map[string]string, databases map[string]map[string]string) error {
+	if err := db.EnsureRoleSettings(username, "", parameters); err != nil {
+		return err
+	}
+
+	current, err := db.RoleSettingDatabases(username)
+	if err != nil {
+		return err
+	}
+	for _, dbname := range current {
+		if _, ok := databases[dbname]; !ok {
+			if err := db.EnsureRoleSettings(username, dbname, nil); err != nil {
+				return err
+			}
+		}
+	}
+	for dbname, desired := range databases {
+		if err := db.EnsureRoleSettings(username, dbname, desired); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// settingsDrift returns the differences between the session defaults of
+// username and the ones ensureSettings sets.
+func settingsDrift(db *postgres.Client, username string, parameters map[string]string, databases map[string]map[string]string) ([]string, error) {
+	diffs, err := db.RoleSettingsDrift(username, "", parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := db.RoleSettingDatabases(username)
+	if err != nil {
+		return nil, err
+	}
+	for _, dbname := range current {
+		if _, ok := databases[dbname]; !ok {
+			dbDiffs, err := db.RoleSettingsDrift(username, dbname, nil)
+			if err != nil {
+				return nil, err
+			}
+			diffs = append(diffs, dbDiffs...)
+		}
+	}
+	for dbname, desired := range databases {
+		dbDiffs, err := db.RoleSettingsDrift(username, dbname, desired)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, dbDiffs...)
+	}
+
+	return diffs, nil
+}
+
+// addSettings merges parameters into the desired session defaults of a
+// database on a host.
+func addSettings(settings map[string]map[string]map[string]string, hostCred, dbname string, parameters map[string]string) {
+	if settings[hostCred] == nil {
+		settings[hostCred] = make(map[string]map[string]string)
+	}
+	if settings[hostCred][dbname] == nil {
+		settings[hostCred][dbname] = make(map[string]string)
+	}
+	for name, value := range parameters {
+		settings[hostCred][dbname][name] = value
+	}
+}
+
+// validateParameters returns an error if user has an invalid parameter name.
+func validateParameters(user *api.PgUser) error {
+	if err := postgres.ValidateRoleSettings(user.Spec.Parameters); err != nil {
+		return err
+	}
+	if user.Spec.AccessSpecs != nil {
+		for _, accessSpec := range *user.Spec.AccessSpecs {
+			if err := postgres.ValidateRoleSettings(accessSpec.Parameters); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
-func (r *PgUserReconciler) finalize(user *api.PgUser) error {
+// roleAttributes returns the desired attributes of the login role of user.
+// It fails if user enables a privileged attribute the operator does not
+// allow.
+func (r *PgUserReconciler) roleAttributes(user *api.PgUser) (postgres.RoleAttributes, error) {
+	spec := user.Spec.Attributes
+	attrs := postgres.DefaultRoleAttributes()
+	if spec.ConnectionLimit != nil {
+		attrs.ConnectionLimit = *spec.ConnectionLimit
+	}
+	if spec.ValidUntil != nil {
+		attrs.ValidUntil = &spec.ValidUntil.Time
+	}
+	if spec.Inherit != nil {
+		attrs.Inherit = *spec.Inherit
+	}
+
+	privileged := []struct {
+		name    string
+		enabled bool
+		attr    *bool
+	}{
+		{"createdb", spec.CreateDB, &attrs.CreateDB},
+		{"replication", spec.Replication, &attrs.Replication},
+		{"bypassrls", spec.BypassRLS, &attrs.BypassRLS},
+	}
+	for _, p := range privileged {
+		if p.enabled && !slices.Contains(r.AllowedRoleAttributes, p.name) {
+			return attrs, fmt.Errorf("role attribute %s is not allowed by the operator", p.name)
+		}
+		*p.attr = p.enabled
+	}
+
+	return attrs, nil
+}
+
+// finalize releases the login roles of the user. The login roles are kept
+// on the hosts.
+func (r *PgUserReconciler) finalize(ctx context.Context, user *api.PgUser) error {
+	for _, claim := range user.Status.Claims {
+		if err := releaseName(ctx, r.Client, api.NameTypeRole, claim, user.UID); err != nil {
+			return err
+		}
+	}
 	r.logger.Info("Successfully finalized PgUser")
 	return nil
 }
@@ -220,6 +983,11 @@ func (r *PgUserReconciler) handleResult(err error) (ctrl.Result, error) {
 		r.user.Status.Phase = phase
 		r.user.Status.PhaseUpdated = metav1.Now()
 		r.user.Status.Error = errorMessage
+		r.user.Status.Plan = r.plan.Statements()
+		if phase == api.PhaseAvailable && r.plan == nil {
+			r.user.Status.ObservedGeneration = r.user.Generation
+		}
+		setConflictCondition(&r.user.Status.Conditions, r.user.Generation, err)
 	}
 
 	if err := r.Status().Update(context.Background(), r.user); err != nil {
@@ -227,6 +995,16 @@ func (r *PgUserReconciler) handleResult(err error) (ctrl.Result, error) {
 	}
 
 	isRequeue := (phase == api.PhaseFailed)
+	if phase == api.PhaseAvailable {
+		// revoke access once it expires
+		requeueAfter := r.requeueAfter
+		if r.ResyncPeriod > 0 && (requeueAfter == 0 || r.ResyncPeriod < requeueAfter) {
+			requeueAfter = r.ResyncPeriod
+		}
+		if requeueAfter > 0 {
+			return ctrl.Result{RequeueAfter: requeueAfter}, err
+		}
+	}
 
 	return ctrl.Result{Requeue: isRequeue}, err
 }
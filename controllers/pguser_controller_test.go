@@ -0,0 +1,309 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	apiutil "github.com/jeewangue/postgres-indb-operator/api/v1alpha1/util"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres/fake"
+)
+
+var _ = Describe("PgUser controller", func() {
+	var (
+		ctx        context.Context
+		server     *fake.Server
+		reconciler *PgUserReconciler
+		namespace  string
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		server = fake.NewServer("admin")
+		reconciler = &PgUserReconciler{
+			Client:    k8sClient,
+			Scheme:    scheme.Scheme,
+			Connector: server.Connector(),
+		}
+		namespace = createNamespace(ctx)
+		createHostCredential(ctx, namespace)
+
+		database := &api.PgDatabase{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "app"},
+			Spec:       api.PgDatabaseSpec{HostCredential: "host", Name: "app"},
+		}
+		Expect(k8sClient.Create(ctx, database)).To(Succeed())
+		databaseReconciler := &PgDatabaseReconciler{
+			Client:    k8sClient,
+			Scheme:    scheme.Scheme,
+			Connector: server.Connector(),
+		}
+		reconcileObject(ctx, databaseReconciler, database)
+		Expect(database.Status.Phase).To(Equal(api.PhaseAvailable), database.Status.Error)
+	})
+
+	accessSpec := func(permission api.Perm) api.AccessSpec {
+		return api.AccessSpec{
+			HostCredential: "host",
+			Database:       "app",
+			Schema:         api.ResourceVar{Value: "public"},
+			Reason:         "testing",
+			Permission:     permission,
+		}
+	}
+
+	table.DescribeTable("converges access",
+		func(permission, updated api.Perm) {
+			user := &api.PgUser{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "alice"},
+				Spec: api.PgUserSpec{
+					Name:        api.ResourceVar{Value: "alice"},
+					Password:    api.ResourceVar{Value: "secret"},
+					AccessSpecs: &[]api.AccessSpec{accessSpec(permission)},
+				},
+			}
+			Expect(k8sClient.Create(ctx, user)).To(Succeed())
+
+			By("creating the user with access")
+			reconcileObject(ctx, reconciler, user)
+			Expect(user.Status.Phase).To(Equal(api.PhaseAvailable), user.Status.Error)
+			Expect(server.CanLogin("alice")).To(BeTrue())
+			Expect(server.Password("alice")).To(Equal("secret"))
+			Expect(server.IsMember("app_"+string(permission), "alice")).To(BeTrue())
+			Expect(user.Status.AccessGrants).To(HaveLen(1))
+			Expect(user.Status.AccessGrants[0].GrantedAt).NotTo(BeNil())
+
+			By("updating the permission")
+			user.Spec.AccessSpecs = &[]api.AccessSpec{accessSpec(updated)}
+			Expect(k8sClient.Update(ctx, user)).To(Succeed())
+			reconcileObject(ctx, reconciler, user)
+			Expect(user.Status.Phase).To(Equal(api.PhaseAvailable), user.Status.Error)
+			Expect(server.IsMember("app_"+string(permission), "alice")).To(BeFalse())
+			Expect(server.IsMember("app_"+string(updated), "alice")).To(BeTrue())
+
+			By("revoking the access")
+			user.Spec.AccessSpecs = nil
+			Expect(k8sClient.Update(ctx, user)).To(Succeed())
+			reconcileObject(ctx, reconciler, user)
+			Expect(user.Status.Phase).To(Equal(api.PhaseAvailable), user.Status.Error)
+			Expect(server.IsMember("app_"+string(updated), "alice")).To(BeFalse())
+			for _, grant := range user.Status.AccessGrants {
+				Expect(grant.RevokedAt).NotTo(BeNil())
+			}
+
+			By("deleting the user")
+			deleteObject(ctx, reconciler, user)
+			// login roles are kept when the custom resource is deleted
+			Expect(server.RoleExists("alice")).To(BeTrue())
+		},
+		table.Entry("readonly to readwrite", api.PermReadOnly, api.PermReadWrite),
+		table.Entry("readwrite to owner", api.PermReadWrite, api.PermOwner),
+		table.Entry("owner to readonly", api.PermOwner, api.PermReadOnly),
+	)
+
+	It("revokes the privileges removed from a custom permission", func() {
+		custom := accessSpec(api.PermCustom)
+		custom.Privileges = []api.Privilege{"SELECT", "INSERT"}
+		user := &api.PgUser{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "alice"},
+			Spec: api.PgUserSpec{
+				Name:        api.ResourceVar{Value: "alice"},
+				Password:    api.ResourceVar{Value: "secret"},
+				AccessSpecs: &[]api.AccessSpec{custom},
+			},
+		}
+		Expect(k8sClient.Create(ctx, user)).To(Succeed())
+		reconcileObject(ctx, reconciler, user)
+		Expect(user.Status.Phase).To(Equal(api.PhaseAvailable), user.Status.Error)
+
+		By("narrowing the privileges")
+		(*user.Spec.AccessSpecs)[0].Privileges = []api.Privilege{"SELECT"}
+		Expect(k8sClient.Update(ctx, user)).To(Succeed())
+		reconciler.DryRun = true
+		reconcileObject(ctx, reconciler, user)
+		Expect(user.Status.Phase).To(Equal(api.PhaseAvailable), user.Status.Error)
+		Expect(user.Status.Plan).To(ContainElement("REVOKE INSERT ON ALL TABLES IN SCHEMA public FROM alice"))
+		Expect(user.Status.Plan).NotTo(ContainElement(ContainSubstring("REVOKE SELECT")))
+
+		By("moving to another schema")
+		server.CreateSchema("app", "reports", "app_owner")
+		(*user.Spec.AccessSpecs)[0].Schema = api.ResourceVar{Value: "reports"}
+		Expect(k8sClient.Update(ctx, user)).To(Succeed())
+		reconcileObject(ctx, reconciler, user)
+		Expect(user.Status.Phase).To(Equal(api.PhaseAvailable), user.Status.Error)
+		Expect(user.Status.Plan).To(ContainElement("REVOKE SELECT, INSERT ON ALL TABLES IN SCHEMA public FROM alice"))
+		Expect(user.Status.Plan).To(ContainElement("GRANT SELECT ON ALL TABLES IN SCHEMA reports TO alice"))
+	})
+
+	It("requires a new approval once the approved access changes", func() {
+		hostCred := &api.PgHostCredential{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "host"}, hostCred)).To(Succeed())
+		hostCred.Spec.ApprovalRequired = []api.Perm{api.PermCustom}
+		Expect(k8sClient.Update(ctx, hostCred)).To(Succeed())
+
+		custom := accessSpec(api.PermCustom)
+		custom.Privileges = []api.Privilege{"SELECT"}
+		user := &api.PgUser{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "alice"},
+			Spec: api.PgUserSpec{
+				Name:        api.ResourceVar{Value: "alice"},
+				Password:    api.ResourceVar{Value: "secret"},
+				AccessSpecs: &[]api.AccessSpec{custom},
+			},
+		}
+		Expect(k8sClient.Create(ctx, user)).To(Succeed())
+		reconcileObject(ctx, reconciler, user)
+		Expect(meta.IsStatusConditionTrue(user.Status.Conditions, api.ConditionPending)).To(BeTrue())
+
+		By("approving the access")
+		// the admission webhook signs the approval and binds it to the access
+		// specification
+		approval := &api.PgAccessApproval{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "alice"},
+			Spec: api.PgAccessApprovalSpec{
+				User:           "alice",
+				HostCredential: "host",
+				Database:       "app",
+				Permission:     api.PermCustom,
+				Reason:         "testing",
+				AccessHash:     apiutil.AccessSpecHash(custom),
+				ApprovedBy:     "bob",
+			},
+		}
+		Expect(k8sClient.Create(ctx, approval)).To(Succeed())
+		reconcileObject(ctx, reconciler, user)
+		Expect(user.Status.Phase).To(Equal(api.PhaseAvailable), user.Status.Error)
+		Expect(meta.IsStatusConditionTrue(user.Status.Conditions, api.ConditionPending)).To(BeFalse())
+		Expect(user.Status.AccessGrants[0].GrantedAt).NotTo(BeNil())
+
+		By("widening the privileges")
+		(*user.Spec.AccessSpecs)[0].Privileges = []api.Privilege{"SELECT", "DELETE"}
+		Expect(k8sClient.Update(ctx, user)).To(Succeed())
+		reconcileObject(ctx, reconciler, user)
+		Expect(meta.IsStatusConditionTrue(user.Status.Conditions, api.ConditionPending)).To(BeTrue())
+		Expect(user.Status.AccessGrants[0].GrantedAt).To(BeNil())
+	})
+
+	It("assumes the owner role in the database for owner access", func() {
+		user := &api.PgUser{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "alice"},
+			Spec: api.PgUserSpec{
+				Name:        api.ResourceVar{Value: "alice"},
+				Password:    api.ResourceVar{Value: "secret"},
+				AccessSpecs: &[]api.AccessSpec{accessSpec(api.PermOwner)},
+			},
+		}
+		Expect(k8sClient.Create(ctx, user)).To(Succeed())
+
+		reconcileObject(ctx, reconciler, user)
+		Expect(user.Status.Phase).To(Equal(api.PhaseAvailable), user.Status.Error)
+		Expect(server.Settings("alice", "app")).To(HaveKeyWithValue("role", "app_owner"))
+	})
+
+	It("revokes the roles removed from the user", func() {
+		roleReconciler := &PgRoleReconciler{
+			Client:    k8sClient,
+			Scheme:    scheme.Scheme,
+			Connector: server.Connector(),
+		}
+		for _, name := range []string{"analysts", "auditors"} {
+			role := &api.PgRole{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+				Spec:       api.PgRoleSpec{HostCredential: "host", Name: name},
+			}
+			Expect(k8sClient.Create(ctx, role)).To(Succeed())
+			reconcileObject(ctx, roleReconciler, role)
+			Expect(role.Status.Phase).To(Equal(api.PhaseAvailable), role.Status.Error)
+		}
+
+		user := &api.PgUser{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "alice"},
+			Spec: api.PgUserSpec{
+				Name:     api.ResourceVar{Value: "alice"},
+				Password: api.ResourceVar{Value: "secret"},
+				Roles:    []string{"analysts", "auditors"},
+			},
+		}
+		Expect(k8sClient.Create(ctx, user)).To(Succeed())
+		reconcileObject(ctx, reconciler, user)
+		Expect(user.Status.Phase).To(Equal(api.PhaseAvailable), user.Status.Error)
+		Expect(server.IsMember("analysts", "alice")).To(BeTrue())
+		Expect(server.IsMember("auditors", "alice")).To(BeTrue())
+
+		user.Spec.Roles = []string{"analysts"}
+		Expect(k8sClient.Update(ctx, user)).To(Succeed())
+		reconcileObject(ctx, reconciler, user)
+		Expect(user.Status.Phase).To(Equal(api.PhaseAvailable), user.Status.Error)
+		Expect(server.IsMember("analysts", "alice")).To(BeTrue())
+		Expect(server.IsMember("auditors", "alice")).To(BeFalse())
+		Expect(user.Status.Roles).To(ConsistOf(api.RoleMembership{HostCredential: "host", Role: "analysts"}))
+	})
+
+	It("releases the login roles it no longer uses", func() {
+		user := &api.PgUser{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "alice"},
+			Spec: api.PgUserSpec{
+				Name:        api.ResourceVar{Value: "alice"},
+				Password:    api.ResourceVar{Value: "secret"},
+				AccessSpecs: &[]api.AccessSpec{accessSpec(api.PermReadOnly)},
+			},
+		}
+		Expect(k8sClient.Create(ctx, user)).To(Succeed())
+		reconcileObject(ctx, reconciler, user)
+		Expect(user.Status.Phase).To(Equal(api.PhaseAvailable), user.Status.Error)
+		Expect(claimedNames(ctx, user.UID)).To(ConsistOf("alice"))
+
+		By("renaming the login role")
+		user.Spec.Name = api.ResourceVar{Value: "alicia"}
+		Expect(k8sClient.Update(ctx, user)).To(Succeed())
+		reconcileObject(ctx, reconciler, user)
+		Expect(user.Status.Phase).To(Equal(api.PhaseAvailable), user.Status.Error)
+		Expect(user.Status.Claims).To(HaveLen(1))
+		Expect(user.Status.Claims[0].Name).To(Equal("alicia"))
+		Expect(claimedNames(ctx, user.UID)).To(ConsistOf("alicia"))
+
+		By("deleting the user")
+		deleteObject(ctx, reconciler, user)
+		Expect(claimedNames(ctx, user.UID)).To(BeEmpty())
+	})
+
+	It("relies on the capabilities recorded for the host", func() {
+		hostCred := &api.PgHostCredential{}
+		Expect(k8sClient.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "host"}, hostCred)).To(Succeed())
+		hostCredReconciler := &PgHostCredentialReconciler{
+			Client:    k8sClient,
+			Scheme:    scheme.Scheme,
+			Connector: server.Connector(),
+		}
+		reconcileObject(ctx, hostCredReconciler, hostCred)
+		Expect(hostCred.Status.Phase).To(Equal(api.PhaseAvailable), hostCred.Status.Error)
+		Expect(hostCred.Status.Host).NotTo(BeNil())
+		Expect(hostCred.Status.Host.ServerVersionNum).To(Equal(int32(150004)))
+		Expect(hostCred.Status.Host.Superuser).To(BeTrue())
+		Expect(hostCred.Status.Host.SCRAM).To(BeTrue())
+		Expect(hostCred.Status.Host.Extensions).To(ContainElement("pgcrypto"))
+
+		user := &api.PgUser{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "alice"},
+			Spec: api.PgUserSpec{
+				Name:        api.ResourceVar{Value: "alice"},
+				Password:    api.ResourceVar{Value: "secret"},
+				AccessSpecs: &[]api.AccessSpec{accessSpec(api.PermReadOnly)},
+			},
+		}
+		Expect(k8sClient.Create(ctx, user)).To(Succeed())
+
+		reconcileObject(ctx, reconciler, user)
+		Expect(user.Status.Phase).To(Equal(api.PhaseAvailable), user.Status.Error)
+		// a superuser does not need to be a member of the user to manage it
+		Expect(server.IsMember("alice", "admin")).To(BeFalse())
+	})
+})
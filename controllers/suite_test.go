@@ -80,4 +80,8 @@ var _ = AfterSuite(func() {
 	By("tearing down the test environment")
 	err := testEnv.Stop()
 	Expect(err).NotTo(HaveOccurred())
+
+	if postgresServer != nil {
+		Expect(postgresServer.Stop()).To(Succeed())
+	}
 })
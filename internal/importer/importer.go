@@ -0,0 +1,230 @@
+// Package importer emits custom resources for the databases and roles of a
+// host which are not managed by the operator yet.
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	apiutil "github.com/jeewangue/postgres-indb-operator/api/v1alpha1/util"
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres"
+)
+
+// accessRoleSuffixes maps the suffixes of the access roles of a database to
+// their permission.
+var accessRoleSuffixes = map[string]api.Perm{
+	"_readonly":  api.PermReadOnly,
+	"_readwrite": api.PermReadWrite,
+	"_owner":     api.PermOwner,
+}
+
+var invalidName = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// Options configures an import.
+type Options struct {
+	// HostCredential is the name of the PgHostCredential or
+	// ClusterPgHostCredential to connect with.
+	HostCredential string
+	// Namespace is the namespace of the host credential and of the emitted
+	// custom resources.
+	Namespace string
+	// PasswordKey is the key of the Secrets holding the passwords of users.
+	PasswordKey string
+	// Connector opens the client of the host. Defaults to
+	// postgres.NewClient.
+	Connector postgres.Connector
+}
+
+// Import connects to the host of the host credential and writes manifests
+// of PgDatabases, PgRoles and PgUsers for the databases and roles not owned
+// by a custom resource to out. The manifests set adopt, so that the objects
+// are stamped as owned by the custom resources once applied. Passwords
+// cannot be read and are referenced from Secrets named after the users.
+// Memberships in roles which are not imported as PgRoles, and login roles
+// without access to a database, are left out with a comment.
+func Import(ctx context.Context, c client.Client, logger logr.Logger, opts Options, out io.Writer) error {
+	hostCred, err := apiutil.PgHostCredentialByName(c, opts.Namespace, opts.HostCredential)
+	if err != nil {
+		return err
+	}
+	connStr, err := apiutil.GetConnectionString(hostCred, c)
+	if err != nil {
+		return err
+	}
+	connector := opts.Connector
+	if connector == nil {
+		connector = postgres.NewClient
+	}
+	db, err := connector(ctx, logger, connStr)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	databases, err := db.ListDatabases()
+	if err != nil {
+		return err
+	}
+	roles, err := db.ListRoles()
+	if err != nil {
+		return err
+	}
+
+	if hostCred.Spec.NamingPolicy != nil {
+		fmt.Fprintf(out, "# Host credential %s has a naming policy. The names below are the names on the host.\n", hostCred.Name)
+	}
+
+	// accessRoles maps the access roles of every database to the database
+	// and permission
+	accessRoles := make(map[string]api.RolePrivilege)
+	for _, database := range databases {
+		for suffix, perm := range accessRoleSuffixes {
+			accessRoles[database.Name+suffix] = api.RolePrivilege{Database: database.Name, Permission: perm}
+		}
+		if database.Owner != nil {
+			continue
+		}
+		if err := write(out, &api.PgDatabase{
+			TypeMeta:   typeMeta("PgDatabase"),
+			ObjectMeta: objectMeta(database.Name, opts.Namespace),
+			Spec: api.PgDatabaseSpec{
+				HostCredential: opts.HostCredential,
+				Name:           database.Name,
+				Adopt:          true,
+			},
+		}); err != nil {
+			return err
+		}
+	}
+
+	// groupRoles holds the roles emitted as PgRoles, the only ones the
+	// emitted custom resources can reference
+	groupRoles := make(map[string]bool)
+	for _, role := range roles {
+		if _, ok := accessRoles[role.Name]; !ok && role.Owner == nil && !role.Login {
+			groupRoles[role.Name] = true
+		}
+	}
+
+	for _, role := range roles {
+		if _, ok := accessRoles[role.Name]; ok || role.Owner != nil {
+			continue
+		}
+
+		var (
+			privileges []api.RolePrivilege
+			memberOf   []string
+			unimported []string
+		)
+		for _, parent := range role.MemberOf {
+			if privilege, ok := accessRoles[parent]; ok {
+				privileges = append(privileges, privilege)
+			} else if groupRoles[parent] {
+				memberOf = append(memberOf, resourceName(parent))
+			} else {
+				unimported = append(unimported, parent)
+			}
+		}
+		if role.Login && len(privileges) == 0 && len(memberOf) == 0 {
+			// a PgUser without access specifications and roles is on no host
+			fmt.Fprintf(out, "# %s has no access to a database nor imported roles and is not imported.\n", role.Name)
+			continue
+		}
+		for _, parent := range unimported {
+			fmt.Fprintf(out, "# %s is a member of %s, which is not imported as a PgRole. Grant the membership by hand.\n", role.Name, parent)
+		}
+
+		var obj runtime.Object
+		if role.Login {
+			secret := resourceName(role.Name) + "-password"
+			fmt.Fprintf(out, "# Create the Secret %s with the key %s holding the password of %s.\n", secret, opts.PasswordKey, role.Name)
+
+			var accessSpecs []api.AccessSpec
+			for _, privilege := range privileges {
+				accessSpecs = append(accessSpecs, api.AccessSpec{
+					HostCredential: opts.HostCredential,
+					Database:       privilege.Database,
+					Permission:     privilege.Permission,
+					Reason:         "imported",
+				})
+			}
+			user := &api.PgUser{
+				TypeMeta:   typeMeta("PgUser"),
+				ObjectMeta: objectMeta(role.Name, opts.Namespace),
+				Spec: api.PgUserSpec{
+					Name: api.ResourceVar{Value: role.Name},
+					Password: api.ResourceVar{ValueFrom: &api.ResourceVarSource{
+						SecretKeyRef: &api.KeySelector{Name: secret, Key: opts.PasswordKey},
+					}},
+					Roles: memberOf,
+					Adopt: true,
+				},
+			}
+			if len(accessSpecs) > 0 {
+				user.Spec.AccessSpecs = &accessSpecs
+			}
+			obj = user
+		} else {
+			obj = &api.PgRole{
+				TypeMeta:   typeMeta("PgRole"),
+				ObjectMeta: objectMeta(role.Name, opts.Namespace),
+				Spec: api.PgRoleSpec{
+					HostCredential: opts.HostCredential,
+					Name:           role.Name,
+					Privileges:     privileges,
+					MemberOf:       memberOf,
+					Adopt:          true,
+				},
+			}
+		}
+		if err := write(out, obj); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func typeMeta(kind string) metav1.TypeMeta {
+	return metav1.TypeMeta{APIVersion: api.GroupVersion.String(), Kind: kind}
+}
+
+func objectMeta(name, namespace string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: resourceName(name), Namespace: namespace}
+}
+
+// resourceName returns a valid name of a custom resource for the database
+// or role name.
+func resourceName(name string) string {
+	return strings.Trim(invalidName.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// write writes obj as a YAML document to out, leaving out its status and
+// other fields set by the API server.
+func write(out io.Writer, obj runtime.Object) error {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	delete(content, "status")
+	if metadata, ok := content["metadata"].(map[string]interface{}); ok {
+		delete(metadata, "creationTimestamp")
+	}
+
+	manifest, err := yaml.Marshal(content)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "---\n%s", manifest)
+	return err
+}
@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// RoleAttributes are the attributes of a role managed by the operator.
+type RoleAttributes struct {
+	ConnectionLimit int32
+	// ValidUntil is the password expiry. nil means the password never
+	// expires.
+	ValidUntil  *time.Time
+	Inherit     bool
+	CreateDB    bool
+	Replication bool
+	BypassRLS   bool
+}
+
+// DefaultRoleAttributes returns the attributes users are created with.
+func DefaultRoleAttributes() RoleAttributes {
+	return RoleAttributes{
+		ConnectionLimit: -1,
+		Inherit:         true,
+	}
+}
+
+// RoleAttributes returns the attributes of an existing role.
+func (c *Client) RoleAttributes(name string) (RoleAttributes, error) {
+	var (
+		attrs      RoleAttributes
+		validUntil pgtype.Timestamptz
+	)
+	err := c.conn.QueryRow(c.ctx, getRoleAttributesQuery(name)).Scan(
+		&attrs.ConnectionLimit, &validUntil, &attrs.Inherit, &attrs.CreateDB, &attrs.Replication, &attrs.BypassRLS)
+	if err == pgx.ErrNoRows && c.dryRun() {
+		// the role would have been created with the defaults
+		return DefaultRoleAttributes(), nil
+	}
+	if err != nil {
+		c.logger.Error(err, "Failed to query from pg_roles")
+		return attrs, err
+	}
+	if validUntil.Valid && validUntil.InfinityModifier == pgtype.Finite {
+		attrs.ValidUntil = &validUntil.Time
+	}
+	return attrs, nil
+}
+
+// EnsureRoleAttributes alters the attributes of a role which differ from
+// desired.
+func (c *Client) EnsureRoleAttributes(name string, desired RoleAttributes) error {
+	current, err := c.RoleAttributes(name)
+	if err != nil {
+		return err
+	}
+
+	options := roleAttributeOptions(current, desired)
+	if len(options) == 0 {
+		return nil
+	}
+
+	if err := c.exec(alterRoleQuery(name, options)); err != nil {
+		c.logger.Error(err, "Failed to alter role attributes")
+		return err
+	}
+	c.logger.Info("Successfully altered role attributes", "options", options)
+
+	return nil
+}
+
+// roleAttributeOptions returns the ALTER ROLE options turning current into
+// desired.
+func roleAttributeOptions(current, desired RoleAttributes) []string {
+	var options []string
+	if current.ConnectionLimit != desired.ConnectionLimit {
+		options = append(options, fmt.Sprintf("CONNECTION LIMIT %d", desired.ConnectionLimit))
+	}
+	if !equalValidUntil(current.ValidUntil, desired.ValidUntil) {
+		validUntil := "infinity"
+		if desired.ValidUntil != nil {
+			validUntil = desired.ValidUntil.UTC().Format(time.RFC3339)
+		}
+		options = append(options, fmt.Sprintf("VALID UNTIL '%s'", validUntil))
+	}
+	flags := []struct {
+		current, desired bool
+		option           string
+	}{
+		{current.Inherit, desired.Inherit, "INHERIT"},
+		{current.CreateDB, desired.CreateDB, "CREATEDB"},
+		{current.Replication, desired.Replication, "REPLICATION"},
+		{current.BypassRLS, desired.BypassRLS, "BYPASSRLS"},
+	}
+	for _, f := range flags {
+		if f.current == f.desired {
+			continue
+		}
+		if f.desired {
+			options = append(options, f.option)
+		} else {
+			options = append(options, "NO"+f.option)
+		}
+	}
+	return options
+}
+
+func equalValidUntil(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	// pg_roles has microsecond precision while the spec has seconds
+	return a.Truncate(time.Second).Equal(b.Truncate(time.Second))
+}
@@ -3,18 +3,54 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/go-logr/logr"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type Client struct {
 	ctx    context.Context
 	logger logr.Logger
 
-	conn *pgx.Conn
+	conn    Conn
+	planner Planner
+	// user is the admin user the client is connected as
+	user string
+	// host is the capabilities of the host, or nil if they are unknown
+	host *HostInfo
+	// ownerExists tells whether the owners stamped on objects exist, or is
+	// nil if it is unknown
+	ownerExists OwnerExists
 }
 
+// Querier runs the queries reading the state of a host.
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Executor runs the statements changing the state of a host.
+type Executor interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// Conn is the connection of a Client. *pgx.Conn implements it, and so does
+// the in-memory server of package fake used by tests.
+type Conn interface {
+	Querier
+	Executor
+	Close(ctx context.Context) error
+}
+
+// Connector opens a Client for a connection string. NewClient connects to
+// live servers.
+type Connector func(ctx context.Context, logger logr.Logger, connStr string) (*Client, error)
+
+// NewClient connects to connStr. If ctx carries a plan (see WithPlan), the
+// client records the statements changing the host in it instead of
+// executing them. Queries reading the host are still run.
 func NewClient(ctx context.Context, logger logr.Logger, connStr string) (*Client, error) {
 	conn, err := pgx.Connect(ctx, connStr)
 	if err != nil {
@@ -22,11 +58,39 @@ func NewClient(ctx context.Context, logger logr.Logger, connStr string) (*Client
 		return nil, err
 	}
 
+	return NewClientWithConn(ctx, logger, conn, conn.Config().User), nil
+}
+
+// NewClientWithConn returns a client running its queries and statements on
+// conn, connected as the admin user. Like NewClient, it records the
+// statements in the plan of ctx if there is one.
+func NewClientWithConn(ctx context.Context, logger logr.Logger, conn Conn, user string) *Client {
+	var planner Planner = connPlanner{conn: conn}
+	if plan := planFrom(ctx); plan != nil {
+		planner = plan
+	}
+
 	return &Client{
-		ctx:    ctx,
-		logger: logger,
-		conn:   conn,
-	}, nil
+		ctx:         ctx,
+		logger:      logger,
+		conn:        conn,
+		planner:     planner,
+		user:        user,
+		host:        hostFrom(ctx),
+		ownerExists: ownerExistsFrom(ctx),
+	}
+}
+
+// exec runs sql through the planner of the client.
+func (c *Client) exec(sql string) error {
+	return c.planner.Exec(c.ctx, sql)
+}
+
+// dryRun returns whether the client records statements instead of executing
+// them.
+func (c *Client) dryRun() bool {
+	_, ok := c.planner.(*Plan)
+	return ok
 }
 
 func (c *Client) Close() error {
@@ -37,13 +101,25 @@ func (c *Client) Close() error {
 	}
 }
 
-func (c *Client) EnsureDatabase(name string) error {
+// EnsureDatabase creates the database name owned by owner. An existing
+// database is only managed if owner created it before, or if it was not
+// created by the operator and adopt is set.
+func (c *Client) EnsureDatabase(name string, owner Owner, adopt bool) error {
+	return c.EnsureDatabaseFromTemplate(name, "", owner, adopt)
+}
+
+// EnsureDatabaseFromTemplate is EnsureDatabase creating a missing database
+// as a copy of the database template, or of the default template if it is
+// empty. The sessions connected to template are terminated first, since
+// PostgreSQL refuses to copy a database in use.
+func (c *Client) EnsureDatabaseFromTemplate(name, template string, owner Owner, adopt bool) error {
 	var (
 		alreadyExists bool = false
 		datname       string
 		datacl        *string
+		comment       *string
 	)
-	err := c.conn.QueryRow(c.ctx, getDatabaseQuery(name)).Scan(&datname, &datacl)
+	err := c.conn.QueryRow(c.ctx, getDatabaseQuery(name)).Scan(&datname, &datacl, &comment)
 	switch {
 	case err == pgx.ErrNoRows:
 		alreadyExists = false
@@ -56,17 +132,71 @@ func (c *Client) EnsureDatabase(name string) error {
 
 	if alreadyExists {
 		c.logger.Info(fmt.Sprintf("Found database with name '%s' from pg_database", name), "datname", datname, "datacl", datacl)
-		return nil
+		stamp, err := c.checkOwner("database "+name, comment, owner, adopt)
+		if err != nil || !stamp {
+			return err
+		}
+		return c.stampDatabase(name, owner)
 	}
 	c.logger.Info(fmt.Sprintf("No database with name %s. Creating...", name))
 
-	if _, err := c.conn.Exec(c.ctx, createDatabaseQuery(name)); err != nil {
+	sql := createDatabaseQuery(name)
+	if template != "" {
+		if err := c.exec(terminateConnectionsQuery(template)); err != nil {
+			c.logger.Error(err, "Failed to terminate the sessions of the template database", "template", template)
+			return err
+		}
+		sql = createDatabaseFromTemplateQuery(name, template)
+	}
+	if err := c.exec(sql); err != nil {
 		c.logger.Error(err, "Failed to create a database")
 		return err
 	}
 	c.logger.Info("Successfully created a database")
 
-	return nil
+	return c.stampDatabase(name, owner)
+}
+
+// DatabaseSize returns the disk space used by the database name in bytes.
+// It is zero if the database does not exist.
+func (c *Client) DatabaseSize(name string) (int64, error) {
+	var size int64
+	err := c.conn.QueryRow(c.ctx, getDatabaseSizeQuery(name)).Scan(&size)
+	switch {
+	case err == pgx.ErrNoRows:
+		return 0, nil
+	case err != nil:
+		c.logger.Error(err, "Failed to query the size of a database")
+		return 0, err
+	}
+	return size, nil
+}
+
+// DatabaseStats is the size and the activity of a database.
+type DatabaseStats struct {
+	// Size is the disk space used in bytes.
+	Size int64
+	// Backends is the number of sessions connected.
+	Backends int32
+	// XactCommit and XactRollback are the numbers of transactions committed
+	// and rolled back since the statistics were last reset.
+	XactCommit   int64
+	XactRollback int64
+}
+
+// DatabaseStats returns the size and the activity of the database name from
+// pg_stat_database. It returns zero stats if the database does not exist.
+func (c *Client) DatabaseStats(name string) (DatabaseStats, error) {
+	var stats DatabaseStats
+	err := c.conn.QueryRow(c.ctx, getDatabaseStatsQuery(), name).Scan(&stats.Size, &stats.Backends, &stats.XactCommit, &stats.XactRollback)
+	switch {
+	case err == pgx.ErrNoRows:
+		return DatabaseStats{}, nil
+	case err != nil:
+		c.logger.Error(err, "Failed to query from pg_stat_database")
+		return DatabaseStats{}, err
+	}
+	return stats, nil
 }
 
 func (c *Client) EnsureDatabaseAccessRoles(name string) error {
@@ -75,18 +205,14 @@ func (c *Client) EnsureDatabaseAccessRoles(name string) error {
 		return err
 	}
 
-	if _, err := c.conn.Exec(c.ctx, grantConnectQuery(readonlyRole, name)); err != nil {
+	if err := c.exec(grantConnectQuery(readonlyRole, name)); err != nil {
 		c.logger.Error(err, "Failed to grant readonly privilege")
 		return err
 	}
-	if _, err := c.conn.Exec(c.ctx, grantUsageOnPublicQuery(readonlyRole)); err != nil {
+	if err := c.exec(grantUsageOnPublicQuery(readonlyRole)); err != nil {
 		c.logger.Error(err, "Failed to grant readonly privilege")
 		return err
 	}
-	if _, err := c.conn.Exec(c.ctx, grantReadOnlyOnTablesQuery(readonlyRole)); err != nil {
-		c.logger.Error(err, "Failed to grant readonly privilege on schema public")
-		return err
-	}
 	c.logger.Info("Successfully granted readonly privilege")
 
 	readwriteRole := name + "_readwrite"
@@ -94,25 +220,45 @@ func (c *Client) EnsureDatabaseAccessRoles(name string) error {
 		return err
 	}
 
-	if _, err := c.conn.Exec(c.ctx, grantConnectQuery(readwriteRole, name)); err != nil {
+	if err := c.exec(grantConnectQuery(readwriteRole, name)); err != nil {
 		c.logger.Error(err, "Failed to grant readwrite privilege")
 		return err
 	}
-	if _, err := c.conn.Exec(c.ctx, grantAllOnDatabase(readwriteRole, name)); err != nil {
+	if err := c.exec(grantAllOnDatabase(readwriteRole, name)); err != nil {
 		c.logger.Error(err, "Failed to grant readwrite privilege on database")
 		return err
 	}
-	if _, err := c.conn.Exec(c.ctx, grantAllOnPublicQuery(readwriteRole)); err != nil {
+	if err := c.exec(grantAllOnPublicQuery(readwriteRole)); err != nil {
 		c.logger.Error(err, "Failed to grant readwrite privilege on schema public")
 		return err
 	}
-	if _, err := c.conn.Exec(c.ctx, grantReadWriteOnTablesQuery(readwriteRole)); err != nil {
-		c.logger.Error(err, "Failed to grant readwrite privilege")
+	c.logger.Info("Successfully granted readwrite privilege")
+
+	ownerRole := name + "_owner"
+	if err := c.EnsureRole(ownerRole); err != nil {
 		return err
 	}
-	c.logger.Info("Successfully granted readwrite privilege")
 
-	return nil
+	// the admin has to be a member of the owner role to transfer ownership
+	if err := c.exec(grantRoleToUserQuery(ownerRole, c.user)); err != nil {
+		c.logger.Error(err, "Failed to grant owner role to root")
+		return err
+	}
+	if err := c.exec(grantAllOnDatabase(ownerRole, name)); err != nil {
+		c.logger.Error(err, "Failed to grant owner privilege on database")
+		return err
+	}
+	if err := c.exec(alterSchemaOwnerQuery("public", ownerRole)); err != nil {
+		c.logger.Error(err, "Failed to transfer ownership of schema public")
+		return err
+	}
+	c.logger.Info("Successfully granted owner privilege")
+
+	if err := c.GrantExistingObjects(name); err != nil {
+		return err
+	}
+
+	return c.EnsureDefaultPrivileges(name)
 }
 
 func (c *Client) EnsureRole(name string) error {
@@ -139,7 +285,7 @@ func (c *Client) EnsureRole(name string) error {
 
 	c.logger.Info(fmt.Sprintf("No role with name %s. Creating...", name))
 
-	if _, err := c.conn.Exec(c.ctx, createRoleQuery(name)); err != nil {
+	if err := c.exec(createRoleQuery(name)); err != nil {
 		c.logger.Error(err, "Failed to create a role")
 		return err
 	}
@@ -148,13 +294,27 @@ func (c *Client) EnsureRole(name string) error {
 	return nil
 }
 
-func (c *Client) EnsureUser(name, password string) error {
+// EnsureUser creates the login role name owned by owner and sets its
+// password. An existing role is only managed if owner created it before, or
+// if it was not created by the operator and adopt is set.
+func (c *Client) EnsureUser(name, password string, owner Owner, adopt bool) error {
+	exists, comment, err := c.roleComment(name)
+	if err != nil {
+		return err
+	}
+	stamp := true
+	if exists {
+		if stamp, err = c.checkOwner("role "+name, comment, owner, adopt); err != nil {
+			return err
+		}
+	}
+
 	var (
 		alreadyExists bool = false
 		usename       string
 		usesysid      uint32
 	)
-	err := c.conn.QueryRow(c.ctx, getUserQuery(name)).Scan(&usename, &usesysid)
+	err = c.conn.QueryRow(c.ctx, getUserQuery(name)).Scan(&usename, &usesysid)
 	switch {
 	case err == pgx.ErrNoRows:
 		alreadyExists = false
@@ -170,21 +330,31 @@ func (c *Client) EnsureUser(name, password string) error {
 	} else {
 		c.logger.Info(fmt.Sprintf("No user with name %s. Creating...", name))
 
-		if _, err := c.conn.Exec(c.ctx, createUserQuery(name)); err != nil {
+		if err := c.exec(createUserQuery(name)); err != nil {
 			c.logger.Error(err, "Failed to create an user")
 			return err
 		}
 		c.logger.Info("Successfully created an user")
 	}
+	if stamp {
+		if err := c.stampRole(name, owner); err != nil {
+			return err
+		}
+	}
 
-	if _, err := c.conn.Exec(c.ctx, setPasswordQuery(name, password)); err != nil {
+	if err := c.exec(setPasswordQuery(name, password)); err != nil {
 		c.logger.Error(err, "Failed to set password for an user")
 		return err
 	}
 	c.logger.Info("Successfully set password for the user")
 
+	if c.superuser() {
+		return nil
+	}
+	// admin users which are not superusers, e.g. on Amazon RDS, have to be
+	// members of the roles they manage
 	// https://docs.aws.amazon.com/AmazonRDS/latest/UserGuide/UsingWithRDS.MasterAccounts.html
-	if _, err := c.conn.Exec(c.ctx, grantRoleToUserQuery(name, c.conn.Config().User)); err != nil {
+	if err := c.exec(grantRoleToUserQuery(name, c.user)); err != nil {
 		c.logger.Error(err, "Failed to grant user role to root")
 		return err
 	}
@@ -193,8 +363,50 @@ func (c *Client) EnsureUser(name, password string) error {
 	return nil
 }
 
+// DropUser drops the login role name if it exists.
+func (c *Client) DropUser(name string) error {
+	if err := c.exec(dropRoleQuery(name)); err != nil {
+		c.logger.Error(err, "Failed to drop an user")
+		return err
+	}
+	c.logger.Info("Successfully dropped an user")
+	return nil
+}
+
+// DropLoginRole drops the login role name if it exists, after handing the
+// objects it owns in the current database over to owner and revoking its
+// privileges there.
+func (c *Client) DropLoginRole(name, owner string) error {
+	exists, err := c.RoleExists(name)
+	if err != nil || !exists {
+		return err
+	}
+	if err := c.exec(reassignOwnedQuery(name, owner)); err != nil {
+		c.logger.Error(err, "Failed to reassign the objects of an user")
+		return err
+	}
+	if err := c.exec(dropOwnedQuery(name)); err != nil {
+		c.logger.Error(err, "Failed to drop the privileges of an user")
+		return err
+	}
+	return c.DropUser(name)
+}
+
+// EnsureRoleToUser grants the membership in role to username. username may
+// be a login user or another role.
+func (c *Client) EnsureRoleToUser(role, username string) error {
+	if err := c.exec(grantRoleToUserQuery(role, username)); err != nil {
+		c.logger.Error(err, "Failed to grant a role to an user")
+		return err
+	}
+
+	c.logger.Info("Successfully granted a role to an user", "role", role)
+
+	return nil
+}
+
 func (c *Client) EnsureReadonlyRoleToUser(dbname, username string) error {
-	if _, err := c.conn.Exec(c.ctx, grantRoleToUserQuery(dbname+"_readonly", username)); err != nil {
+	if err := c.exec(grantRoleToUserQuery(dbname+"_readonly", username)); err != nil {
 		c.logger.Error(err, "Failed to grant a role to an user")
 		return err
 	}
@@ -205,16 +417,110 @@ func (c *Client) EnsureReadonlyRoleToUser(dbname, username string) error {
 }
 
 func (c *Client) EnsureReadwriteRoleToUser(dbname, username string) error {
-	if _, err := c.conn.Exec(c.ctx, grantRoleToUserQuery(dbname+"_readwrite", username)); err != nil {
+	if err := c.exec(grantRoleToUserQuery(dbname+"_readwrite", username)); err != nil {
 		c.logger.Error(err, "Failed to grant a role to an user")
 		return err
 	}
 
-	if _, err := c.conn.Exec(c.ctx, grantFutureQuery("SELECT", username, dbname+"_readonly")); err != nil {
-		c.logger.Error(err, "Failed to grant default privilege")
+	if err := c.ensureDefaultPrivilegesFor(dbname, username); err != nil {
 		return err
 	}
 	c.logger.Info("Successfully granted a role to an user")
 
 	return nil
 }
+
+// EnsureOwnerRoleToUser grants the owner role of a database to username and
+// makes the sessions of username in the database assume the owner role.
+func (c *Client) EnsureOwnerRoleToUser(dbname, username string) error {
+	ownerRole := dbname + "_owner"
+	if err := c.exec(grantRoleToUserQuery(ownerRole, username)); err != nil {
+		c.logger.Error(err, "Failed to grant a role to an user")
+		return err
+	}
+
+	if err := c.exec(setRoleInDatabaseQuery(username, dbname, ownerRole)); err != nil {
+		c.logger.Error(err, "Failed to set default role of an user")
+		return err
+	}
+	if err := c.ensureDefaultPrivilegesFor(dbname, username); err != nil {
+		return err
+	}
+	c.logger.Info("Successfully granted a role to an user")
+
+	return nil
+}
+
+// EnsureCustomPrivilegesToUser grants privileges on all current and future
+// tables of schema to username.
+func (c *Client) EnsureCustomPrivilegesToUser(dbname, schema, username string, privileges []string) error {
+	if err := ValidateTablePrivileges(privileges); err != nil {
+		return err
+	}
+	privilegeList := strings.Join(privileges, ", ")
+
+	if err := c.exec(grantConnectQuery(username, dbname)); err != nil {
+		c.logger.Error(err, "Failed to grant custom privilege")
+		return err
+	}
+	if err := c.exec(grantUsageOnSchemaQuery(schema, username)); err != nil {
+		c.logger.Error(err, "Failed to grant custom privilege")
+		return err
+	}
+	if err := c.exec(grantOnTablesInSchemaQuery(privilegeList, schema, username)); err != nil {
+		c.logger.Error(err, "Failed to grant custom privilege on schema "+schema)
+		return err
+	}
+	if err := c.exec(grantFutureInSchemaQuery(privilegeList, dbname+"_owner", schema, username)); err != nil {
+		c.logger.Error(err, "Failed to grant default privilege")
+		return err
+	}
+	c.logger.Info("Successfully granted custom privilege", "privileges", privileges)
+
+	return nil
+}
+
+// RevokeRoleFromUser revokes the membership in role from username.
+func (c *Client) RevokeRoleFromUser(role, username string) error {
+	if err := c.exec(revokeRoleFromUserQuery(role, username)); err != nil {
+		c.logger.Error(err, "Failed to revoke a role from an user")
+		return err
+	}
+
+	c.logger.Info("Successfully revoked a role from an user", "role", role)
+
+	return nil
+}
+
+func (c *Client) RevokeReadonlyRoleFromUser(dbname, username string) error {
+	return c.RevokeRoleFromUser(dbname+"_readonly", username)
+}
+
+func (c *Client) RevokeReadwriteRoleFromUser(dbname, username string) error {
+	return c.RevokeRoleFromUser(dbname+"_readwrite", username)
+}
+
+// RevokeOwnerRoleFromUser revokes the owner role of a database from
+// username. The session default role of username in the database is left to
+// EnsureRoleSettings.
+func (c *Client) RevokeOwnerRoleFromUser(dbname, username string) error {
+	return c.RevokeRoleFromUser(dbname+"_owner", username)
+}
+
+// RevokeCustomPrivilegesFromUser revokes privileges on all current and
+// future tables of schema from username.
+func (c *Client) RevokeCustomPrivilegesFromUser(dbname, schema, username string, privileges []string) error {
+	privilegeList := strings.Join(privileges, ", ")
+
+	if err := c.exec(revokeOnTablesInSchemaQuery(privilegeList, schema, username)); err != nil {
+		c.logger.Error(err, "Failed to revoke custom privilege on schema "+schema)
+		return err
+	}
+	if err := c.exec(revokeFutureInSchemaQuery(privilegeList, dbname+"_owner", schema, username)); err != nil {
+		c.logger.Error(err, "Failed to revoke default privilege")
+		return err
+	}
+	c.logger.Info("Successfully revoked custom privilege", "privileges", privileges)
+
+	return nil
+}
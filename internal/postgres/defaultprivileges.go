@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"github.com/jackc/pgx/v5"
+)
+
+// accessPrivileges lists the privileges of the readonly and readwrite roles
+// of a database on each type of object in the public schema.
+var accessPrivileges = []struct {
+	objects   string
+	readonly  string
+	readwrite string
+	// existing tells whether GRANT ... ON ALL <objects> is supported
+	existing bool
+}{
+	{objects: "TABLES", readonly: "SELECT", readwrite: "SELECT, INSERT, UPDATE, DELETE", existing: true},
+	{objects: "SEQUENCES", readonly: "SELECT", readwrite: "USAGE, SELECT, UPDATE", existing: true},
+	{objects: "FUNCTIONS", readonly: "EXECUTE", readwrite: "EXECUTE", existing: true},
+	{objects: "TYPES", readonly: "USAGE", readwrite: "USAGE"},
+}
+
+// GrantExistingObjects grants the privileges of the readonly and readwrite
+// roles of a database on all objects currently in the public schema. Run it
+// periodically to cover objects created outside of the default privileges,
+// e.g., by the admin or by roles the operator does not manage.
+func (c *Client) GrantExistingObjects(dbname string) error {
+	for _, p := range accessPrivileges {
+		if !p.existing {
+			continue
+		}
+		if err := c.exec(grantOnAllInSchemaQuery(p.readonly, p.objects, "public", dbname+"_readonly")); err != nil {
+			c.logger.Error(err, "Failed to grant readonly privilege on existing "+p.objects)
+			return err
+		}
+		if err := c.exec(grantOnAllInSchemaQuery(p.readwrite, p.objects, "public", dbname+"_readwrite")); err != nil {
+			c.logger.Error(err, "Failed to grant readwrite privilege on existing "+p.objects)
+			return err
+		}
+	}
+	c.logger.Info("Successfully granted privileges on existing objects")
+
+	return nil
+}
+
+// EnsureDefaultPrivileges makes the objects created by any role able to
+// create objects in the database accessible by its readonly and readwrite
+// roles.
+func (c *Client) EnsureDefaultPrivileges(dbname string) error {
+	rows, err := c.conn.Query(c.ctx, getCreatorRolesQuery(dbname))
+	if err != nil {
+		c.logger.Error(err, "Failed to query from pg_roles")
+		return err
+	}
+	creators, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		c.logger.Error(err, "Failed to query from pg_roles")
+		return err
+	}
+
+	for _, creator := range creators {
+		if err := c.ensureDefaultPrivilegesFor(dbname, creator); err != nil {
+			return err
+		}
+	}
+	c.logger.Info("Successfully granted default privileges", "creators", creators)
+
+	return nil
+}
+
+// ensureDefaultPrivilegesFor makes the objects created by creator accessible
+// by the readonly and readwrite roles of the database.
+func (c *Client) ensureDefaultPrivilegesFor(dbname, creator string) error {
+	for _, p := range accessPrivileges {
+		if err := c.exec(grantFutureOnQuery(p.readonly, creator, p.objects, "public", dbname+"_readonly")); err != nil {
+			c.logger.Error(err, "Failed to grant default privilege", "creator", creator)
+			return err
+		}
+		if err := c.exec(grantFutureOnQuery(p.readwrite, creator, p.objects, "public", dbname+"_readwrite")); err != nil {
+			c.logger.Error(err, "Failed to grant default privilege", "creator", creator)
+			return err
+		}
+	}
+
+	return nil
+}
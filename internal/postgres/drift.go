@@ -0,0 +1,254 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// maxDriftObjects is the number of objects listed in a difference about
+// missing privileges on existing objects.
+const maxDriftObjects = 5
+
+// RoleExists returns whether the role name exists.
+func (c *Client) RoleExists(name string) (bool, error) {
+	var (
+		rolname string
+		oid     uint32
+	)
+	err := c.conn.QueryRow(c.ctx, getRoleQuery(name)).Scan(&rolname, &oid)
+	switch {
+	case err == pgx.ErrNoRows:
+		return false, nil
+	case err != nil:
+		c.logger.Error(err, "Failed to query from pg_roles")
+		return false, err
+	}
+	return true, nil
+}
+
+// DatabaseExists returns whether the database name exists.
+func (c *Client) DatabaseExists(name string) (bool, error) {
+	var (
+		datname string
+		datacl  *string
+		comment *string
+	)
+	err := c.conn.QueryRow(c.ctx, getDatabaseQuery(name)).Scan(&datname, &datacl, &comment)
+	switch {
+	case err == pgx.ErrNoRows:
+		return false, nil
+	case err != nil:
+		c.logger.Error(err, "Failed to query from pg_database")
+		return false, err
+	}
+	return true, nil
+}
+
+// DatabaseDrift returns the differences between the access roles of the
+// database name and the state EnsureDatabaseAccessRoles establishes. The
+// client must be connected to the database.
+func (c *Client) DatabaseDrift(name string) ([]string, error) {
+	var diffs []string
+
+	roles := []struct {
+		name      string
+		database  []string
+		schema    []string
+		readwrite bool
+		objects   bool
+	}{
+		{name: name + "_readonly", database: []string{"CONNECT"}, schema: []string{"USAGE"}, objects: true},
+		{name: name + "_readwrite", database: []string{"CONNECT", "CREATE", "TEMPORARY"}, schema: []string{"USAGE", "CREATE"}, readwrite: true, objects: true},
+		{name: name + "_owner", database: []string{"CONNECT", "CREATE", "TEMPORARY"}},
+	}
+	for _, role := range roles {
+		exists, err := c.RoleExists(role.name)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			diffs = append(diffs, fmt.Sprintf("role %s does not exist", role.name))
+			continue
+		}
+
+		for _, privilege := range role.database {
+			granted, err := c.hasPrivilege("database", role.name, name, privilege)
+			if err != nil {
+				return nil, err
+			}
+			if !granted {
+				diffs = append(diffs, fmt.Sprintf("role %s lacks %s on database %s", role.name, privilege, name))
+			}
+		}
+		for _, privilege := range role.schema {
+			granted, err := c.hasPrivilege("schema", role.name, "public", privilege)
+			if err != nil {
+				return nil, err
+			}
+			if !granted {
+				diffs = append(diffs, fmt.Sprintf("role %s lacks %s on schema public", role.name, privilege))
+			}
+		}
+		if !role.objects {
+			continue
+		}
+
+		for _, p := range accessPrivileges {
+			if !p.existing {
+				continue
+			}
+			privileges := p.readonly
+			if role.readwrite {
+				privileges = p.readwrite
+			}
+			for _, privilege := range strings.Split(privileges, ", ") {
+				missing, err := c.objectsMissingPrivilege(p.objects, "public", role.name, privilege)
+				if err != nil {
+					return nil, err
+				}
+				if len(missing) > 0 {
+					diffs = append(diffs, fmt.Sprintf("role %s lacks %s on %s", role.name, privilege, objectList(p.objects, missing)))
+				}
+			}
+		}
+	}
+
+	var owner string
+	if err := c.conn.QueryRow(c.ctx, getSchemaOwnerQuery(), "public").Scan(&owner); err != nil {
+		c.logger.Error(err, "Failed to query from pg_namespace")
+		return nil, err
+	}
+	if owner != name+"_owner" {
+		diffs = append(diffs, fmt.Sprintf("schema public is owned by %s instead of %s_owner", owner, name))
+	}
+
+	return diffs, nil
+}
+
+// RoleDrift returns the differences between the attributes of the existing
+// role name and desired.
+func (c *Client) RoleDrift(name string, desired RoleAttributes) ([]string, error) {
+	current, err := c.RoleAttributes(name)
+	if err != nil {
+		return nil, err
+	}
+	if options := roleAttributeOptions(current, desired); len(options) > 0 {
+		return []string{fmt.Sprintf("role %s needs %s", name, strings.Join(options, " "))}, nil
+	}
+	return nil, nil
+}
+
+// MembershipDrift returns a difference if member is not directly a member
+// of role.
+func (c *Client) MembershipDrift(role, member string) ([]string, error) {
+	var isMember bool
+	if err := c.conn.QueryRow(c.ctx, getMembershipQuery(), role, member).Scan(&isMember); err != nil {
+		c.logger.Error(err, "Failed to query from pg_auth_members")
+		return nil, err
+	}
+	if !isMember {
+		return []string{fmt.Sprintf("role %s is not a member of %s", member, role)}, nil
+	}
+	return nil, nil
+}
+
+// CustomPrivilegesDrift returns the differences between the privileges of
+// username in the database the client is connected to and the ones
+// EnsureCustomPrivilegesToUser grants.
+func (c *Client) CustomPrivilegesDrift(dbname, schema, username string, privileges []string) ([]string, error) {
+	var diffs []string
+
+	granted, err := c.hasPrivilege("database", username, dbname, "CONNECT")
+	if err != nil {
+		return nil, err
+	}
+	if !granted {
+		diffs = append(diffs, fmt.Sprintf("role %s lacks CONNECT on database %s", username, dbname))
+	}
+	granted, err = c.hasPrivilege("schema", username, schema, "USAGE")
+	if err != nil {
+		return nil, err
+	}
+	if !granted {
+		diffs = append(diffs, fmt.Sprintf("role %s lacks USAGE on schema %s", username, schema))
+	}
+
+	for _, privilege := range privileges {
+		missing, err := c.objectsMissingPrivilege("TABLES", schema, username, privilege)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) > 0 {
+			diffs = append(diffs, fmt.Sprintf("role %s lacks %s on %s", username, privilege, objectList("TABLES", missing)))
+		}
+	}
+
+	return diffs, nil
+}
+
+// RoleSettingsDrift returns the differences between the session defaults of
+// role in dbname and desired.
+func (c *Client) RoleSettingsDrift(role, dbname string, desired map[string]string) ([]string, error) {
+	current, err := c.RoleSettings(role, dbname)
+	if err != nil {
+		return nil, err
+	}
+
+	where := ""
+	if dbname != "" {
+		where = " in database " + dbname
+	}
+	var diffs []string
+	for name, value := range desired {
+		value = settingValue(value)
+		if current[name] != value {
+			diffs = append(diffs, fmt.Sprintf("role %s has %s = %q%s instead of %q", role, name, current[name], where, value))
+		}
+	}
+	for name, value := range current {
+		if _, ok := desired[name]; !ok {
+			diffs = append(diffs, fmt.Sprintf("role %s has unexpected %s = %q%s", role, name, value, where))
+		}
+	}
+
+	return diffs, nil
+}
+
+// hasPrivilege returns whether role has privilege on the object of
+// objectType named target.
+func (c *Client) hasPrivilege(objectType, role, target, privilege string) (bool, error) {
+	var granted bool
+	if err := c.conn.QueryRow(c.ctx, hasPrivilegeQuery(objectType), role, target, privilege).Scan(&granted); err != nil {
+		c.logger.Error(err, "Failed to check a privilege", "objectType", objectType, "target", target)
+		return false, err
+	}
+	return granted, nil
+}
+
+// objectsMissingPrivilege returns the objects of a type (e.g., TABLES) in
+// schema role does not have privilege on.
+func (c *Client) objectsMissingPrivilege(objects, schema, role, privilege string) ([]string, error) {
+	rows, err := c.conn.Query(c.ctx, getObjectsMissingPrivilegeQuery(objects), schema, role, privilege)
+	if err != nil {
+		c.logger.Error(err, "Failed to check privileges on existing "+objects)
+		return nil, err
+	}
+	missing, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		c.logger.Error(err, "Failed to check privileges on existing "+objects)
+		return nil, err
+	}
+	return missing, nil
+}
+
+// objectList describes the objects of a type (e.g., TABLES), listing at most
+// maxDriftObjects of them.
+func objectList(objects string, names []string) string {
+	kind := strings.ToLower(objects)
+	if len(names) > maxDriftObjects {
+		return fmt.Sprintf("%s %s and %d more", kind, strings.Join(names[:maxDriftObjects], ", "), len(names)-maxDriftObjects)
+	}
+	return fmt.Sprintf("%s %s", kind, strings.Join(names, ", "))
+}
@@ -0,0 +1,149 @@
+package fake
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Conn is a connection to a database of a Server. It implements
+// postgres.Conn.
+type Conn struct {
+	server   *Server
+	database string
+	user     string
+	// statementTimeout is the statement timeout of the session in
+	// milliseconds, or zero
+	statementTimeout int64
+	// tx is the transaction in progress, or nil
+	tx *transaction
+}
+
+// Exec runs a statement changing the state of the server.
+func (c *Conn) Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error) {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+
+	if err := c.exec(sql); err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	return pgconn.NewCommandTag("OK"), nil
+}
+
+// Query runs a query reading the state of the server.
+func (c *Conn) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+
+	values, err := c.query(sql, args)
+	if err != nil {
+		return nil, err
+	}
+	return &rows{values: values}, nil
+}
+
+// QueryRow runs a query reading a single row of the state of the server.
+func (c *Conn) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	rows, err := c.Query(ctx, sql, args...)
+	if err != nil {
+		return errRow{err: err}
+	}
+	return row{rows: rows}
+}
+
+// Close closes the connection.
+func (c *Conn) Close(ctx context.Context) error {
+	return nil
+}
+
+// rows are the result of a query.
+type rows struct {
+	values [][]any
+	i      int
+}
+
+func (r *rows) Close()     {}
+func (r *rows) Err() error { return nil }
+func (r *rows) CommandTag() pgconn.CommandTag {
+	return pgconn.NewCommandTag(fmt.Sprintf("SELECT %d", len(r.values)))
+}
+func (r *rows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+func (r *rows) RawValues() [][]byte                          { return nil }
+func (r *rows) Conn() *pgx.Conn                              { return nil }
+
+func (r *rows) Next() bool {
+	if r.i >= len(r.values) {
+		return false
+	}
+	r.i++
+	return true
+}
+
+func (r *rows) Values() ([]any, error) {
+	return r.values[r.i-1], nil
+}
+
+func (r *rows) Scan(dest ...any) error {
+	values := r.values[r.i-1]
+	if len(dest) != len(values) {
+		return fmt.Errorf("fake: %d destinations for %d values", len(dest), len(values))
+	}
+	for i := range dest {
+		if err := assign(dest[i], values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// row is the first row of the result of a query.
+type row struct {
+	rows pgx.Rows
+}
+
+func (r row) Scan(dest ...any) error {
+	defer r.rows.Close()
+	if !r.rows.Next() {
+		return pgx.ErrNoRows
+	}
+	return r.rows.Scan(dest...)
+}
+
+// errRow is the result of a failed query.
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(dest ...any) error {
+	return r.err
+}
+
+// assign stores value in the pointer dest. Values are assigned to pointers
+// to their type or to pointers to pointers to their type.
+func assign(dest, value any) error {
+	d := reflect.ValueOf(dest)
+	if d.Kind() != reflect.Pointer || d.IsNil() {
+		return fmt.Errorf("fake: cannot scan into %T", dest)
+	}
+	target := d.Elem()
+	if value == nil {
+		target.Set(reflect.Zero(target.Type()))
+		return nil
+	}
+
+	v := reflect.ValueOf(value)
+	switch {
+	case v.Type().AssignableTo(target.Type()):
+		target.Set(v)
+	case target.Kind() == reflect.Pointer && v.Type().AssignableTo(target.Type().Elem()):
+		p := reflect.New(target.Type().Elem())
+		p.Elem().Set(v)
+		target.Set(p)
+	default:
+		return fmt.Errorf("fake: cannot scan %T into %T", value, dest)
+	}
+	return nil
+}
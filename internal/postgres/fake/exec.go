@@ -0,0 +1,749 @@
+package fake
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres"
+)
+
+var (
+	createRoleRegexp       = regexp.MustCompile(`^CREATE ROLE\s+(\S+)(?: WITH (.*))?$`)
+	dropRoleRegexp         = regexp.MustCompile(`^DROP ROLE (IF EXISTS )?(\S+)$`)
+	dropOwnedRegexp        = regexp.MustCompile(`^DROP OWNED BY (\S+)$`)
+	reassignOwnedRegexp    = regexp.MustCompile(`^REASSIGN OWNED BY (\S+) TO (\S+)$`)
+	setPasswordRegexp      = regexp.MustCompile(`^ALTER ROLE (\S+) PASSWORD '((?:[^']|'')*)'$`)
+	setRegexp              = regexp.MustCompile(`^ALTER ROLE (\S+)(?: IN DATABASE (\S+))? SET (\S+) = (.*)$`)
+	resetRegexp            = regexp.MustCompile(`^ALTER ROLE (\S+)(?: IN DATABASE (\S+))? RESET (\S+)$`)
+	alterRoleRegexp        = regexp.MustCompile(`^ALTER ROLE (\S+) WITH (.*)$`)
+	createDatabaseRegexp   = regexp.MustCompile(`^CREATE DATABASE (\S+)(?: TEMPLATE (\S+))?$`)
+	terminateRegexp        = regexp.MustCompile(`^SELECT pg_terminate_backend\(pid\) FROM pg_stat_activity WHERE datname = '(.*)'`)
+	scriptRegexp           = regexp.MustCompile(`(?s)^SET ROLE (\S+);\n(.*)$`)
+	transactionRegexp      = regexp.MustCompile(`^(BEGIN|COMMIT|ROLLBACK)$`)
+	migrationTableRegexp   = regexp.MustCompile(`^CREATE TABLE IF NOT EXISTS \S+ \(version text PRIMARY KEY, .*\)$`)
+	recordMigrationRegexp  = regexp.MustCompile(`^INSERT INTO \S+ \(version, checksum\) VALUES \('((?:[^']|'')*)', '((?:[^']|'')*)'\)$`)
+	statementTimeoutRegexp = regexp.MustCompile(`^(?:SET statement_timeout = (\d+)|RESET statement_timeout)$`)
+	maintenanceRegexp      = regexp.MustCompile(`^(VACUUM|ANALYZE|REINDEX (INDEX|TABLE|DATABASE)|REFRESH MATERIALIZED VIEW)(?: CONCURRENTLY)?(?: (\S+))?$`)
+	dropDatabaseRegexp     = regexp.MustCompile(`^DROP DATABASE (\S+)$`)
+	commentRegexp          = regexp.MustCompile(`^COMMENT ON (ROLE|DATABASE) (\S+) IS '((?:[^']|'')*)'$`)
+	schemaOwnerRegexp      = regexp.MustCompile(`^ALTER SCHEMA (\S+) OWNER TO (\S+)$`)
+	defaultPrivilegeRegexp = regexp.MustCompile(`^ALTER DEFAULT PRIVILEGES FOR USER (\S+) IN SCHEMA (\S+) (?:GRANT|REVOKE) .* (?:TO|FROM) (\S+)$`)
+	onAllRegexp            = regexp.MustCompile(`^(?:GRANT|REVOKE) .+ ON ALL \w+ IN SCHEMA (\S+) (?:TO|FROM) (\S+)$`)
+	onDatabaseRegexp       = regexp.MustCompile(`^(GRANT|REVOKE) (.+) ON DATABASE (\S+) (?:TO|FROM) (\S+)$`)
+	onSchemaRegexp         = regexp.MustCompile(`^(GRANT|REVOKE) (.+) ON SCHEMA (\S+) (?:TO|FROM) (\S+)$`)
+	onObjectRegexp         = regexp.MustCompile(`^(GRANT|REVOKE) (.+) ON (?:TABLE|SEQUENCE|FUNCTION) (.+) (?:TO|FROM) (\S+)$`)
+	grantRoleRegexp        = regexp.MustCompile(`^GRANT (\S+) TO (\S+)$`)
+	revokeRoleRegexp       = regexp.MustCompile(`^REVOKE (\S+) FROM (\S+)$`)
+	createPubRegexp        = regexp.MustCompile(`^CREATE PUBLICATION (\S+)(?: FOR (ALL TABLES|TABLE (.+)))? WITH \(publish = '(.*)'\)$`)
+	alterPubTablesRegexp   = regexp.MustCompile(`^ALTER PUBLICATION (\S+) (ADD|DROP) TABLE (.+)$`)
+	alterPubPublishRegexp  = regexp.MustCompile(`^ALTER PUBLICATION (\S+) SET \(publish = '(.*)'\)$`)
+	dropPubRegexp          = regexp.MustCompile(`^DROP PUBLICATION IF EXISTS (\S+)$`)
+	createSubRegexp        = regexp.MustCompile(`^CREATE SUBSCRIPTION (\S+) CONNECTION '((?:[^']|'')*)' PUBLICATION (.+) WITH \(slot_name = '(.*)', create_slot = (true|false), enabled = (true|false)\)$`)
+	alterSubRegexp         = regexp.MustCompile(`^ALTER SUBSCRIPTION (\S+) (?:CONNECTION '((?:[^']|'')*)'|(ENABLE|DISABLE)|SET \(slot_name = '(.*)'\)|SET PUBLICATION (.+?)( WITH \(refresh = false\))?)$`)
+	dropSubRegexp          = regexp.MustCompile(`^DROP SUBSCRIPTION IF EXISTS (\S+)$`)
+	createSlotRegexp       = regexp.MustCompile(`^SELECT pg_create_logical_replication_slot\('((?:[^']|'')*)', 'pgoutput'\)$`)
+
+	roleOptionRegexp   = regexp.MustCompile(`CONNECTION LIMIT (-?\d+)|VALID UNTIL '([^']*)'|(\w+)`)
+	quotedRegexp       = regexp.MustCompile(`'((?:[^']|'')*)'`)
+	privilegeRegexp    = regexp.MustCompile(`^(\w+)(?: \((.+)\))?$`)
+	allPrivilegesOnDB  = []string{"CONNECT", "CREATE", "TEMPORARY"}
+	allPrivilegesOnNSP = []string{"USAGE", "CREATE"}
+)
+
+// exec runs sql. The caller holds the lock of the server.
+func (c *Conn) exec(sql string) error {
+	s := c.server
+	sql = strings.TrimSpace(sql)
+
+	switch {
+	case createRoleRegexp.MatchString(sql):
+		m := createRoleRegexp.FindStringSubmatch(sql)
+		name := unquote(m[1])
+		if _, ok := s.roles[name]; ok {
+			return pgError("42710", "role %q already exists", name)
+		}
+		r := s.newRole(false)
+		if err := r.alter(m[2]); err != nil {
+			return err
+		}
+		s.roles[name] = r
+
+	case dropRoleRegexp.MatchString(sql):
+		m := dropRoleRegexp.FindStringSubmatch(sql)
+		name := unquote(m[2])
+		if _, err := s.role(name); err != nil {
+			if m[1] != "" {
+				break
+			}
+			return err
+		}
+		delete(s.roles, name)
+		for m := range s.members {
+			if m[0] == name || m[1] == name {
+				delete(s.members, m)
+			}
+		}
+		for key := range s.settings {
+			if key.role == name {
+				delete(s.settings, key)
+			}
+		}
+		for key := range s.privileges {
+			if key.role == name {
+				delete(s.privileges, key)
+			}
+		}
+		for _, db := range s.databases {
+			delete(db.privileges, name)
+		}
+
+	case reassignOwnedRegexp.MatchString(sql):
+		// objects are not modeled, so only the schemas change owners
+		m := reassignOwnedRegexp.FindStringSubmatch(sql)
+		name, owner := unquote(m[1]), unquote(m[2])
+		if _, err := s.role(name); err != nil {
+			return err
+		}
+		if _, err := s.role(owner); err != nil {
+			return err
+		}
+		db := s.databases[c.database]
+		for schema, current := range db.schemas {
+			if current == name {
+				db.schemas[schema] = owner
+			}
+		}
+
+	case dropOwnedRegexp.MatchString(sql):
+		// objects are not modeled, so only the privileges are revoked
+		name := unquote(dropOwnedRegexp.FindStringSubmatch(sql)[1])
+		if _, err := s.role(name); err != nil {
+			return err
+		}
+		for key := range s.privileges {
+			if key.database == c.database && key.role == name {
+				delete(s.privileges, key)
+			}
+		}
+		db := s.databases[c.database]
+		for key := range db.schemaPrivileges {
+			if key[1] == name {
+				delete(db.schemaPrivileges, key)
+			}
+		}
+		for _, db := range s.databases {
+			delete(db.privileges, name)
+		}
+
+	case setPasswordRegexp.MatchString(sql):
+		m := setPasswordRegexp.FindStringSubmatch(sql)
+		r, err := s.role(unquote(m[1]))
+		if err != nil {
+			return err
+		}
+		r.password = strings.ReplaceAll(m[2], "''", "'")
+
+	case setRegexp.MatchString(sql):
+		m := setRegexp.FindStringSubmatch(sql)
+		key, err := s.settingKey(m[1], m[2])
+		if err != nil {
+			return err
+		}
+		if s.settings[key] == nil {
+			s.settings[key] = make(map[string]string)
+		}
+		s.settings[key][strings.ToLower(m[3])] = settingValue(m[4])
+
+	case resetRegexp.MatchString(sql):
+		m := resetRegexp.FindStringSubmatch(sql)
+		key, err := s.settingKey(m[1], m[2])
+		if err != nil {
+			return err
+		}
+		delete(s.settings[key], strings.ToLower(m[3]))
+		if len(s.settings[key]) == 0 {
+			delete(s.settings, key)
+		}
+
+	case alterRoleRegexp.MatchString(sql):
+		m := alterRoleRegexp.FindStringSubmatch(sql)
+		r, err := s.role(unquote(m[1]))
+		if err != nil {
+			return err
+		}
+		return r.alter(m[2])
+
+	case createDatabaseRegexp.MatchString(sql):
+		m := createDatabaseRegexp.FindStringSubmatch(sql)
+		name := unquote(m[1])
+		if _, ok := s.databases[name]; ok {
+			return pgError("42P04", "database %q already exists", name)
+		}
+		if m[2] == "" {
+			s.databases[name] = s.newDatabase()
+			break
+		}
+		template, err := s.database(unquote(m[2]))
+		if err != nil {
+			return err
+		}
+		s.databases[name] = s.copyDatabase(template)
+		for key, privileges := range s.privileges {
+			if key.database == unquote(m[2]) {
+				key.database = name
+				s.privileges[key] = copySet(privileges)
+			}
+		}
+
+	case terminateRegexp.MatchString(sql):
+		// sessions are not modeled
+
+	case transactionRegexp.MatchString(sql):
+		// transactions only cover the scripts and the migration table
+		db := s.databases[c.database]
+		switch sql {
+		case "BEGIN":
+			s.nextXID++
+			c.tx = &transaction{
+				xid:        s.nextXID,
+				scripts:    append([]string(nil), db.scripts...),
+				migrations: append([]postgres.AppliedMigration(nil), db.migrations...),
+				existed:    db.migrations != nil,
+			}
+		case "ROLLBACK":
+			if c.tx != nil {
+				db.scripts = c.tx.scripts
+				db.migrations = c.tx.migrations
+				if c.tx.existed && db.migrations == nil {
+					db.migrations = []postgres.AppliedMigration{}
+				}
+			}
+			c.tx = nil
+		default:
+			c.tx = nil
+		}
+
+	case migrationTableRegexp.MatchString(sql):
+		db := s.databases[c.database]
+		if db.migrations == nil {
+			db.migrations = []postgres.AppliedMigration{}
+		}
+
+	case recordMigrationRegexp.MatchString(sql):
+		m := recordMigrationRegexp.FindStringSubmatch(sql)
+		db := s.databases[c.database]
+		if db.migrations == nil {
+			return pgError("42P01", "relation %q does not exist", postgres.MigrationTable)
+		}
+		version := strings.ReplaceAll(m[1], "''", "'")
+		for _, applied := range db.migrations {
+			if applied.Version == version {
+				return pgError("23505", "duplicate key value violates unique constraint, version %q exists", version)
+			}
+		}
+		db.migrations = append(db.migrations, postgres.AppliedMigration{Version: version, Checksum: strings.ReplaceAll(m[2], "''", "'"), AppliedAt: time.Now()})
+
+	case scriptRegexp.MatchString(sql):
+		m := scriptRegexp.FindStringSubmatch(sql)
+		if _, err := s.role(unquote(m[1])); err != nil {
+			return err
+		}
+		db := s.databases[c.database]
+		db.scripts = append(db.scripts, m[2])
+
+	case statementTimeoutRegexp.MatchString(sql):
+		m := statementTimeoutRegexp.FindStringSubmatch(sql)
+		c.statementTimeout = 0
+		if m[1] != "" {
+			timeout, err := strconv.ParseInt(m[1], 10, 64)
+			if err != nil {
+				return err
+			}
+			c.statementTimeout = timeout
+		}
+
+	case maintenanceRegexp.MatchString(sql):
+		m := maintenanceRegexp.FindStringSubmatch(sql)
+		db := s.databases[c.database]
+		if m[2] == "DATABASE" {
+			if unquote(m[3]) != c.database {
+				return pgError("0A000", "can only reindex the currently open database")
+			}
+		} else if m[3] != "" {
+			if err := c.checkMaintenance(m[1], m[2], unquote(m[3])); err != nil {
+				return err
+			}
+		} else if m[1] == "REFRESH MATERIALIZED VIEW" {
+			return pgError("42601", "syntax error at end of input")
+		}
+		db.maintenance = append(db.maintenance, sql)
+
+	case dropDatabaseRegexp.MatchString(sql):
+		name := unquote(dropDatabaseRegexp.FindStringSubmatch(sql)[1])
+		if _, err := s.database(name); err != nil {
+			return err
+		}
+		delete(s.databases, name)
+		for key := range s.settings {
+			if key.database == name {
+				delete(s.settings, key)
+			}
+		}
+		for key := range s.privileges {
+			if key.database == name {
+				delete(s.privileges, key)
+			}
+		}
+
+	case commentRegexp.MatchString(sql):
+		m := commentRegexp.FindStringSubmatch(sql)
+		comment := strings.ReplaceAll(m[3], "''", "'")
+		if m[1] == "ROLE" {
+			r, err := s.role(unquote(m[2]))
+			if err != nil {
+				return err
+			}
+			r.comment = &comment
+		} else {
+			db, err := s.database(unquote(m[2]))
+			if err != nil {
+				return err
+			}
+			db.comment = &comment
+		}
+
+	case schemaOwnerRegexp.MatchString(sql):
+		m := schemaOwnerRegexp.FindStringSubmatch(sql)
+		schema, owner := unquote(m[1]), unquote(m[2])
+		db := s.databases[c.database]
+		if _, ok := db.schemas[schema]; !ok {
+			return pgError("3F000", "schema %q does not exist", schema)
+		}
+		if _, err := s.role(owner); err != nil {
+			return err
+		}
+		db.schemas[schema] = owner
+
+	case defaultPrivilegeRegexp.MatchString(sql):
+		// objects are not modeled, so default privileges have no effect
+		m := defaultPrivilegeRegexp.FindStringSubmatch(sql)
+		if _, err := s.role(unquote(m[1])); err != nil {
+			return err
+		}
+		if _, err := s.role(unquote(m[3])); err != nil {
+			return err
+		}
+
+	case onAllRegexp.MatchString(sql):
+		// objects are not modeled, so there are none to grant on
+		m := onAllRegexp.FindStringSubmatch(sql)
+		if _, ok := s.databases[c.database].schemas[unquote(m[1])]; !ok {
+			return pgError("3F000", "schema %q does not exist", unquote(m[1]))
+		}
+		if _, err := s.role(unquote(m[2])); err != nil {
+			return err
+		}
+
+	case onDatabaseRegexp.MatchString(sql):
+		m := onDatabaseRegexp.FindStringSubmatch(sql)
+		db, err := s.database(unquote(m[3]))
+		if err != nil {
+			return err
+		}
+		grantee := unquote(m[4])
+		if _, err := s.role(grantee); err != nil {
+			return err
+		}
+		if db.privileges[grantee] == nil {
+			db.privileges[grantee] = make(map[string]bool)
+		}
+		apply(db.privileges[grantee], m[1] == "GRANT", expand(m[2], allPrivilegesOnDB))
+
+	case onSchemaRegexp.MatchString(sql):
+		m := onSchemaRegexp.FindStringSubmatch(sql)
+		db := s.databases[c.database]
+		schema, grantee := unquote(m[3]), unquote(m[4])
+		if _, ok := db.schemas[schema]; !ok {
+			return pgError("3F000", "schema %q does not exist", schema)
+		}
+		if _, err := s.role(grantee); err != nil {
+			return err
+		}
+		key := [2]string{schema, grantee}
+		if db.schemaPrivileges[key] == nil {
+			db.schemaPrivileges[key] = make(map[string]bool)
+		}
+		apply(db.schemaPrivileges[key], m[1] == "GRANT", expand(m[2], allPrivilegesOnNSP))
+
+	case onObjectRegexp.MatchString(sql):
+		m := onObjectRegexp.FindStringSubmatch(sql)
+		grantee := unquote(m[4])
+		if _, err := s.role(grantee); err != nil {
+			return err
+		}
+		for _, item := range strings.Split(m[2], ", ") {
+			p := privilegeRegexp.FindStringSubmatch(item)
+			if p == nil {
+				return fmt.Errorf("fake: unsupported privilege %q", item)
+			}
+			key := objectKey{database: c.database, role: grantee, object: unquote(m[3]), column: unquote(p[2])}
+			if s.privileges[key] == nil {
+				s.privileges[key] = make(map[string]bool)
+			}
+			apply(s.privileges[key], m[1] == "GRANT", []string{p[1]})
+			if len(s.privileges[key]) == 0 {
+				delete(s.privileges, key)
+			}
+		}
+
+	case grantRoleRegexp.MatchString(sql):
+		m := grantRoleRegexp.FindStringSubmatch(sql)
+		name, member := unquote(m[1]), unquote(m[2])
+		if _, err := s.role(name); err != nil {
+			return err
+		}
+		if _, err := s.role(member); err != nil {
+			return err
+		}
+		if s.isMemberOf(name, member) {
+			return pgError("0LP01", "role %q is a member of role %q", name, member)
+		}
+		s.members[[2]string{name, member}] = true
+
+	case revokeRoleRegexp.MatchString(sql):
+		m := revokeRoleRegexp.FindStringSubmatch(sql)
+		name, member := unquote(m[1]), unquote(m[2])
+		if _, err := s.role(name); err != nil {
+			return err
+		}
+		if _, err := s.role(member); err != nil {
+			return err
+		}
+		delete(s.members, [2]string{name, member})
+
+	case createPubRegexp.MatchString(sql):
+		m := createPubRegexp.FindStringSubmatch(sql)
+		db := s.databases[c.database]
+		name := unquote(m[1])
+		if _, ok := db.publications[name]; ok {
+			return pgError("42710", "publication %q already exists", name)
+		}
+		p := &publication{allTables: m[2] == "ALL TABLES", tables: make(map[string]bool), publish: splitList(m[4])}
+		if m[3] != "" {
+			if err := c.publishTables(p, true, m[3]); err != nil {
+				return err
+			}
+		}
+		db.publications[name] = p
+
+	case alterPubTablesRegexp.MatchString(sql):
+		m := alterPubTablesRegexp.FindStringSubmatch(sql)
+		p, err := c.publication(unquote(m[1]))
+		if err != nil {
+			return err
+		}
+		if p.allTables {
+			return pgError("55000", "publication %q is defined as FOR ALL TABLES", unquote(m[1]))
+		}
+		return c.publishTables(p, m[2] == "ADD", m[3])
+
+	case alterPubPublishRegexp.MatchString(sql):
+		m := alterPubPublishRegexp.FindStringSubmatch(sql)
+		p, err := c.publication(unquote(m[1]))
+		if err != nil {
+			return err
+		}
+		p.publish = splitList(m[2])
+
+	case dropPubRegexp.MatchString(sql):
+		delete(s.databases[c.database].publications, unquote(dropPubRegexp.FindStringSubmatch(sql)[1]))
+
+	case createSubRegexp.MatchString(sql):
+		m := createSubRegexp.FindStringSubmatch(sql)
+		db := s.databases[c.database]
+		name := unquote(m[1])
+		if _, ok := db.subscriptions[name]; ok {
+			return pgError("42710", "subscription %q already exists", name)
+		}
+		if m[5] == "true" {
+			// the publisher is this server, where creating the slot waits
+			// for the transaction creating the subscription
+			return fmt.Errorf("fake: subscription %q creating its replication slot on the same cluster blocks forever", name)
+		}
+		db.subscriptions[name] = &subscription{
+			enabled:      m[6] == "true",
+			connInfo:     strings.ReplaceAll(m[2], "''", "'"),
+			slotName:     m[4],
+			publications: unquoteList(m[3]),
+		}
+
+	case alterSubRegexp.MatchString(sql):
+		m := alterSubRegexp.FindStringSubmatch(sql)
+		sub, ok := s.databases[c.database].subscriptions[unquote(m[1])]
+		if !ok {
+			return pgError("42704", "subscription %q does not exist", unquote(m[1]))
+		}
+		switch {
+		case strings.Contains(sql, " CONNECTION '"):
+			sub.connInfo = strings.ReplaceAll(m[2], "''", "'")
+		case m[3] != "":
+			sub.enabled = m[3] == "ENABLE"
+		case strings.Contains(sql, " SET (slot_name"):
+			if sub.enabled {
+				return pgError("55000", "cannot set slot_name for enabled subscription")
+			}
+			sub.slotName = m[4]
+		default:
+			if !sub.enabled && m[6] == "" {
+				return pgError("55000", "ALTER SUBSCRIPTION with refresh is not allowed for disabled subscriptions")
+			}
+			sub.publications = unquoteList(m[5])
+		}
+
+	case dropSubRegexp.MatchString(sql):
+		db := s.databases[c.database]
+		name := unquote(dropSubRegexp.FindStringSubmatch(sql)[1])
+		if sub, ok := db.subscriptions[name]; ok {
+			delete(s.slots, sub.slotName)
+			delete(db.subscriptions, name)
+		}
+
+	case createSlotRegexp.MatchString(sql):
+		name := strings.ReplaceAll(createSlotRegexp.FindStringSubmatch(sql)[1], "''", "'")
+		if _, ok := s.slots[name]; ok {
+			return pgError("42710", "replication slot %q already exists", name)
+		}
+		s.slots[name] = c.database
+
+	case c.user != s.admin:
+		// other users only run the scripts of tenants
+		db := s.databases[c.database]
+		db.scripts = append(db.scripts, sql)
+
+	default:
+		return fmt.Errorf("fake: unsupported statement %q", sql)
+	}
+
+	return nil
+}
+
+// publication returns the publication name of the current database. The
+// caller holds the lock of the server.
+func (c *Conn) publication(name string) (*publication, error) {
+	p, ok := c.server.databases[c.database].publications[name]
+	if !ok {
+		return nil, pgError("42704", "publication %q does not exist", name)
+	}
+	return p, nil
+}
+
+// publishTables adds the tables of a comma-separated list to p or drops them
+// from it. The caller holds the lock of the server.
+func (c *Conn) publishTables(p *publication, add bool, list string) error {
+	db := c.server.databases[c.database]
+	for _, table := range unquoteList(list) {
+		switch {
+		case db.relations[table] != "r":
+			return pgError("42P01", "relation %q does not exist", table)
+		case add && p.tables[table]:
+			return pgError("42710", "relation %q is already member of publication", table)
+		case !add && !p.tables[table]:
+			return pgError("42704", "relation %q is not part of the publication", table)
+		}
+		if add {
+			p.tables[table] = true
+		} else {
+			delete(p.tables, table)
+		}
+	}
+	return nil
+}
+
+// checkMaintenance returns the error of the maintenance statement on the
+// relation name, e.g., if it does not exist or has the wrong kind. The caller
+// holds the lock of the server.
+func (c *Conn) checkMaintenance(statement, kind, name string) error {
+	db := c.server.databases[c.database]
+	if !strings.Contains(name, ".") {
+		name = "public." + name
+	}
+	relkind, ok := db.relations[name]
+	if !ok {
+		return pgError("42P01", "relation %q does not exist", name)
+	}
+	var want string
+	switch {
+	case kind == "INDEX":
+		want = "i"
+	case statement == "REFRESH MATERIALIZED VIEW":
+		want = "m"
+	case relkind == "i":
+		return pgError("42809", "%q is an index", name)
+	}
+	if want != "" && relkind != want {
+		return pgError("42809", "%q has the wrong kind", name)
+	}
+	if db.locked[name] {
+		if c.statementTimeout == 0 {
+			return fmt.Errorf("fake: maintenance of the locked relation %q blocks forever", name)
+		}
+		return pgError("57014", "canceling statement due to statement timeout")
+	}
+	return nil
+}
+
+// alter applies the options of CREATE ROLE or ALTER ROLE.
+func (r *role) alter(options string) error {
+	for _, m := range roleOptionRegexp.FindAllStringSubmatch(options, -1) {
+		switch {
+		case m[1] != "":
+			limit, err := strconv.ParseInt(m[1], 10, 32)
+			if err != nil {
+				return err
+			}
+			r.connLimit = int32(limit)
+			continue
+		case strings.HasPrefix(m[0], "VALID UNTIL"):
+			if m[2] == "infinity" {
+				r.validUntil = nil
+				continue
+			}
+			validUntil, err := time.Parse(time.RFC3339, m[2])
+			if err != nil {
+				return err
+			}
+			r.validUntil = &validUntil
+			continue
+		}
+
+		option := strings.ToUpper(m[3])
+		enable := true
+		if strings.HasPrefix(option, "NO") {
+			option, enable = strings.TrimPrefix(option, "NO"), false
+		}
+		switch option {
+		case "LOGIN":
+			r.login = enable
+		case "SUPERUSER":
+			r.superuser = enable
+		case "CREATEDB":
+			r.createdb = enable
+		case "CREATEROLE":
+			r.createrole = enable
+		case "INHERIT":
+			r.inherit = enable
+		case "REPLICATION":
+			r.replication = enable
+		case "BYPASSRLS":
+			r.bypassrls = enable
+		default:
+			return fmt.Errorf("fake: unsupported role option %q", m[0])
+		}
+	}
+	return nil
+}
+
+// role returns the role name. The caller holds the lock of the server.
+func (s *Server) role(name string) (*role, error) {
+	r, ok := s.roles[name]
+	if !ok {
+		return nil, pgError("42704", "role %q does not exist", name)
+	}
+	return r, nil
+}
+
+// database returns the database name. The caller holds the lock of the
+// server.
+func (s *Server) database(name string) (*database, error) {
+	db, ok := s.databases[name]
+	if !ok {
+		return nil, pgError("3D000", "database %q does not exist", name)
+	}
+	return db, nil
+}
+
+// settingKey returns the key of the session defaults of role in database,
+// or of the database independent ones if database is empty.
+func (s *Server) settingKey(role, database string) (settingKey, error) {
+	key := settingKey{role: unquote(role), database: unquote(database)}
+	if _, err := s.role(key.role); err != nil {
+		return key, err
+	}
+	if key.database != "" {
+		if _, err := s.database(key.database); err != nil {
+			return key, err
+		}
+	}
+	return key, nil
+}
+
+// settingValue returns the value of SET as stored in pg_db_role_setting.
+func settingValue(value string) string {
+	items := quotedRegexp.FindAllStringSubmatch(value, -1)
+	if items == nil {
+		return strings.TrimSpace(value)
+	}
+	values := make([]string, len(items))
+	for i, item := range items {
+		values[i] = strings.ReplaceAll(item[1], "''", "'")
+	}
+	return strings.Join(values, ", ")
+}
+
+// expand returns the privileges of a comma-separated list, expanding ALL to
+// all.
+func expand(list string, all []string) []string {
+	var privileges []string
+	for _, p := range strings.Split(list, ",") {
+		p = strings.ToUpper(strings.TrimSpace(p))
+		switch p {
+		case "ALL", "ALL PRIVILEGES":
+			privileges = append(privileges, all...)
+		case "TEMP":
+			privileges = append(privileges, "TEMPORARY")
+		default:
+			privileges = append(privileges, p)
+		}
+	}
+	return privileges
+}
+
+// apply grants or revokes privileges in held.
+func apply(held map[string]bool, grant bool, privileges []string) {
+	for _, p := range privileges {
+		if grant {
+			held[p] = true
+		} else {
+			delete(held, p)
+		}
+	}
+}
+
+// splitList returns the items of a comma-separated list.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// unquoteList returns the unquoted identifiers of a comma-separated list.
+func unquoteList(list string) []string {
+	items := splitList(list)
+	for i, item := range items {
+		items[i] = unquote(item)
+	}
+	return items
+}
+
+// unquote removes the double quotes of an identifier, possibly qualified
+// with its schema, e.g., `"public"."accounts"` becomes `public.accounts`.
+func unquote(identifier string) string {
+	return strings.ReplaceAll(identifier, `"`, "")
+}
@@ -0,0 +1,568 @@
+// Package fake provides an in-memory PostgreSQL server for tests. It models
+// pg_roles, pg_database, pg_auth_members and pg_db_role_setting, the kinds of
+// the relations in pg_class, the publications, subscriptions and replication
+// slots, and the privileges on databases, schemas and single objects well
+// enough to assert that the operator converges. It understands the statements and queries of
+// package postgres only. Statements it does not understand in sessions of
+// other users than the admin are recorded as the scripts of tenants.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres"
+)
+
+// Server is an in-memory PostgreSQL server. It is safe for concurrent use.
+type Server struct {
+	mu sync.Mutex
+
+	admin     string
+	nextOID   uint32
+	nextXID   int64
+	roles     map[string]*role
+	databases map[string]*database
+	// members holds the direct memberships as [role, member]
+	members  map[[2]string]bool
+	settings map[settingKey]map[string]string
+	// privileges holds the privileges granted on single objects
+	privileges map[objectKey]map[string]bool
+	// slots holds the databases of the replication slots
+	slots map[string]string
+	// host holds the version, settings and available extensions of the
+	// server
+	host postgres.HostInfo
+}
+
+type role struct {
+	oid         uint32
+	login       bool
+	superuser   bool
+	password    string
+	comment     *string
+	connLimit   int32
+	validUntil  *time.Time
+	inherit     bool
+	createdb    bool
+	createrole  bool
+	replication bool
+	bypassrls   bool
+}
+
+type database struct {
+	oid      uint32
+	template bool
+	comment  *string
+	size     int64
+	// stats holds the activity reported by pg_stat_database. The size
+	// reported is size.
+	stats postgres.DatabaseStats
+	// privileges holds the privileges of roles on the database
+	privileges map[string]map[string]bool
+	// schemas holds the owners of the schemas
+	schemas map[string]string
+	// schemaPrivileges holds the privileges of roles on the schemas
+	schemaPrivileges map[[2]string]map[string]bool
+	// scripts holds the SQL scripts run in the database, which are recorded
+	// but not interpreted
+	scripts []string
+	// migrations holds the migration table, which does not exist if it is
+	// nil
+	migrations []postgres.AppliedMigration
+	// relations holds the relkind of the tables, indexes and materialized
+	// views by their names qualified with their schemas
+	relations map[string]string
+	// locked holds the relations whose maintenance blocks until the
+	// statement timeout
+	locked map[string]bool
+	// maintenance holds the maintenance statements run in the database
+	maintenance []string
+	// publications holds the publications of the database
+	publications map[string]*publication
+	// subscriptions holds the subscriptions of the database
+	subscriptions map[string]*subscription
+}
+
+type publication struct {
+	allTables bool
+	// tables holds the published tables qualified with their schemas
+	tables  map[string]bool
+	publish []string
+}
+
+// subscription is a subscription of a database. The server models a single
+// cluster, so every subscription connects to it.
+type subscription struct {
+	enabled      bool
+	connInfo     string
+	slotName     string
+	publications []string
+}
+
+// transaction is a transaction of a session. It holds the scripts and the
+// migration table of the database as of its start, which are restored if it
+// is rolled back.
+type transaction struct {
+	xid        int64
+	scripts    []string
+	migrations []postgres.AppliedMigration
+	// existed tells whether the migration table existed
+	existed bool
+}
+
+// settingKey identifies the session defaults of a role in a database, or
+// the database independent ones if database is empty.
+type settingKey struct {
+	role     string
+	database string
+}
+
+// objectKey identifies the privileges of a role on a table, sequence or
+// function, or on a column of a table.
+type objectKey struct {
+	database string
+	role     string
+	object   string
+	column   string
+}
+
+// NewServer returns a server with the superuser admin and the databases
+// postgres and template1.
+func NewServer(admin string) *Server {
+	s := &Server{
+		admin:      admin,
+		nextOID:    16384,
+		roles:      make(map[string]*role),
+		databases:  make(map[string]*database),
+		members:    make(map[[2]string]bool),
+		settings:   make(map[settingKey]map[string]string),
+		privileges: make(map[objectKey]map[string]bool),
+		slots:      make(map[string]string),
+		host: postgres.HostInfo{
+			ServerVersionNum:   150004,
+			MaxConnections:     100,
+			PasswordEncryption: "scram-sha-256",
+			Extensions:         []string{"pg_stat_statements", "pgcrypto", "plpgsql"},
+		},
+	}
+	s.roles[admin] = s.newRole(true)
+	s.roles[admin].superuser = true
+	s.roles[admin].replication = true
+	s.roles[admin].bypassrls = true
+	s.databases["postgres"] = s.newDatabase()
+	s.databases["template1"] = s.newDatabase()
+	s.databases["template1"].template = true
+	return s
+}
+
+func (s *Server) newRole(login bool) *role {
+	s.nextOID++
+	return &role{oid: s.nextOID, login: login, connLimit: -1, inherit: true}
+}
+
+func (s *Server) newDatabase() *database {
+	s.nextOID++
+	return &database{
+		oid:              s.nextOID,
+		privileges:       make(map[string]map[string]bool),
+		schemas:          map[string]string{"public": "pg_database_owner"},
+		schemaPrivileges: make(map[[2]string]map[string]bool),
+		relations:        make(map[string]string),
+		locked:           make(map[string]bool),
+		publications:     make(map[string]*publication),
+		subscriptions:    make(map[string]*subscription),
+	}
+}
+
+// copyDatabase returns a new database with the schemas, schema privileges
+// and scripts of template, like CREATE DATABASE ... TEMPLATE.
+func (s *Server) copyDatabase(template *database) *database {
+	db := s.newDatabase()
+	db.size = template.size
+	for name, owner := range template.schemas {
+		db.schemas[name] = owner
+	}
+	for key, privileges := range template.schemaPrivileges {
+		db.schemaPrivileges[key] = copySet(privileges)
+	}
+	db.scripts = append(db.scripts, template.scripts...)
+	db.migrations = append(db.migrations, template.migrations...)
+	for name, relkind := range template.relations {
+		db.relations[name] = relkind
+	}
+	return db
+}
+
+func copySet(set map[string]bool) map[string]bool {
+	c := make(map[string]bool, len(set))
+	for k, v := range set {
+		c[k] = v
+	}
+	return c
+}
+
+// Connector returns a postgres.Connector opening clients connected to the
+// server as the user and to the database of the connection string.
+func (s *Server) Connector() postgres.Connector {
+	return func(ctx context.Context, logger logr.Logger, connStr string) (*postgres.Client, error) {
+		conn, err := s.Connect(connStr)
+		if err != nil {
+			logger.Error(err, "Failed to open database connection")
+			return nil, err
+		}
+		return postgres.NewClientWithConn(ctx, logger, conn, conn.user), nil
+	}
+}
+
+// Connect opens a connection to the database of connStr as its user.
+func (s *Server) Connect(connStr string) (*Conn, error) {
+	config, err := pgx.ParseConfig(connStr)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.databases[config.Database]; !ok {
+		return nil, pgError("3D000", "database %q does not exist", config.Database)
+	}
+	if _, ok := s.roles[config.User]; !ok {
+		return nil, pgError("28000", "role %q does not exist", config.User)
+	}
+	return &Conn{server: s, database: config.Database, user: config.User}, nil
+}
+
+// Exec runs sql in database as the admin, e.g., to change the state behind
+// the back of the operator.
+func (s *Server) Exec(database, sql string) error {
+	conn := &Conn{server: s, database: database, user: s.admin}
+	_, err := conn.Exec(context.Background(), sql)
+	return err
+}
+
+// DatabaseExists returns whether the database name exists.
+func (s *Server) DatabaseExists(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.databases[name]
+	return ok
+}
+
+// DatabaseComment returns the comment on the database name.
+func (s *Server) DatabaseComment(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if db, ok := s.databases[name]; ok && db.comment != nil {
+		return *db.comment
+	}
+	return ""
+}
+
+// SetDatabaseSize sets the size pg_database_size reports for the database
+// name.
+func (s *Server) SetDatabaseSize(name string, size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if db, ok := s.databases[name]; ok {
+		db.size = size
+	}
+}
+
+// SetDatabaseStats sets the activity pg_stat_database reports for the
+// database name. The size is the one set by SetDatabaseSize.
+func (s *Server) SetDatabaseStats(name string, stats postgres.DatabaseStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if db, ok := s.databases[name]; ok {
+		db.stats = stats
+	}
+}
+
+// SetHostInfo sets the version, the maximum number of connections, the
+// password encryption and the available extensions of the server. Whether the
+// admin user is a superuser, has the replication and bypassrls attributes or
+// is a member of a managed admin role follows from the roles of the server.
+func (s *Server) SetHostInfo(host postgres.HostInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.host = host
+}
+
+// Scripts returns the SQL scripts run in the database name, including the
+// ones of the database it was copied from.
+func (s *Server) Scripts(name string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if db, ok := s.databases[name]; ok {
+		return append([]string(nil), db.scripts...)
+	}
+	return nil
+}
+
+// Migrations returns the migration table of the database name.
+func (s *Server) Migrations(name string) []postgres.AppliedMigration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if db, ok := s.databases[name]; ok {
+		return append([]postgres.AppliedMigration(nil), db.migrations...)
+	}
+	return nil
+}
+
+// CreateSchema creates the schema name owned by owner in database.
+func (s *Server) CreateSchema(database, name, owner string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if db, ok := s.databases[database]; ok {
+		db.schemas[name] = owner
+	}
+}
+
+// CreateRelation creates the relation name of relkind in database, e.g.,
+// `r` for a table, `i` for an index or `m` for a materialized view. name is
+// qualified with its schema, e.g., `public.accounts`.
+func (s *Server) CreateRelation(database, name, relkind string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if db, ok := s.databases[database]; ok {
+		db.relations[name] = relkind
+	}
+}
+
+// LockRelation makes the maintenance statements on the relation name of
+// database run until they are canceled by the statement timeout, as if
+// another session held a conflicting lock.
+func (s *Server) LockRelation(database, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if db, ok := s.databases[database]; ok {
+		db.locked[name] = true
+	}
+}
+
+// Maintenance returns the maintenance statements run in the database name.
+func (s *Server) Maintenance(name string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if db, ok := s.databases[name]; ok {
+		return append([]string(nil), db.maintenance...)
+	}
+	return nil
+}
+
+// Publication returns the tables of the publication name in database
+// qualified with their schemas in order, or `ALL TABLES`, and its published
+// operations. ok is false if the publication does not exist.
+func (s *Server) Publication(database, name string) (tables []string, publish []string, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	db, found := s.databases[database]
+	if !found {
+		return nil, nil, false
+	}
+	p, found := db.publications[name]
+	if !found {
+		return nil, nil, false
+	}
+	if p.allTables {
+		tables = []string{"ALL TABLES"}
+	} else {
+		tables = sortedKeys(p.tables)
+	}
+	return tables, append([]string(nil), p.publish...), true
+}
+
+// Subscription returns the subscription name of database, or nil if it does
+// not exist.
+func (s *Server) Subscription(database, name string) *postgres.Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	db, ok := s.databases[database]
+	if !ok || db.subscriptions[name] == nil {
+		return nil
+	}
+	sub := db.subscriptions[name]
+	return &postgres.Subscription{
+		Name:         name,
+		ConnInfo:     sub.connInfo,
+		Publications: append([]string(nil), sub.publications...),
+		SlotName:     sub.slotName,
+		Enabled:      sub.enabled,
+	}
+}
+
+// ReplicationSlotExists returns whether the replication slot name exists.
+func (s *Server) ReplicationSlotExists(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.slots[name]
+	return ok
+}
+
+// RoleExists returns whether the role name exists.
+func (s *Server) RoleExists(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.roles[name]
+	return ok
+}
+
+// CanLogin returns whether the role name exists and can log in.
+func (s *Server) CanLogin(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.roles[name]
+	return ok && r.login
+}
+
+// Password returns the password of the role name.
+func (s *Server) Password(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.roles[name]; ok {
+		return r.password
+	}
+	return ""
+}
+
+// RoleComment returns the comment on the role name.
+func (s *Server) RoleComment(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.roles[name]; ok && r.comment != nil {
+		return *r.comment
+	}
+	return ""
+}
+
+// Attributes returns the attributes of the role name.
+func (s *Server) Attributes(name string) postgres.RoleAttributes {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.roles[name]
+	if !ok {
+		return postgres.RoleAttributes{}
+	}
+	return postgres.RoleAttributes{
+		ConnectionLimit: r.connLimit,
+		ValidUntil:      r.validUntil,
+		Inherit:         r.inherit,
+		CreateDB:        r.createdb,
+		Replication:     r.replication,
+		BypassRLS:       r.bypassrls,
+	}
+}
+
+// IsMember returns whether member is directly a member of role.
+func (s *Server) IsMember(role, member string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.members[[2]string{role, member}]
+}
+
+// Members returns the direct members of role in order.
+func (s *Server) Members(role string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var members []string
+	for m := range s.members {
+		if m[0] == role {
+			members = append(members, m[1])
+		}
+	}
+	sort.Strings(members)
+	return members
+}
+
+// HasDatabasePrivilege returns whether privilege on database was granted
+// directly to role.
+func (s *Server) HasDatabasePrivilege(role, database, privilege string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	db, ok := s.databases[database]
+	return ok && db.privileges[role][privilege]
+}
+
+// SchemaOwner returns the owner of schema in database.
+func (s *Server) SchemaOwner(database, schema string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if db, ok := s.databases[database]; ok {
+		return db.schemas[schema]
+	}
+	return ""
+}
+
+// Privileges returns the privileges granted directly to role on object in
+// database in order. object is a table, sequence or function qualified with
+// its schema, e.g., `public.accounts`.
+func (s *Server) Privileges(database, role, object string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedKeys(s.privileges[objectKey{database: database, role: role, object: object}])
+}
+
+// Settings returns the session defaults of role in database, or the
+// database independent ones if database is empty.
+func (s *Server) Settings(role, database string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	settings := make(map[string]string)
+	for name, value := range s.settings[settingKey{role: role, database: database}] {
+		settings[name] = value
+	}
+	return settings
+}
+
+// isMemberOf returns whether name is role or a direct or indirect member of
+// it, like pg_has_role with MEMBER.
+func (s *Server) isMemberOf(name, role string) bool {
+	if name == role {
+		return true
+	}
+	for m := range s.members {
+		if m[1] == name && s.isMemberOf(m[0], role) {
+			return true
+		}
+	}
+	return false
+}
+
+// effectiveRoles returns name and the roles it inherits privileges from.
+func (s *Server) effectiveRoles(name string) []string {
+	roles := []string{name}
+	seen := map[string]bool{name: true}
+	for i := 0; i < len(roles); i++ {
+		if r := s.roles[roles[i]]; r == nil || !r.inherit {
+			continue
+		}
+		for m := range s.members {
+			if m[1] == roles[i] && !seen[m[0]] {
+				seen[m[0]] = true
+				roles = append(roles, m[0])
+			}
+		}
+	}
+	return roles
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func pgError(code, format string, args ...any) error {
+	return &pgconn.PgError{Severity: "ERROR", Code: code, Message: fmt.Sprintf(format, args...)}
+}
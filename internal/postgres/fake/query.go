@@ -0,0 +1,403 @@
+package fake
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"k8s.io/utils/strings/slices"
+)
+
+var (
+	userRegexp              = regexp.MustCompile(`^SELECT usename, usesysid FROM pg_user WHERE usename = '(.*)'$`)
+	databaseRegexp          = regexp.MustCompile(`^SELECT datname, datacl, shobj_description\(oid, 'pg_database'\) FROM pg_database WHERE datname = '(.*)'$`)
+	databaseSizeRegexp      = regexp.MustCompile(`^SELECT pg_database_size\(datname\) FROM pg_database WHERE datname = '(.*)'$`)
+	databaseStatsRegexp     = regexp.MustCompile(`^SELECT pg_database_size\(datid\), numbackends, xact_commit, xact_rollback FROM pg_stat_database WHERE datname = \$1$`)
+	hostInfoRegexp          = regexp.MustCompile(`^SELECT current_setting\('server_version_num'\)::int, r\.rolsuper, r\.rolreplication, r\.rolbypassrls, .* FROM pg_roles r WHERE r\.rolname = current_user$`)
+	extensionsRegexp        = regexp.MustCompile(`^SELECT name FROM pg_available_extensions ORDER BY name$`)
+	roleRegexp              = regexp.MustCompile(`^SELECT rolname, oid FROM pg_roles WHERE rolname = '(.*)'$`)
+	roleCommentRegexp       = regexp.MustCompile(`^SELECT shobj_description\(oid, 'pg_authid'\) FROM pg_roles WHERE rolname = '(.*)'$`)
+	roleAttributesRegexp    = regexp.MustCompile(`^SELECT rolconnlimit, rolvaliduntil, rolinherit, rolcreatedb, rolreplication, rolbypassrls FROM pg_roles WHERE rolname = '(.*)'$`)
+	listDatabasesRegexp     = regexp.MustCompile(`^SELECT datname, shobj_description\(oid, 'pg_database'\) FROM pg_database WHERE NOT datistemplate`)
+	listRolesRegexp         = regexp.MustCompile(`^SELECT r\.rolname, r\.rolcanlogin, .* r\.rolname <> '(.*)' AND`)
+	creatorRolesRegexp      = regexp.MustCompile(`^SELECT rolname FROM pg_roles WHERE .*\(pg_has_role\(oid, '(.*)_readwrite', 'MEMBER'\)`)
+	tableGrantsRegexp       = regexp.MustCompile(`^SELECT privilege_type FROM information_schema\.role_table_grants`)
+	columnGrantsRegexp      = regexp.MustCompile(`^SELECT DISTINCT a\.privilege_type FROM pg_attribute o, aclexplode`)
+	resolveFunctionRegexp   = regexp.MustCompile(`^SELECT to_regprocedure\(\$1\)::text$`)
+	hasPrivilegeRegexp      = regexp.MustCompile(`^SELECT has_(\w+)_privilege\(\$1, \$2, \$3\)$`)
+	directGrantsRegexp      = regexp.MustCompile(`^SELECT DISTINCT a\.privilege_type FROM (pg_class|pg_proc|pg_namespace) o, aclexplode`)
+	roleSettingsRegexp      = regexp.MustCompile(`^SELECT unnest\(s\.setconfig\) FROM pg_db_role_setting`)
+	settingDBsRegexp        = regexp.MustCompile(`^SELECT d\.datname FROM pg_db_role_setting`)
+	schemaOwnerQueryRegexp  = regexp.MustCompile(`^SELECT pg_get_userbyid\(nspowner\) FROM pg_namespace`)
+	membershipRegexp        = regexp.MustCompile(`^SELECT EXISTS \(SELECT 1 FROM pg_auth_members`)
+	transactionIDRegexp     = regexp.MustCompile(`^SELECT txid_current\(\)$`)
+	migrationsRegexp        = regexp.MustCompile(`^SELECT version, checksum, applied_at FROM (\S+) ORDER BY version$`)
+	currentDatabaseRegexp   = regexp.MustCompile(`^SELECT current_database\(\)$`)
+	relationKindRegexp      = regexp.MustCompile(`^SELECT relkind::text FROM pg_class WHERE oid = to_regclass\(\$1\)$`)
+	publicationRegexp       = regexp.MustCompile(`^SELECT puballtables, pubinsert, pubupdate, pubdelete, pubtruncate FROM pg_publication WHERE pubname = \$1$`)
+	publicationTablesRegexp = regexp.MustCompile(`^SELECT schemaname \|\| '\.' \|\| tablename FROM pg_publication_tables WHERE pubname = \$1`)
+	subscriptionRegexp      = regexp.MustCompile(`^SELECT s\.subenabled, s\.subconninfo, .* FROM pg_subscription s`)
+	subscriptionStatsRegexp = regexp.MustCompile(`^SELECT pid IS NOT NULL, .* FROM pg_stat_subscription WHERE subname = \$1`)
+	replicationSlotRegexp   = regexp.MustCompile(`^SELECT active, .* FROM pg_replication_slots WHERE slot_name = \$1$`)
+	missingObjectsRegexp    = regexp.MustCompile(`^SELECT (?:c\.relname FROM pg_class|p\.proname FROM pg_proc) .* NOT has_\w+_privilege\(\$2`)
+
+	systemRoleRegexp = regexp.MustCompile(`^(pg_|rds|cloudsql)`)
+	signatureRegexp  = regexp.MustCompile(`^(?:"[^"]+"|\w+)\.\w+\([\w ,.\[\]"]*\)$`)
+)
+
+// query runs sql with args and returns the rows. The caller holds the lock
+// of the server.
+func (c *Conn) query(sql string, args []any) ([][]any, error) {
+	s := c.server
+	sql = strings.TrimSpace(sql)
+	arg := func(i int) string {
+		if i >= len(args) {
+			return ""
+		}
+		return fmt.Sprint(args[i])
+	}
+
+	switch {
+	case userRegexp.MatchString(sql):
+		name := userRegexp.FindStringSubmatch(sql)[1]
+		if r, ok := s.roles[name]; ok && r.login {
+			return [][]any{{name, r.oid}}, nil
+		}
+		return nil, nil
+
+	case databaseRegexp.MatchString(sql):
+		name := databaseRegexp.FindStringSubmatch(sql)[1]
+		if db, ok := s.databases[name]; ok {
+			return [][]any{{name, (*string)(nil), db.comment}}, nil
+		}
+		return nil, nil
+
+	case databaseSizeRegexp.MatchString(sql):
+		name := databaseSizeRegexp.FindStringSubmatch(sql)[1]
+		if db, ok := s.databases[name]; ok {
+			return [][]any{{db.size}}, nil
+		}
+		return nil, nil
+
+	case databaseStatsRegexp.MatchString(sql):
+		if db, ok := s.databases[arg(0)]; ok {
+			return [][]any{{db.size, db.stats.Backends, db.stats.XactCommit, db.stats.XactRollback}}, nil
+		}
+		return nil, nil
+
+	case hostInfoRegexp.MatchString(sql):
+		r, err := s.role(c.user)
+		if err != nil {
+			return nil, err
+		}
+		var managedAdmin *string
+		roles, _ := args[0].([]string)
+		for _, name := range roles {
+			if _, ok := s.roles[name]; ok && s.isMemberOf(c.user, name) {
+				managedAdmin = &name
+				break
+			}
+		}
+		return [][]any{{s.host.ServerVersionNum, r.superuser, r.replication, r.bypassrls, managedAdmin, s.host.MaxConnections, s.host.PasswordEncryption}}, nil
+
+	case extensionsRegexp.MatchString(sql):
+		var values [][]any
+		for _, name := range s.host.Extensions {
+			values = append(values, []any{name})
+		}
+		return values, nil
+
+	case transactionIDRegexp.MatchString(sql):
+		if c.tx == nil {
+			// every query runs in a transaction of its own
+			s.nextXID++
+			return [][]any{{s.nextXID}}, nil
+		}
+		return [][]any{{c.tx.xid}}, nil
+
+	case migrationsRegexp.MatchString(sql):
+		db := s.databases[c.database]
+		if db.migrations == nil {
+			return nil, pgError("42P01", "relation %s does not exist", migrationsRegexp.FindStringSubmatch(sql)[1])
+		}
+		var values [][]any
+		for _, m := range db.migrations {
+			values = append(values, []any{m.Version, m.Checksum, m.AppliedAt})
+		}
+		sort.Slice(values, func(i, j int) bool { return values[i][0].(string) < values[j][0].(string) })
+		return values, nil
+
+	case currentDatabaseRegexp.MatchString(sql):
+		return [][]any{{c.database}}, nil
+
+	case relationKindRegexp.MatchString(sql):
+		name := unquote(arg(0))
+		if !strings.Contains(name, ".") {
+			name = "public." + name
+		}
+		if relkind, ok := s.databases[c.database].relations[name]; ok {
+			return [][]any{{relkind}}, nil
+		}
+		return nil, nil
+
+	case roleRegexp.MatchString(sql):
+		name := roleRegexp.FindStringSubmatch(sql)[1]
+		if r, ok := s.roles[name]; ok {
+			return [][]any{{name, r.oid}}, nil
+		}
+		return nil, nil
+
+	case roleCommentRegexp.MatchString(sql):
+		name := roleCommentRegexp.FindStringSubmatch(sql)[1]
+		if r, ok := s.roles[name]; ok {
+			return [][]any{{r.comment}}, nil
+		}
+		return nil, nil
+
+	case roleAttributesRegexp.MatchString(sql):
+		name := roleAttributesRegexp.FindStringSubmatch(sql)[1]
+		r, ok := s.roles[name]
+		if !ok {
+			return nil, nil
+		}
+		validUntil := pgtype.Timestamptz{Valid: true, InfinityModifier: pgtype.Infinity}
+		if r.validUntil != nil {
+			validUntil = pgtype.Timestamptz{Valid: true, Time: *r.validUntil}
+		}
+		return [][]any{{r.connLimit, validUntil, r.inherit, r.createdb, r.replication, r.bypassrls}}, nil
+
+	case listDatabasesRegexp.MatchString(sql):
+		var values [][]any
+		for _, name := range sortedKeys(s.databases) {
+			if db := s.databases[name]; !db.template && name != "postgres" {
+				values = append(values, []any{name, db.comment})
+			}
+		}
+		return values, nil
+
+	case listRolesRegexp.MatchString(sql):
+		admin := listRolesRegexp.FindStringSubmatch(sql)[1]
+		var values [][]any
+		for _, name := range sortedKeys(s.roles) {
+			r := s.roles[name]
+			if r.superuser || name == admin || systemRoleRegexp.MatchString(name) {
+				continue
+			}
+			memberOf := []string{}
+			for m := range s.members {
+				if m[1] == name {
+					memberOf = append(memberOf, m[0])
+				}
+			}
+			sort.Strings(memberOf)
+			values = append(values, []any{name, r.login, r.comment, memberOf})
+		}
+		return values, nil
+
+	case creatorRolesRegexp.MatchString(sql):
+		dbname := creatorRolesRegexp.FindStringSubmatch(sql)[1]
+		for _, name := range []string{dbname + "_readwrite", dbname + "_owner"} {
+			if _, err := s.role(name); err != nil {
+				return nil, err
+			}
+		}
+		var values [][]any
+		for _, name := range sortedKeys(s.roles) {
+			r := s.roles[name]
+			if strings.HasPrefix(name, "pg_") || (r.superuser && name != c.user) {
+				continue
+			}
+			if s.isMemberOf(name, dbname+"_readwrite") || s.isMemberOf(name, dbname+"_owner") {
+				values = append(values, []any{name})
+			}
+		}
+		return values, nil
+
+	case tableGrantsRegexp.MatchString(sql):
+		key := objectKey{database: c.database, role: arg(0), object: arg(1) + "." + arg(2)}
+		var values [][]any
+		for _, p := range sortedKeys(s.privileges[key]) {
+			values = append(values, []any{p})
+		}
+		return values, nil
+
+	case columnGrantsRegexp.MatchString(sql):
+		key := objectKey{database: c.database, role: arg(0), object: unquote(arg(1)), column: arg(2)}
+		var values [][]any
+		for _, p := range sortedKeys(s.privileges[key]) {
+			values = append(values, []any{p})
+		}
+		return values, nil
+
+	case resolveFunctionRegexp.MatchString(sql):
+		// functions are not tracked: any signature is taken to exist
+		if !signatureRegexp.MatchString(arg(0)) {
+			return [][]any{{nil}}, nil
+		}
+		return [][]any{{arg(0)}}, nil
+
+	case directGrantsRegexp.MatchString(sql):
+		object := unquote(arg(1))
+		var held map[string]bool
+		if directGrantsRegexp.FindStringSubmatch(sql)[1] == "pg_namespace" {
+			held = s.databases[c.database].schemaPrivileges[[2]string{object, arg(0)}]
+		} else {
+			held = s.privileges[objectKey{database: c.database, role: arg(0), object: object}]
+		}
+		var values [][]any
+		for _, p := range sortedKeys(held) {
+			values = append(values, []any{p})
+		}
+		return values, nil
+
+	case hasPrivilegeRegexp.MatchString(sql):
+		objectType := hasPrivilegeRegexp.FindStringSubmatch(sql)[1]
+		has, err := c.hasPrivilege(objectType, arg(0), unquote(arg(1)), strings.ToUpper(arg(2)))
+		if err != nil {
+			return nil, err
+		}
+		return [][]any{{has}}, nil
+
+	case roleSettingsRegexp.MatchString(sql):
+		key := settingKey{role: arg(0), database: arg(1)}
+		if _, ok := s.databases[key.database]; !ok {
+			key.database = ""
+		}
+		var values [][]any
+		settings := s.settings[key]
+		for _, name := range sortedKeys(settings) {
+			values = append(values, []any{name + "=" + settings[name]})
+		}
+		return values, nil
+
+	case settingDBsRegexp.MatchString(sql):
+		var databases []string
+		for key := range s.settings {
+			if key.role == arg(0) && key.database != "" {
+				databases = append(databases, key.database)
+			}
+		}
+		sort.Strings(databases)
+		var values [][]any
+		for _, name := range databases {
+			values = append(values, []any{name})
+		}
+		return values, nil
+
+	case schemaOwnerQueryRegexp.MatchString(sql):
+		if owner, ok := s.databases[c.database].schemas[arg(0)]; ok {
+			return [][]any{{owner}}, nil
+		}
+		return nil, nil
+
+	case membershipRegexp.MatchString(sql):
+		return [][]any{{s.members[[2]string{arg(0), arg(1)}]}}, nil
+
+	case publicationRegexp.MatchString(sql):
+		p, ok := s.databases[c.database].publications[arg(0)]
+		if !ok {
+			return nil, nil
+		}
+		values := []any{p.allTables}
+		for _, op := range []string{"insert", "update", "delete", "truncate"} {
+			values = append(values, slices.Contains(p.publish, op))
+		}
+		return [][]any{values}, nil
+
+	case publicationTablesRegexp.MatchString(sql):
+		db := s.databases[c.database]
+		p, ok := db.publications[arg(0)]
+		if !ok {
+			return nil, nil
+		}
+		var values [][]any
+		for _, name := range sortedKeys(db.relations) {
+			if db.relations[name] == "r" && (p.allTables || p.tables[name]) {
+				values = append(values, []any{name})
+			}
+		}
+		return values, nil
+
+	case subscriptionRegexp.MatchString(sql):
+		if sub, ok := s.databases[c.database].subscriptions[arg(0)]; ok {
+			return [][]any{{sub.enabled, sub.connInfo, sub.slotName, append([]string(nil), sub.publications...)}}, nil
+		}
+		return nil, nil
+
+	case subscriptionStatsRegexp.MatchString(sql):
+		// the apply worker runs if the subscription is enabled and its slot
+		// exists, but receives nothing
+		for _, name := range sortedKeys(s.databases) {
+			if sub, ok := s.databases[name].subscriptions[arg(0)]; ok {
+				_, slot := s.slots[sub.slotName]
+				return [][]any{{sub.enabled && slot, nil, nil, nil, nil}}, nil
+			}
+		}
+		return nil, nil
+
+	case replicationSlotRegexp.MatchString(sql):
+		if _, ok := s.slots[arg(0)]; !ok {
+			return nil, nil
+		}
+		active := false
+		for _, db := range s.databases {
+			for _, sub := range db.subscriptions {
+				active = active || (sub.enabled && sub.slotName == arg(0))
+			}
+		}
+		return [][]any{{active, nil, nil}}, nil
+
+	case missingObjectsRegexp.MatchString(sql):
+		// objects are not modeled, so none misses a privilege
+		if _, err := s.role(arg(1)); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("fake: unsupported query %q", sql)
+}
+
+// hasPrivilege returns whether the role name holds privilege on object of
+// objectType directly or through the roles it inherits from. The caller
+// holds the lock of the server.
+func (c *Conn) hasPrivilege(objectType, name, object, privilege string) (bool, error) {
+	s := c.server
+	r, err := s.role(name)
+	if err != nil {
+		return false, err
+	}
+	if r.superuser {
+		return true, nil
+	}
+
+	db := s.databases[c.database]
+	switch objectType {
+	case "database":
+		if db, err = s.database(object); err != nil {
+			return false, err
+		}
+	case "schema":
+		if _, ok := db.schemas[object]; !ok {
+			return false, pgError("3F000", "schema %q does not exist", object)
+		}
+	}
+
+	for _, effective := range s.effectiveRoles(name) {
+		var held bool
+		switch objectType {
+		case "database":
+			held = db.privileges[effective][privilege]
+		case "schema":
+			held = db.schemas[object] == effective || db.schemaPrivileges[[2]string{object, effective}][privilege]
+		default:
+			held = s.privileges[objectKey{database: c.database, role: effective, object: object}][privilege]
+		}
+		if held {
+			return true, nil
+		}
+	}
+	return false, nil
+}
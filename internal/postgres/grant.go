@@ -0,0 +1,229 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"k8s.io/utils/strings/slices"
+)
+
+// ObjectType is the type of a database object privileges are granted on.
+type ObjectType string
+
+const (
+	ObjectTable    ObjectType = "table"
+	ObjectSequence ObjectType = "sequence"
+	ObjectFunction ObjectType = "function"
+	ObjectSchema   ObjectType = "schema"
+)
+
+// objectPrivileges lists the privileges applicable to each object type.
+var objectPrivileges = map[ObjectType][]string{
+	ObjectTable:    {"SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER"},
+	ObjectSequence: {"USAGE", "SELECT", "UPDATE"},
+	ObjectFunction: {"EXECUTE"},
+	ObjectSchema:   {"USAGE"},
+}
+
+// columnPrivileges lists the privileges applicable to table columns.
+var columnPrivileges = []string{"SELECT", "INSERT", "UPDATE", "REFERENCES"}
+
+// Grant describes the privileges of a role on a single object or column.
+type Grant struct {
+	Role       string
+	ObjectType ObjectType
+	Schema     string
+	// Object is the table or sequence name, or the function signature. It
+	// is empty for schemas.
+	Object string
+	// Column restricts table privileges to a single column.
+	Column     string
+	Privileges []string
+}
+
+// Validate returns an error if the grant references an unknown object type
+// or privileges that are not applicable to the object.
+func (g Grant) Validate() error {
+	applicable, ok := objectPrivileges[g.ObjectType]
+	if !ok {
+		return fmt.Errorf("unknown object type %q", g.ObjectType)
+	}
+	if g.Column != "" {
+		if g.ObjectType != ObjectTable {
+			return fmt.Errorf("columns can only be specified for tables")
+		}
+		applicable = columnPrivileges
+	}
+	if g.ObjectType != ObjectSchema && g.Object == "" {
+		return fmt.Errorf("no %s given", g.ObjectType)
+	}
+	if p := inapplicable(applicable, g.Privileges); p != "" {
+		return fmt.Errorf("privilege %s is not applicable to %s %s", p, g.ObjectType, g.object())
+	}
+	return nil
+}
+
+// ValidateTablePrivileges returns an error if privileges contains a
+// privilege that cannot be granted on tables.
+func ValidateTablePrivileges(privileges []string) error {
+	if p := inapplicable(objectPrivileges[ObjectTable], privileges); p != "" {
+		return fmt.Errorf("privilege %s is not applicable to tables", p)
+	}
+	return nil
+}
+
+// inapplicable returns the first privilege not in applicable.
+func inapplicable(applicable, privileges []string) string {
+	for _, p := range privileges {
+		if !slices.Contains(applicable, p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// target returns the object clause of GRANT and REVOKE statements for g.
+// Function signatures carry argument types and cannot be quoted, so they are
+// resolved by the server and its canonical form is used instead. ok is false
+// if the function does not exist.
+func (c *Client) target(g Grant) (target string, ok bool, err error) {
+	switch g.ObjectType {
+	case ObjectSchema:
+		return "SCHEMA " + pgx.Identifier{g.Schema}.Sanitize(), true, nil
+	case ObjectFunction:
+		var signature *string
+		if err := c.conn.QueryRow(c.ctx, resolveFunctionQuery(), g.object()).Scan(&signature); err != nil {
+			c.logger.Error(err, "Failed to resolve the function", "function", g.object())
+			return "", false, err
+		}
+		if signature == nil {
+			return "", false, nil
+		}
+		return "FUNCTION " + *signature, true, nil
+	default:
+		return strings.ToUpper(string(g.ObjectType)) + " " + pgx.Identifier{g.Schema, g.Object}.Sanitize(), true, nil
+	}
+}
+
+// object returns the name of the object as accepted by to_regclass and
+// to_regprocedure, or the schema name for schemas.
+func (g Grant) object() string {
+	switch g.ObjectType {
+	case ObjectSchema:
+		return g.Schema
+	case ObjectFunction:
+		return pgx.Identifier{g.Schema}.Sanitize() + "." + g.Object
+	default:
+		return pgx.Identifier{g.Schema, g.Object}.Sanitize()
+	}
+}
+
+// privilegeList returns privileges formatted for GRANT and REVOKE statements.
+func (g Grant) privilegeList(privileges []string) string {
+	if g.Column == "" {
+		return strings.Join(privileges, ", ")
+	}
+	column := pgx.Identifier{g.Column}.Sanitize()
+	parts := make([]string, len(privileges))
+	for i, p := range privileges {
+		parts[i] = fmt.Sprintf("%s (%s)", p, column)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Privileges returns the privileges granted directly to the grant's role on
+// its object. Privileges the role inherits or holds through PUBLIC are left
+// out, since they cannot be revoked from the role.
+func (c *Client) Privileges(g Grant) ([]string, error) {
+	var (
+		rows pgx.Rows
+		err  error
+	)
+	switch {
+	case g.Column != "":
+		rows, err = c.conn.Query(c.ctx, columnGrantsQuery(), g.Role, g.object(), g.Column)
+	case g.ObjectType == ObjectTable:
+		rows, err = c.conn.Query(c.ctx, tableGrantsQuery(), g.Role, g.Schema, g.Object)
+	default:
+		rows, err = c.conn.Query(c.ctx, directGrantsQuery(string(g.ObjectType)), g.Role, g.object())
+	}
+	if err != nil {
+		c.logger.Error(err, "Failed to query privileges")
+		return nil, err
+	}
+	privileges, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		c.logger.Error(err, "Failed to query privileges")
+		return nil, err
+	}
+	return privileges, nil
+}
+
+// EnsureGrant grants the missing privileges of g and revokes the privileges
+// of applied, the ones granted on the object before, which g no longer
+// lists. Other privileges the role holds on the object, e.g. granted by
+// another PgGrant, are left alone.
+func (c *Client) EnsureGrant(g Grant, applied []string) error {
+	target, ok, err := c.target(g)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%s %s does not exist", g.ObjectType, g.object())
+	}
+	current, err := c.Privileges(g)
+	if err != nil {
+		return err
+	}
+
+	var missing, extra []string
+	for _, p := range g.Privileges {
+		if !slices.Contains(current, p) {
+			missing = append(missing, p)
+		}
+	}
+	for _, p := range current {
+		if slices.Contains(applied, p) && !slices.Contains(g.Privileges, p) {
+			extra = append(extra, p)
+		}
+	}
+
+	role := pgx.Identifier{g.Role}.Sanitize()
+	if len(missing) > 0 {
+		if err := c.exec(grantPrivilegesQuery(g.privilegeList(missing), target, role)); err != nil {
+			c.logger.Error(err, "Failed to grant privileges")
+			return err
+		}
+		c.logger.Info("Successfully granted privileges", "target", target, "privileges", missing)
+	}
+	if len(extra) > 0 {
+		if err := c.exec(revokePrivilegesQuery(g.privilegeList(extra), target, role)); err != nil {
+			c.logger.Error(err, "Failed to revoke privileges")
+			return err
+		}
+		c.logger.Info("Successfully revoked privileges", "target", target, "privileges", extra)
+	}
+
+	return nil
+}
+
+// RevokeGrant revokes all privileges of g. Nothing is revoked from functions
+// which no longer exist.
+func (c *Client) RevokeGrant(g Grant) error {
+	if len(g.Privileges) == 0 {
+		return nil
+	}
+	target, ok, err := c.target(g)
+	if err != nil || !ok {
+		return err
+	}
+	role := pgx.Identifier{g.Role}.Sanitize()
+	if err := c.exec(revokePrivilegesQuery(g.privilegeList(g.Privileges), target, role)); err != nil {
+		c.logger.Error(err, "Failed to revoke privileges")
+		return err
+	}
+	c.logger.Info("Successfully revoked privileges", "target", target, "privileges", g.Privileges)
+
+	return nil
+}
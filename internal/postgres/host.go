@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// HostInfo describes the capabilities of a host and of the admin user the
+// clients connect as.
+type HostInfo struct {
+	// ServerVersionNum is the version of the server, e.g. 150004 for 15.4.
+	ServerVersionNum int32
+	// Superuser tells whether the admin user is a superuser.
+	Superuser bool
+	// Replication and BypassRLS tell whether the admin user has the
+	// REPLICATION and BYPASSRLS attributes.
+	Replication bool
+	BypassRLS   bool
+	// ManagedAdminRole is the role of a managed service granting limited
+	// admin rights the admin user is a member of, i.e. rds_superuser on
+	// Amazon RDS or cloudsqlsuperuser on Cloud SQL. It is empty otherwise.
+	ManagedAdminRole string
+	// MaxConnections is the maximum number of sessions of the server.
+	MaxConnections int32
+	// PasswordEncryption is the algorithm new passwords are encrypted with,
+	// `scram-sha-256` or `md5`.
+	PasswordEncryption string
+	// Extensions are the extensions available for installation.
+	Extensions []string
+}
+
+// SCRAM returns whether new passwords are encrypted with SCRAM-SHA-256.
+func (h HostInfo) SCRAM() bool {
+	return h.PasswordEncryption == "scram-sha-256"
+}
+
+// managedAdminRoles are the roles of managed services granting limited
+// admin rights, by preference.
+var managedAdminRoles = []string{"rds_superuser", "cloudsqlsuperuser"}
+
+// HostInfo returns the capabilities of the host and of the admin user.
+func (c *Client) HostInfo() (HostInfo, error) {
+	var (
+		host         HostInfo
+		managedAdmin *string
+	)
+	err := c.conn.QueryRow(c.ctx, getHostInfoQuery(), managedAdminRoles).Scan(&host.ServerVersionNum, &host.Superuser, &host.Replication, &host.BypassRLS, &managedAdmin, &host.MaxConnections, &host.PasswordEncryption)
+	if err != nil {
+		c.logger.Error(err, "Failed to query the capabilities of the host")
+		return HostInfo{}, err
+	}
+	if managedAdmin != nil {
+		host.ManagedAdminRole = *managedAdmin
+	}
+
+	rows, err := c.conn.Query(c.ctx, getAvailableExtensionsQuery())
+	if err != nil {
+		c.logger.Error(err, "Failed to query from pg_available_extensions")
+		return HostInfo{}, err
+	}
+	host.Extensions, err = pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		c.logger.Error(err, "Failed to query from pg_available_extensions")
+		return HostInfo{}, err
+	}
+	return host, nil
+}
+
+type hostKey struct{}
+
+// WithHost returns a copy of ctx in which the clients created by NewClient
+// rely on host for the capabilities of the host, e.g. to skip workarounds
+// for admin users which are not superusers. Without it, clients assume the
+// least capable admin user.
+func WithHost(ctx context.Context, host HostInfo) context.Context {
+	return context.WithValue(ctx, hostKey{}, host)
+}
+
+// hostFrom returns the host of ctx, or nil.
+func hostFrom(ctx context.Context) *HostInfo {
+	host, ok := ctx.Value(hostKey{}).(HostInfo)
+	if !ok {
+		return nil
+	}
+	return &host
+}
+
+// superuser returns whether the admin user is known to be a superuser.
+func (c *Client) superuser() bool {
+	return c.host != nil && c.host.Superuser
+}
@@ -0,0 +1,79 @@
+package postgres
+
+// DatabaseInfo describes a database found on a host.
+type DatabaseInfo struct {
+	Name string
+	// Owner is the custom resource which owns the database, if any.
+	Owner *Owner
+}
+
+// RoleInfo describes a role found on a host.
+type RoleInfo struct {
+	Name  string
+	Login bool
+	// Owner is the custom resource which owns the role, if any.
+	Owner *Owner
+	// MemberOf lists the roles the role is a member of.
+	MemberOf []string
+}
+
+// ListDatabases returns the databases on the host except templates and the
+// maintenance database.
+func (c *Client) ListDatabases() ([]DatabaseInfo, error) {
+	rows, err := c.conn.Query(c.ctx, listDatabasesQuery())
+	if err != nil {
+		c.logger.Error(err, "Failed to query from pg_database")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var databases []DatabaseInfo
+	for rows.Next() {
+		var (
+			database DatabaseInfo
+			comment  *string
+		)
+		if err := rows.Scan(&database.Name, &comment); err != nil {
+			return nil, err
+		}
+		database.Owner = ownerOf(comment)
+		databases = append(databases, database)
+	}
+	return databases, rows.Err()
+}
+
+// ListRoles returns the roles on the host except superusers, the admin
+// user and the roles predefined by Postgres or cloud providers.
+func (c *Client) ListRoles() ([]RoleInfo, error) {
+	rows, err := c.conn.Query(c.ctx, listRolesQuery(c.user))
+	if err != nil {
+		c.logger.Error(err, "Failed to query from pg_roles")
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []RoleInfo
+	for rows.Next() {
+		var (
+			role    RoleInfo
+			comment *string
+		)
+		if err := rows.Scan(&role.Name, &role.Login, &comment, &role.MemberOf); err != nil {
+			return nil, err
+		}
+		role.Owner = ownerOf(comment)
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// ownerOf returns the owner stamped in comment, or nil.
+func ownerOf(comment *string) *Owner {
+	if comment == nil {
+		return nil
+	}
+	if owner, ok := parseOwner(*comment); ok {
+		return &owner
+	}
+	return nil
+}
@@ -0,0 +1,766 @@
+package postgres_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jeewangue/postgres-indb-operator/internal/postgres"
+	"github.com/jeewangue/postgres-indb-operator/test"
+)
+
+// The integration tests share a server started from the locally installed
+// PostgreSQL binaries and use unique database and role names. They are
+// skipped if no server can be started.
+var (
+	server    *test.Postgres
+	serverErr error
+	startOnce sync.Once
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if server != nil {
+		if err := server.Stop(); err != nil {
+			os.Stderr.WriteString(err.Error() + "\n")
+		}
+	}
+	os.Exit(code)
+}
+
+// integration returns the shared server, skipping t if none can be started.
+func integration(t *testing.T) *test.Postgres {
+	t.Helper()
+	startOnce.Do(func() {
+		server, serverErr = test.StartPostgres()
+	})
+	if errors.Is(serverErr, test.ErrNoPostgres) {
+		t.Skipf("Integration tests skipped: %v", serverErr)
+	}
+	require.NoError(t, serverErr)
+	return server
+}
+
+// adminClient returns a client connected to dbname as the superuser.
+func adminClient(t *testing.T, pg *test.Postgres, dbname string) *postgres.Client {
+	t.Helper()
+	db, err := postgres.NewClient(context.Background(), test.NewLogger(t), pg.ConnectionString(dbname))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// execAs runs sql in dbname as the login role user with the password
+// "secret".
+func execAs(t *testing.T, pg *test.Postgres, user, dbname, sql string) error {
+	t.Helper()
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, pg.UserConnectionString(user, "secret", dbname))
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+
+	_, err = conn.Exec(ctx, sql)
+	return err
+}
+
+func assertPermissionDenied(t *testing.T, err error) {
+	t.Helper()
+	var pgErr *pgconn.PgError
+	if assert.ErrorAs(t, err, &pgErr) {
+		assert.Equal(t, "42501", pgErr.Code, pgErr.Message)
+	}
+}
+
+// integrationDatabase creates a database with its access roles and returns
+// its name.
+func integrationDatabase(t *testing.T, pg *test.Postgres) string {
+	t.Helper()
+	dbname := test.Name("db")
+	require.NoError(t, adminClient(t, pg, "template1").EnsureDatabase(dbname, owner, false))
+	require.NoError(t, adminClient(t, pg, dbname).EnsureDatabaseAccessRoles(dbname))
+	return dbname
+}
+
+// ownerClient returns a client connected to dbname as a login role which
+// assumes the owner role of dbname, like the one SQL scripts run as.
+func ownerClient(t *testing.T, pg *test.Postgres, dbname string) *postgres.Client {
+	t.Helper()
+	admin := adminClient(t, pg, dbname)
+	name := integrationUser(t, admin)
+	require.NoError(t, admin.EnsureOwnerRoleToUser(dbname, name))
+	db, err := postgres.NewClient(context.Background(), test.NewLogger(t), pg.UserConnectionString(name, "secret", dbname))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		db.Close()
+		// the sessions of admin may have been terminated
+		admin, err := postgres.NewClient(context.Background(), test.NewLogger(t), pg.ConnectionString(dbname))
+		require.NoError(t, err)
+		defer admin.Close()
+		assert.NoError(t, admin.DropLoginRole(name, dbname+"_owner"))
+	})
+	return db
+}
+
+// integrationUser creates a login role with the password "secret" and
+// returns its name.
+func integrationUser(t *testing.T, db *postgres.Client) string {
+	t.Helper()
+	name := test.Name("user")
+	require.NoError(t, db.EnsureUser(name, "secret", postgres.Owner{Kind: "PgUser", Namespace: "default", Name: name, UID: "uid-" + name}, false))
+	return name
+}
+
+func TestIntegration_EnsureDatabase(t *testing.T) {
+	pg := integration(t)
+	db := adminClient(t, pg, "template1")
+
+	dbname := test.Name("db")
+	require.NoError(t, db.EnsureDatabase(dbname, owner, false))
+	require.NoError(t, db.EnsureDatabase(dbname, owner, false))
+	exists, err := db.DatabaseExists(dbname)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	size, err := db.DatabaseSize(dbname)
+	require.NoError(t, err)
+	assert.Positive(t, size)
+
+	other := postgres.Owner{Kind: "PgDatabase", Namespace: "other", Name: "app", UID: "uid-2"}
+	assert.ErrorIs(t, db.EnsureDatabase(dbname, other, true), postgres.ErrConflict)
+
+	unmanaged := test.Name("db")
+	require.NoError(t, pgxExec(t, pg, "postgres", "CREATE DATABASE "+unmanaged))
+	assert.ErrorIs(t, db.EnsureDatabase(unmanaged, owner, false), postgres.ErrUnmanaged)
+	assert.NoError(t, db.EnsureDatabase(unmanaged, owner, true))
+
+	databases, err := db.ListDatabases()
+	require.NoError(t, err)
+	var found bool
+	for _, database := range databases {
+		if database.Name == dbname {
+			found = true
+			if assert.NotNil(t, database.Owner) {
+				assert.Equal(t, owner, *database.Owner)
+			}
+		}
+	}
+	assert.True(t, found, "database %s not listed", dbname)
+}
+
+func TestIntegration_DatabaseStats(t *testing.T) {
+	pg := integration(t)
+	dbname := integrationDatabase(t, pg)
+	db := adminClient(t, pg, dbname)
+	require.NoError(t, pgxExec(t, pg, dbname, "SELECT 1"))
+
+	stats, err := db.DatabaseStats(dbname)
+	require.NoError(t, err)
+	assert.Positive(t, stats.Size)
+	// the session of db
+	assert.GreaterOrEqual(t, stats.Backends, int32(1))
+
+	stats, err = db.DatabaseStats(test.Name("missing"))
+	require.NoError(t, err)
+	assert.Zero(t, stats)
+}
+
+func TestIntegration_HostInfo(t *testing.T) {
+	pg := integration(t)
+	db := adminClient(t, pg, "postgres")
+
+	host, err := db.HostInfo()
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, host.ServerVersionNum, int32(100000))
+	assert.True(t, host.Superuser)
+	assert.Empty(t, host.ManagedAdminRole)
+	assert.Positive(t, host.MaxConnections)
+	assert.NotEmpty(t, host.PasswordEncryption)
+	assert.Contains(t, host.Extensions, "plpgsql")
+}
+
+func TestIntegration_EnsureDatabaseFromTemplate(t *testing.T) {
+	pg := integration(t)
+	source := integrationDatabase(t, pg)
+	require.NoError(t, ownerClient(t, pg, source).RunScript("CREATE TABLE items (id int); INSERT INTO items VALUES (1), (2);"))
+
+	// a session connected to the source is terminated
+	ctx := context.Background()
+	session, err := pgx.Connect(ctx, pg.ConnectionString(source))
+	require.NoError(t, err)
+	defer session.Close(ctx)
+
+	dbname := test.Name("db")
+	require.NoError(t, adminClient(t, pg, "template1").EnsureDatabaseFromTemplate(dbname, source, owner, false))
+	var (
+		count      int
+		tableOwner string
+	)
+	require.NoError(t, pgxQueryRow(t, pg, dbname, "SELECT count(*), (SELECT tableowner FROM pg_tables WHERE tablename = 'items') FROM items", &count, &tableOwner))
+	assert.Equal(t, 2, count)
+	assert.Equal(t, source+"_owner", tableOwner)
+	assert.Error(t, session.Ping(ctx))
+}
+
+func TestIntegration_ApplyMigration(t *testing.T) {
+	pg := integration(t)
+	dbname := integrationDatabase(t, pg)
+	db := ownerClient(t, pg, dbname)
+
+	applied, err := db.AppliedMigrations()
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	// a failing migration is not recorded
+	failing := postgres.Migration{Version: "0001_create", Script: "CREATE TABLE items (id int); SELECT 1/0"}
+	assert.Error(t, db.ApplyMigration(failing))
+	applied, err = db.AppliedMigrations()
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	// nor is a migration recording others or ending its transaction
+	forged := postgres.Migration{Version: "0001_create", Script: "INSERT INTO " + postgres.MigrationTable + " (version, checksum) VALUES ('0099_skipped', 'forged')"}
+	assert.ErrorContains(t, db.ApplyMigration(forged), "changed the migration table")
+	committing := postgres.Migration{Version: "0001_create", Script: "COMMIT; CREATE TABLE committed (id int)"}
+	assert.ErrorContains(t, db.ApplyMigration(committing), "ended its transaction")
+	applied, err = db.AppliedMigrations()
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	m := postgres.Migration{Version: "0001_create", Script: "CREATE TABLE items (id int) -- without a semicolon"}
+	require.NoError(t, db.ApplyMigration(m))
+	applied, err = db.AppliedMigrations()
+	require.NoError(t, err)
+	if assert.Len(t, applied, 1) {
+		assert.Equal(t, m.Checksum(), applied[0].Checksum)
+		assert.WithinDuration(t, time.Now(), applied[0].AppliedAt, time.Minute)
+	}
+
+	var tableOwner string
+	require.NoError(t, pgxQueryRow(t, pg, dbname, "SELECT tableowner FROM pg_tables WHERE tablename = '"+postgres.MigrationTable+"'", &tableOwner))
+	assert.Equal(t, dbname+"_owner", tableOwner)
+}
+
+func TestIntegration_RunScript(t *testing.T) {
+	pg := integration(t)
+	dbname := integrationDatabase(t, pg)
+	db := ownerClient(t, pg, dbname)
+
+	// a failing script leaves no trace
+	assert.Error(t, db.RunScript("CREATE TABLE kept (id int); SELECT 1/0;"))
+	var exists bool
+	require.NoError(t, pgxQueryRow(t, pg, dbname, "SELECT to_regclass('kept') IS NOT NULL", &exists))
+	assert.False(t, exists)
+
+	require.NoError(t, db.RunScript("CREATE TABLE kept (id int);"))
+	var tableOwner string
+	require.NoError(t, pgxQueryRow(t, pg, dbname, "SELECT tableowner FROM pg_tables WHERE tablename = 'kept'", &tableOwner))
+	assert.Equal(t, dbname+"_owner", tableOwner)
+
+	// resetting the role does not switch to the admin
+	assert.Error(t, db.RunScript("RESET ROLE; CREATE ROLE "+test.Name("escalated")+";"))
+}
+
+func TestIntegration_RunMaintenance(t *testing.T) {
+	pg := integration(t)
+	dbname := integrationDatabase(t, pg)
+	require.NoError(t, pgxExec(t, pg, dbname, "CREATE TABLE items (id int PRIMARY KEY); CREATE MATERIALIZED VIEW totals AS SELECT count(*) FROM items; CREATE UNIQUE INDEX ON totals (count)"))
+	db := adminClient(t, pg, dbname)
+
+	for _, task := range []postgres.MaintenanceTask{
+		{Operation: postgres.MaintenanceVacuum},
+		{Operation: postgres.MaintenanceAnalyze, Target: "public.items"},
+		{Operation: postgres.MaintenanceReindex, Target: "items_pkey"},
+		{Operation: postgres.MaintenanceReindex, Target: "items", Concurrently: true},
+		{Operation: postgres.MaintenanceReindex, Timeout: time.Minute},
+		{Operation: postgres.MaintenanceRefreshMaterializedView, Target: "totals", Concurrently: true},
+	} {
+		assert.NoError(t, db.RunMaintenance(task), "%+v", task)
+	}
+
+	// a statement waiting for a lock is canceled by the timeout
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, pg.ConnectionString(dbname))
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+	tx, err := conn.Begin(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, "LOCK TABLE items IN ACCESS EXCLUSIVE MODE")
+	require.NoError(t, err)
+
+	err = db.RunMaintenance(postgres.MaintenanceTask{Operation: postgres.MaintenanceVacuum, Target: "items", Timeout: 100 * time.Millisecond})
+	assert.True(t, postgres.IsStatementTimeout(err), "%v", err)
+}
+
+func TestIntegration_EnsureDatabaseAccessRoles(t *testing.T) {
+	pg := integration(t)
+	dbname := integrationDatabase(t, pg)
+	db := adminClient(t, pg, dbname)
+
+	// idempotent
+	require.NoError(t, db.EnsureDatabaseAccessRoles(dbname))
+	diffs, err := db.DatabaseDrift(dbname)
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+
+	// objects created by the admin are covered by the default privileges
+	require.NoError(t, pgxExec(t, pg, dbname, "CREATE TABLE accounts (id int)"))
+	diffs, err = db.DatabaseDrift(dbname)
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+
+	require.NoError(t, pgxExec(t, pg, dbname, "REVOKE CONNECT ON DATABASE "+dbname+" FROM "+dbname+"_readonly"))
+	diffs, err = db.DatabaseDrift(dbname)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"role " + dbname + "_readonly lacks CONNECT on database " + dbname}, diffs)
+
+	require.NoError(t, db.EnsureDatabaseAccessRoles(dbname))
+	diffs, err = db.DatabaseDrift(dbname)
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+}
+
+func TestIntegration_permissions(t *testing.T) {
+	pg := integration(t)
+	tt := []struct {
+		name   string
+		grant  func(db *postgres.Client, dbname, user string) error
+		revoke func(db *postgres.Client, dbname, user string) error
+		// allowed and denied are run in order by the user while granted
+		allowed []string
+		denied  []string
+	}{
+		{
+			name:    "readonly",
+			grant:   (*postgres.Client).EnsureReadonlyRoleToUser,
+			revoke:  (*postgres.Client).RevokeReadonlyRoleFromUser,
+			allowed: []string{"SELECT * FROM accounts"},
+			denied:  []string{"INSERT INTO accounts VALUES (1)", "DELETE FROM accounts"},
+		},
+		{
+			name:    "readwrite",
+			grant:   (*postgres.Client).EnsureReadwriteRoleToUser,
+			revoke:  (*postgres.Client).RevokeReadwriteRoleFromUser,
+			allowed: []string{"SELECT * FROM accounts", "INSERT INTO accounts VALUES (1)", "CREATE TABLE orders (id int)"},
+			denied:  []string{"DROP TABLE accounts"},
+		},
+		{
+			name:    "owner",
+			grant:   (*postgres.Client).EnsureOwnerRoleToUser,
+			revoke:  (*postgres.Client).RevokeOwnerRoleFromUser,
+			allowed: []string{"CREATE TABLE orders (id int)", "INSERT INTO orders VALUES (1)", "DROP TABLE orders"},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			dbname := integrationDatabase(t, pg)
+			db := adminClient(t, pg, dbname)
+			require.NoError(t, pgxExec(t, pg, dbname, "CREATE TABLE accounts (id int)"))
+			user := integrationUser(t, db)
+
+			require.NoError(t, tc.grant(db, dbname, user))
+			for _, sql := range tc.allowed {
+				assert.NoError(t, execAs(t, pg, user, dbname, sql), sql)
+			}
+			for _, sql := range tc.denied {
+				assertPermissionDenied(t, execAs(t, pg, user, dbname, sql))
+			}
+			diffs, err := db.MembershipDrift(dbname+"_"+tc.name, user)
+			require.NoError(t, err)
+			assert.Empty(t, diffs)
+
+			require.NoError(t, tc.revoke(db, dbname, user))
+			require.NoError(t, db.EnsureRoleSettings(user, dbname, nil))
+			assertPermissionDenied(t, execAs(t, pg, user, dbname, "SELECT * FROM accounts"))
+		})
+	}
+}
+
+func TestIntegration_EnsureOwnerRoleToUser(t *testing.T) {
+	pg := integration(t)
+	dbname := integrationDatabase(t, pg)
+	db := adminClient(t, pg, dbname)
+	ownerUser := integrationUser(t, db)
+	reader := integrationUser(t, db)
+	require.NoError(t, db.EnsureOwnerRoleToUser(dbname, ownerUser))
+	require.NoError(t, db.EnsureReadonlyRoleToUser(dbname, reader))
+
+	// tables of the owner are owned by the owner role and readable by the
+	// readonly role
+	require.NoError(t, execAs(t, pg, ownerUser, dbname, "CREATE TABLE accounts (id int)"))
+	var tableOwner string
+	require.NoError(t, pgxQueryRow(t, pg, dbname, "SELECT tableowner FROM pg_tables WHERE tablename = 'accounts'", &tableOwner))
+	assert.Equal(t, dbname+"_owner", tableOwner)
+	assert.NoError(t, execAs(t, pg, reader, dbname, "SELECT * FROM accounts"))
+}
+
+func TestIntegration_EnsureCustomPrivilegesToUser(t *testing.T) {
+	pg := integration(t)
+	dbname := integrationDatabase(t, pg)
+	db := adminClient(t, pg, dbname)
+	require.NoError(t, pgxExec(t, pg, dbname, "CREATE TABLE accounts (id int)"))
+	user := integrationUser(t, db)
+
+	privileges := []string{"SELECT", "INSERT"}
+	require.NoError(t, db.EnsureCustomPrivilegesToUser(dbname, "public", user, privileges))
+	assert.NoError(t, execAs(t, pg, user, dbname, "INSERT INTO accounts VALUES (1)"))
+	assertPermissionDenied(t, execAs(t, pg, user, dbname, "DELETE FROM accounts"))
+	diffs, err := db.CustomPrivilegesDrift(dbname, "public", user, privileges)
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+
+	require.NoError(t, db.RevokeCustomPrivilegesFromUser(dbname, "public", user, privileges))
+	assertPermissionDenied(t, execAs(t, pg, user, dbname, "SELECT * FROM accounts"))
+	diffs, err = db.CustomPrivilegesDrift(dbname, "public", user, privileges)
+	require.NoError(t, err)
+	assert.NotEmpty(t, diffs)
+
+	assert.Error(t, db.EnsureCustomPrivilegesToUser(dbname, "public", user, []string{"EXECUTE"}))
+}
+
+func TestIntegration_EnsureRole(t *testing.T) {
+	pg := integration(t)
+	db := adminClient(t, pg, "postgres")
+
+	role := test.Name("role")
+	require.NoError(t, db.EnsureRole(role))
+	require.NoError(t, db.EnsureRole(role))
+	exists, err := db.RoleExists(role)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	roleOwner := postgres.Owner{Kind: "PgRole", Namespace: "default", Name: "reporting", UID: "uid-5"}
+	assert.ErrorIs(t, db.EnsureOwnedRole(role, roleOwner, false), postgres.ErrUnmanaged)
+	require.NoError(t, db.EnsureOwnedRole(role, roleOwner, true))
+	other := postgres.Owner{Kind: "PgRole", Namespace: "other", Name: "reporting", UID: "uid-6"}
+	assert.ErrorIs(t, db.EnsureOwnedRole(role, other, true), postgres.ErrConflict)
+
+	user := integrationUser(t, db)
+	require.NoError(t, db.EnsureRoleToUser(role, user))
+	diffs, err := db.MembershipDrift(role, user)
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+	require.NoError(t, db.RevokeRoleFromUser(role, user))
+	diffs, err = db.MembershipDrift(role, user)
+	require.NoError(t, err)
+	assert.NotEmpty(t, diffs)
+}
+
+func TestIntegration_EnsureUser(t *testing.T) {
+	pg := integration(t)
+	db := adminClient(t, pg, "postgres")
+	user := integrationUser(t, db)
+	assert.NoError(t, execAs(t, pg, user, "postgres", "SELECT 1"))
+
+	userOwner := postgres.Owner{Kind: "PgUser", Namespace: "default", Name: user, UID: "uid-" + user}
+	require.NoError(t, db.EnsureUser(user, "changed", userOwner, false))
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, pg.UserConnectionString(user, "changed", "postgres"))
+	require.NoError(t, err)
+	conn.Close(ctx)
+
+	other := postgres.Owner{Kind: "PgUser", Namespace: "other", Name: user, UID: "uid-other"}
+	assert.ErrorIs(t, db.EnsureUser(user, "secret", other, true), postgres.ErrConflict)
+
+	roles, err := db.ListRoles()
+	require.NoError(t, err)
+	var found bool
+	for _, role := range roles {
+		if role.Name == user {
+			found = true
+			assert.True(t, role.Login)
+		}
+	}
+	assert.True(t, found, "role %s not listed", user)
+}
+
+func TestIntegration_EnsureRoleAttributes(t *testing.T) {
+	pg := integration(t)
+	db := adminClient(t, pg, "postgres")
+	validUntil := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	tt := []struct {
+		name    string
+		desired postgres.RoleAttributes
+	}{
+		{
+			name:    "defaults",
+			desired: postgres.DefaultRoleAttributes(),
+		},
+		{
+			name: "limited",
+			desired: postgres.RoleAttributes{
+				ConnectionLimit: 5,
+				ValidUntil:      &validUntil,
+				Inherit:         false,
+				CreateDB:        true,
+				Replication:     true,
+				BypassRLS:       true,
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			user := integrationUser(t, db)
+
+			require.NoError(t, db.EnsureRoleAttributes(user, tc.desired))
+			diffs, err := db.RoleDrift(user, tc.desired)
+			require.NoError(t, err)
+			assert.Empty(t, diffs)
+
+			current, err := db.RoleAttributes(user)
+			require.NoError(t, err)
+			assert.Equal(t, tc.desired.ConnectionLimit, current.ConnectionLimit)
+			assert.Equal(t, tc.desired.Inherit, current.Inherit)
+			assert.Equal(t, tc.desired.CreateDB, current.CreateDB)
+			if tc.desired.ValidUntil != nil && assert.NotNil(t, current.ValidUntil) {
+				assert.True(t, tc.desired.ValidUntil.Equal(*current.ValidUntil))
+			}
+
+			require.NoError(t, db.EnsureRoleAttributes(user, postgres.DefaultRoleAttributes()))
+			diffs, err = db.RoleDrift(user, tc.desired)
+			require.NoError(t, err)
+			assert.Equal(t, tc.name != "defaults", len(diffs) > 0, diffs)
+		})
+	}
+}
+
+func TestIntegration_EnsureRoleSettings(t *testing.T) {
+	pg := integration(t)
+	dbname := integrationDatabase(t, pg)
+	db := adminClient(t, pg, dbname)
+	user := integrationUser(t, db)
+	require.NoError(t, db.EnsureReadonlyRoleToUser(dbname, user))
+
+	require.NoError(t, db.EnsureRoleSettings(user, "", map[string]string{"work_mem": "8MB"}))
+	require.NoError(t, db.EnsureRoleSettings(user, dbname, map[string]string{
+		"statement_timeout": "30s",
+		"search_path":       "app,public",
+	}))
+	settings, err := db.RoleSettings(user, dbname)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"statement_timeout": "30s", "search_path": "app, public"}, settings)
+	databases, err := db.RoleSettingDatabases(user)
+	require.NoError(t, err)
+	assert.Equal(t, []string{dbname}, databases)
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, pg.UserConnectionString(user, "secret", dbname))
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+	var timeout, workMem string
+	require.NoError(t, conn.QueryRow(ctx, "SELECT current_setting('statement_timeout'), current_setting('work_mem')").Scan(&timeout, &workMem))
+	assert.Equal(t, "30s", timeout)
+	assert.Equal(t, "8MB", workMem)
+
+	require.NoError(t, db.EnsureRoleSettings(user, dbname, map[string]string{"statement_timeout": "1min"}))
+	settings, err = db.RoleSettings(user, dbname)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"statement_timeout": "1min"}, settings)
+	diffs, err := db.RoleSettingsDrift(user, dbname, map[string]string{"statement_timeout": "1min"})
+	require.NoError(t, err)
+	assert.Empty(t, diffs)
+}
+
+func TestIntegration_EnsureGrant(t *testing.T) {
+	pg := integration(t)
+	dbname := integrationDatabase(t, pg)
+	db := adminClient(t, pg, dbname)
+	for _, sql := range []string{
+		"CREATE SCHEMA reporting",
+		"CREATE TABLE reporting.accounts (id int, email text)",
+		"CREATE SEQUENCE reporting.ids",
+		"CREATE FUNCTION reporting.refresh_stats(n integer) RETURNS integer LANGUAGE sql AS 'SELECT n'",
+		// functions are executable by everyone by default
+		"REVOKE EXECUTE ON FUNCTION reporting.refresh_stats(integer) FROM PUBLIC",
+	} {
+		require.NoError(t, pgxExec(t, pg, dbname, sql), sql)
+	}
+	role := test.Name("role")
+	require.NoError(t, db.EnsureRole(role))
+
+	tt := []struct {
+		name    string
+		grant   postgres.Grant
+		updated []string
+	}{
+		{
+			name:    "schema",
+			grant:   postgres.Grant{ObjectType: postgres.ObjectSchema, Privileges: []string{"USAGE"}},
+			updated: []string{},
+		},
+		{
+			name:    "table",
+			grant:   postgres.Grant{ObjectType: postgres.ObjectTable, Object: "accounts", Privileges: []string{"SELECT", "INSERT"}},
+			updated: []string{"SELECT", "UPDATE"},
+		},
+		{
+			name:    "column",
+			grant:   postgres.Grant{ObjectType: postgres.ObjectTable, Object: "accounts", Column: "email", Privileges: []string{"SELECT"}},
+			updated: []string{"UPDATE"},
+		},
+		{
+			name:    "sequence",
+			grant:   postgres.Grant{ObjectType: postgres.ObjectSequence, Object: "ids", Privileges: []string{"USAGE"}},
+			updated: []string{"SELECT", "UPDATE"},
+		},
+		{
+			name:    "function",
+			grant:   postgres.Grant{ObjectType: postgres.ObjectFunction, Object: "refresh_stats(integer)", Privileges: []string{"EXECUTE"}},
+			updated: []string{},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			grant := tc.grant
+			grant.Role = role
+			grant.Schema = "reporting"
+			require.NoError(t, grant.Validate())
+
+			require.NoError(t, db.EnsureGrant(grant, nil))
+			privileges, err := db.Privileges(grant)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, grant.Privileges, privileges)
+
+			all := append(grant.Privileges, tc.updated...)
+			applied := grant.Privileges
+			grant.Privileges = tc.updated
+			require.NoError(t, db.EnsureGrant(grant, applied))
+			privileges, err = db.Privileges(grant)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.updated, privileges)
+
+			grant.Privileges = all
+			require.NoError(t, db.RevokeGrant(grant))
+			privileges, err = db.Privileges(grant)
+			require.NoError(t, err)
+			assert.Empty(t, privileges)
+		})
+	}
+}
+
+func TestIntegration_Privileges_column(t *testing.T) {
+	pg := integration(t)
+	dbname := integrationDatabase(t, pg)
+	db := adminClient(t, pg, dbname)
+	require.NoError(t, pgxExec(t, pg, dbname, "CREATE TABLE accounts (id int, email text)"))
+	role := test.Name("role")
+	require.NoError(t, db.EnsureRole(role))
+
+	// table privileges are not column privileges, though information_schema
+	// lists them for every column
+	table := postgres.Grant{Role: role, ObjectType: postgres.ObjectTable, Schema: "public", Object: "accounts", Privileges: []string{"SELECT"}}
+	require.NoError(t, db.EnsureGrant(table, nil))
+	column := table
+	column.Column = "email"
+	privileges, err := db.Privileges(column)
+	require.NoError(t, err)
+	assert.Empty(t, privileges)
+}
+
+func TestIntegration_dryRun(t *testing.T) {
+	pg := integration(t)
+	plan := &postgres.Plan{}
+	db, err := postgres.NewClient(postgres.WithPlan(context.Background(), plan), test.NewLogger(t), pg.ConnectionString("postgres"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	user := test.Name("user")
+	userOwner := postgres.Owner{Kind: "PgUser", Namespace: "default", Name: user, UID: "uid-" + user}
+	require.NoError(t, db.EnsureUser(user, "secret", userOwner, false))
+	require.NoError(t, db.EnsureRoleAttributes(user, postgres.RoleAttributes{ConnectionLimit: 5, Inherit: true}))
+
+	exists, err := db.RoleExists(user)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.Contains(t, plan.Statements(), "ALTER ROLE "+user+" PASSWORD '<redacted>'")
+}
+
+func TestIntegration_EnsurePublication(t *testing.T) {
+	pg := integration(t)
+	dbname := integrationDatabase(t, pg)
+	db := adminClient(t, pg, dbname)
+	require.NoError(t, pgxExec(t, pg, dbname, "CREATE TABLE accounts (id int PRIMARY KEY); CREATE TABLE orders (id int PRIMARY KEY)"))
+
+	tables := func(name string) []string {
+		var tables []string
+		require.NoError(t, pgxQueryRow(t, pg, dbname,
+			"SELECT COALESCE(array_agg(tablename::text ORDER BY tablename), '{}') FROM pg_publication_tables WHERE pubname = '"+name+"'", &tables))
+		return tables
+	}
+
+	publication := postgres.Publication{Name: "changes", Tables: []string{"accounts", "public.orders"}}
+	require.NoError(t, db.EnsurePublication(publication))
+	assert.Equal(t, []string{"accounts", "orders"}, tables("changes"))
+
+	publication.Tables = []string{"orders"}
+	publication.Publish = []string{"insert", "update"}
+	require.NoError(t, db.EnsurePublication(publication))
+	assert.Equal(t, []string{"orders"}, tables("changes"))
+	var pubdelete bool
+	require.NoError(t, pgxQueryRow(t, pg, dbname, "SELECT pubdelete FROM pg_publication WHERE pubname = 'changes'", &pubdelete))
+	assert.False(t, pubdelete)
+
+	publication.Tables = nil
+	publication.AllTables = true
+	require.NoError(t, db.EnsurePublication(publication))
+	assert.Equal(t, []string{"accounts", "orders"}, tables("changes"))
+
+	require.NoError(t, db.DropPublication("changes"))
+	require.NoError(t, db.DropPublication("changes"))
+	assert.Empty(t, tables("changes"))
+}
+
+func TestIntegration_EnsureSubscription_dryRun(t *testing.T) {
+	pg := integration(t)
+	dbname := integrationDatabase(t, pg)
+	plan := &postgres.Plan{}
+	db, err := postgres.NewClient(postgres.WithPlan(context.Background(), plan), test.NewLogger(t), pg.ConnectionString(dbname))
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.NoError(t, db.EnsureSubscription(postgres.Subscription{
+		Name:         "changes",
+		ConnInfo:     pg.ConnectionString("source"),
+		Publications: []string{"changes"},
+		SlotName:     "changes",
+		Enabled:      true,
+	}))
+	assert.Equal(t, []string{
+		`CREATE SUBSCRIPTION "changes" CONNECTION '<redacted>' PUBLICATION "changes" WITH (slot_name = 'changes', create_slot = true, enabled = true)`,
+	}, plan.Statements())
+
+	stats, err := db.SubscriptionStats("changes")
+	require.NoError(t, err)
+	assert.False(t, stats.WorkerRunning)
+	slot, err := db.ReplicationSlot("changes")
+	require.NoError(t, err)
+	assert.Nil(t, slot)
+}
+
+// pgxExec runs sql in dbname as the superuser.
+func pgxExec(t *testing.T, pg *test.Postgres, dbname, sql string) error {
+	t.Helper()
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, pg.ConnectionString(dbname))
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+	_, err = conn.Exec(ctx, sql)
+	return err
+}
+
+// pgxQueryRow scans the single row sql returns in dbname into dest.
+func pgxQueryRow(t *testing.T, pg *test.Postgres, dbname, sql string, dest ...any) error {
+	t.Helper()
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, pg.ConnectionString(dbname))
+	require.NoError(t, err)
+	defer conn.Close(ctx)
+	return conn.QueryRow(ctx, sql).Scan(dest...)
+}
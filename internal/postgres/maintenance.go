@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// MaintenanceOperation is the statement of a maintenance task.
+type MaintenanceOperation string
+
+const (
+	MaintenanceVacuum                  MaintenanceOperation = "Vacuum"
+	MaintenanceAnalyze                 MaintenanceOperation = "Analyze"
+	MaintenanceReindex                 MaintenanceOperation = "Reindex"
+	MaintenanceRefreshMaterializedView MaintenanceOperation = "RefreshMaterializedView"
+)
+
+// MaintenanceTask is a maintenance statement run in the current database.
+type MaintenanceTask struct {
+	Operation MaintenanceOperation
+	// Target is the relation, optionally qualified with its schema. Vacuum,
+	// Analyze and Reindex apply to the whole database if it is empty.
+	Target string
+	// Concurrently applies to Reindex and RefreshMaterializedView.
+	Concurrently bool
+	// Timeout cancels the statement if it is positive and the statement runs
+	// longer.
+	Timeout time.Duration
+}
+
+// IsStatementTimeout returns whether err is the cancellation of a statement
+// which ran longer than the statement timeout.
+func IsStatementTimeout(err error) bool {
+	var pgErr *pgconn.PgError
+	// query_canceled
+	return errors.As(err, &pgErr) && pgErr.Code == "57014"
+}
+
+// RunMaintenance runs the statement of task in the current database. It is
+// run on its own rather than in a transaction, since VACUUM and REINDEX
+// DATABASE refuse to run in one.
+func (c *Client) RunMaintenance(task MaintenanceTask) error {
+	sql, err := c.maintenanceQuery(task)
+	if err != nil {
+		c.logger.Error(err, "Failed to prepare maintenance task", "operation", task.Operation, "target", task.Target)
+		return err
+	}
+
+	if task.Timeout > 0 {
+		if err := c.exec(setStatementTimeoutQuery(task.Timeout.Milliseconds())); err != nil {
+			c.logger.Error(err, "Failed to set the statement timeout")
+			return err
+		}
+		defer func() {
+			if err := c.exec(resetStatementTimeoutQuery()); err != nil {
+				c.logger.Error(err, "Failed to reset the statement timeout")
+			}
+		}()
+	}
+
+	if err := c.exec(sql); err != nil {
+		c.logger.Error(err, "Failed to run maintenance task", "operation", task.Operation, "target", task.Target)
+		return err
+	}
+	c.logger.Info("Successfully ran maintenance task", "operation", task.Operation, "target", task.Target)
+	return nil
+}
+
+// maintenanceQuery returns the statement of task.
+func (c *Client) maintenanceQuery(task MaintenanceTask) (string, error) {
+	target := ""
+	if task.Target != "" {
+		target = pgx.Identifier(strings.SplitN(task.Target, ".", 2)).Sanitize()
+	}
+
+	switch task.Operation {
+	case MaintenanceVacuum:
+		return vacuumQuery(target), nil
+	case MaintenanceAnalyze:
+		return analyzeQuery(target), nil
+	case MaintenanceReindex:
+		if target == "" {
+			var dbname string
+			if err := c.conn.QueryRow(c.ctx, getCurrentDatabaseQuery()).Scan(&dbname); err != nil {
+				return "", err
+			}
+			return reindexQuery("DATABASE", pgx.Identifier{dbname}.Sanitize(), task.Concurrently), nil
+		}
+		var relkind string
+		err := c.conn.QueryRow(c.ctx, getRelationKindQuery(), target).Scan(&relkind)
+		switch {
+		case err == pgx.ErrNoRows:
+			return "", fmt.Errorf("relation %s does not exist", task.Target)
+		case err != nil:
+			return "", err
+		}
+		kind := "TABLE"
+		if relkind == "i" || relkind == "I" {
+			kind = "INDEX"
+		}
+		return reindexQuery(kind, target, task.Concurrently), nil
+	case MaintenanceRefreshMaterializedView:
+		if target == "" {
+			return "", fmt.Errorf("no materialized view given to refresh")
+		}
+		return refreshMaterializedViewQuery(target, task.Concurrently), nil
+	}
+	return "", fmt.Errorf("unknown maintenance operation %q", task.Operation)
+}
@@ -0,0 +1,188 @@
+package postgres
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// MigrationTable is the table in the schema public of a database recording
+// the migrations applied to it.
+const MigrationTable = "postgres_indb_migrations"
+
+var (
+	// ErrMigrationChanged is returned if the script of an applied migration
+	// was changed.
+	ErrMigrationChanged = errors.New("applied migration changed")
+	// ErrMigrationOutOfOrder is returned if a migration older than the last
+	// applied one was not applied.
+	ErrMigrationOutOfOrder = errors.New("migration out of order")
+)
+
+// Migration is a versioned SQL script.
+type Migration struct {
+	Version string
+	Script  string
+}
+
+// Checksum returns the SHA-256 checksum of the script of m.
+func (m Migration) Checksum() string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(m.Script)))
+}
+
+// AppliedMigration is a migration recorded in the migration table.
+type AppliedMigration struct {
+	Version   string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// PendingMigrations returns the migrations which are not applied yet,
+// ordered by version. It fails if the checksum of an applied migration
+// changed, or if a migration older than the last applied one is missing.
+// Applied migrations missing from migrations are ignored.
+func PendingMigrations(migrations []Migration, applied []AppliedMigration) ([]Migration, error) {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	checksums := make(map[string]string, len(applied))
+	latest := ""
+	for _, m := range applied {
+		checksums[m.Version] = m.Checksum
+		if m.Version > latest {
+			latest = m.Version
+		}
+	}
+
+	var pending []Migration
+	for _, m := range sorted {
+		checksum, ok := checksums[m.Version]
+		switch {
+		case ok && checksum != m.Checksum():
+			return nil, fmt.Errorf("%w: the checksum of migration %s is %s instead of %s", ErrMigrationChanged, m.Version, m.Checksum(), checksum)
+		case ok:
+			continue
+		case m.Version < latest:
+			return nil, fmt.Errorf("%w: migration %s is older than the applied migration %s", ErrMigrationOutOfOrder, m.Version, latest)
+		}
+		pending = append(pending, m)
+	}
+	return pending, nil
+}
+
+// AppliedMigrations returns the migrations recorded in the migration table
+// of the current database, ordered by version. It returns none if the table
+// does not exist.
+func (c *Client) AppliedMigrations() ([]AppliedMigration, error) {
+	applied, err := c.appliedMigrations()
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.As(err, &pgErr) && pgErr.Code == "42P01":
+		// undefined_table
+		return nil, nil
+	case err != nil:
+		c.logger.Error(err, "Failed to query from the migration table")
+		return nil, err
+	}
+	return applied, nil
+}
+
+func (c *Client) appliedMigrations() ([]AppliedMigration, error) {
+	rows, err := c.conn.Query(c.ctx, getAppliedMigrationsQuery(pgx.Identifier{"public", MigrationTable}.Sanitize()))
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (AppliedMigration, error) {
+		var m AppliedMigration
+		err := row.Scan(&m.Version, &m.Checksum, &m.AppliedAt)
+		return m, err
+	})
+}
+
+// ApplyMigration runs the script of m in the current database as the user of
+// the client and records it in the migration table, which is created if
+// needed, in a single transaction. The script runs as a statement of its own
+// and the client records it with another one once it succeeded, so that the
+// script can neither forge nor skip the record: a script which ends the
+// transaction or changes the migration table fails. The script must not
+// control transactions.
+func (c *Client) ApplyMigration(m Migration) (err error) {
+	table := pgx.Identifier{"public", MigrationTable}.Sanitize()
+	if err := c.exec(createMigrationTableQuery(table)); err != nil {
+		c.logger.Error(err, "Failed to create the migration table")
+		return err
+	}
+
+	if err := c.exec(beginQuery()); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			c.logger.Error(err, "Failed to apply migration", "version", m.Version)
+			if err := c.exec(rollbackQuery()); err != nil {
+				c.logger.Error(err, "Failed to roll back migration", "version", m.Version)
+			}
+		}
+	}()
+
+	var (
+		txid   int64
+		before []AppliedMigration
+	)
+	if !c.dryRun() {
+		if err := c.conn.QueryRow(c.ctx, getTransactionIDQuery()).Scan(&txid); err != nil {
+			return err
+		}
+		if before, err = c.appliedMigrations(); err != nil {
+			return err
+		}
+	}
+
+	if err := c.exec(m.Script); err != nil {
+		return err
+	}
+
+	if !c.dryRun() {
+		var after int64
+		if err := c.conn.QueryRow(c.ctx, getTransactionIDQuery()).Scan(&after); err != nil {
+			return err
+		}
+		if after != txid {
+			return fmt.Errorf("the script of migration %s ended its transaction", m.Version)
+		}
+		applied, err := c.appliedMigrations()
+		if err != nil {
+			return err
+		}
+		if !sameMigrations(before, applied) {
+			return fmt.Errorf("the script of migration %s changed the migration table", m.Version)
+		}
+	}
+
+	if err := c.exec(recordMigrationQuery(table, m.Version, m.Checksum())); err != nil {
+		return err
+	}
+	if err := c.exec(commitQuery()); err != nil {
+		return err
+	}
+	c.logger.Info("Successfully applied migration", "version", m.Version)
+	return nil
+}
+
+// sameMigrations returns whether a and b record the same migrations.
+func sameMigrations(a, b []AppliedMigration) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Version != b[i].Version || a[i].Checksum != b[i].Checksum || !a[i].AppliedAt.Equal(b[i].AppliedAt) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,174 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ownerCommentPrefix starts the comment stamped on databases and roles
+// created by the operator.
+const ownerCommentPrefix = "managed by postgres-indb-operator:"
+
+var (
+	// ErrConflict indicates that a database or role is owned by another
+	// custom resource.
+	ErrConflict = errors.New("owned by another custom resource")
+	// ErrUnmanaged indicates that a database or role was not created by the
+	// operator and is not adopted.
+	ErrUnmanaged = errors.New("not managed by the operator")
+)
+
+// Owner identifies the custom resource which owns a database or role.
+type Owner struct {
+	Kind      string
+	Namespace string
+	Name      string
+	UID       string
+}
+
+// OwnerExists returns whether the custom resource owner still exists.
+type OwnerExists func(owner Owner) (bool, error)
+
+type ownerExistsKey struct{}
+
+// WithOwnerExists returns a copy of ctx in which the clients created by
+// NewClient take over databases and roles stamped with an owner for which
+// exists returns false. Without it, stamps are only taken over from owners of
+// the same kind, namespace and name, i.e. custom resources deleted and
+// recreated, since both cannot exist at once.
+func WithOwnerExists(ctx context.Context, exists OwnerExists) context.Context {
+	return context.WithValue(ctx, ownerExistsKey{}, exists)
+}
+
+// ownerExistsFrom returns the OwnerExists of ctx, or nil.
+func ownerExistsFrom(ctx context.Context) OwnerExists {
+	exists, _ := ctx.Value(ownerExistsKey{}).(OwnerExists)
+	return exists
+}
+
+func (o Owner) String() string {
+	return fmt.Sprintf("%s %s/%s", o.Kind, o.Namespace, o.Name)
+}
+
+func (o Owner) comment() string {
+	return fmt.Sprintf("%s %s %s/%s %s", ownerCommentPrefix, o.Kind, o.Namespace, o.Name, o.UID)
+}
+
+// parseOwner returns the owner stamped in comment.
+func parseOwner(comment string) (Owner, bool) {
+	if !strings.HasPrefix(comment, ownerCommentPrefix) {
+		return Owner{}, false
+	}
+	fields := strings.Fields(strings.TrimPrefix(comment, ownerCommentPrefix))
+	if len(fields) != 3 {
+		return Owner{}, false
+	}
+	namespace, name, ok := strings.Cut(fields[1], "/")
+	if !ok {
+		return Owner{}, false
+	}
+	return Owner{Kind: fields[0], Namespace: namespace, Name: name, UID: fields[2]}, true
+}
+
+// checkOwner returns whether owner may manage object whose comment is
+// comment, and whether the ownership has to be stamped on it. The stamp of
+// an owner which no longer exists is taken over.
+func (c *Client) checkOwner(object string, comment *string, owner Owner, adopt bool) (bool, error) {
+	if comment != nil {
+		if current, ok := parseOwner(*comment); ok {
+			if current.UID == owner.UID {
+				return false, nil
+			}
+			gone, err := c.ownerGone(current, owner)
+			if err != nil {
+				return false, err
+			}
+			if !gone {
+				return false, fmt.Errorf("%s is %w: %s", object, ErrConflict, current)
+			}
+			c.logger.Info("Taking over from an owner which no longer exists", "object", object, "previous", current.String())
+			return true, nil
+		}
+	}
+	if !adopt {
+		return false, fmt.Errorf("%s is %w, set adopt to manage it", object, ErrUnmanaged)
+	}
+	return true, nil
+}
+
+// ownerGone returns whether current, the owner stamped on an object, no
+// longer exists, either because owner replaced it or according to the
+// OwnerExists of the client.
+func (c *Client) ownerGone(current, owner Owner) (bool, error) {
+	if current.Kind == owner.Kind && current.Namespace == owner.Namespace && current.Name == owner.Name {
+		return true, nil
+	}
+	if c.ownerExists == nil {
+		return false, nil
+	}
+	exists, err := c.ownerExists(current)
+	if err != nil {
+		return false, err
+	}
+	return !exists, nil
+}
+
+// roleComment returns whether the role name exists and its comment.
+func (c *Client) roleComment(name string) (bool, *string, error) {
+	var comment *string
+	err := c.conn.QueryRow(c.ctx, getRoleCommentQuery(name)).Scan(&comment)
+	switch {
+	case err == pgx.ErrNoRows:
+		return false, nil, nil
+	case err != nil:
+		c.logger.Error(err, "Failed to query from pg_roles")
+		return false, nil, err
+	}
+	return true, comment, nil
+}
+
+// EnsureOwnedRole creates the NOLOGIN role name owned by owner. An existing
+// role is only managed if owner created it before, or if it was not created
+// by the operator and adopt is set.
+func (c *Client) EnsureOwnedRole(name string, owner Owner, adopt bool) error {
+	exists, comment, err := c.roleComment(name)
+	if err != nil {
+		return err
+	}
+
+	stamp := true
+	if exists {
+		if stamp, err = c.checkOwner("role "+name, comment, owner, adopt); err != nil {
+			return err
+		}
+	} else if err := c.EnsureRole(name); err != nil {
+		return err
+	}
+
+	if stamp {
+		return c.stampRole(name, owner)
+	}
+	return nil
+}
+
+func (c *Client) stampRole(name string, owner Owner) error {
+	if err := c.exec(commentOnRoleQuery(name, owner.comment())); err != nil {
+		c.logger.Error(err, "Failed to stamp the owner of a role")
+		return err
+	}
+	c.logger.Info("Successfully stamped the owner of a role", "role", name, "owner", owner.String())
+	return nil
+}
+
+func (c *Client) stampDatabase(name string, owner Owner) error {
+	if err := c.exec(commentOnDatabaseQuery(name, owner.comment())); err != nil {
+		c.logger.Error(err, "Failed to stamp the owner of a database")
+		return err
+	}
+	c.logger.Info("Successfully stamped the owner of a database", "database", name, "owner", owner.String())
+	return nil
+}
@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"context"
+	"regexp"
+	"sync"
+)
+
+// Planner executes the statements changing the state of a host. Every
+// statement of a Client goes through its planner, so that dry runs can
+// record the statements instead of executing them.
+type Planner interface {
+	Exec(ctx context.Context, sql string) error
+}
+
+// connPlanner executes statements on a connection.
+type connPlanner struct {
+	conn Executor
+}
+
+func (p connPlanner) Exec(ctx context.Context, sql string) error {
+	_, err := p.conn.Exec(ctx, sql)
+	return err
+}
+
+// Plan is a Planner recording statements instead of executing them.
+// Passwords are redacted from the recorded statements.
+type Plan struct {
+	mu         sync.Mutex
+	statements []string
+}
+
+func (p *Plan) Exec(ctx context.Context, sql string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.statements = append(p.statements, redact(sql))
+	return nil
+}
+
+// Statements returns the recorded statements in order. It returns nil for a
+// nil plan.
+func (p *Plan) Statements() []string {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.statements...)
+}
+
+type planKey struct{}
+
+// WithPlan returns a copy of ctx in which the clients created by NewClient
+// record their statements in plan instead of executing them.
+func WithPlan(ctx context.Context, plan *Plan) context.Context {
+	return context.WithValue(ctx, planKey{}, plan)
+}
+
+// planFrom returns the plan of ctx, or nil.
+func planFrom(ctx context.Context) *Plan {
+	plan, _ := ctx.Value(planKey{}).(*Plan)
+	return plan
+}
+
+var (
+	passwordRegexp   = regexp.MustCompile(`(?i)\bPASSWORD\s+'(?:[^']|'')*'`)
+	connectionRegexp = regexp.MustCompile(`(?i)\bCONNECTION\s+'(?:[^']|'')*'`)
+)
+
+// redact replaces the passwords in sql, including the connection strings of
+// subscriptions.
+func redact(sql string) string {
+	sql = passwordRegexp.ReplaceAllString(sql, "PASSWORD '<redacted>'")
+	return connectionRegexp.ReplaceAllString(sql, "CONNECTION '<redacted>'")
+}
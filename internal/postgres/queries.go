@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"fmt"
+	"strings"
 )
 
 func getUserQuery(name string) string {
@@ -19,22 +20,172 @@ func createUserQuery(name string) string {
 		"CONNECTION LIMIT -1", name)
 }
 
+func dropRoleQuery(name string) string {
+	return fmt.Sprintf("DROP ROLE IF EXISTS %s", name)
+}
+
+// reassignOwnedQuery hands the objects a role owns in the current database
+// and shared objects over to another role
+func reassignOwnedQuery(name, owner string) string {
+	return fmt.Sprintf("REASSIGN OWNED BY %s TO %s", name, owner)
+}
+
+// dropOwnedQuery revokes the privileges of a role in the current database and
+// on shared objects, and drops the objects it owns there
+func dropOwnedQuery(name string) string {
+	return fmt.Sprintf("DROP OWNED BY %s", name)
+}
+
 func setPasswordQuery(name, password string) string {
 	return fmt.Sprintf("ALTER ROLE %s PASSWORD '%s'", name, password)
 }
 
 func getDatabaseQuery(name string) string {
-	return fmt.Sprintf("SELECT datname, datacl FROM pg_database WHERE datname = '%s'", name)
+	return fmt.Sprintf("SELECT datname, datacl, shobj_description(oid, 'pg_database') FROM pg_database WHERE datname = '%s'", name)
+}
+
+func getDatabaseSizeQuery(name string) string {
+	return fmt.Sprintf("SELECT pg_database_size(datname) FROM pg_database WHERE datname = '%s'", name)
+}
+
+func getDatabaseStatsQuery() string {
+	return "SELECT pg_database_size(datid), numbackends, xact_commit, xact_rollback FROM pg_stat_database WHERE datname = $1"
+}
+
+// getHostInfoQuery returns the version of the server, whether the admin user
+// is a superuser, whether it has the replication and bypassrls attributes,
+// the first of the roles $1 it is a member of, the maximum number of
+// connections and the password encryption
+func getHostInfoQuery() string {
+	return "SELECT current_setting('server_version_num')::int, r.rolsuper, r.rolreplication, r.rolbypassrls, " +
+		"(SELECT m.rolname FROM unnest($1::text[]) WITH ORDINALITY AS n(rolname, i) JOIN pg_roles m ON m.rolname = n.rolname " +
+		"WHERE pg_has_role(r.oid, m.oid, 'MEMBER') ORDER BY n.i LIMIT 1), " +
+		"current_setting('max_connections')::int, current_setting('password_encryption') " +
+		"FROM pg_roles r WHERE r.rolname = current_user"
+}
+
+func getAvailableExtensionsQuery() string {
+	return "SELECT name FROM pg_available_extensions ORDER BY name"
 }
 
 func createDatabaseQuery(name string) string {
 	return fmt.Sprintf("CREATE DATABASE %s", name)
 }
 
+func createDatabaseFromTemplateQuery(name, template string) string {
+	return fmt.Sprintf("CREATE DATABASE %s TEMPLATE %s", name, template)
+}
+
+// terminateConnectionsQuery terminates the sessions connected to a database
+// other than the current one
+func terminateConnectionsQuery(database string) string {
+	return fmt.Sprintf("SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = '%s' AND pid <> pg_backend_pid()",
+		strings.ReplaceAll(database, "'", "''"))
+}
+
+func getAppliedMigrationsQuery(table string) string {
+	return fmt.Sprintf("SELECT version, checksum, applied_at FROM %s ORDER BY version", table)
+}
+
+func createMigrationTableQuery(table string) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version text PRIMARY KEY, checksum text NOT NULL, applied_at timestamptz NOT NULL DEFAULT now())", table)
+}
+
+// recordMigrationQuery records a migration in the migration table
+func recordMigrationQuery(table, version, checksum string) string {
+	return fmt.Sprintf("INSERT INTO %s (version, checksum) VALUES ('%s', '%s')",
+		table, strings.ReplaceAll(version, "'", "''"), strings.ReplaceAll(checksum, "'", "''"))
+}
+
+func beginQuery() string {
+	return "BEGIN"
+}
+
+func commitQuery() string {
+	return "COMMIT"
+}
+
+func rollbackQuery() string {
+	return "ROLLBACK"
+}
+
+// getTransactionIDQuery returns the ID of the current transaction, assigning
+// one if needed
+func getTransactionIDQuery() string {
+	return "SELECT txid_current()"
+}
+
+func setStatementTimeoutQuery(milliseconds int64) string {
+	return fmt.Sprintf("SET statement_timeout = %d", milliseconds)
+}
+
+func resetStatementTimeoutQuery() string {
+	return "RESET statement_timeout"
+}
+
+func getCurrentDatabaseQuery() string {
+	return "SELECT current_database()"
+}
+
+// getRelationKindQuery returns the relkind of the relation named by $1, and
+// no row if it does not exist
+func getRelationKindQuery() string {
+	return "SELECT relkind::text FROM pg_class WHERE oid = to_regclass($1)"
+}
+
+// vacuumQuery vacuums a table, or the whole database if table is empty
+func vacuumQuery(table string) string {
+	return strings.TrimSpace("VACUUM " + table)
+}
+
+// analyzeQuery analyzes a table, or the whole database if table is empty
+func analyzeQuery(table string) string {
+	return strings.TrimSpace("ANALYZE " + table)
+}
+
+// reindexQuery rebuilds an INDEX, the indexes of a TABLE or of a DATABASE
+func reindexQuery(kind, name string, concurrently bool) string {
+	if concurrently {
+		return fmt.Sprintf("REINDEX %s CONCURRENTLY %s", kind, name)
+	}
+	return fmt.Sprintf("REINDEX %s %s", kind, name)
+}
+
+func refreshMaterializedViewQuery(name string, concurrently bool) string {
+	if concurrently {
+		return fmt.Sprintf("REFRESH MATERIALIZED VIEW CONCURRENTLY %s", name)
+	}
+	return fmt.Sprintf("REFRESH MATERIALIZED VIEW %s", name)
+}
+
 func getRoleQuery(name string) string {
 	return fmt.Sprintf("SELECT rolname, oid FROM pg_roles WHERE rolname = '%s'", name)
 }
 
+func getRoleCommentQuery(name string) string {
+	return fmt.Sprintf("SELECT shobj_description(oid, 'pg_authid') FROM pg_roles WHERE rolname = '%s'", name)
+}
+
+func commentOnRoleQuery(name, comment string) string {
+	return fmt.Sprintf("COMMENT ON ROLE %s IS '%s'", name, strings.ReplaceAll(comment, "'", "''"))
+}
+
+func commentOnDatabaseQuery(name, comment string) string {
+	return fmt.Sprintf("COMMENT ON DATABASE %s IS '%s'", name, strings.ReplaceAll(comment, "'", "''"))
+}
+
+func listDatabasesQuery() string {
+	return "SELECT datname, shobj_description(oid, 'pg_database') FROM pg_database " +
+		"WHERE NOT datistemplate AND datname <> 'postgres' ORDER BY datname"
+}
+
+func listRolesQuery(admin string) string {
+	return fmt.Sprintf("SELECT r.rolname, r.rolcanlogin, shobj_description(r.oid, 'pg_authid'), "+
+		"ARRAY(SELECT b.rolname FROM pg_auth_members m JOIN pg_roles b ON m.roleid = b.oid WHERE m.member = r.oid ORDER BY 1) "+
+		"FROM pg_roles r WHERE NOT r.rolsuper AND r.rolname <> '%s' AND r.rolname !~ '^(pg_|rds|cloudsql)' "+
+		"ORDER BY r.rolname", admin)
+}
+
 func createRoleQuery(name string) string {
 	return fmt.Sprintf("CREATE ROLE  %s", name)
 }
@@ -43,16 +194,14 @@ func grantRoleToUserQuery(role, user string) string {
 	return fmt.Sprintf("GRANT %s TO %s", role, user)
 }
 
-func grantOnTablesQuery(privileges, role string) string {
-	return fmt.Sprintf("GRANT %s ON ALL TABLES IN SCHEMA public TO %s", privileges, role)
-}
-
-func grantReadOnlyOnTablesQuery(role string) string {
-	return grantOnTablesQuery("SELECT", role)
+func grantOnTablesInSchemaQuery(privileges, schema, role string) string {
+	return grantOnAllInSchemaQuery(privileges, "TABLES", schema, role)
 }
 
-func grantReadWriteOnTablesQuery(role string) string {
-	return grantOnTablesQuery("SELECT, INSERT, UPDATE, DELETE", role)
+// grantOnAllInSchemaQuery grant access to all existing objects of a type
+// (e.g., SEQUENCES) in a schema
+func grantOnAllInSchemaQuery(privileges, objects, schema, role string) string {
+	return fmt.Sprintf("GRANT %s ON ALL %s IN SCHEMA %s TO %s", privileges, objects, schema, role)
 }
 
 func grantAllOnDatabase(role, dbName string) string {
@@ -64,15 +213,248 @@ func grantAllOnPublicQuery(role string) string {
 }
 
 func grantUsageOnPublicQuery(role string) string {
-	return fmt.Sprintf("GRANT USAGE ON SCHEMA public TO %s", role)
+	return grantUsageOnSchemaQuery("public", role)
+}
+
+func grantUsageOnSchemaQuery(schema, role string) string {
+	return fmt.Sprintf("GRANT USAGE ON SCHEMA %s TO %s", schema, role)
+}
+
+func alterSchemaOwnerQuery(schema, role string) string {
+	return fmt.Sprintf("ALTER SCHEMA %s OWNER TO %s", schema, role)
+}
+
+// grantFutureInSchemaQuery grant access to future tables in a schema
+func grantFutureInSchemaQuery(privileges, user, schema, role string) string {
+	return grantFutureOnQuery(privileges, user, "TABLES", schema, role)
+}
+
+// grantFutureOnQuery grant access to future objects of a type (e.g.,
+// SEQUENCES) created by user in a schema
+func grantFutureOnQuery(privileges, user, objects, schema, role string) string {
+	return fmt.Sprintf("ALTER DEFAULT PRIVILEGES FOR USER %s IN SCHEMA %s GRANT %s ON %s TO %s", user, schema, privileges, objects, role)
+}
+
+// getCreatorRolesQuery lists the roles which can create objects in a managed
+// database, i.e., its readwrite and owner roles and their members
+func getCreatorRolesQuery(dbName string) string {
+	return fmt.Sprintf("SELECT rolname FROM pg_roles "+
+		"WHERE rolname NOT LIKE 'pg\\_%%' "+
+		"AND (NOT rolsuper OR rolname = current_user) "+
+		"AND (pg_has_role(oid, '%s_readwrite', 'MEMBER') OR pg_has_role(oid, '%s_owner', 'MEMBER'))", dbName, dbName)
 }
 
-// grantFutureQuery grant access to future tables
-func grantFutureQuery(privileges, user, role string) string {
-	return fmt.Sprintf("ALTER DEFAULT PRIVILEGES FOR USER %s IN SCHEMA public GRANT %s ON TABLES TO %s", user, privileges, role)
+// setRoleInDatabaseQuery makes sessions of user in a database assume role
+func setRoleInDatabaseQuery(user, dbName, role string) string {
+	return fmt.Sprintf("ALTER ROLE %s IN DATABASE %s SET role = '%s'", user, dbName, role)
 }
 
 // grantConnectQuery grant connect on database
 func grantConnectQuery(role, dbName string) string {
 	return fmt.Sprintf("GRANT CONNECT ON DATABASE %s TO %s", dbName, role)
 }
+
+func grantPrivilegesQuery(privileges, target, role string) string {
+	return fmt.Sprintf("GRANT %s ON %s TO %s", privileges, target, role)
+}
+
+func revokePrivilegesQuery(privileges, target, role string) string {
+	return fmt.Sprintf("REVOKE %s ON %s FROM %s", privileges, target, role)
+}
+
+// tableGrantsQuery lists the privileges granted to a role on a table
+func tableGrantsQuery() string {
+	return "SELECT privilege_type FROM information_schema.role_table_grants " +
+		"WHERE grantee = $1 AND table_schema = $2 AND table_name = $3"
+}
+
+// columnGrantsQuery lists the privileges granted to a role on a column
+// itself, leaving out the table privileges information_schema expands to
+// every column
+func columnGrantsQuery() string {
+	return "SELECT DISTINCT a.privilege_type FROM pg_attribute o, aclexplode(o.attacl) a " +
+		"WHERE o.attrelid = to_regclass($2) AND o.attname = $3 AND NOT o.attisdropped " +
+		"AND a.grantee = (SELECT oid FROM pg_roles WHERE rolname = $1)"
+}
+
+// resolveFunctionQuery returns the canonical signature of a function, or
+// NULL if it does not exist
+func resolveFunctionQuery() string {
+	return "SELECT to_regprocedure($1)::text"
+}
+
+// directGrantsQuery lists the privileges granted directly to a role on a
+// sequence, function or schema, leaving out the ones it inherits or holds
+// through PUBLIC
+func directGrantsQuery(objectType string) string {
+	var from string
+	switch objectType {
+	case "function":
+		from = "pg_proc o, aclexplode(o.proacl) a WHERE o.oid = to_regprocedure($2)"
+	case "schema":
+		from = "pg_namespace o, aclexplode(o.nspacl) a WHERE o.nspname = $2"
+	default:
+		from = "pg_class o, aclexplode(o.relacl) a WHERE o.oid = to_regclass($2)"
+	}
+	return "SELECT DISTINCT a.privilege_type FROM " + from +
+		" AND a.grantee = (SELECT oid FROM pg_roles WHERE rolname = $1)"
+}
+
+// hasPrivilegeQuery checks a privilege of a role with the has_*_privilege
+// function matching the object type (e.g., has_sequence_privilege)
+func hasPrivilegeQuery(objectType string) string {
+	return fmt.Sprintf("SELECT has_%s_privilege($1, $2, $3)", objectType)
+}
+
+// getSchemaOwnerQuery returns the owner of a schema
+func getSchemaOwnerQuery() string {
+	return "SELECT pg_get_userbyid(nspowner) FROM pg_namespace WHERE nspname = $1"
+}
+
+// getMembershipQuery checks whether a role is directly a member of another
+func getMembershipQuery() string {
+	return "SELECT EXISTS (SELECT 1 FROM pg_auth_members m " +
+		"JOIN pg_roles r ON r.oid = m.roleid " +
+		"JOIN pg_roles u ON u.oid = m.member " +
+		"WHERE r.rolname = $1 AND u.rolname = $2)"
+}
+
+// getObjectsMissingPrivilegeQuery lists the objects of a type (e.g.,
+// SEQUENCES) in a schema a role does not have a privilege on
+func getObjectsMissingPrivilegeQuery(objects string) string {
+	switch objects {
+	case "SEQUENCES":
+		return "SELECT c.relname FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace " +
+			"WHERE n.nspname = $1 AND c.relkind = 'S' AND NOT has_sequence_privilege($2, c.oid, $3) ORDER BY 1"
+	case "FUNCTIONS":
+		return "SELECT p.proname FROM pg_proc p JOIN pg_namespace n ON n.oid = p.pronamespace " +
+			"WHERE n.nspname = $1 AND p.prokind = 'f' AND NOT has_function_privilege($2, p.oid, $3) ORDER BY 1"
+	default:
+		return "SELECT c.relname FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace " +
+			"WHERE n.nspname = $1 AND c.relkind IN ('r', 'p', 'v', 'm', 'f') AND NOT has_table_privilege($2, c.oid, $3) ORDER BY 1"
+	}
+}
+
+func getRoleAttributesQuery(name string) string {
+	return fmt.Sprintf("SELECT rolconnlimit, rolvaliduntil, rolinherit, rolcreatedb, rolreplication, rolbypassrls FROM pg_roles WHERE rolname = '%s'", name)
+}
+
+func alterRoleQuery(name string, options []string) string {
+	return fmt.Sprintf("ALTER ROLE %s WITH %s", name, strings.Join(options, " "))
+}
+
+// getRoleSettingsQuery lists the session defaults of a role in a database,
+// or the database independent ones if the database does not exist
+func getRoleSettingsQuery() string {
+	return "SELECT unnest(s.setconfig) FROM pg_db_role_setting s " +
+		"JOIN pg_roles r ON r.oid = s.setrole " +
+		"WHERE r.rolname = $1 AND s.setdatabase = COALESCE((SELECT oid FROM pg_database WHERE datname = $2), 0)"
+}
+
+// getRoleSettingDatabasesQuery lists the databases a role has session
+// defaults in
+func getRoleSettingDatabasesQuery() string {
+	return "SELECT d.datname FROM pg_db_role_setting s " +
+		"JOIN pg_roles r ON r.oid = s.setrole " +
+		"JOIN pg_database d ON d.oid = s.setdatabase " +
+		"WHERE r.rolname = $1"
+}
+
+func alterRoleSetQuery(role, dbName, name, value string) string {
+	return fmt.Sprintf("ALTER ROLE %s%s SET %s = %s", role, inDatabase(dbName), name, value)
+}
+
+func alterRoleResetQuery(role, dbName, name string) string {
+	return fmt.Sprintf("ALTER ROLE %s%s RESET %s", role, inDatabase(dbName), name)
+}
+
+func inDatabase(dbName string) string {
+	if dbName == "" {
+		return ""
+	}
+	return " IN DATABASE " + dbName
+}
+
+func revokeRoleFromUserQuery(role, user string) string {
+	return fmt.Sprintf("REVOKE %s FROM %s", role, user)
+}
+
+func revokeOnTablesInSchemaQuery(privileges, schema, role string) string {
+	return fmt.Sprintf("REVOKE %s ON ALL TABLES IN SCHEMA %s FROM %s", privileges, schema, role)
+}
+
+// revokeFutureInSchemaQuery revoke access to future tables in a schema
+func revokeFutureInSchemaQuery(privileges, user, schema, role string) string {
+	return fmt.Sprintf("ALTER DEFAULT PRIVILEGES FOR USER %s IN SCHEMA %s REVOKE %s ON TABLES FROM %s", user, schema, privileges, role)
+}
+
+// getPublicationQuery returns whether a publication covers all tables and
+// the operations it publishes
+func getPublicationQuery() string {
+	return "SELECT puballtables, pubinsert, pubupdate, pubdelete, pubtruncate FROM pg_publication WHERE pubname = $1"
+}
+
+// getPublicationTablesQuery lists the tables of a publication as
+// schema-qualified names
+func getPublicationTablesQuery() string {
+	return "SELECT schemaname || '.' || tablename FROM pg_publication_tables WHERE pubname = $1 ORDER BY 1"
+}
+
+// createPublicationQuery creates a publication, for no tables if target is
+// empty
+func createPublicationQuery(name, target, publish string) string {
+	if target != "" {
+		target = " " + target
+	}
+	return fmt.Sprintf("CREATE PUBLICATION %s%s WITH (publish = '%s')", name, target, publish)
+}
+
+func alterPublicationQuery(name, action string) string {
+	return fmt.Sprintf("ALTER PUBLICATION %s %s", name, action)
+}
+
+func dropPublicationQuery(name string) string {
+	return fmt.Sprintf("DROP PUBLICATION IF EXISTS %s", name)
+}
+
+// getSubscriptionQuery returns the state of a subscription of the current
+// database
+func getSubscriptionQuery() string {
+	return "SELECT s.subenabled, s.subconninfo, COALESCE(s.subslotname, ''), s.subpublications FROM pg_subscription s " +
+		"JOIN pg_database d ON d.oid = s.subdbid " +
+		"WHERE s.subname = $1 AND d.datname = current_database()"
+}
+
+// getSubscriptionStatsQuery returns the state of the apply worker of a
+// subscription. Table synchronization workers are ignored
+func getSubscriptionStatsQuery() string {
+	return "SELECT pid IS NOT NULL, received_lsn::text, latest_end_lsn::text, last_msg_receipt_time, latest_end_time " +
+		"FROM pg_stat_subscription WHERE subname = $1 AND relid IS NULL"
+}
+
+// getReplicationSlotQuery returns the state of a replication slot and the
+// amount of write-ahead log its consumer has not yet confirmed
+func getReplicationSlotQuery() string {
+	return "SELECT active, confirmed_flush_lsn::text, " +
+		"pg_wal_lsn_diff(pg_current_wal_lsn(), confirmed_flush_lsn)::bigint " +
+		"FROM pg_replication_slots WHERE slot_name = $1"
+}
+
+func createSubscriptionQuery(name, connInfo, publications, slotName string, createSlot, enabled bool) string {
+	return fmt.Sprintf("CREATE SUBSCRIPTION %s CONNECTION '%s' PUBLICATION %s WITH (slot_name = '%s', create_slot = %t, enabled = %t)",
+		name, strings.ReplaceAll(connInfo, "'", "''"), publications, slotName, createSlot, enabled)
+}
+
+// createReplicationSlotQuery creates a logical replication slot for the
+// pgoutput plugin of the built-in logical replication
+func createReplicationSlotQuery(slotName string) string {
+	return fmt.Sprintf("SELECT pg_create_logical_replication_slot('%s', 'pgoutput')", strings.ReplaceAll(slotName, "'", "''"))
+}
+
+func alterSubscriptionQuery(name, action string) string {
+	return fmt.Sprintf("ALTER SUBSCRIPTION %s %s", name, action)
+}
+
+func dropSubscriptionQuery(name string) string {
+	return fmt.Sprintf("DROP SUBSCRIPTION IF EXISTS %s", name)
+}
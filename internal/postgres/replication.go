@@ -0,0 +1,464 @@
+package postgres
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"k8s.io/utils/strings/slices"
+)
+
+// publishOperations are the operations a publication can publish, in the
+// order of the columns of pg_publication.
+var publishOperations = []string{"insert", "update", "delete", "truncate"}
+
+// Publication describes a publication for logical replication.
+type Publication struct {
+	Name string
+	// AllTables publishes every table of the database.
+	AllTables bool
+	// Tables are the published tables. Names without a schema are in the
+	// schema public.
+	Tables []string
+	// Publish lists the published operations, all of them if empty.
+	Publish []string
+}
+
+// EnsurePublication creates the publication p or alters it to match p. A
+// publication changed from or to all tables is recreated.
+func (c *Client) EnsurePublication(p Publication) error {
+	if p.AllTables && len(p.Tables) > 0 {
+		return fmt.Errorf("publication %s cannot list tables and publish all tables", p.Name)
+	}
+	publish := p.Publish
+	if len(publish) == 0 {
+		publish = publishOperations
+	}
+	for _, op := range publish {
+		if !slices.Contains(publishOperations, op) {
+			return fmt.Errorf("unknown publish operation %q", op)
+		}
+	}
+	name := pgx.Identifier{p.Name}.Sanitize()
+
+	var (
+		allTables bool
+		ops       = make([]bool, len(publishOperations))
+	)
+	err := c.conn.QueryRow(c.ctx, getPublicationQuery(), p.Name).Scan(&allTables, &ops[0], &ops[1], &ops[2], &ops[3])
+	switch {
+	case err == pgx.ErrNoRows:
+		return c.createPublication(p, publish)
+	case err != nil:
+		c.logger.Error(err, "Failed to query from pg_publication")
+		return err
+	}
+
+	if allTables != p.AllTables {
+		c.logger.Info("Recreating publication to change whether it publishes all tables", "publication", p.Name)
+		if err := c.exec(dropPublicationQuery(name)); err != nil {
+			c.logger.Error(err, "Failed to drop publication", "publication", p.Name)
+			return err
+		}
+		return c.createPublication(p, publish)
+	}
+
+	if !p.AllTables {
+		current, err := c.publicationTables(p.Name)
+		if err != nil {
+			return err
+		}
+		desired := qualifiedTables(p.Tables)
+		if added := difference(desired, current); len(added) > 0 {
+			if err := c.exec(alterPublicationQuery(name, "ADD TABLE "+tableList(added))); err != nil {
+				c.logger.Error(err, "Failed to add tables to publication", "publication", p.Name)
+				return err
+			}
+		}
+		if dropped := difference(current, desired); len(dropped) > 0 {
+			if err := c.exec(alterPublicationQuery(name, "DROP TABLE "+tableList(dropped))); err != nil {
+				c.logger.Error(err, "Failed to drop tables from publication", "publication", p.Name)
+				return err
+			}
+		}
+	}
+
+	var published []string
+	for i, op := range publishOperations {
+		if ops[i] {
+			published = append(published, op)
+		}
+	}
+	if publishList(published) != publishList(publish) {
+		if err := c.exec(alterPublicationQuery(name, fmt.Sprintf("SET (publish = '%s')", publishList(publish)))); err != nil {
+			c.logger.Error(err, "Failed to set published operations", "publication", p.Name)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) createPublication(p Publication, publish []string) error {
+	target := "FOR ALL TABLES"
+	if !p.AllTables {
+		target = ""
+		if len(p.Tables) > 0 {
+			target = "FOR TABLE " + tableList(qualifiedTables(p.Tables))
+		}
+	}
+	sql := createPublicationQuery(pgx.Identifier{p.Name}.Sanitize(), target, publishList(publish))
+	if err := c.exec(sql); err != nil {
+		c.logger.Error(err, "Failed to create publication", "publication", p.Name)
+		return err
+	}
+	c.logger.Info("Successfully created publication", "publication", p.Name)
+	return nil
+}
+
+// publicationTables returns the schema-qualified tables of a publication.
+func (c *Client) publicationTables(name string) ([]string, error) {
+	rows, err := c.conn.Query(c.ctx, getPublicationTablesQuery(), name)
+	if err != nil {
+		c.logger.Error(err, "Failed to query from pg_publication_tables")
+		return nil, err
+	}
+	tables, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		c.logger.Error(err, "Failed to query from pg_publication_tables")
+		return nil, err
+	}
+	return tables, nil
+}
+
+// DropPublication drops the publication name if it exists.
+func (c *Client) DropPublication(name string) error {
+	if err := c.exec(dropPublicationQuery(pgx.Identifier{name}.Sanitize())); err != nil {
+		c.logger.Error(err, "Failed to drop publication", "publication", name)
+		return err
+	}
+	return nil
+}
+
+// Subscription describes a subscription for logical replication.
+type Subscription struct {
+	Name string
+	// ConnInfo is the connection string of the publishing database.
+	ConnInfo     string
+	Publications []string
+	// SlotName is the replication slot on the publishing host.
+	SlotName string
+	// SlotCreated tells that the slot was created on the publishing host
+	// beforehand, so that the subscription does not create it. A
+	// subscription on the publishing host itself waits forever for the
+	// slot it creates.
+	SlotCreated bool
+	Enabled     bool
+}
+
+// EnsureSubscription creates the subscription s or alters it to match s.
+// Changing the slot of a subscription disables it while the slot is
+// changed. The new slot must exist on the publishing host.
+func (c *Client) EnsureSubscription(s Subscription) error {
+	name := pgx.Identifier{s.Name}.Sanitize()
+	publications := identifierList(s.Publications)
+
+	var (
+		enabled         bool
+		connInfo        string
+		slotName        string
+		curPublications []string
+	)
+	err := c.conn.QueryRow(c.ctx, getSubscriptionQuery(), s.Name).Scan(&enabled, &connInfo, &slotName, &curPublications)
+	switch {
+	case err == pgx.ErrNoRows:
+		if err := c.exec(createSubscriptionQuery(name, s.ConnInfo, publications, s.SlotName, !s.SlotCreated, s.Enabled)); err != nil {
+			c.logger.Error(err, "Failed to create subscription", "subscription", s.Name)
+			return err
+		}
+		c.logger.Info("Successfully created subscription", "subscription", s.Name)
+		return nil
+	case err != nil:
+		c.logger.Error(err, "Failed to query from pg_subscription")
+		return err
+	}
+
+	var actions []string
+	if connInfo != s.ConnInfo {
+		actions = append(actions, fmt.Sprintf("CONNECTION '%s'", strings.ReplaceAll(s.ConnInfo, "'", "''")))
+	}
+	if slotName != s.SlotName {
+		if enabled {
+			actions = append(actions, "DISABLE")
+			enabled = false
+		}
+		actions = append(actions, fmt.Sprintf("SET (slot_name = '%s')", s.SlotName))
+	}
+	if len(difference(s.Publications, curPublications)) > 0 || len(difference(curPublications, s.Publications)) > 0 {
+		// tables can only be refreshed from an enabled subscription
+		action := "SET PUBLICATION " + publications
+		if !enabled {
+			action += " WITH (refresh = false)"
+		}
+		actions = append(actions, action)
+	}
+	if enabled != s.Enabled {
+		if s.Enabled {
+			actions = append(actions, "ENABLE")
+		} else {
+			actions = append(actions, "DISABLE")
+		}
+	}
+
+	for _, action := range actions {
+		if err := c.exec(alterSubscriptionQuery(name, action)); err != nil {
+			c.logger.Error(err, "Failed to alter subscription", "subscription", s.Name)
+			return err
+		}
+	}
+	return nil
+}
+
+// DropSubscription drops the subscription name if it exists, together with
+// its replication slot on the publishing host.
+func (c *Client) DropSubscription(name string) error {
+	if err := c.exec(dropSubscriptionQuery(pgx.Identifier{name}.Sanitize())); err != nil {
+		c.logger.Error(err, "Failed to drop subscription", "subscription", name)
+		return err
+	}
+	return nil
+}
+
+// PublishedTables returns the schema-qualified tables of the publications.
+func (c *Client) PublishedTables(publications []string) ([]string, error) {
+	var tables []string
+	for _, name := range publications {
+		published, err := c.publicationTables(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, table := range published {
+			if !slices.Contains(tables, table) {
+				tables = append(tables, table)
+			}
+		}
+	}
+	sort.Strings(tables)
+	return tables, nil
+}
+
+// EnsureReplicationGrants grants the login role name of a subscription
+// CONNECT on the database dbname of the client, and USAGE on the schemas and
+// SELECT on the schema-qualified tables, so that the subscription can copy
+// their initial data. SELECT is revoked from the tables of applied, the
+// ones granted before, which are no longer listed and still exist.
+func (c *Client) EnsureReplicationGrants(name, dbname string, tables, applied []string) error {
+	if err := c.exec(grantConnectQuery(name, pgx.Identifier{dbname}.Sanitize())); err != nil {
+		c.logger.Error(err, "Failed to grant connect to the replication user")
+		return err
+	}
+
+	var schemas []string
+	for _, table := range tables {
+		g := replicationGrant(name, table)
+		if err := c.EnsureGrant(g, nil); err != nil {
+			return err
+		}
+		if !slices.Contains(schemas, g.Schema) {
+			schemas = append(schemas, g.Schema)
+		}
+	}
+	for _, schema := range schemas {
+		g := Grant{Role: name, ObjectType: ObjectSchema, Schema: schema, Privileges: []string{"USAGE"}}
+		if err := c.EnsureGrant(g, nil); err != nil {
+			return err
+		}
+	}
+
+	for _, table := range applied {
+		if slices.Contains(tables, table) {
+			continue
+		}
+		g := replicationGrant(name, table)
+		var relkind string
+		err := c.conn.QueryRow(c.ctx, getRelationKindQuery(), g.object()).Scan(&relkind)
+		switch {
+		case err == pgx.ErrNoRows:
+			continue
+		case err != nil:
+			c.logger.Error(err, "Failed to query from pg_class")
+			return err
+		}
+		if err := c.RevokeGrant(g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replicationGrant returns the SELECT privilege of the login role name on
+// the schema-qualified table.
+func replicationGrant(name, table string) Grant {
+	schema, object, _ := strings.Cut(table, ".")
+	return Grant{Role: name, ObjectType: ObjectTable, Schema: schema, Object: object, Privileges: []string{"SELECT"}}
+}
+
+// DropReplicationUser revokes the privileges of the login role name of a
+// subscription in the database of the client and on the database itself,
+// and drops the role.
+func (c *Client) DropReplicationUser(name string) error {
+	exists, err := c.RoleExists(name)
+	if err != nil || !exists {
+		return err
+	}
+	if err := c.exec(dropOwnedQuery(name)); err != nil {
+		c.logger.Error(err, "Failed to drop the privileges of the replication user")
+		return err
+	}
+	return c.DropUser(name)
+}
+
+// SubscriptionStats is the state of the apply worker of a subscription.
+type SubscriptionStats struct {
+	WorkerRunning          bool
+	ReceivedLSN            string
+	LatestEndLSN           string
+	LastMessageReceiptTime *time.Time
+	LatestEndTime          *time.Time
+}
+
+// SubscriptionStats returns the state of the subscription name from
+// pg_stat_subscription. It returns zero stats if the subscription does not
+// exist.
+func (c *Client) SubscriptionStats(name string) (SubscriptionStats, error) {
+	var (
+		stats       SubscriptionStats
+		received    *string
+		latestEnd   *string
+		lastMsg     *time.Time
+		latestEndAt *time.Time
+		isRunning   bool
+	)
+	err := c.conn.QueryRow(c.ctx, getSubscriptionStatsQuery(), name).Scan(&isRunning, &received, &latestEnd, &lastMsg, &latestEndAt)
+	switch {
+	case err == pgx.ErrNoRows:
+		return stats, nil
+	case err != nil:
+		c.logger.Error(err, "Failed to query from pg_stat_subscription")
+		return stats, err
+	}
+
+	stats.WorkerRunning = isRunning
+	if received != nil {
+		stats.ReceivedLSN = *received
+	}
+	if latestEnd != nil {
+		stats.LatestEndLSN = *latestEnd
+	}
+	stats.LastMessageReceiptTime = lastMsg
+	stats.LatestEndTime = latestEndAt
+	return stats, nil
+}
+
+// EnsureReplicationSlot creates the logical replication slot name for the
+// pgoutput plugin in the current database if it does not exist.
+func (c *Client) EnsureReplicationSlot(name string) error {
+	slot, err := c.ReplicationSlot(name)
+	if err != nil || slot != nil {
+		return err
+	}
+	if err := c.exec(createReplicationSlotQuery(name)); err != nil {
+		c.logger.Error(err, "Failed to create replication slot", "slot", name)
+		return err
+	}
+	c.logger.Info("Successfully created replication slot", "slot", name)
+	return nil
+}
+
+// ReplicationSlot is the state of a replication slot on a publishing host.
+type ReplicationSlot struct {
+	Active            bool
+	ConfirmedFlushLSN string
+	// LagBytes is the amount of write-ahead log written since the
+	// confirmed flush location, or nil if nothing was confirmed yet.
+	LagBytes *int64
+}
+
+// ReplicationSlot returns the state of the replication slot name. It
+// returns nil if the slot does not exist.
+func (c *Client) ReplicationSlot(name string) (*ReplicationSlot, error) {
+	var (
+		slot      ReplicationSlot
+		confirmed *string
+	)
+	err := c.conn.QueryRow(c.ctx, getReplicationSlotQuery(), name).Scan(&slot.Active, &confirmed, &slot.LagBytes)
+	switch {
+	case err == pgx.ErrNoRows:
+		return nil, nil
+	case err != nil:
+		c.logger.Error(err, "Failed to query from pg_replication_slots")
+		return nil, err
+	}
+	if confirmed != nil {
+		slot.ConfirmedFlushLSN = *confirmed
+	}
+	return &slot, nil
+}
+
+// qualifiedTables returns tables qualified with the schema public if they
+// have no schema, sorted.
+func qualifiedTables(tables []string) []string {
+	qualified := make([]string, len(tables))
+	for i, table := range tables {
+		if !strings.Contains(table, ".") {
+			table = "public." + table
+		}
+		qualified[i] = table
+	}
+	sort.Strings(qualified)
+	return qualified
+}
+
+// tableList returns schema-qualified tables formatted for publication
+// statements.
+func tableList(tables []string) string {
+	quoted := make([]string, len(tables))
+	for i, table := range tables {
+		schema, name, _ := strings.Cut(table, ".")
+		quoted[i] = pgx.Identifier{schema, name}.Sanitize()
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func identifierList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = pgx.Identifier{name}.Sanitize()
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// publishList returns operations in the order of publishOperations,
+// formatted for the publish parameter.
+func publishList(operations []string) string {
+	var ordered []string
+	for _, op := range publishOperations {
+		if slices.Contains(operations, op) {
+			ordered = append(ordered, op)
+		}
+	}
+	return strings.Join(ordered, ", ")
+}
+
+// difference returns the items of a that are not in b.
+func difference(a, b []string) []string {
+	var diff []string
+	for _, item := range a {
+		if !slices.Contains(b, item) {
+			diff = append(diff, item)
+		}
+	}
+	return diff
+}
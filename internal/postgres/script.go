@@ -0,0 +1,16 @@
+package postgres
+
+// RunScript runs the SQL script in the current database as the user of the
+// client. Scripts of tenants run over a client connected as a login role
+// which assumes the owner role of the database, so that the objects they
+// create are owned by it and they cannot switch to the admin. A script
+// without transaction control statements runs in a single transaction and
+// leaves no trace if one of its statements fails.
+func (c *Client) RunScript(script string) error {
+	if err := c.exec(script); err != nil {
+		c.logger.Error(err, "Failed to run script", "user", c.user)
+		return err
+	}
+	c.logger.Info("Successfully ran script", "user", c.user)
+	return nil
+}
@@ -0,0 +1,117 @@
+package postgres
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var parameterNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.]*$`)
+
+// ValidateRoleSettings returns an error if a parameter name of settings is
+// not a valid configuration parameter name.
+func ValidateRoleSettings(settings map[string]string) error {
+	for name := range settings {
+		if !parameterNameRegexp.MatchString(name) {
+			return fmt.Errorf("invalid parameter name %q", name)
+		}
+	}
+	return nil
+}
+
+// RoleSettings returns the session defaults of role in dbname. If dbname is
+// empty, the database independent defaults are returned.
+func (c *Client) RoleSettings(role, dbname string) (map[string]string, error) {
+	rows, err := c.conn.Query(c.ctx, getRoleSettingsQuery(), role, dbname)
+	if err != nil {
+		c.logger.Error(err, "Failed to query from pg_db_role_setting")
+		return nil, err
+	}
+	configs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		c.logger.Error(err, "Failed to query from pg_db_role_setting")
+		return nil, err
+	}
+
+	settings := make(map[string]string, len(configs))
+	for _, config := range configs {
+		name, value, _ := strings.Cut(config, "=")
+		settings[name] = value
+	}
+	return settings, nil
+}
+
+// RoleSettingDatabases returns the databases role has session defaults in.
+func (c *Client) RoleSettingDatabases(role string) ([]string, error) {
+	rows, err := c.conn.Query(c.ctx, getRoleSettingDatabasesQuery(), role)
+	if err != nil {
+		c.logger.Error(err, "Failed to query from pg_db_role_setting")
+		return nil, err
+	}
+	databases, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		c.logger.Error(err, "Failed to query from pg_db_role_setting")
+		return nil, err
+	}
+	return databases, nil
+}
+
+// EnsureRoleSettings sets the session defaults of role in dbname to desired
+// and resets the ones not in desired. If dbname is empty, the database
+// independent defaults are managed.
+func (c *Client) EnsureRoleSettings(role, dbname string, desired map[string]string) error {
+	if err := ValidateRoleSettings(desired); err != nil {
+		return err
+	}
+
+	current, err := c.RoleSettings(role, dbname)
+	if err != nil {
+		return err
+	}
+
+	for name, value := range desired {
+		value = settingValue(value)
+		if current[name] == value {
+			continue
+		}
+		if err := c.exec(alterRoleSetQuery(role, dbname, name, quoteSettingValue(value))); err != nil {
+			c.logger.Error(err, "Failed to set session default", "parameter", name, "database", dbname)
+			return err
+		}
+		c.logger.Info("Successfully set session default", "parameter", name, "database", dbname)
+	}
+
+	for name := range current {
+		if _, ok := desired[name]; ok {
+			continue
+		}
+		if err := c.exec(alterRoleResetQuery(role, dbname, name)); err != nil {
+			c.logger.Error(err, "Failed to reset session default", "parameter", name, "database", dbname)
+			return err
+		}
+		c.logger.Info("Successfully reset session default", "parameter", name, "database", dbname)
+	}
+
+	return nil
+}
+
+// settingValue normalizes value to the form stored in pg_db_role_setting.
+func settingValue(value string) string {
+	items := strings.Split(value, ",")
+	for i, item := range items {
+		items[i] = strings.TrimSpace(item)
+	}
+	return strings.Join(items, ", ")
+}
+
+// quoteSettingValue quotes every item of a comma-separated value as a
+// literal, so that list parameters like search_path keep their items.
+func quoteSettingValue(value string) string {
+	items := strings.Split(value, ", ")
+	for i, item := range items {
+		items[i] = "'" + strings.ReplaceAll(item, "'", "''") + "'"
+	}
+	return strings.Join(items, ", ")
+}
@@ -19,6 +19,8 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -30,11 +32,14 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	postgresv1alpha1 "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
 	"github.com/jeewangue/postgres-indb-operator/controllers"
+	"github.com/jeewangue/postgres-indb-operator/internal/importer"
+	"github.com/jeewangue/postgres-indb-operator/webhooks"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -51,14 +56,53 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		runImport(os.Args[2:])
+		return
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var databaseResyncPeriod time.Duration
+	var userResyncPeriod time.Duration
+	var allowedRoleAttributes string
+	var approverGroups string
+	var quotaResyncPeriod time.Duration
+	var subscriptionResyncPeriod time.Duration
+	var backupImage string
+	var backupUploadImage string
+	var dryRun bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.DurationVar(&databaseResyncPeriod, "database-resync-period", 10*time.Minute,
+		"The interval at which databases are reconciled again to repair or report drift, to grant "+
+			"privileges on objects created outside of the default privileges and to measure their size and "+
+			"activity. Set to 0 to disable.")
+	flag.DurationVar(&userResyncPeriod, "user-resync-period", 10*time.Minute,
+		"The interval at which users are reconciled again to repair or report drift of their roles, "+
+			"memberships and privileges. Set to 0 to disable.")
+	flag.StringVar(&allowedRoleAttributes, "allowed-role-attributes", "",
+		"Comma-separated list of privileged role attributes (createdb, replication, bypassrls) "+
+			"which PgUsers may enable.")
+	flag.StringVar(&approverGroups, "approver-groups", "",
+		"Comma-separated list of groups whose members may create PgAccessApprovals.")
+	flag.DurationVar(&quotaResyncPeriod, "quota-resync-period", 10*time.Minute,
+		"The interval at which PgQuotas are reconciled again to measure the size of the databases. "+
+			"Set to 0 to disable.")
+	flag.DurationVar(&subscriptionResyncPeriod, "subscription-resync-period", time.Minute,
+		"The interval at which PgSubscriptions are reconciled again to report the state and lag of the "+
+			"replication. Set to 0 to disable.")
+	flag.StringVar(&backupImage, "backup-image", controllers.DefaultBackupImage,
+		"The image with pg_dump and pg_restore the backup and restore jobs run. Its major version must not be older than the one of the hosts.")
+	flag.StringVar(&backupUploadImage, "backup-upload-image", controllers.DefaultBackupUploadImage,
+		"The image with the AWS CLI the backup jobs upload to and delete from S3-compatible storages with.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Record the statements the controllers would execute in the status of the objects "+
+			"instead of executing them. Objects can also be annotated with "+postgresv1alpha1.AnnotationDryRun+"=true.")
 	opts := zap.Options{
 		Development: true,
 		TimeEncoder: zapcore.ISO8601TimeEncoder,
@@ -93,15 +137,21 @@ func main() {
 	}
 
 	if err = (&controllers.PgDatabaseReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:       mgr.GetClient(),
+		Scheme:       mgr.GetScheme(),
+		ResyncPeriod: databaseResyncPeriod,
+		DryRun:       dryRun,
+		Images:       controllers.BackupImages{Postgres: backupImage, Upload: backupUploadImage},
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PgDatabase")
 		os.Exit(1)
 	}
 	if err = (&controllers.PgUserReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:                mgr.GetClient(),
+		Scheme:                mgr.GetScheme(),
+		AllowedRoleAttributes: splitList(allowedRoleAttributes),
+		ResyncPeriod:          userResyncPeriod,
+		DryRun:                dryRun,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "PgUser")
 		os.Exit(1)
@@ -113,6 +163,90 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "PgHostCredential")
 		os.Exit(1)
 	}
+	if err = (&controllers.ClusterPgHostCredentialReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ClusterPgHostCredential")
+		os.Exit(1)
+	}
+	if err = (&controllers.PgRoleReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		DryRun: dryRun,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PgRole")
+		os.Exit(1)
+	}
+	if err = (&controllers.PgGrantReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		DryRun: dryRun,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PgGrant")
+		os.Exit(1)
+	}
+	if err = (&controllers.PgQuotaReconciler{
+		Client:       mgr.GetClient(),
+		Scheme:       mgr.GetScheme(),
+		ResyncPeriod: quotaResyncPeriod,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PgQuota")
+		os.Exit(1)
+	}
+	if err = (&controllers.PgPublicationReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		DryRun: dryRun,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PgPublication")
+		os.Exit(1)
+	}
+	if err = (&controllers.PgSubscriptionReconciler{
+		Client:       mgr.GetClient(),
+		Scheme:       mgr.GetScheme(),
+		ResyncPeriod: subscriptionResyncPeriod,
+		DryRun:       dryRun,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PgSubscription")
+		os.Exit(1)
+	}
+	if err = (&controllers.PgBackupReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		Images: controllers.BackupImages{Postgres: backupImage, Upload: backupUploadImage},
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PgBackup")
+		os.Exit(1)
+	}
+	if err = (&controllers.PgBackupScheduleReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PgBackupSchedule")
+		os.Exit(1)
+	}
+	if err = (&controllers.PgMigrationReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		DryRun: dryRun,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PgMigration")
+		os.Exit(1)
+	}
+	if err = (&controllers.PgMaintenanceReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+		DryRun: dryRun,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "PgMaintenance")
+		os.Exit(1)
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		webhooks.SetupWithManager(mgr, webhooks.Options{
+			ApproverGroups: splitList(approverGroups),
+		})
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -130,3 +264,44 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// runImport writes manifests of custom resources for the unmanaged databases
+// and roles of a host to stdout.
+func runImport(args []string) {
+	var opts importer.Options
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	fs.StringVar(&opts.HostCredential, "host-credential", "", "The name of the (Cluster)PgHostCredential to connect with.")
+	fs.StringVar(&opts.Namespace, "namespace", "default", "The namespace of the host credential and of the emitted resources.")
+	fs.StringVar(&opts.PasswordKey, "password-key", "password", "The key of the Secrets referenced for the passwords of users.")
+	zapOpts := zap.Options{Development: true}
+	zapOpts.BindFlags(fs)
+	_ = fs.Parse(args)
+
+	logger := zap.New(zap.UseFlagOptions(&zapOpts), zap.WriteTo(os.Stderr))
+	ctrl.SetLogger(logger)
+	if opts.HostCredential == "" {
+		setupLog.Error(nil, "--host-credential is required")
+		os.Exit(1)
+	}
+
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		os.Exit(1)
+	}
+	if err := importer.Import(ctrl.SetupSignalHandler(), c, logger, opts, os.Stdout); err != nil {
+		setupLog.Error(err, "unable to import")
+		os.Exit(1)
+	}
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
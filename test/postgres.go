@@ -0,0 +1,259 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// PostgresBinDirEnv names the environment variable with the directory
+	// of the initdb and pg_ctl binaries. They are looked up in PATH and in
+	// the usual installation directories if it is empty.
+	PostgresBinDirEnv = "POSTGRESQL_CONTROLLER_BIN_DIR"
+
+	// PostgresUser and PostgresPassword are the credentials of the superuser
+	// of the servers started by StartPostgres.
+	PostgresUser     = "admin"
+	PostgresPassword = "admin-password"
+)
+
+// ErrNoPostgres is returned by StartPostgres if no PostgreSQL binaries are
+// installed or if they cannot be run.
+var ErrNoPostgres = errors.New("no PostgreSQL server can be started")
+
+// postgresBinDirs are the directories searched for the binaries after PATH.
+var postgresBinDirs = []string{
+	"/usr/lib/postgresql/*/bin",
+	"/usr/pgsql-*/bin",
+	"/usr/local/pgsql/bin",
+	"/usr/local/opt/postgresql*/bin",
+	"/opt/homebrew/opt/postgresql*/bin",
+}
+
+// Postgres is a throwaway PostgreSQL server listening on 127.0.0.1 whose
+// data lives in a temporary directory.
+type Postgres struct {
+	// Host is the address of the server as a PgHostCredential expects it,
+	// i.e., `127.0.0.1:<port>`.
+	Host string
+
+	dir   string
+	pgCtl string
+}
+
+// NewPostgres starts a server for the test t and stops it once t and its
+// subtests completed. t is skipped if no server can be started.
+func NewPostgres(t *testing.T) *Postgres {
+	t.Helper()
+	pg, err := StartPostgres()
+	if errors.Is(err, ErrNoPostgres) {
+		t.Skipf("Integration tests skipped: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("Failed to start PostgreSQL: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := pg.Stop(); err != nil {
+			t.Errorf("Failed to stop PostgreSQL: %v", err)
+		}
+	})
+	return pg
+}
+
+// StartPostgres initializes a database cluster in a temporary directory and
+// starts a server on a random port with the superuser PostgresUser. Call
+// Stop to stop the server and remove the directory.
+func StartPostgres() (*Postgres, error) {
+	binDir, err := postgresBinDir()
+	if err != nil {
+		return nil, err
+	}
+	if os.Geteuid() == 0 {
+		return nil, fmt.Errorf("%w: initdb cannot be run as root", ErrNoPostgres)
+	}
+
+	dir, err := os.MkdirTemp("", "postgres-indb-operator-")
+	if err != nil {
+		return nil, err
+	}
+	pg := &Postgres{dir: dir, pgCtl: filepath.Join(binDir, "pg_ctl")}
+
+	pwfile := filepath.Join(dir, "pwfile")
+	if err := os.WriteFile(pwfile, []byte(PostgresPassword), 0600); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	err = run(filepath.Join(binDir, "initdb"),
+		"--pgdata", pg.dataDir(),
+		"--username", PostgresUser,
+		"--pwfile", pwfile,
+		"--auth-host", "md5",
+		"--auth-local", "trust",
+		"--encoding", "UTF8",
+		"--no-sync",
+	)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	// another process may take the port between choosing and binding it
+	for attempt := 0; ; attempt++ {
+		port, err := freePort()
+		if err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+		err = pg.start(port)
+		if err == nil {
+			break
+		}
+		if attempt == 2 {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+
+	if err := pg.waitReady(30 * time.Second); err != nil {
+		pg.Stop()
+		return nil, err
+	}
+	return pg, nil
+}
+
+// ConnectionString returns the connection string of the superuser to
+// database.
+func (p *Postgres) ConnectionString(database string) string {
+	return p.UserConnectionString(PostgresUser, PostgresPassword, database)
+}
+
+// UserConnectionString returns the connection string of user with password
+// to database, e.g., to check the privileges granted to user.
+func (p *Postgres) UserConnectionString(user, password, database string) string {
+	return fmt.Sprintf("postgresql://%s:%s@%s/%s?sslmode=disable", user, url.QueryEscape(password), p.Host, database)
+}
+
+// Stop stops the server immediately and removes its data.
+func (p *Postgres) Stop() error {
+	err := run(p.pgCtl, "stop", "--pgdata", p.dataDir(), "--mode", "immediate", "--wait")
+	if rmErr := os.RemoveAll(p.dir); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+func (p *Postgres) dataDir() string {
+	return filepath.Join(p.dir, "data")
+}
+
+// start starts the server on port, listening on TCP on 127.0.0.1 and on a
+// Unix socket in the temporary directory only.
+func (p *Postgres) start(port int) error {
+	options := fmt.Sprintf("-p %d -c listen_addresses=127.0.0.1 -k %s -c fsync=off -c full_page_writes=off", port, p.dir)
+	err := run(p.pgCtl, "start",
+		"--pgdata", p.dataDir(),
+		"--log", filepath.Join(p.dir, "postgres.log"),
+		"--options", options,
+		"--wait",
+	)
+	if err != nil {
+		log, _ := os.ReadFile(filepath.Join(p.dir, "postgres.log"))
+		return fmt.Errorf("%w\n%s", err, log)
+	}
+	p.Host = net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	return nil
+}
+
+// waitReady waits until the superuser can connect over TCP.
+func (p *Postgres) waitReady(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for {
+		conn, err := pgx.Connect(ctx, p.ConnectionString("postgres"))
+		if err == nil {
+			return conn.Close(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("server on %s is not ready: %w", p.Host, err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// postgresBinDir returns the directory of the initdb and pg_ctl binaries.
+func postgresBinDir() (string, error) {
+	if dir := os.Getenv(PostgresBinDirEnv); dir != "" {
+		if !hasPostgresBinaries(dir) {
+			return "", fmt.Errorf("%w: no initdb and pg_ctl in %s=%s", ErrNoPostgres, PostgresBinDirEnv, dir)
+		}
+		return dir, nil
+	}
+
+	if initdb, err := exec.LookPath("initdb"); err == nil && hasPostgresBinaries(filepath.Dir(initdb)) {
+		return filepath.Dir(initdb), nil
+	}
+	for _, pattern := range postgresBinDirs {
+		dirs, _ := filepath.Glob(pattern)
+		// prefer the latest version
+		sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+		for _, dir := range dirs {
+			if hasPostgresBinaries(dir) {
+				return dir, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: initdb and pg_ctl not found; install PostgreSQL or set %s", ErrNoPostgres, PostgresBinDirEnv)
+}
+
+func hasPostgresBinaries(dir string) bool {
+	for _, name := range []string{"initdb", "pg_ctl"} {
+		if info, err := os.Stat(filepath.Join(dir, name)); err != nil || info.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+// freePort returns a TCP port on 127.0.0.1 nothing listens on.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+func run(name string, args ...string) error {
+	var output bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w\n%s", filepath.Base(name), err, output.String())
+	}
+	return nil
+}
+
+var nameCounter int64
+
+// Name returns a name starting with prefix that is unique within the test
+// binary, for databases and roles on a server shared by tests.
+func Name(prefix string) string {
+	return fmt.Sprintf("%s_%d", prefix, atomic.AddInt64(&nameCounter, 1))
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"net/http"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	apiutil "github.com/jeewangue/postgres-indb-operator/api/v1alpha1/util"
+)
+
+//+kubebuilder:webhook:path=/validate-postgres-jeewangue-com-v1alpha1-hostcredential,mutating=false,failurePolicy=fail,sideEffects=None,groups=postgres.jeewangue.com,resources=pgdatabases;pggrants;pgquotas;pgroles;pgusers,verbs=create;update,versions=v1alpha1,name=vhostcredential.kb.io,admissionReviewVersions=v1
+
+// HostCredentialValidator rejects PgDatabases, PgGrants, PgQuotas, PgRoles and
+// PgUsers which reference a ClusterPgHostCredential not allowing their
+// namespace, PgGrants through their PgDatabase, and PgUsers with attributes
+// the admin user of a host cannot grant. References to databases or host
+// credentials which do not exist yet or whose capabilities were not checked
+// yet are admitted.
+type HostCredentialValidator struct {
+	Client client.Client
+
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &HostCredentialValidator{}
+
+// InjectDecoder injects the decoder.
+func (v *HostCredentialValidator) InjectDecoder(decoder *admission.Decoder) error {
+	v.decoder = decoder
+	return nil
+}
+
+// Handle validates the host credentials referenced by the object.
+func (v *HostCredentialValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var (
+		names []string
+		attrs *api.RoleAttributes
+	)
+	switch req.Kind.Kind {
+	case "PgDatabase":
+		database := &api.PgDatabase{}
+		if err := v.decoder.Decode(req, database); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		names = append(names, database.Spec.HostCredential)
+	case "PgGrant":
+		grant := &api.PgGrant{}
+		if err := v.decoder.Decode(req, grant); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		database, err := apiutil.PgDatabaseByName(v.Client, req.Namespace, grant.Spec.Database)
+		if err == nil {
+			names = append(names, database.Spec.HostCredential)
+		}
+	case "PgQuota":
+		quota := &api.PgQuota{}
+		if err := v.decoder.Decode(req, quota); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		names = append(names, quota.Spec.HostCredential)
+	case "PgRole":
+		role := &api.PgRole{}
+		if err := v.decoder.Decode(req, role); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		names = append(names, role.Spec.HostCredential)
+	case "PgUser":
+		user := &api.PgUser{}
+		if err := v.decoder.Decode(req, user); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if user.Spec.AccessSpecs != nil {
+			for _, accessSpec := range *user.Spec.AccessSpecs {
+				names = append(names, accessSpec.HostCredential)
+			}
+		}
+		attrs = &user.Spec.Attributes
+	default:
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("unexpected kind %s", req.Kind.Kind))
+	}
+
+	for _, name := range names {
+		hostCred, err := apiutil.PgHostCredentialByName(v.Client, req.Namespace, name)
+		if goerrors.Is(err, apiutil.ErrNamespaceNotAllowed) {
+			return admission.Denied(err.Error())
+		}
+		if err != nil || attrs == nil {
+			continue
+		}
+		if err := checkAttributes(hostCred, *attrs); err != nil {
+			return admission.Denied(err.Error())
+		}
+	}
+
+	return admission.Allowed("")
+}
+
+// checkAttributes returns an error if the admin user of hostCred cannot
+// grant attrs. An admin user which is not a superuser, like the limited
+// admin users of managed services as Amazon RDS, can only grant the
+// REPLICATION and BYPASSRLS attributes it has itself, and only from
+// PostgreSQL 16 on.
+func checkAttributes(hostCred *api.PgHostCredential, attrs api.RoleAttributes) error {
+	host := hostCred.Status.Host
+	if host == nil || host.Superuser {
+		return nil
+	}
+	if attrs.Replication {
+		if err := checkAttribute(hostCred, "replication", host.Replication); err != nil {
+			return err
+		}
+	}
+	if attrs.BypassRLS {
+		if err := checkAttribute(hostCred, "bypassrls", host.BypassRLS); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkAttribute returns an error if the admin user of hostCred, which is
+// not a superuser, cannot grant the attribute name. held tells whether it
+// has the attribute itself.
+func checkAttribute(hostCred *api.PgHostCredential, name string, held bool) error {
+	if !held {
+		return fmt.Errorf("the admin user of host credential %s does not have the %s attribute and cannot grant it", hostCred.Name, name)
+	}
+	if hostCred.Status.Host.ServerVersionNum < 160000 {
+		return fmt.Errorf("the admin user of host credential %s is not a superuser and cannot grant the %s attribute before PostgreSQL 16", hostCred.Name, name)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/strings/slices"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	apiutil "github.com/jeewangue/postgres-indb-operator/api/v1alpha1/util"
+)
+
+//+kubebuilder:webhook:path=/mutate-postgres-jeewangue-com-v1alpha1-pgaccessapproval,mutating=true,failurePolicy=fail,sideEffects=None,groups=postgres.jeewangue.com,resources=pgaccessapprovals,verbs=create;update,versions=v1alpha1,name=mpgaccessapproval.kb.io,admissionReviewVersions=v1
+
+// PgAccessApprovalDefaulter signs PgAccessApprovals with the user who
+// created them and binds them to the approved access specification.
+type PgAccessApprovalDefaulter struct {
+	Client client.Client
+
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &PgAccessApprovalDefaulter{}
+
+// InjectDecoder injects the decoder.
+func (d *PgAccessApprovalDefaulter) InjectDecoder(decoder *admission.Decoder) error {
+	d.decoder = decoder
+	return nil
+}
+
+// Handle sets ApprovedBy, ApprovedAt and AccessHash on creation and keeps the
+// previous ones on update. AccessHash is left empty if the PgUser has no
+// matching access specification, which the validator denies.
+func (d *PgAccessApprovalDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	approval := &api.PgAccessApproval{}
+	if err := d.decoder.Decode(req, approval); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	switch req.Operation {
+	case admissionv1.Create:
+		now := metav1.Now()
+		approval.Spec.ApprovedBy = req.UserInfo.Username
+		approval.Spec.ApprovedAt = &now
+		approval.Spec.AccessHash = ""
+
+		user := &api.PgUser{}
+		err := d.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: approval.Spec.User}, user)
+		switch {
+		case errors.IsNotFound(err):
+		case err != nil:
+			return admission.Errored(http.StatusInternalServerError, err)
+		case user.Spec.AccessSpecs != nil:
+			for _, accessSpec := range *user.Spec.AccessSpecs {
+				if apiutil.MatchesAccessSpec(approval, user.Name, accessSpec) {
+					approval.Spec.AccessHash = apiutil.AccessSpecHash(accessSpec)
+					break
+				}
+			}
+		}
+	case admissionv1.Update:
+		old := &api.PgAccessApproval{}
+		if err := d.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		approval.Spec.ApprovedBy = old.Spec.ApprovedBy
+		approval.Spec.ApprovedAt = old.Spec.ApprovedAt
+		approval.Spec.AccessHash = old.Spec.AccessHash
+	}
+
+	marshaled, err := json.Marshal(approval)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+//+kubebuilder:webhook:path=/validate-postgres-jeewangue-com-v1alpha1-pgaccessapproval,mutating=false,failurePolicy=fail,sideEffects=None,groups=postgres.jeewangue.com,resources=pgaccessapprovals,verbs=create;update,versions=v1alpha1,name=vpgaccessapproval.kb.io,admissionReviewVersions=v1
+
+// PgAccessApprovalValidator only admits PgAccessApprovals created by a member
+// of one of ApproverGroups who did not request the approved access
+// themselves. Approvals cannot be changed once created.
+type PgAccessApprovalValidator struct {
+	Client         client.Client
+	ApproverGroups []string
+
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &PgAccessApprovalValidator{}
+
+// InjectDecoder injects the decoder.
+func (v *PgAccessApprovalValidator) InjectDecoder(decoder *admission.Decoder) error {
+	v.decoder = decoder
+	return nil
+}
+
+// Handle validates the approver on creation and the immutability of the
+// specification on update.
+func (v *PgAccessApprovalValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	approval := &api.PgAccessApproval{}
+	if err := v.decoder.Decode(req, approval); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if req.Operation == admissionv1.Update {
+		old := &api.PgAccessApproval{}
+		if err := v.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if !equality.Semantic.DeepEqual(old.Spec, approval.Spec) {
+			return admission.Denied("the spec of a PgAccessApproval is immutable")
+		}
+		return admission.Allowed("")
+	}
+
+	if !isApprover(req.UserInfo.Groups, v.ApproverGroups) {
+		return admission.Denied(fmt.Sprintf("user %s is not in any of the approver groups %v", req.UserInfo.Username, v.ApproverGroups))
+	}
+
+	user := &api.PgUser{}
+	err := v.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: approval.Spec.User}, user)
+	switch {
+	case errors.IsNotFound(err):
+		return admission.Denied(fmt.Sprintf("PgUser %s not found", approval.Spec.User))
+	case err != nil:
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if requestedBy := user.Annotations[api.AnnotationRequestedBy]; requestedBy == req.UserInfo.Username {
+		return admission.Denied(fmt.Sprintf("user %s cannot approve access they requested", requestedBy))
+	}
+	if approval.Spec.AccessHash == "" {
+		return admission.Denied(fmt.Sprintf("PgUser %s has no access specification matching the approval", approval.Spec.User))
+	}
+
+	return admission.Allowed("")
+}
+
+// isApprover returns whether one of groups is an approver group.
+func isApprover(groups, approverGroups []string) bool {
+	for _, group := range groups {
+		if slices.Contains(approverGroups, group) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"k8s.io/utils/strings/slices"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+	apiutil "github.com/jeewangue/postgres-indb-operator/api/v1alpha1/util"
+)
+
+//+kubebuilder:webhook:path=/validate-postgres-jeewangue-com-v1alpha1-pgquota,mutating=false,failurePolicy=fail,sideEffects=None,groups=postgres.jeewangue.com,resources=pgdatabases;pgusers,verbs=create;update,versions=v1alpha1,name=vpgquota.kb.io,admissionReviewVersions=v1
+
+// PgQuotaValidator rejects PgDatabases and PgUsers which make the usage of
+// their namespace exceed a PgQuota. Changes which do not increase the usage
+// are admitted even if it exceeds the limits, so that the usage can be
+// reduced after a limit was lowered.
+type PgQuotaValidator struct {
+	Client client.Client
+
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &PgQuotaValidator{}
+
+// InjectDecoder injects the decoder.
+func (v *PgQuotaValidator) InjectDecoder(decoder *admission.Decoder) error {
+	v.decoder = decoder
+	return nil
+}
+
+// Handle compares the usage of every PgQuota in the namespace with and
+// without the object.
+func (v *PgQuotaValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	quotas, err := apiutil.PgQuotas(v.Client, req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if len(quotas) == 0 {
+		return admission.Allowed("")
+	}
+
+	databases, err := apiutil.PgDatabases(v.Client, req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	users, err := apiutil.PgUsers(v.Client, req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	roles, err := apiutil.PgRoles(v.Client, req.Namespace)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	// newDatabase is the host credential of a PgDatabase created on it
+	var newDatabase string
+	afterDatabases, afterUsers := databases, users
+	switch req.Kind.Kind {
+	case "PgDatabase":
+		database := &api.PgDatabase{}
+		if err := v.decoder.Decode(req, database); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if database.DeletionTimestamp != nil {
+			return admission.Allowed("")
+		}
+		var existing *api.PgDatabase
+		databases, existing = withoutDatabase(databases, database.Name)
+		if existing == nil || existing.Spec.HostCredential != database.Spec.HostCredential {
+			newDatabase = database.Spec.HostCredential
+		}
+		afterDatabases = append(append([]api.PgDatabase{}, databases...), *database)
+	case "PgUser":
+		user := &api.PgUser{}
+		if err := v.decoder.Decode(req, user); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if user.DeletionTimestamp != nil {
+			return admission.Allowed("")
+		}
+		users = withoutUser(users, user.Name)
+		afterUsers = append(append([]api.PgUser{}, users...), *user)
+	default:
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("unexpected kind %s", req.Kind.Kind))
+	}
+
+	for i := range quotas {
+		quota := &quotas[i]
+		before, beforeUnlimited := apiutil.QuotaUsage(quota.Spec.HostCredential, databases, users, roles)
+		after, afterUnlimited := apiutil.QuotaUsage(quota.Spec.HostCredential, afterDatabases, afterUsers, roles)
+
+		var exceeded []string
+		spec := quota.Spec
+		if spec.MaxDatabases != nil && after.Databases > *spec.MaxDatabases && after.Databases > before.Databases {
+			exceeded = append(exceeded, fmt.Sprintf("%d databases exceed the limit of %d", after.Databases, *spec.MaxDatabases))
+		}
+		if spec.MaxUsers != nil && after.Users > *spec.MaxUsers && after.Users > before.Users {
+			exceeded = append(exceeded, fmt.Sprintf("%d users exceed the limit of %d", after.Users, *spec.MaxUsers))
+		}
+		if spec.MaxConnections != nil && after.Connections > *spec.MaxConnections && after.Connections > before.Connections {
+			exceeded = append(exceeded, fmt.Sprintf("%d connections exceed the limit of %d", after.Connections, *spec.MaxConnections))
+		}
+		if spec.MaxConnections != nil {
+			for _, name := range afterUnlimited {
+				if !slices.Contains(beforeUnlimited, name) {
+					exceeded = append(exceeded, fmt.Sprintf("user %s needs a connection limit", name))
+				}
+			}
+		}
+		if spec.MaxSize != nil && newDatabase == spec.HostCredential && quota.Status.Used.Size != nil && quota.Status.Used.Size.Cmp(*spec.MaxSize) >= 0 {
+			exceeded = append(exceeded, fmt.Sprintf("size %s reached the limit of %s", quota.Status.Used.Size, spec.MaxSize))
+		}
+
+		if len(exceeded) > 0 {
+			return admission.Denied(fmt.Sprintf("PgQuota %s: %s", quota.Name, strings.Join(exceeded, ", ")))
+		}
+	}
+
+	return admission.Allowed("")
+}
+
+// withoutDatabase returns databases without the one named name, which is
+// also returned if found.
+func withoutDatabase(databases []api.PgDatabase, name string) ([]api.PgDatabase, *api.PgDatabase) {
+	for i := range databases {
+		if databases[i].Name == name {
+			existing := databases[i]
+			return append(append([]api.PgDatabase{}, databases[:i]...), databases[i+1:]...), &existing
+		}
+	}
+	return databases, nil
+}
+
+// withoutUser returns users without the one named name.
+func withoutUser(users []api.PgUser, name string) []api.PgUser {
+	for i := range users {
+		if users[i].Name == name {
+			return append(append([]api.PgUser{}, users[:i]...), users[i+1:]...)
+		}
+	}
+	return users
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	api "github.com/jeewangue/postgres-indb-operator/api/v1alpha1"
+)
+
+//+kubebuilder:webhook:path=/mutate-postgres-jeewangue-com-v1alpha1-pguser,mutating=true,failurePolicy=fail,sideEffects=None,groups=postgres.jeewangue.com,resources=pgusers,verbs=create;update,versions=v1alpha1,name=mpguser.kb.io,admissionReviewVersions=v1
+
+// PgUserDefaulter records the user who changed the specification of a PgUser
+// in the requested-by annotation.
+type PgUserDefaulter struct {
+	decoder *admission.Decoder
+}
+
+var _ admission.DecoderInjector = &PgUserDefaulter{}
+
+// InjectDecoder injects the decoder.
+func (d *PgUserDefaulter) InjectDecoder(decoder *admission.Decoder) error {
+	d.decoder = decoder
+	return nil
+}
+
+// Handle sets the requested-by annotation to the requesting user if the
+// specification changed and keeps the previous one otherwise.
+func (d *PgUserDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	user := &api.PgUser{}
+	if err := d.decoder.Decode(req, user); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	requestedBy := req.UserInfo.Username
+	if req.Operation == admissionv1.Update {
+		old := &api.PgUser{}
+		if err := d.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if equality.Semantic.DeepEqual(old.Spec, user.Spec) {
+			requestedBy = old.Annotations[api.AnnotationRequestedBy]
+		}
+	}
+
+	if requestedBy == "" {
+		delete(user.Annotations, api.AnnotationRequestedBy)
+	} else {
+		if user.Annotations == nil {
+			user.Annotations = make(map[string]string)
+		}
+		user.Annotations[api.AnnotationRequestedBy] = requestedBy
+	}
+
+	marshaled, err := json.Marshal(user)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhooks contains the admission webhooks of the operator which need
+// the admission request, e.g. the user sending it, or the cluster state.
+package webhooks
+
+import (
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// Options configures the admission webhooks.
+type Options struct {
+	// ApproverGroups lists the groups whose members may create
+	// PgAccessApprovals.
+	ApproverGroups []string
+}
+
+// SetupWithManager registers the admission webhooks with the webhook server
+// of the Manager.
+func SetupWithManager(mgr ctrl.Manager, opts Options) {
+	server := mgr.GetWebhookServer()
+	server.Register("/mutate-postgres-jeewangue-com-v1alpha1-pguser",
+		&webhook.Admission{Handler: &PgUserDefaulter{}})
+	server.Register("/mutate-postgres-jeewangue-com-v1alpha1-pgaccessapproval",
+		&webhook.Admission{Handler: &PgAccessApprovalDefaulter{Client: mgr.GetClient()}})
+	server.Register("/validate-postgres-jeewangue-com-v1alpha1-pgaccessapproval",
+		&webhook.Admission{Handler: &PgAccessApprovalValidator{
+			Client:         mgr.GetClient(),
+			ApproverGroups: opts.ApproverGroups,
+		}})
+	server.Register("/validate-postgres-jeewangue-com-v1alpha1-hostcredential",
+		&webhook.Admission{Handler: &HostCredentialValidator{Client: mgr.GetClient()}})
+	server.Register("/validate-postgres-jeewangue-com-v1alpha1-pgquota",
+		&webhook.Admission{Handler: &PgQuotaValidator{Client: mgr.GetClient()}})
+}